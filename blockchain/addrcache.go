@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"container/list"
+	"sync"
+)
+
+// addressCacheSize 地址缓存的最大条目数
+const addressCacheSize = 1024
+
+// addressCacheEntry 地址缓存条目
+type addressCacheEntry struct {
+	address    string
+	pubKeyHash []byte
+}
+
+// addressCache 地址到公钥哈希的 LRU 缓存
+type addressCache struct {
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+	mutex    sync.Mutex
+}
+
+// newAddressCache 创建地址缓存
+func newAddressCache(capacity int) *addressCache {
+	if capacity <= 0 {
+		capacity = addressCacheSize
+	}
+
+	return &addressCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get 查找缓存的公钥哈希
+func (c *addressCache) get(address string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.items[address]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return elem.Value.(*addressCacheEntry).pubKeyHash, true
+}
+
+// add 添加缓存条目，超出容量时淘汰最久未使用的条目
+func (c *addressCache) add(address string, pubKeyHash []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.items[address]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[address] = c.order.PushFront(&addressCacheEntry{address, pubKeyHash})
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*addressCacheEntry).address)
+	}
+}
+
+// len 返回缓存条目数
+func (c *addressCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
+
+var pubKeyHashCache = newAddressCache(addressCacheSize)
+
+// AddressToPubKeyHash 将地址解码为公钥哈希，结果会被缓存
+// 返回的切片在调用方之间共享，不应被修改
+func AddressToPubKeyHash(address string) []byte {
+	if pubKeyHash, ok := pubKeyHashCache.get(address); ok {
+		return pubKeyHash
+	}
+
+	pubKeyHash := decodeAddressPubKeyHash(address)
+	pubKeyHashCache.add(address, pubKeyHash)
+
+	return pubKeyHash
+}
+
+// decodeAddressPubKeyHash 不经缓存直接解码地址中的公钥哈希
+func decodeAddressPubKeyHash(address string) []byte {
+	pubKeyHash := Base58Decode([]byte(address))
+	return pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
+}
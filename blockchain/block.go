@@ -3,18 +3,60 @@ package blockchain
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
+// 区块版本号，旧区块没有该字段，解码后为 0
+const (
+	LegacyBlockVersion  = 0 // 旧区块，工作量证明数据不包含区块高度
+	HeightBlockVersion  = 1 // 工作量证明数据包含版本号和区块高度
+	CurrentBlockVersion = HeightBlockVersion
+)
+
 // Block 是区块链的基本组成单位
 type Block struct {
+	Version       int            // 区块版本号，决定工作量证明数据的格式
 	Timestamp     int64          // 时间戳, 区块创建的时间
 	Transactions  []*Transaction // 区块存储的实际有效信息，例如交易
 	PrevBlockHash []byte         // 前一个区块的哈希值
 	Hash          []byte         // 当前区块的哈希值
 	Nonce         int            // 工作量证明的计数器
 	Height        int            // 区块高度
+	TargetBits    int            // 工作量证明难度（目标位数）
+	HashAlgorithm string         // 工作量证明哈希算法，为空时使用 SHA256
+}
+
+// BlockHeader 区块头，不包含交易数据，供轻节点使用
+type BlockHeader struct {
+	Version       int    // 区块版本号
+	Timestamp     int64  // 时间戳
+	PrevBlockHash []byte // 前一个区块的哈希值
+	MerkleRoot    []byte // 交易的默克尔根
+	Hash          []byte // 当前区块的哈希值
+	Nonce         int    // 工作量证明的计数器
+	Height        int    // 区块高度
+	TargetBits    int    // 工作量证明难度（目标位数）
+	HashAlgorithm string // 工作量证明哈希算法
+	TxCount       int    // 区块包含的交易数
+}
+
+// Header 返回区块的区块头
+func (b *Block) Header() *BlockHeader {
+	return &BlockHeader{
+		Version:       b.Version,
+		Timestamp:     b.Timestamp,
+		PrevBlockHash: b.PrevBlockHash,
+		MerkleRoot:    b.HashTransactions(),
+		Hash:          b.Hash,
+		Nonce:         b.Nonce,
+		Height:        b.Height,
+		TargetBits:    NewProofOfWork(b).TargetBits(),
+		HashAlgorithm: b.HashAlgorithm,
+		TxCount:       len(b.Transactions),
+	}
 }
 
 // Serialize 将区块序列化为一个字节切片
@@ -43,6 +85,29 @@ func DeserializeBlock(d []byte) *Block {
 	return &block
 }
 
+// String 返回区块的可读表示
+func (b *Block) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("============ Block %x ============", b.Hash))
+	lines = append(lines, fmt.Sprintf("Height:       %d", b.Height))
+	lines = append(lines, fmt.Sprintf("Timestamp:    %s", time.Unix(b.Timestamp, 0).Format("2006-01-02 15:04:05")))
+	lines = append(lines, fmt.Sprintf("Prev. block:  %x", b.PrevBlockHash))
+	lines = append(lines, fmt.Sprintf("Nonce:        %d", b.Nonce))
+	lines = append(lines, fmt.Sprintf("Difficulty:   %d", NewProofOfWork(b).TargetBits()))
+	lines = append(lines, fmt.Sprintf("Transactions: %d", len(b.Transactions)))
+
+	for _, tx := range b.Transactions {
+		if tx.IsCoinbase() {
+			lines = append(lines, fmt.Sprintf("  %x (coinbase)", tx.ID))
+		} else {
+			lines = append(lines, fmt.Sprintf("  %x (%d inputs, %d outputs)", tx.ID, len(tx.Vin), len(tx.Vout)))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
 func (b *Block) HashTransactions() []byte {
 	var transactions [][]byte
 
@@ -57,13 +122,26 @@ func (b *Block) HashTransactions() []byte {
 
 // NewBlock 创建并返回一个新区块
 func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int) *Block {
+	return NewBlockWithTargetBits(transactions, prevBlockHash, height, targetBits)
+}
+
+// NewBlockWithTargetBits 按指定难度创建并返回一个新区块
+func NewBlockWithTargetBits(transactions []*Transaction, prevBlockHash []byte, height, bits int) *Block {
+	return NewBlockWithHashAlgorithm(transactions, prevBlockHash, height, bits, "")
+}
+
+// NewBlockWithHashAlgorithm 按指定难度和哈希算法创建并返回一个新区块
+func NewBlockWithHashAlgorithm(transactions []*Transaction, prevBlockHash []byte, height, bits int, algorithm string) *Block {
 	block := &Block{
+		Version:       CurrentBlockVersion,
 		Timestamp:     time.Now().Unix(),
 		Transactions:  transactions,
 		PrevBlockHash: prevBlockHash,
 		Hash:          []byte{},
 		Nonce:         0,
 		Height:        height,
+		TargetBits:    bits,
+		HashAlgorithm: algorithm,
 	}
 	pow := NewProofOfWork(block)
 	nonce, hash := pow.Run() // 通过挖矿得到 nonce 和 hash
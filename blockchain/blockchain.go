@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync"
+	"time"
 
 	"go.etcd.io/bbolt"
 )
@@ -26,6 +29,9 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 	accumulated := 0
 	db := u.Blockchain.DB
 
+	u.Blockchain.utxoMutex.RLock()
+	defer u.Blockchain.utxoMutex.RUnlock()
+
 	err := db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
 		c := b.Cursor()
@@ -54,11 +60,74 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[s
 	return accumulated, unspentOutputs
 }
 
+// SpendableOutput 被选中用于支付的未花费输出，PubKeyHash 指明签名时应使用的密钥
+type SpendableOutput struct {
+	TxID       string
+	OutIdx     int
+	Value      int
+	PubKeyHash []byte
+}
+
+// FindSpendableOutputsMulti 单次遍历 chainstate，从多个公钥哈希的未花费输出中累计到 amount
+func (u UTXOSet) FindSpendableOutputsMulti(pubKeyHashes [][]byte, amount int) (int, []SpendableOutput) {
+	var selected []SpendableOutput
+	accumulated := 0
+
+	owners := make(map[string][]byte, len(pubKeyHashes))
+	for _, pubKeyHash := range pubKeyHashes {
+		owners[string(pubKeyHash)] = pubKeyHash
+	}
+	if len(owners) == 0 || amount <= 0 {
+		return accumulated, selected
+	}
+
+	u.Blockchain.utxoMutex.RLock()
+	defer u.Blockchain.utxoMutex.RUnlock()
+
+	err := u.Blockchain.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+	Work:
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			txID := hex.EncodeToString(k)
+			outs := DeserializeOutputs(v)
+
+			for outIdx, out := range outs.Outputs {
+				pubKeyHash, ok := owners[string(out.ScriptPubKey)]
+				if !ok {
+					continue
+				}
+
+				accumulated += out.Value
+				selected = append(selected, SpendableOutput{
+					TxID:       txID,
+					OutIdx:     outIdx,
+					Value:      out.Value,
+					PubKeyHash: pubKeyHash,
+				})
+
+				if accumulated >= amount {
+					break Work
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	return accumulated, selected
+}
+
 // FindUTXO 查找所有未花费的交易输出并返回已移除花费输出的交易
 func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 	var UTXOs []TXOutput
 	db := u.Blockchain.DB
 
+	u.Blockchain.utxoMutex.RLock()
+	defer u.Blockchain.utxoMutex.RUnlock()
+
 	err := db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
 		c := b.Cursor()
@@ -82,45 +151,89 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 	return UTXOs
 }
 
-// Reindex 重建 UTXO 集合
-func (u UTXOSet) Reindex() {
-	db := u.Blockchain.DB
-	bucketName := []byte(utxoBucket)
+// GetBalances 单次遍历 chainstate 统计多个地址的余额
+func (u UTXOSet) GetBalances(addresses []string) map[string]int {
+	balances := make(map[string]int, len(addresses))
+	owners := make(map[string][]string, len(addresses))
 
-	err := db.Update(func(tx *bbolt.Tx) error {
-		err := tx.DeleteBucket(bucketName)
-		if err != nil && err != bbolt.ErrBucketNotFound {
-			log.Panic(err)
+	for _, address := range addresses {
+		if _, seen := balances[address]; seen {
+			continue
+		}
+		balances[address] = 0
+		if !ValidateAddress(address) {
+			continue
 		}
+		pubKeyHash := string(AddressToPubKeyHash(address))
+		owners[pubKeyHash] = append(owners[pubKeyHash], address)
+	}
 
-		_, err = tx.CreateBucket(bucketName)
-		if err != nil {
-			log.Panic(err)
+	u.Blockchain.utxoMutex.RLock()
+	defer u.Blockchain.utxoMutex.RUnlock()
+
+	err := u.Blockchain.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			outs := DeserializeOutputs(v)
+
+			for _, out := range outs.Outputs {
+				for _, address := range owners[string(out.ScriptPubKey)] {
+					balances[address] += out.Value
+				}
+			}
 		}
+
 		return nil
 	})
 	if err != nil {
 		log.Panic(err)
 	}
 
-	UTXO := u.Blockchain.FindUTXO()
+	return balances
+}
 
-	err = db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketName)
+// Reindex 重建 UTXO 集合
+// 删除和重建在同一数据库事务中完成，并持有写锁，查询不会看到中间的空集合
+func (u UTXOSet) Reindex() {
+	u.Blockchain.utxoMutex.Lock()
+	defer u.Blockchain.utxoMutex.Unlock()
 
-		for txID, outs := range UTXO {
-			key, err := hex.DecodeString(txID)
-			if err != nil {
-				log.Panic(err)
-			}
+	err := u.Blockchain.DB.Update(func(tx *bbolt.Tx) error {
+		return rebuildUTXO(tx, tx.Bucket([]byte(blocksBucket)).Get([]byte("l")))
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
 
-			err = b.Put(key, outs.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
+// CountTransactions 返回 UTXO 集合中包含未花费输出的交易数量
+func (u UTXOSet) CountTransactions() int {
+	db := u.Blockchain.DB
+	counter := 0
+
+	u.Blockchain.utxoMutex.RLock()
+	defer u.Blockchain.utxoMutex.RUnlock()
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			counter++
 		}
+
 		return nil
 	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return counter
 }
 
 // Update 使用区块中的交易更新 UTXO 集合
@@ -128,92 +241,224 @@ func (u UTXOSet) Reindex() {
 func (u UTXOSet) Update(block *Block) {
 	db := u.Blockchain.DB
 
+	u.Blockchain.utxoMutex.Lock()
+	defer u.Blockchain.utxoMutex.Unlock()
+
 	err := db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
+		return applyBlockToUTXO(tx.Bucket([]byte(utxoBucket)), block)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
 
-		for _, tx := range block.Transactions {
-			if tx.IsCoinbase() == false {
-				for _, vin := range tx.Vin {
-					updatedOuts := TXOutputs{}
-					outsBytes := b.Get(vin.Txid)
-					outs := DeserializeOutputs(outsBytes)
-
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-						}
+// applyBlockToUTXO 在 UTXO 桶中移除区块花费的输出并加入新产生的输出
+func applyBlockToUTXO(b *bbolt.Bucket, block *Block) error {
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() == false {
+			for _, vin := range tx.Vin {
+				updatedOuts := TXOutputs{}
+				outsBytes := b.Get(vin.Txid)
+				if outsBytes == nil {
+					return fmt.Errorf("%w: transaction %x spends output %x:%d that is not in the UTXO set", ErrDoubleSpend, tx.ID, vin.Txid, vin.Vout)
+				}
+				outs := DeserializeOutputs(outsBytes)
+
+				for outIdx, out := range outs.Outputs {
+					if outIdx != vin.Vout {
+						updatedOuts.Outputs = append(updatedOuts.Outputs, out)
 					}
+				}
 
-					if len(updatedOuts.Outputs) == 0 {
-						err := b.Delete(vin.Txid)
-						if err != nil {
-							log.Panic(err)
-						}
-					} else {
-						err := b.Put(vin.Txid, updatedOuts.Serialize())
-						if err != nil {
-							log.Panic(err)
-						}
+				if len(updatedOuts.Outputs) == 0 {
+					if err := b.Delete(vin.Txid); err != nil {
+						return err
+					}
+				} else {
+					if err := b.Put(vin.Txid, updatedOuts.Serialize()); err != nil {
+						return err
 					}
 				}
 			}
+		}
 
-			newOutputs := TXOutputs{}
-			for _, out := range tx.Vout {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
-			}
+		newOutputs := TXOutputs{}
+		for _, out := range tx.Vout {
+			newOutputs.Outputs = append(newOutputs.Outputs, out)
+		}
 
-			err := b.Put(tx.ID, newOutputs.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
+		if err := b.Put(tx.ID, newOutputs.Serialize()); err != nil {
+			return err
 		}
+	}
 
-		return nil
-	})
+	return nil
+}
+
+// rebuildUTXO 在同一数据库事务中根据以 tip 结尾的链重建 UTXO 桶
+func rebuildUTXO(tx *bbolt.Tx, tip []byte) error {
+	err := tx.DeleteBucket([]byte(utxoBucket))
+	if err != nil && err != bbolt.ErrBucketNotFound {
+		return err
+	}
+
+	b, err := tx.CreateBucket([]byte(utxoBucket))
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+
+	for txID, outs := range findUTXOFrom(tx.Bucket([]byte(blocksBucket)), tip) {
+		key, err := hex.DecodeString(txID)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, outs.Serialize()); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
 // Blockchain 结构体现在只包含数据库连接和链的末端哈希
 type Blockchain struct {
-	tip []byte
-	DB  *bbolt.DB
+	tip     []byte
+	DB      *bbolt.DB
+	mempool TxPool
+	mutex   sync.Mutex // 串行化区块写入
+
+	utxoMutex sync.RWMutex // 协调 UTXO 集合的查询与重建
+
+	maxReorgDepth      int           // 允许回滚的最大区块数
+	maxFutureBlockTime time.Duration // 区块时间戳允许超前区块链时钟的最大偏差
+	minBlockTxFee      int           // 区块内交易的共识最低手续费，0 表示不限制
+	blockSubsidy       int           // 每个区块的 coinbase 补贴，0 表示使用默认值
+	minTargetBits      int           // 难度下限，0 表示使用默认难度
+	clock              Clock         // 时间戳验证和过期检测使用的时钟，为空时使用系统时间
 }
 
-// AddBlock 将区块保存到区块链中
+// defaultMaxReorgDepth 默认允许的最大链重组深度
+const defaultMaxReorgDepth = 100
+
+// SetMaxReorgDepth 设置允许的最大链重组深度，小于等于 0 时使用默认值
+func (bc *Blockchain) SetMaxReorgDepth(depth int) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	if depth <= 0 {
+		depth = defaultMaxReorgDepth
+	}
+	bc.maxReorgDepth = depth
+}
+
+// reorgDepth 计算切换到以 block 结尾的分叉需要回滚的主链区块数
+// 分叉的祖先区块缺失、找不到与主链的分叉点时返回 false
+func reorgDepth(b *bbolt.Bucket, tip *Block, block *Block) (int, bool) {
+	main := tip
+	fork := block
+	for !bytes.Equal(main.Hash, fork.Hash) {
+		var next **Block
+		var hash []byte
+		if main.Height >= fork.Height {
+			next, hash = &main, main.PrevBlockHash
+		} else {
+			next, hash = &fork, fork.PrevBlockHash
+		}
+
+		data := b.Get(hash)
+		if len(hash) == 0 || data == nil {
+			return 0, false
+		}
+		*next = DeserializeBlock(data)
+	}
+
+	return tip.Height - main.Height, true
+}
+
+// AddBlock 将区块保存到区块链中，重复的区块返回 ErrBlockExists
+// 成为新链尖的区块会在同一数据库事务中更新 UTXO 集合，并发重复投递只会生效一次
 func (bc *Blockchain) AddBlock(block *Block) error {
+	if block == nil || len(block.Hash) == 0 {
+		return fmt.Errorf("%w: block has no hash", ErrInvalidBlock)
+	}
+
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
 	err := bc.DB.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		blockInDb := b.Get(block.Hash)
 
 		if blockInDb != nil {
-			return nil
+			return ErrBlockExists
 		}
 
+		lastHash := b.Get([]byte("l"))
+		lastBlockData := b.Get(lastHash)
+		lastBlock := DeserializeBlock(lastBlockData)
+
 		blockData := block.Serialize()
 		err := b.Put(block.Hash, blockData)
 		if err != nil {
 			return fmt.Errorf("failed to put block data: %v", err)
 		}
 
-		lastHash := b.Get([]byte("l"))
-		lastBlockData := b.Get(lastHash)
-		lastBlock := DeserializeBlock(lastBlockData)
+		// 祖先缺失的孤儿区块只保存不切换链尖，等父区块到达后再接入
+		if !bytes.Equal(block.PrevBlockHash, lastHash) {
+			if _, ok := reorgDepth(b, lastBlock, block); !ok {
+				log.Printf("Stored orphan block %x: its ancestors are not known", block.Hash)
+				return addOrphan(tx, block)
+			}
+		}
+
+		// 之前保存的以该区块为祖先的孤儿区块随之接入，其中最高的区块作为链尖候选，
+		// 这样按链尖在前的顺序下载的区块在最早的区块到达后整体接入
+		best, err := connectOrphans(tx, block)
+		if err != nil {
+			return fmt.Errorf("failed to connect orphan blocks: %v", err)
+		}
+		if best.Height <= lastBlock.Height {
+			return nil
+		}
+
+		// 找到分叉点后拒绝回滚超过上限的链重组
+		maxDepth := bc.maxReorgDepth
+		if maxDepth <= 0 {
+			maxDepth = defaultMaxReorgDepth
+		}
+		if depth, _ := reorgDepth(b, lastBlock, best); depth > maxDepth {
+			log.Printf("Rejected block %x: reorganization would roll back more than %d blocks", block.Hash, maxDepth)
+			return ErrReorgTooDeep
+		}
+
+		err = b.Put([]byte("l"), best.Hash)
+		if err != nil {
+			return fmt.Errorf("failed to update last block hash: %v", err)
+		}
+		if err := updateTxIndexForTip(tx, lastBlock, best); err != nil {
+			return fmt.Errorf("failed to index block transactions: %v", err)
+		}
 
-		if block.Height > lastBlock.Height {
-			err = b.Put([]byte("l"), block.Hash)
+		// 尚未建立 UTXO 集合的旧数据库由 Reindex 负责构建
+		if utxo := tx.Bucket([]byte(utxoBucket)); utxo != nil {
+			if best == block && bytes.Equal(block.PrevBlockHash, lastHash) {
+				err = applyBlockToUTXO(utxo, block)
+			} else {
+				err = rebuildUTXO(tx, best.Hash)
+			}
 			if err != nil {
-				return fmt.Errorf("failed to update last block hash: %v", err)
+				return fmt.Errorf("failed to update UTXO set: %w", err)
 			}
-			bc.tip = block.Hash
 		}
+		bc.tip = best.Hash
 
 		return nil
 	})
+	if err == ErrBlockExists || err == ErrReorgTooDeep {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("failed to add block to blockchain: %v", err)
+		return fmt.Errorf("failed to add block to blockchain: %w", err)
 	}
 	return nil
 }
@@ -237,6 +482,64 @@ func (bc *Blockchain) GetBestHeight() int {
 	return lastBlock.Height
 }
 
+// lastHash 从数据库读取当前链尖的哈希，无需持有 bc.mutex
+func (bc *Blockchain) lastHash() []byte {
+	var hash []byte
+
+	err := bc.DB.View(func(tx *bbolt.Tx) error {
+		hash = append([]byte{}, tx.Bucket([]byte(blocksBucket)).Get([]byte("l"))...)
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return hash
+}
+
+// IsTipStale 判断链末端区块的时间戳是否已比当前时间早超过 maxAge，
+// 长时间没有新区块可能意味着节点被隔离或网络停滞
+func (bc *Blockchain) IsTipStale(maxAge time.Duration) bool {
+	tip, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		return false
+	}
+
+	return bc.now().Sub(time.Unix(tip.Timestamp, 0)) > maxAge
+}
+
+// TotalSupply 返回当前已发行的币量，即主链上 coinbase 输出总额减去其中领取的交易手续费
+// 手续费是已有币的转移而非新发行，未领取的手续费视为销毁
+func (bc *Blockchain) TotalSupply() int {
+	supply := 0
+
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			if !tx.IsCoinbase() {
+				continue
+			}
+			for _, out := range tx.Vout {
+				supply += out.Value
+			}
+		}
+
+		fees, err := bc.blockFees(block)
+		if err != nil {
+			log.Panic(err)
+		}
+		supply -= fees
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return supply
+}
+
 // GetBlock 通过哈希查找区块并返回
 func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 	var block Block
@@ -247,7 +550,7 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 		blockData := b.Get(blockHash)
 
 		if blockData == nil {
-			return fmt.Errorf("Block is not found.")
+			return ErrBlockNotFound
 		}
 
 		block = *DeserializeBlock(blockData)
@@ -261,6 +564,26 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 	return block, nil
 }
 
+// GetBlockHeader 根据区块哈希返回区块头
+func (bc *Blockchain) GetBlockHeader(blockHash []byte) (*BlockHeader, error) {
+	block, err := bc.GetBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Header(), nil
+}
+
+// GetBlockTransactions 根据区块哈希返回区块中的所有交易
+func (bc *Blockchain) GetBlockTransactions(blockHash []byte) ([]*Transaction, error) {
+	block, err := bc.GetBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Transactions, nil
+}
+
 // GetBlockHashes 返回链中所有区块的哈希列表
 func (bc *Blockchain) GetBlockHashes() [][]byte {
 	var blocks [][]byte
@@ -279,78 +602,161 @@ func (bc *Blockchain) GetBlockHashes() [][]byte {
 	return blocks
 }
 
-// MineBlock 使用提供的交易挖掘一个新区块
-func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
-	var lastHash []byte
-	var lastHeight int
+// GetRecentBlockHashes 返回从链尖向前的 n 个区块哈希，链尖在前
+func (bc *Blockchain) GetRecentBlockHashes(n int) [][]byte {
+	var hashes [][]byte
+	if n <= 0 {
+		return hashes
+	}
 
-	for _, tx := range transactions {
-		if bc.VerifyTransaction(tx) != true {
-			log.Panic("ERROR: Invalid transaction")
+	bci := bc.Iterator()
+	for len(hashes) < n {
+		block := bci.Next()
+
+		hashes = append(hashes, block.Hash)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return hashes
+}
+
+// MineBlock 使用提供的交易挖掘一个新区块
+// minerAddress 非空时在首位加入向该地址支付奖励的 coinbase 交易，为空时不发放奖励
+func (bc *Blockchain) MineBlock(transactions []*Transaction, minerAddress string) (*Block, error) {
+	if minerAddress != "" {
+		if _, _, err := Base58CheckDecode(minerAddress); err != nil {
+			return nil, err
 		}
+		cbTx := NewCoinbaseTXWithReward(minerAddress, "", bc.BlockSubsidy())
+		transactions = append([]*Transaction{cbTx}, transactions...)
 	}
 
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	var lastBlock *Block
+
 	err := bc.DB.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
+		lastHash := b.Get([]byte("l"))
 
 		blockData := b.Get(lastHash)
-		block := DeserializeBlock(blockData)
-
-		lastHeight = block.Height
+		lastBlock = DeserializeBlock(blockData)
 
 		return nil
 	})
 	if err != nil {
-		log.Panic(err)
+		return nil, err
+	}
+
+	bits, err := bc.NextTargetBits(lastBlock)
+	if err != nil {
+		return nil, err
 	}
+	newBlock := NewBlockWithTargetBits(transactions, lastBlock.Hash, lastBlock.Height+1, bits)
 
-	newBlock := NewBlock(transactions, lastHash, lastHeight+1)
+	// 与 ValidateBlock 执行相同的区块级交易检查，本地挖出的区块也不能绕过共识规则
+	if err := bc.checkBlockTransactions(newBlock, true); err != nil {
+		return nil, err
+	}
 
 	err = bc.DB.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		err := b.Put(newBlock.Hash, newBlock.Serialize())
-		if err != nil {
-			log.Panic(err)
+		if err := b.Put(newBlock.Hash, newBlock.Serialize()); err != nil {
+			return err
 		}
 
-		err = b.Put([]byte("l"), newBlock.Hash)
-		if err != nil {
-			log.Panic(err)
+		if err := b.Put([]byte("l"), newBlock.Hash); err != nil {
+			return err
 		}
 
-		bc.tip = newBlock.Hash
-
-		return nil
+		return indexBlockTransactions(tx, newBlock)
 	})
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
 
-	return newBlock
+	bc.tip = newBlock.Hash
+
+	return newBlock, nil
+}
+
+// Options 打开区块链数据库的选项
+type Options struct {
+	ReadOnly bool           // 只读模式，只获取共享锁，适用于查询类命令
+	Timeout  time.Duration  // 等待数据库文件锁的超时时间，0 表示一直等待
+	Genesis  *GenesisConfig // 新建区块链时的创世区块配置，为空时使用默认配置
 }
 
 // NewBlockchain 创建一个带有创世区块的新区块链
 func NewBlockchain(address, nodeID string) *Blockchain {
+	bc, err := NewBlockchainWithOptions(address, nodeID, nil)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return bc
+}
+
+// NewBlockchainWithOptions 按指定选项打开区块链数据库
+func NewBlockchainWithOptions(address, nodeID string, opts *Options) (*Blockchain, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
 	dbFile := fmt.Sprintf("blockchain_%s.db", nodeID)
-	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+	_, statErr := os.Stat(dbFile)
+	existed := statErr == nil
+	if os.IsNotExist(statErr) {
+		if opts.ReadOnly {
+			return nil, fmt.Errorf("No existing blockchain found.")
+		}
 		log.Println("Blockchain file not found, creating a new one.")
 	}
 
 	var tip []byte
-	db, err := bbolt.Open(dbFile, 0600, nil)
+	db, err := bbolt.Open(dbFile, 0600, &bbolt.Options{ReadOnly: opts.ReadOnly, Timeout: opts.Timeout})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		// bbolt 的读写句柄持有排他锁，只读句柄在其关闭前无法打开
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseLocked, dbFile)
+	}
 	if err != nil {
-		log.Panic(err)
+		return nil, fmt.Errorf("failed to open blockchain database: %v", err)
+	}
+
+	if opts.ReadOnly {
+		err = db.View(func(tx *bbolt.Tx) error {
+			b := tx.Bucket([]byte(blocksBucket))
+			if b == nil {
+				return fmt.Errorf("%w: %s has no blocks bucket", ErrCorruptDB, dbFile)
+			}
+			tip = b.Get([]byte("l"))
+
+			return nil
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return &Blockchain{tip: tip, DB: db}, nil
 	}
 
 	err = db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 
 		if b == nil {
+			// 已有的数据库文件缺少区块数据，不能当作新链覆盖
+			if existed {
+				return fmt.Errorf("%w: %s has no blocks bucket", ErrCorruptDB, dbFile)
+			}
 			if address == "" {
-				log.Panic("No existing blockchain found and no address provided")
+				return fmt.Errorf("No existing blockchain found and no address provided")
 			}
-			genesis := NewGenesisBlock(NewCoinbaseTX(address, genesisCoinbaseData))
+			genesis := NewGenesisBlock(opts.Genesis.coinbase(address))
 
 			b, err := tx.CreateBucket([]byte(blocksBucket))
 			if err != nil {
@@ -366,32 +772,69 @@ func NewBlockchain(address, nodeID string) *Blockchain {
 			if err != nil {
 				log.Panic(err)
 			}
+
+			err = indexBlockTransactions(tx, genesis)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			utxo, err := tx.CreateBucketIfNotExists([]byte(utxoBucket))
+			if err != nil {
+				log.Panic(err)
+			}
+			err = applyBlockToUTXO(utxo, genesis)
+			if err != nil {
+				log.Panic(err)
+			}
 			tip = genesis.Hash
 		} else {
 			tip = b.Get([]byte("l"))
+			if tip == nil {
+				return fmt.Errorf("%w: %s has no chain tip", ErrCorruptDB, dbFile)
+			}
 		}
 
 		return nil
 	})
 	if err != nil {
-		log.Panic(err)
+		db.Close()
+		if !existed {
+			os.Remove(dbFile) // 不留下没有区块数据的空文件
+		}
+		return nil, err
 	}
 
-	bc := Blockchain{tip, db}
-
-	return &bc
+	return &Blockchain{tip: tip, DB: db}, nil
 }
 
 // FindUTXO 查找所有未花费的交易输出并返回已移除花费输出的交易
 func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
+	var UTXO map[string]TXOutputs
+
+	err := bc.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		UTXO = findUTXOFrom(b, b.Get([]byte("l")))
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return UTXO
+}
+
+// findUTXOFrom 从 tip 开始回溯区块桶，查找所有未花费的交易输出
+func findUTXOFrom(b *bbolt.Bucket, tip []byte) map[string]TXOutputs {
 	UTXO := make(map[string]TXOutputs)
 	spentTXOs := make(map[string][]int)
-	bci := bc.Iterator()
+	hash := tip
 
-	for {
-		block := bci.Next()
+	for len(hash) > 0 {
+		block := DeserializeBlock(b.Get(hash))
 
-		for _, tx := range block.Transactions {
+		// 倒序遍历，使同一区块内花费父交易输出的子交易先于父交易被处理
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
 			txID := hex.EncodeToString(tx.ID)
 
 		Outputs:
@@ -418,62 +861,100 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 			}
 		}
 
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
+		hash = block.PrevBlockHash
 	}
 
 	return UTXO
 }
 
-// NewUTXOTransaction 创建一个新交易
-func NewUTXOTransaction(from, to string, amount int, UTXOSet *UTXOSet) *Transaction {
+// ChangeAddressSource 为交易找零提供新地址，通常由钱包实现
+type ChangeAddressSource interface {
+	NewChangeAddress() string
+}
+
+// NewUTXOTransaction 创建一个新交易，找零退回 from 地址
+// 金额非正数时返回 ErrInvalidAmount，地址无效时返回 ErrInvalidAddress，余额不足时返回 ErrNotEnoughFunds
+func NewUTXOTransaction(from, to string, amount int, UTXOSet *UTXOSet) (*Transaction, error) {
+	return NewUTXOTransactionWithChange(from, to, amount, UTXOSet, nil)
+}
+
+// NewUTXOTransactionWithChange 创建一个新交易，有找零时发送到 changeSource 提供的新地址
+// changeSource 为 nil 时找零退回 from 地址
+func NewUTXOTransactionWithChange(from, to string, amount int, UTXOSet *UTXOSet, changeSource ChangeAddressSource) (*Transaction, error) {
 	var inputs []TXInput
 	var outputs []TXOutput
 
-	pubKeyHash := Base58Decode([]byte(from))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	for _, address := range []string{from, to} {
+		if !ValidateAddress(address) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, address)
+		}
+	}
+
+	pubKeyHash := AddressToPubKeyHash(from)
 
 	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
 
 	if acc < amount {
-		log.Panic("ERROR: Not enough funds")
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrNotEnoughFunds, acc, amount)
 	}
 
 	// 构建输入列表
 	for txid, outs := range validOutputs {
 		txID, err := hex.DecodeString(txid)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
 		}
 
 		for _, out := range outs {
-			input := TXInput{txID, out, ""}
+			input := TXInput{Txid: txID, Vout: out}
 			inputs = append(inputs, input)
 		}
 	}
 
 	// 构建输出列表
-	outputs = append(outputs, *NewTXOutput(amount, to))
+	out, err := NewTXOutput(amount, to)
+	if err != nil {
+		return nil, err
+	}
+	outputs = append(outputs, *out)
 	if acc > amount {
-		outputs = append(outputs, *NewTXOutput(acc-amount, from)) // 找零
+		changeAddress := from
+		if changeSource != nil {
+			changeAddress = changeSource.NewChangeAddress()
+		}
+		change, err := NewTXOutput(acc-amount, changeAddress) // 找零
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, *change)
 	}
 
 	tx := Transaction{nil, inputs, outputs}
 	tx.ID = tx.Hash()
 
-	return &tx
+	return &tx, nil
 }
 
 // BlockchainIterator 用于遍历区块链区块
 type BlockchainIterator struct {
 	currentHash []byte
+	height      int
 	DB          *bbolt.DB
 }
 
 // Iterator 返回一个区块链迭代器
 func (bc *Blockchain) Iterator() *BlockchainIterator {
-	bci := &BlockchainIterator{bc.tip, bc.DB}
+	bci := &BlockchainIterator{currentHash: bc.tip, height: -1, DB: bc.DB}
+
+	return bci
+}
+
+// IteratorFrom 返回从指定区块开始向前遍历的迭代器
+func (bc *Blockchain) IteratorFrom(blockHash []byte) *BlockchainIterator {
+	bci := &BlockchainIterator{currentHash: blockHash, height: -1, DB: bc.DB}
 
 	return bci
 }
@@ -494,10 +975,16 @@ func (i *BlockchainIterator) Next() *Block {
 	}
 
 	i.currentHash = block.PrevBlockHash
+	i.height = block.Height
 
 	return block
 }
 
+// Height 返回上一次 Next 返回的区块高度，尚未遍历时返回 -1
+func (i *BlockchainIterator) Height() int {
+	return i.height
+}
+
 // FindTransaction 通过ID查找交易
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	bci := bc.Iterator()
@@ -516,7 +1003,7 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 		}
 	}
 
-	return Transaction{}, fmt.Errorf("Transaction is not found")
+	return Transaction{}, ErrTransactionNotFound
 }
 
 // SignTransaction 对交易的输入进行签名
@@ -534,21 +1021,43 @@ func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey)
 	tx.Sign(privKey, prevTXs)
 }
 
-// VerifyTransaction 验证交易输入签名
+// VerifyTransaction 验证交易输入签名，引用的交易不存在时返回 false
 func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
-	if tx.IsCoinbase() {
-		return true
+	return bc.verifyTransactionWith(tx, nil)
+}
+
+// VerifyTransactionInBlock 验证交易，允许其花费同一区块中排在它之前的交易的输出
+func (bc *Blockchain) VerifyTransactionInBlock(tx *Transaction, preceding []*Transaction) bool {
+	return bc.ValidateTransactionInBlock(tx, preceding) == nil
+}
+
+// verifyTransactionWith 验证交易，引用的交易先在 inBlock 中查找，再在链上查找
+func (bc *Blockchain) verifyTransactionWith(tx *Transaction, inBlock map[string]*Transaction) bool {
+	if err := bc.validateTransactionWith(tx, inBlock); err != nil {
+		log.Printf("Transaction %x rejected: %v", tx.ID, err)
+		return false
 	}
 
+	return true
+}
+
+// findPrevTransactions 查找交易输入引用的交易，inBlock 中的同区块交易优先
+func (bc *Blockchain) findPrevTransactions(tx *Transaction, inBlock map[string]*Transaction) (map[string]Transaction, error) {
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
+		txID := hex.EncodeToString(vin.Txid)
+		if prevTX, ok := inBlock[txID]; ok {
+			prevTXs[txID] = *prevTX
+			continue
+		}
+
 		prevTX, err := bc.FindTransaction(vin.Txid)
 		if err != nil {
-			log.Panic(err)
+			return nil, fmt.Errorf("referenced transaction %x: %w", vin.Txid, err)
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+		prevTXs[txID] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	return prevTXs, nil
 }
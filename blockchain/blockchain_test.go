@@ -1,8 +1,21 @@
 package blockchain
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
 )
 
 const (
@@ -46,35 +59,130 @@ func TestNewBlockchain(t *testing.T) {
 	}
 }
 
+// mustMineBlock 挖出不带奖励的区块，失败时终止测试
+func mustMineBlock(tb testing.TB, bc *Blockchain, txs []*Transaction) *Block {
+	tb.Helper()
+
+	block, err := bc.MineBlock(txs, "")
+	if err != nil {
+		tb.Fatalf("Failed to mine block: %v", err)
+	}
+	return block
+}
+
+// newTestKey 生成测试用私钥，返回私钥和对应的地址
+func newTestKey(tb testing.TB) (*ecdsa.PrivateKey, string) {
+	tb.Helper()
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatalf("Failed to generate key: %v", err)
+	}
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+
+	return privKey, PubKeyHashToAddress(HashPubKey(pubKey))
+}
+
 // TestBlockchain_MineBlock 测试挖矿功能
 func TestBlockchain_MineBlock(t *testing.T) {
 	setupTestEnvironment()
 	defer teardownTestEnvironment()
 
 	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	miner := PubKeyHashToAddress(HashPubKey([]byte("mine_block_miner")))
 	bc := NewBlockchain(address, testNodeID)
 	defer bc.DB.Close()
 
-	// 创建一个新交易
-	tx := NewCoinbaseTX(address, "")
+	t.Run("WithReward", func(t *testing.T) {
+		block, err := bc.MineBlock(nil, miner)
+		if err != nil {
+			t.Fatalf("Failed to mine block: %v", err)
+		}
+		if len(block.Transactions) != 1 || !block.Transactions[0].IsCoinbase() {
+			t.Fatalf("Expected a single coinbase transaction, got %d transactions", len(block.Transactions))
+		}
+		if !block.Transactions[0].Vout[0].IsLockedWithKey(AddressToPubKeyHash(miner)) {
+			t.Error("Coinbase should pay the miner address")
+		}
+		if !bytes.Equal(bc.tip, block.Hash) || block.Height != 1 {
+			t.Errorf("Expected mined block to become the tip at height 1, got height %d", block.Height)
+		}
+	})
+
+	t.Run("WithoutReward", func(t *testing.T) {
+		tx := NewCoinbaseTX(address, "no reward")
+		block, err := bc.MineBlock([]*Transaction{tx}, "")
+		if err != nil {
+			t.Fatalf("Failed to mine block: %v", err)
+		}
+		if len(block.Transactions) != 1 || !bytes.Equal(block.Transactions[0].ID, tx.ID) {
+			t.Error("Expected only the given transaction without an extra coinbase")
+		}
+		if block.Height != 2 {
+			t.Errorf("Expected height 2, got %d", block.Height)
+		}
+	})
+
+	t.Run("InvalidMinerAddress", func(t *testing.T) {
+		if _, err := bc.MineBlock(nil, "invalid"); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("Expected ErrInvalidAddress, got %v", err)
+		}
+	})
 
-	// 挖矿
-	bc.MineBlock([]*Transaction{tx})
+	t.Run("InvalidTransaction", func(t *testing.T) {
+		tx := NewCoinbaseTX(address, "tampered")
+		tx.Vin = append(tx.Vin, TXInput{Txid: []byte("missing"), Vout: 0})
+		if _, err := bc.MineBlock([]*Transaction{tx}, ""); !errors.Is(err, ErrInvalidTransaction) {
+			t.Errorf("Expected ErrInvalidTransaction, got %v", err)
+		}
+		if bc.GetBestHeight() != 2 {
+			t.Errorf("Rejected block should not change the tip, height %d", bc.GetBestHeight())
+		}
+	})
+}
 
-	// 验证区块链长度
-	iterator := bc.Iterator()
-	count := 0
-	for {
-		block := iterator.Next()
-		count++
+// TestBlockchain_MineBlockWithoutReward 测试不发放奖励的区块可通过验证且只转移已有输入的价值
+func TestBlockchain_MineBlockWithoutReward(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
 
-		if len(block.PrevBlockHash) == 0 {
-			break
+	privKey, address := newTestKey(t)
+	other := PubKeyHashToAddress(HashPubKey([]byte("no_reward_key")))
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+	utxoSet := UTXOSet{Blockchain: bc}
+	utxoSet.Reindex()
+
+	tx, err := NewUTXOTransaction(address, other, 30, &utxoSet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	bc.SignTransaction(tx, *privKey)
+	supply := bc.TotalSupply()
+
+	block, err := bc.MineBlock([]*Transaction{tx}, "")
+	if err != nil {
+		t.Fatalf("Failed to mine block without reward: %v", err)
+	}
+	if !bytes.Equal(bc.tip, block.Hash) || block.Height != 1 {
+		t.Fatalf("Expected block to connect at height 1, got height %d", block.Height)
+	}
+	if err := bc.ValidateBlock(block); err != nil {
+		t.Errorf("Expected block without reward to validate, got %v", err)
+	}
+	for _, blockTx := range block.Transactions {
+		if blockTx.IsCoinbase() {
+			t.Error("Block without reward should not contain a coinbase")
 		}
 	}
 
-	if count < 2 { // 至少应该有创世区块 + 新挖的区块
-		t.Errorf("Expected at least 2 blocks, got %d", count)
+	utxoSet.Update(block)
+	if bc.TotalSupply() != supply {
+		t.Errorf("Expected supply to stay at %d, got %d", supply, bc.TotalSupply())
+	}
+	balances := utxoSet.GetBalances([]string{address, other})
+	if balances[address]+balances[other] != subsidy || balances[other] != 30 {
+		t.Errorf("Expected the genesis reward to be redistributed, got %v", balances)
 	}
 }
 
@@ -87,7 +195,7 @@ func TestUTXOSet_Reindex(t *testing.T) {
 	bc := NewBlockchain(address, testNodeID)
 	defer bc.DB.Close()
 
-	utxoSet := UTXOSet{bc}
+	utxoSet := UTXOSet{Blockchain: bc}
 	utxoSet.Reindex()
 
 	// 查找 UTXO
@@ -99,3 +207,1714 @@ func TestUTXOSet_Reindex(t *testing.T) {
 		t.Error("Expected at least one UTXO for the address")
 	}
 }
+
+// TestUTXOSet_ReindexConcurrentReads 测试重建 UTXO 集合期间的查询不会看到空集合
+func TestUTXOSet_ReindexConcurrentReads(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	utxoSet := UTXOSet{Blockchain: bc}
+	utxoSet.Reindex()
+	pubKeyHash := AddressToPubKeyHash(address)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			utxoSet.Reindex()
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if len(utxoSet.FindUTXO(pubKeyHash)) == 0 {
+					t.Error("FindUTXO observed an empty UTXO set during reindex")
+					return
+				}
+				if utxoSet.GetBalances([]string{address})[address] == 0 {
+					t.Error("GetBalances observed an empty UTXO set during reindex")
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// TestUTXOSet_FindSpendableOutputsDuringReindex 测试重建索引期间查找可花费输出不会多算或少算
+func TestUTXOSet_FindSpendableOutputsDuringReindex(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	for i := 0; i < 5; i++ {
+		mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, fmt.Sprintf("spendable %d", i))})
+	}
+
+	utxoSet := UTXOSet{Blockchain: bc}
+	utxoSet.Reindex()
+	pubKeyHash := AddressToPubKeyHash(address)
+	balance := utxoSet.GetBalances([]string{address})[address]
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			utxoSet.Reindex()
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				accumulated, outputs := utxoSet.FindSpendableOutputs(pubKeyHash, balance+1)
+				if accumulated != balance {
+					t.Errorf("Expected accumulated %d during reindex, got %d", balance, accumulated)
+					return
+				}
+				if len(outputs) != 6 {
+					t.Errorf("Expected 6 spendable transactions, got %d", len(outputs))
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// testTxPool 用于测试的内存池
+type testTxPool map[string]bool
+
+// HasTransaction 检查交易是否在测试内存池中
+func (p testTxPool) HasTransaction(txID []byte) bool {
+	return p[string(txID)]
+}
+
+// TestBlockchain_GetTransactionConfirmations 测试交易确认数查询
+func TestBlockchain_GetTransactionConfirmations(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, _ := bc.GetBlock(bc.tip)
+	genesisTxID := genesis.Transactions[0].ID
+
+	cbTx := NewCoinbaseTX(address, "block 1")
+	mustMineBlock(t, bc, []*Transaction{cbTx})
+	mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, "block 2")})
+
+	// 已确认交易
+	confirmations, err := bc.GetTransactionConfirmations(genesisTxID)
+	if err != nil || confirmations != 3 {
+		t.Errorf("Expected 3 confirmations for genesis transaction, got %d (%v)", confirmations, err)
+	}
+	confirmations, err = bc.GetTransactionConfirmations(cbTx.ID)
+	if err != nil || confirmations != 2 {
+		t.Errorf("Expected 2 confirmations, got %d (%v)", confirmations, err)
+	}
+
+	// 仅在内存池中的交易
+	pending := NewCoinbaseTX(address, "pending")
+	bc.SetMempool(testTxPool{string(pending.ID): true})
+	confirmations, err = bc.GetTransactionConfirmations(pending.ID)
+	if err != nil || confirmations != 0 {
+		t.Errorf("Expected 0 confirmations for mempool transaction, got %d (%v)", confirmations, err)
+	}
+
+	// 未知交易
+	if _, err := bc.GetTransactionConfirmations([]byte("unknown")); err == nil {
+		t.Error("Expected error for unknown transaction")
+	}
+
+	// 重建索引后结果保持一致
+	if indexed := bc.ReindexTransactions(); indexed != 3 {
+		t.Errorf("Expected 3 indexed transactions, got %d", indexed)
+	}
+	confirmations, err = bc.GetTransactionConfirmations(cbTx.ID)
+	if err != nil || confirmations != 2 {
+		t.Errorf("Expected 2 confirmations after reindex, got %d (%v)", confirmations, err)
+	}
+}
+
+// TestBlockchain_TransactionConfirmationsAfterReorg 测试链重组后交易索引跟随新主链
+func TestBlockchain_TransactionConfirmationsAfterReorg(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	// extend 在 parent 之上依次添加 count 个区块，返回所有新区块
+	extend := func(parent *Block, label string, count int) []*Block {
+		var blocks []*Block
+		for i := 0; i < count; i++ {
+			block := NewBlock([]*Transaction{NewCoinbaseTX(address, fmt.Sprintf("%s %d", label, i))}, parent.Hash, parent.Height+1)
+			if err := bc.AddBlock(block); err != nil {
+				t.Fatalf("Failed to add %s block %d: %v", label, i, err)
+			}
+			blocks = append(blocks, block)
+			parent = block
+		}
+		return blocks
+	}
+	// confirmations 返回交易的确认数，交易不在主链上时返回 -1
+	confirmations := func(block *Block) int {
+		count, err := bc.GetTransactionConfirmations(block.Transactions[0].ID)
+		if err == ErrTransactionNotFound {
+			return -1
+		}
+		if err != nil {
+			t.Fatalf("Failed to get confirmations: %v", err)
+		}
+		return count
+	}
+
+	genesis, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	main := extend(&genesis, "main", 1)
+	fork := extend(&genesis, "fork", 2)
+	if string(bc.tip) != string(fork[1].Hash) {
+		t.Fatal("Expected the longer fork to become the tip")
+	}
+
+	// 新分支上的每个区块都被索引，被放弃的区块不再计入确认
+	if got := confirmations(fork[0]); got != 2 {
+		t.Errorf("Expected 2 confirmations for first fork block, got %d", got)
+	}
+	if got := confirmations(fork[1]); got != 1 {
+		t.Errorf("Expected 1 confirmation for fork tip, got %d", got)
+	}
+	if got := confirmations(main[0]); got != -1 {
+		t.Errorf("Expected abandoned transaction to be unconfirmed, got %d confirmations", got)
+	}
+
+	// 原分支重新成为主链后索引随之切回
+	main = append(main, extend(main[0], "main again", 2)...)
+	if got := confirmations(main[0]); got != 3 {
+		t.Errorf("Expected 3 confirmations after switching back, got %d", got)
+	}
+	if got := confirmations(fork[0]); got != -1 {
+		t.Errorf("Expected fork transaction to be unconfirmed after switching back, got %d confirmations", got)
+	}
+	if _, _, ok := bc.findConfirmedTransaction(fork[1].Transactions[0].ID); ok {
+		t.Error("Expected abandoned fork transaction to be missing from the index")
+	}
+}
+
+// TestBlockchainIterator_Height 测试迭代器高度随遍历递减
+func TestBlockchainIterator_Height(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, "block 1")})
+	mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, "block 2")})
+
+	iterator := bc.Iterator()
+	if iterator.Height() != -1 {
+		t.Errorf("Expected height -1 before iteration, got %d", iterator.Height())
+	}
+
+	expected := bc.GetBestHeight()
+	for {
+		block := iterator.Next()
+
+		if iterator.Height() != expected {
+			t.Errorf("Expected height %d, got %d", expected, iterator.Height())
+		}
+		if iterator.Height() != block.Height {
+			t.Errorf("Iterator height %d does not match block height %d", iterator.Height(), block.Height)
+		}
+		expected--
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	if expected != -1 {
+		t.Errorf("Expected to walk down to genesis, stopped at height %d", expected+1)
+	}
+}
+
+// TestBlock_String 测试区块的可读表示
+func TestBlock_String(t *testing.T) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "string")}, []byte{}, 7)
+
+	output := block.String()
+
+	if !strings.Contains(output, "Height:       7") {
+		t.Errorf("Expected output to contain block height, got:\n%s", output)
+	}
+	if !strings.Contains(output, fmt.Sprintf("%x", block.Hash)) {
+		t.Errorf("Expected output to contain block hash, got:\n%s", output)
+	}
+	if !strings.Contains(output, "coinbase") {
+		t.Errorf("Expected output to summarize coinbase transaction, got:\n%s", output)
+	}
+}
+
+// TestProofOfWork_Difficulty 测试更高的目标位数得到成比例的难度值
+func TestProofOfWork_Difficulty(t *testing.T) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+
+	base := NewProofOfWork(NewBlock([]*Transaction{NewCoinbaseTX(address, "base")}, []byte{}, 0))
+	if base.Difficulty() != 1 {
+		t.Errorf("Expected default difficulty 1, got %f", base.Difficulty())
+	}
+
+	harder := NewBlockWithTargetBits([]*Transaction{NewCoinbaseTX(address, "harder")}, []byte{}, 0, targetBits+4)
+	pow := NewProofOfWork(harder)
+	if !pow.Validate() {
+		t.Fatal("Expected block mined at higher target bits to validate")
+	}
+	if pow.TargetBits() != targetBits+4 {
+		t.Errorf("Expected target bits %d, got %d", targetBits+4, pow.TargetBits())
+	}
+	if pow.Difficulty() != 16*base.Difficulty() {
+		t.Errorf("Expected difficulty %f, got %f", 16*base.Difficulty(), pow.Difficulty())
+	}
+}
+
+// TestProofOfWork_CommitsHeight 测试新版本区块挖矿后修改高度会使工作量证明失效，旧版本区块仍然有效
+func TestProofOfWork_CommitsHeight(t *testing.T) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "commit")}, []byte("parent"), 5)
+	if block.Version != CurrentBlockVersion {
+		t.Fatalf("Expected new block version %d, got %d", CurrentBlockVersion, block.Version)
+	}
+	if !NewProofOfWork(block).Validate() {
+		t.Fatal("Expected mined block to be valid")
+	}
+
+	// 目标位数很低时篡改后的哈希也可能满足难度，因此比较哈希本身
+	tampered := *block
+	tampered.Height++
+	if bytes.Equal(NewProofOfWork(&tampered).Hash(tampered.Nonce), block.Hash) {
+		t.Error("Expected changing height after mining to invalidate the proof of work")
+	}
+	tampered = *block
+	tampered.Version = LegacyBlockVersion
+	if bytes.Equal(NewProofOfWork(&tampered).Hash(tampered.Nonce), block.Hash) {
+		t.Error("Expected downgrading version after mining to invalidate the proof of work")
+	}
+
+	// 旧区块的工作量证明数据不包含高度，升级后仍然有效
+	legacy := &Block{
+		Timestamp:     block.Timestamp,
+		Transactions:  block.Transactions,
+		PrevBlockHash: block.PrevBlockHash,
+		Height:        5,
+		TargetBits:    targetBits,
+	}
+	legacy.Nonce, legacy.Hash = NewProofOfWork(legacy).Run()
+	if !NewProofOfWork(legacy).Validate() {
+		t.Error("Expected legacy block to remain valid")
+	}
+	legacy.Height++
+	if !bytes.Equal(NewProofOfWork(legacy).Hash(legacy.Nonce), legacy.Hash) {
+		t.Error("Expected legacy block proof of work not to cover height")
+	}
+}
+
+// TestBlockchain_MinBlockVersion 测试版本化区块之后不能接旧版本区块，旧链上的旧版本区块仍然有效
+func TestBlockchain_MinBlockVersion(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	// legacyBlock 挖出以 parent 为父区块的旧版本区块
+	legacyBlock := func(parent []byte, height int, data string) *Block {
+		block := NewBlock([]*Transaction{NewCoinbaseTX(address, data)}, parent, height)
+		block.Version = LegacyBlockVersion
+		block.Nonce, block.Hash = NewProofOfWork(block).Run()
+		return block
+	}
+
+	legacy := legacyBlock(bc.tip, 1, "legacy")
+	if err := bc.ValidateBlock(legacy); !errors.Is(err, ErrInvalidBlock) || !strings.Contains(err.Error(), "version") {
+		t.Fatalf("Expected a legacy block after a versioned parent to be rejected, got %v", err)
+	}
+
+	// 旧链上已有的旧版本区块之后仍可接旧版本区块
+	if err := bc.AddBlock(legacy); err != nil {
+		t.Fatalf("Failed to add legacy block: %v", err)
+	}
+	if err := bc.ValidateBlock(legacyBlock(legacy.Hash, 2, "legacy child")); err != nil {
+		t.Errorf("Expected a legacy block on a legacy chain to be valid, got %v", err)
+	}
+	if err := bc.ValidateBlock(NewBlock([]*Transaction{NewCoinbaseTX(address, "upgrade")}, legacy.Hash, 2)); err != nil {
+		t.Errorf("Expected a versioned block on a legacy chain to be valid, got %v", err)
+	}
+}
+
+// TestProofOfWork_HashAlgorithm 测试按区块记录的哈希算法挖矿和验证
+func TestProofOfWork_HashAlgorithm(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	for _, algorithm := range []string{"", HashDoubleSHA256} {
+		block := NewBlockWithHashAlgorithm([]*Transaction{NewCoinbaseTX(address, "pow "+algorithm)}, bc.tip, bc.GetBestHeight()+1, targetBits, algorithm)
+		if !NewProofOfWork(block).Validate() {
+			t.Fatalf("Expected block mined with %q to validate", algorithm)
+		}
+		if err := bc.ValidateBlock(block); err != nil {
+			t.Fatalf("Expected block mined with %q to pass validation, got %v", algorithm, err)
+		}
+
+		// 换用另一种算法验证同一区块必须失败
+		other := *block
+		other.HashAlgorithm = HashDoubleSHA256
+		if algorithm == HashDoubleSHA256 {
+			other.HashAlgorithm = HashSHA256
+		}
+		if err := bc.ValidateBlock(&other); !errors.Is(err, ErrInvalidBlock) {
+			t.Errorf("Expected block mined with %q to fail under %s, got %v", algorithm, other.HashAlgorithm, err)
+		}
+	}
+
+	unknown := NewBlock([]*Transaction{NewCoinbaseTX(address, "unknown")}, bc.tip, bc.GetBestHeight()+1)
+	unknown.HashAlgorithm = "unknown"
+	if NewProofOfWork(unknown).Validate() {
+		t.Error("Expected block with unknown hash algorithm to fail validation")
+	}
+}
+
+// TestBlockchain_AddBlockDuplicate 测试重复添加区块不会改变链状态
+func TestBlockchain_AddBlockDuplicate(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "duplicate")}, bc.tip, bc.GetBestHeight()+1)
+
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	height := bc.GetBestHeight()
+	tip := string(bc.tip)
+
+	if err := bc.AddBlock(block); err != ErrBlockExists {
+		t.Fatalf("Expected ErrBlockExists, got %v", err)
+	}
+
+	if bc.GetBestHeight() != height {
+		t.Errorf("Expected height %d after duplicate add, got %d", height, bc.GetBestHeight())
+	}
+	if string(bc.tip) != tip {
+		t.Error("Tip should not change after duplicate add")
+	}
+}
+
+// TestBlockchain_AddBlockConcurrentDuplicate 测试并发投递同一区块只会应用一次
+func TestBlockchain_AddBlockConcurrentDuplicate(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	other := PubKeyHashToAddress(make([]byte, 20))
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	coinbase := genesis.Transactions[0]
+
+	spend := &Transaction{
+		Vin:  []TXInput{{Txid: coinbase.ID, Vout: 0, ScriptSig: address}},
+		Vout: []TXOutput{newTestOutput(40, other), newTestOutput(coinbase.Vout[0].Value-40, address)},
+	}
+	spend.ID = spend.Hash()
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "concurrent"), spend}, bc.tip, 1)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- bc.AddBlock(block)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	added := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			added++
+		case ErrBlockExists:
+		default:
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if added != 1 {
+		t.Fatalf("Expected exactly one successful add, got %d", added)
+	}
+
+	balances := UTXOSet{bc}.GetBalances([]string{address, other})
+	if balances[other] != 40 {
+		t.Errorf("Expected balance 40 for %s, got %d", other, balances[other])
+	}
+	if want := coinbase.Vout[0].Value - 40 + 100; balances[address] != want {
+		t.Errorf("Expected balance %d for %s, got %d", want, address, balances[address])
+	}
+}
+
+// newBalanceTestChain 创建向多个地址发放挖矿奖励的测试链
+func newBalanceTestChain(tb testing.TB, count int) (*Blockchain, []string) {
+	setupTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+
+	addresses := []string{address}
+	for i := 1; i < count; i++ {
+		addr := PubKeyHashToAddress(HashPubKey([]byte(fmt.Sprintf("balance_key_%d", i))))
+		mustMineBlock(tb, bc, []*Transaction{NewCoinbaseTX(addr, "")})
+		addresses = append(addresses, addr)
+	}
+	UTXOSet{Blockchain: bc}.Reindex()
+
+	tb.Cleanup(func() {
+		bc.DB.Close()
+		teardownTestEnvironment()
+	})
+
+	return bc, addresses
+}
+
+// TestUTXOSet_GetBalances 测试批量余额与逐个查询结果一致
+func TestUTXOSet_GetBalances(t *testing.T) {
+	bc, addresses := newBalanceTestChain(t, 5)
+	utxoSet := UTXOSet{Blockchain: bc}
+
+	// 重复地址和无效地址也应被正确处理
+	query := append(addresses, addresses[1], "invalid")
+	balances := utxoSet.GetBalances(query)
+
+	for _, address := range addresses {
+		expected := 0
+		for _, out := range utxoSet.FindUTXO(AddressToPubKeyHash(address)) {
+			expected += out.Value
+		}
+
+		if balances[address] != expected {
+			t.Errorf("Balance mismatch for %s: expected %d, got %d", address, expected, balances[address])
+		}
+	}
+
+	if balances["invalid"] != 0 {
+		t.Errorf("Expected zero balance for invalid address, got %d", balances["invalid"])
+	}
+}
+
+// TestUTXOSet_FindSpendableOutputsMulti 测试从两个密钥的输出中凑足一笔支付
+func TestUTXOSet_FindSpendableOutputsMulti(t *testing.T) {
+	bc, addresses := newBalanceTestChain(t, 2)
+	utxoSet := UTXOSet{Blockchain: bc}
+
+	keyA := AddressToPubKeyHash(addresses[0])
+	keyB := AddressToPubKeyHash(addresses[1])
+	balances := utxoSet.GetBalances(addresses)
+	balanceA, balanceB := balances[addresses[0]], balances[addresses[1]]
+
+	// 金额超过任一密钥的余额，必须组合两个密钥的输出
+	amount := balanceA + 1
+	if amount > balanceA+balanceB || amount <= balanceB {
+		t.Fatalf("Unexpected test balances %d and %d", balanceA, balanceB)
+	}
+
+	accumulated, outputs := utxoSet.FindSpendableOutputsMulti([][]byte{keyA, keyB, keyA}, amount)
+	if accumulated < amount {
+		t.Fatalf("Expected at least %d accumulated, got %d", amount, accumulated)
+	}
+
+	total := 0
+	used := make(map[string]bool)
+	for _, output := range outputs {
+		total += output.Value
+
+		txID, err := hex.DecodeString(output.TxID)
+		if err != nil {
+			t.Fatalf("Invalid transaction ID %s: %v", output.TxID, err)
+		}
+		tx, err := bc.FindTransaction(txID)
+		if err != nil {
+			t.Fatalf("Selected output references unknown transaction %s: %v", output.TxID, err)
+		}
+		if !tx.Vout[output.OutIdx].IsLockedWithKey(output.PubKeyHash) {
+			t.Errorf("Output %s:%d is not locked with its reported key", output.TxID, output.OutIdx)
+		}
+		used[string(output.PubKeyHash)] = true
+	}
+	if total != accumulated {
+		t.Errorf("Expected selected outputs to sum to %d, got %d", accumulated, total)
+	}
+	if !used[string(keyA)] || !used[string(keyB)] {
+		t.Error("Expected outputs from both keys to be selected")
+	}
+
+	// 余额不足时返回全部可用输出
+	accumulated, _ = utxoSet.FindSpendableOutputsMulti([][]byte{keyA, keyB}, balanceA+balanceB+1)
+	if accumulated != balanceA+balanceB {
+		t.Errorf("Expected %d accumulated when funds are insufficient, got %d", balanceA+balanceB, accumulated)
+	}
+}
+
+// BenchmarkUTXOSet_GetBalances 对比逐个查询与单次遍历的余额统计
+func BenchmarkUTXOSet_GetBalances(b *testing.B) {
+	bc, addresses := newBalanceTestChain(b, 50)
+	utxoSet := UTXOSet{Blockchain: bc}
+
+	b.Run("PerAddress", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, address := range addresses {
+				balance := 0
+				for _, out := range utxoSet.FindUTXO(AddressToPubKeyHash(address)) {
+					balance += out.Value
+				}
+			}
+		}
+	})
+
+	b.Run("SinglePass", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			utxoSet.GetBalances(addresses)
+		}
+	})
+}
+
+// TestBlockchain_GetChainTips 测试分叉情况下的链尖查询
+func TestBlockchain_GetChainTips(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis := bc.tip
+
+	// 主链: genesis -> A1 -> A2
+	mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, "A1")})
+	active := mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, "A2")})
+
+	// 分叉: genesis -> B1
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(address, "B1")}, genesis, 1)
+	if err := bc.AddBlock(fork); err != nil {
+		t.Fatalf("Failed to add fork block: %v", err)
+	}
+
+	// 孤儿: 父区块未知
+	orphan := NewBlock([]*Transaction{NewCoinbaseTX(address, "orphan")}, []byte("unknown parent"), 1)
+	if err := bc.AddBlock(orphan); err != nil {
+		t.Fatalf("Failed to add orphan block: %v", err)
+	}
+
+	tips := bc.GetChainTips()
+	if len(tips) != 3 {
+		t.Fatalf("Expected 3 chain tips, got %d", len(tips))
+	}
+
+	expected := map[string]ChainTip{
+		string(active.Hash): {Height: 2, Status: ChainTipActive},
+		string(fork.Hash):   {Height: 1, Status: ChainTipValidFork},
+		string(orphan.Hash): {Height: 1, Status: ChainTipOrphan},
+	}
+	for _, tip := range tips {
+		want, ok := expected[string(tip.Hash)]
+		if !ok {
+			t.Errorf("Unexpected chain tip %x", tip.Hash)
+			continue
+		}
+		if tip.Height != want.Height || tip.Status != want.Status {
+			t.Errorf("Tip %x: expected height %d status %s, got height %d status %s",
+				tip.Hash, want.Height, want.Status, tip.Height, tip.Status)
+		}
+	}
+
+	if tips[0].Status != ChainTipActive {
+		t.Errorf("Expected highest tip to be active, got %s", tips[0].Status)
+	}
+}
+
+// TestNewBlockchainWithOptions 测试只读模式与文件锁超时
+func TestNewBlockchainWithOptions(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	NewBlockchain(address, testNodeID).DB.Close()
+
+	t.Run("ReadOnlyShared", func(t *testing.T) {
+		first, err := NewBlockchainWithOptions("", testNodeID, &Options{ReadOnly: true, Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("Failed to open read-only blockchain: %v", err)
+		}
+		defer first.DB.Close()
+
+		second, err := NewBlockchainWithOptions("", testNodeID, &Options{ReadOnly: true, Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("Second read-only handle should not block: %v", err)
+		}
+		defer second.DB.Close()
+
+		if second.GetBestHeight() != 0 {
+			t.Errorf("Expected best height 0, got %d", second.GetBestHeight())
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		writer := NewBlockchain("", testNodeID)
+		defer writer.DB.Close()
+
+		start := time.Now()
+		_, err := NewBlockchainWithOptions("", testNodeID, &Options{ReadOnly: true, Timeout: 100 * time.Millisecond})
+		if err == nil {
+			t.Fatal("Expected timeout error while the database is locked for writing")
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("Open should time out promptly, took %v", time.Since(start))
+		}
+	})
+
+	t.Run("ReadOnlyMissing", func(t *testing.T) {
+		if _, err := NewBlockchainWithOptions("", "missing_node", &Options{ReadOnly: true}); err == nil {
+			t.Error("Expected error opening a missing blockchain read-only")
+		}
+	})
+}
+
+// testTxSource 可提供候选交易的测试内存池
+type testTxSource []*Transaction
+
+// HasTransaction 检查交易是否在测试内存池中
+func (s testTxSource) HasTransaction(txID []byte) bool {
+	for _, tx := range s {
+		if string(tx.ID) == string(txID) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPrioritizedTransactions 按加入顺序返回测试内存池中的交易
+func (s testTxSource) GetPrioritizedTransactions() []*Transaction {
+	return s
+}
+
+// TestBlockchain_GetBlockTemplate 测试构建区块模板、外部挖矿并提交
+func TestBlockchain_GetBlockTemplate(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, address := newTestKey(t)
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	coinbase := genesis.Transactions[0]
+
+	spend := func(value int) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: coinbase.ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{newTestOutput(value, address)},
+		}
+		tx.ID = tx.Hash()
+		bc.SignTransaction(tx, *privKey)
+		return tx
+	}
+	valid, higherFee := spend(90), spend(80)
+	bc.SetMempool(testTxSource{valid, higherFee, spend(1000)})
+	bc.SetBlockSubsidy(50)
+
+	if _, err := bc.GetBlockTemplate("invalid"); err == nil {
+		t.Error("Expected invalid miner address to be rejected")
+	}
+
+	template, err := bc.GetBlockTemplate(address)
+	if err != nil {
+		t.Fatalf("Failed to build block template: %v", err)
+	}
+	if template.Height != 1 || string(template.PrevBlockHash) != string(genesis.Hash) {
+		t.Errorf("Expected template on top of genesis, got height %d prev %x", template.Height, template.PrevBlockHash)
+	}
+	// 冲突交易和超额花费的交易不应被选入
+	if len(template.Transactions) != 2 || !template.Transactions[0].IsCoinbase() ||
+		string(template.Transactions[1].ID) != string(valid.ID) {
+		t.Fatalf("Expected coinbase and one valid transaction, got %d transactions", len(template.Transactions))
+	}
+	if reward := template.Transactions[0].Vout[0].Value; reward != bc.BlockSubsidy() {
+		t.Errorf("Expected template coinbase to pay the configured subsidy %d, got %d", bc.BlockSubsidy(), reward)
+	}
+
+	// 手续费低于共识下限的交易不应被选入，让位给满足下限的冲突交易
+	bc.SetMinBlockTxFee(subsidy - 90 + 1)
+	filtered, err := bc.GetBlockTemplate(address)
+	if err != nil {
+		t.Fatalf("Failed to build block template: %v", err)
+	}
+	if len(filtered.Transactions) != 2 || string(filtered.Transactions[1].ID) != string(higherFee.ID) {
+		t.Errorf("Expected only the transaction meeting the minimum fee, got %d transactions", len(filtered.Transactions))
+	}
+	bc.SetMinBlockTxFee(0)
+
+	// 外部矿工完成工作量证明
+	block := template.Block()
+	if string(block.HashTransactions()) != string(template.MerkleRoot) {
+		t.Error("Template merkle root does not match its transactions")
+	}
+	if err := bc.SubmitBlock(block); err == nil {
+		t.Error("Expected unmined block to be rejected")
+	}
+	block.Nonce, block.Hash = NewProofOfWork(block).Run()
+
+	if err := bc.SubmitBlock(block); err != nil {
+		t.Fatalf("Failed to submit mined block: %v", err)
+	}
+	if bc.GetBestHeight() != 1 || string(bc.tip) != string(block.Hash) {
+		t.Error("Expected submitted block to become the chain tip")
+	}
+}
+
+// TestBlockchain_MaxReorgDepth 测试超过最大深度的链重组被拒绝
+func TestBlockchain_MaxReorgDepth(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+	bc.SetMaxReorgDepth(2)
+
+	// extend 在 parent 之上依次添加 count 个区块，返回所有新区块
+	extend := func(parent *Block, label string, count int) []*Block {
+		var blocks []*Block
+		for i := 0; i < count; i++ {
+			block := NewBlock([]*Transaction{NewCoinbaseTX(address, fmt.Sprintf("%s %d", label, i))}, parent.Hash, parent.Height+1)
+			if err := bc.AddBlock(block); err != nil {
+				t.Fatalf("Failed to add %s block %d: %v", label, i, err)
+			}
+			blocks = append(blocks, block)
+			parent = block
+		}
+		return blocks
+	}
+
+	genesis, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	main := extend(&genesis, "main", 3)
+
+	// 从创世区块分叉需要回滚 3 个区块，超过上限
+	deep := extend(&genesis, "deep", 3)
+	deepTip := deep[len(deep)-1]
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "deep tip")}, deepTip.Hash, deepTip.Height+1)
+	if err := bc.AddBlock(block); err != ErrReorgTooDeep {
+		t.Fatalf("Expected ErrReorgTooDeep, got %v", err)
+	}
+	if string(bc.tip) != string(main[2].Hash) {
+		t.Fatal("Tip should not change after a rejected reorganization")
+	}
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		t.Error("Rejected block should not be stored")
+	}
+
+	// 父区块未知的区块不是过深的重组，只保存为孤儿区块且不切换链尖
+	orphan := NewBlock([]*Transaction{NewCoinbaseTX(address, "orphan")}, []byte("unknown parent"), main[2].Height+10)
+	if err := bc.AddBlock(orphan); err != nil {
+		t.Fatalf("Expected orphan block to be stored, got %v", err)
+	}
+	if string(bc.tip) != string(main[2].Hash) {
+		t.Fatal("Tip should not change after an orphan block")
+	}
+	if _, err := bc.GetBlock(orphan.Hash); err != nil {
+		t.Errorf("Orphan block should be stored: %v", err)
+	}
+
+	// 从高度 1 分叉只需回滚 2 个区块
+	shallow := extend(main[0], "shallow", 3)
+	if string(bc.tip) != string(shallow[2].Hash) {
+		t.Error("Expected shallow reorganization to switch the tip")
+	}
+}
+
+// TestBlockchain_AddBlockOutOfOrder 测试按链尖在前的顺序添加区块时，最早的区块到达后整条链接入
+func TestBlockchain_AddBlockOutOfOrder(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+
+	var blocks []*Block
+	parent := &genesis
+	for i := 0; i < 3; i++ {
+		block := NewBlock([]*Transaction{NewCoinbaseTX(address, fmt.Sprintf("block %d", i))}, parent.Hash, parent.Height+1)
+		blocks = append(blocks, block)
+		parent = block
+	}
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if err := bc.AddBlock(blocks[i]); err != nil {
+			t.Fatalf("Failed to add block %d: %v", blocks[i].Height, err)
+		}
+	}
+
+	if height := bc.GetBestHeight(); height != 3 {
+		t.Fatalf("Expected best height 3, got %d", height)
+	}
+	if string(bc.tip) != string(blocks[2].Hash) {
+		t.Error("Expected the highest connected block to become the tip")
+	}
+	if count := (UTXOSet{bc}).CountTransactions(); count != 4 {
+		t.Errorf("Expected UTXO set to hold 4 coinbase transactions, got %d", count)
+	}
+
+	// 孤儿索引在接入后清空，之后的区块正常延长链尖
+	next := NewBlock([]*Transaction{NewCoinbaseTX(address, "next")}, blocks[2].Hash, blocks[2].Height+1)
+	if err := bc.AddBlock(next); err != nil {
+		t.Fatalf("Failed to extend connected chain: %v", err)
+	}
+	if height := bc.GetBestHeight(); height != 4 {
+		t.Errorf("Expected best height 4, got %d", height)
+	}
+}
+
+// TestBlockchain_TotalSupply 测试已发行币量等于各高度奖励之和
+func TestBlockchain_TotalSupply(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	if bc.TotalSupply() != subsidy {
+		t.Errorf("Expected genesis supply %d, got %d", subsidy, bc.TotalSupply())
+	}
+
+	const blocks = 5
+	for i := 1; i <= blocks; i++ {
+		mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, fmt.Sprintf("supply %d", i))})
+	}
+
+	expected := 0
+	for height := 0; height <= blocks; height++ {
+		expected += subsidy
+	}
+	if bc.TotalSupply() != expected {
+		t.Errorf("Expected supply %d after %d blocks, got %d", expected, blocks, bc.TotalSupply())
+	}
+}
+
+// TestBlockchain_StructuredErrors 测试各类失败返回可用 errors.Is 匹配的错误
+func TestBlockchain_StructuredErrors(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	other := PubKeyHashToAddress(make([]byte, 20))
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+	utxoSet := UTXOSet{bc}
+
+	if _, err := bc.GetBlock([]byte("missing")); !errors.Is(err, ErrBlockNotFound) {
+		t.Errorf("Expected ErrBlockNotFound, got %v", err)
+	}
+	if _, err := bc.FindTransaction([]byte("missing")); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("Expected ErrTransactionNotFound, got %v", err)
+	}
+
+	if _, err := NewUTXOTransaction(address, other, 1000, &utxoSet); !errors.Is(err, ErrNotEnoughFunds) {
+		t.Errorf("Expected ErrNotEnoughFunds, got %v", err)
+	}
+	if _, err := NewUTXOTransaction(address, other, 0, &utxoSet); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount, got %v", err)
+	}
+	if _, err := NewUTXOTransaction(address, "invalid", 10, &utxoSet); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+	if _, err := NewUTXOTransaction(address, other, 10, &utxoSet); err != nil {
+		t.Errorf("Expected funded transaction to succeed, got %v", err)
+	}
+
+	if err := bc.AddBlock(&Block{}); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Expected ErrInvalidBlock from AddBlock, got %v", err)
+	}
+
+	// 同一区块内两次花费创世区块的 coinbase 输出
+	genesis, _ := bc.GetBlock(bc.tip)
+	spend := func(value int) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{newTestOutput(value, other)},
+		}
+		tx.ID = tx.Hash()
+		return tx
+	}
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "double spend"), spend(10), spend(20)}, bc.tip, 1)
+	err := bc.ValidateBlock(block)
+	if !errors.Is(err, ErrInvalidBlock) || !errors.Is(err, ErrDoubleSpend) {
+		t.Errorf("Expected ErrInvalidBlock and ErrDoubleSpend, got %v", err)
+	}
+}
+
+// TestBlockchain_RespendAcrossBlocks 测试再次花费之前区块已花费的输出会被拒绝，且不会导致 AddBlock panic
+func TestBlockchain_RespendAcrossBlocks(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, address := newTestKey(t)
+	other := PubKeyHashToAddress(HashPubKey([]byte("respend_other")))
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+	utxoSet := UTXOSet{Blockchain: bc}
+	utxoSet.Reindex()
+
+	genesis, _ := bc.GetBlock(bc.tip)
+	spend := func(value int) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{newTestOutput(value, other)},
+		}
+		tx.ID = tx.Hash()
+		bc.SignTransaction(tx, *privKey)
+		return tx
+	}
+
+	first := NewBlock([]*Transaction{NewCoinbaseTX(address, "first spend"), spend(10)}, bc.tip, 1)
+	if err := bc.AddBlock(first); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	respend := spend(20)
+	if err := bc.ValidateTransaction(respend); !errors.Is(err, ErrDoubleSpend) {
+		t.Errorf("Expected ErrDoubleSpend for a re-spent output, got %v", err)
+	}
+	if _, err := bc.MineBlock([]*Transaction{respend}, ""); !errors.Is(err, ErrDoubleSpend) {
+		t.Errorf("Expected MineBlock to reject the re-spend, got %v", err)
+	}
+
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "respend"), respend}, bc.tip, 2)
+	if err := bc.ValidateBlock(block); !errors.Is(err, ErrInvalidBlock) || !errors.Is(err, ErrDoubleSpend) {
+		t.Errorf("Expected ErrInvalidBlock and ErrDoubleSpend, got %v", err)
+	}
+	// 未经验证直接加入时返回错误而不是 panic，链尖保持不变
+	if err := bc.AddBlock(block); !errors.Is(err, ErrDoubleSpend) {
+		t.Errorf("Expected AddBlock to fail with ErrDoubleSpend, got %v", err)
+	}
+	if !bytes.Equal(bc.tip, first.Hash) {
+		t.Errorf("Expected tip to stay at %x, got %x", first.Hash, bc.tip)
+	}
+}
+
+// TestBlockchain_GetBlockHeader 测试区块头与完整区块一致
+func TestBlockchain_GetBlockHeader(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	block := mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, "header")})
+
+	header, err := bc.GetBlockHeader(block.Hash)
+	if err != nil {
+		t.Fatalf("Failed to get block header: %v", err)
+	}
+	if !bytes.Equal(header.MerkleRoot, block.HashTransactions()) {
+		t.Errorf("Expected merkle root %x, got %x", block.HashTransactions(), header.MerkleRoot)
+	}
+	if !bytes.Equal(header.PrevBlockHash, block.PrevBlockHash) {
+		t.Errorf("Expected prev hash %x, got %x", block.PrevBlockHash, header.PrevBlockHash)
+	}
+	if !bytes.Equal(header.Hash, block.Hash) || header.Height != block.Height || header.TxCount != len(block.Transactions) {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+
+	if _, err := bc.GetBlockHeader([]byte("missing")); !errors.Is(err, ErrBlockNotFound) {
+		t.Errorf("Expected ErrBlockNotFound, got %v", err)
+	}
+}
+
+// TestBlockchain_GetAddressHistory 测试地址交易记录按高度列出收入和支出
+func TestBlockchain_GetAddressHistory(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	addressKey, address := newTestKey(t)
+	otherKey, other := newTestKey(t)
+	keys := map[string]*ecdsa.PrivateKey{address: addressKey, other: otherKey}
+	miner := PubKeyHashToAddress(HashPubKey([]byte("history_miner")))
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+	utxoSet := UTXOSet{Blockchain: bc}
+
+	send := func(from, to string, amount int) *Transaction {
+		tx, err := NewUTXOTransaction(from, to, amount, &utxoSet)
+		if err != nil {
+			t.Fatalf("Failed to create transaction: %v", err)
+		}
+		bc.SignTransaction(tx, *keys[from])
+		utxoSet.Update(mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(miner, ""), tx}))
+		return tx
+	}
+	first := send(address, other, 30)
+	second := send(other, address, 10)
+
+	genesis, _ := bc.GetBlock(bc.GetBlockHashes()[len(bc.GetBlockHashes())-1])
+	expected := []TxHistoryEntry{
+		{genesis.Transactions[0].ID, HistoryReceived, subsidy, 0},
+		{first.ID, HistorySent, 30, 1},
+		{second.ID, HistoryReceived, 10, 2},
+	}
+
+	history, err := bc.GetAddressHistory(address)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(expected), len(history), history)
+	}
+	for i, entry := range history {
+		want := expected[i]
+		if !bytes.Equal(entry.TxID, want.TxID) || entry.Direction != want.Direction || entry.Amount != want.Amount || entry.Height != want.Height {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want, entry)
+		}
+	}
+
+	otherHistory, _ := bc.GetAddressHistory(other)
+	if len(otherHistory) != 2 || otherHistory[0].Direction != HistoryReceived || otherHistory[1].Direction != HistorySent {
+		t.Errorf("Unexpected history for %s: %+v", other, otherHistory)
+	}
+
+	if _, err := bc.GetAddressHistory("invalid"); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+}
+
+// TestBlockchain_MinBlockTxFee 测试共识最低手续费规则的开启与关闭
+func TestBlockchain_MinBlockTxFee(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, address := newTestKey(t)
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, _ := bc.GetBlock(bc.tip)
+	lowFee := &Transaction{
+		Vin:  []TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0, ScriptSig: address}},
+		Vout: []TXOutput{newTestOutput(subsidy-1, address)},
+	}
+	lowFee.ID = lowFee.Hash()
+	bc.SignTransaction(lowFee, *privKey)
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "min fee"), lowFee}, bc.tip, bc.GetBestHeight()+1)
+
+	// 默认不限制：矿工可以打包低手续费交易
+	if err := bc.ValidateBlock(block); err != nil {
+		t.Fatalf("Expected low-fee block to be valid without the rule, got %v", err)
+	}
+
+	bc.SetMinBlockTxFee(5)
+	if err := bc.ValidateBlock(block); !errors.Is(err, ErrInvalidBlock) || !strings.Contains(err.Error(), "consensus minimum") {
+		t.Errorf("Expected block below the consensus minimum fee to be rejected, got %v", err)
+	}
+
+	bc.SetMinBlockTxFee(1)
+	if err := bc.ValidateBlock(block); err != nil {
+		t.Errorf("Expected block meeting the minimum fee to be valid, got %v", err)
+	}
+
+	bc.SetMinBlockTxFee(0)
+	if err := bc.ValidateBlock(block); err != nil {
+		t.Errorf("Expected rule to be disabled, got %v", err)
+	}
+}
+
+// TestBlockchain_CoinbaseValue 测试 coinbase 输出总额不能超过区块奖励加手续费
+func TestBlockchain_CoinbaseValue(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, address := newTestKey(t)
+	dev := PubKeyHashToAddress(HashPubKey([]byte("coinbase_dev")))
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, _ := bc.GetBlock(bc.tip)
+	const fee = 3
+	spend := &Transaction{
+		Vin:  []TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0, ScriptSig: address}},
+		Vout: []TXOutput{newTestOutput(subsidy-fee, address)},
+	}
+	spend.ID = spend.Hash()
+	bc.SignTransaction(spend, *privKey)
+
+	newBlock := func(outputs map[string]int, txs ...*Transaction) *Block {
+		coinbase, err := NewCoinbaseTXMulti(outputs, "")
+		if err != nil {
+			t.Fatalf("Failed to create coinbase: %v", err)
+		}
+		return NewBlock(append([]*Transaction{coinbase}, txs...), bc.tip, bc.GetBestHeight()+1)
+	}
+
+	// 拆分后总额等于区块奖励
+	if err := bc.ValidateBlock(newBlock(map[string]int{address: subsidy - 20, dev: 20})); err != nil {
+		t.Errorf("Expected split coinbase within the subsidy to be valid, got %v", err)
+	}
+
+	// 领取区块内交易的手续费
+	if err := bc.ValidateBlock(newBlock(map[string]int{address: subsidy, dev: fee}, spend)); err != nil {
+		t.Errorf("Expected coinbase claiming the fees to be valid, got %v", err)
+	}
+
+	// 超出奖励加手续费
+	block := newBlock(map[string]int{address: subsidy, dev: fee + 1}, spend)
+	if err := bc.ValidateBlock(block); !errors.Is(err, ErrInvalidBlock) || !strings.Contains(err.Error(), "allowed reward") {
+		t.Errorf("Expected overpaying coinbase to be rejected, got %v", err)
+	}
+
+	// 领取的手续费来自已有的币，不计入已发行币量
+	claiming := newBlock(map[string]int{address: subsidy, dev: fee}, spend)
+	if err := bc.AddBlock(claiming); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if supply := bc.TotalSupply(); supply != 2*subsidy {
+		t.Errorf("Expected supply %d after claiming fees, got %d", 2*subsidy, supply)
+	}
+}
+
+// TestBlockchain_MineBlockBlockChecks 测试 MineBlock 与 ValidateBlock 执行相同的区块级检查
+func TestBlockchain_MineBlockBlockChecks(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, address := newTestKey(t)
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+	UTXOSet{Blockchain: bc}.Reindex()
+
+	genesis, _ := bc.GetBlock(bc.tip)
+	spend := func(value int) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{newTestOutput(value, address)},
+		}
+		tx.ID = tx.Hash()
+		bc.SignTransaction(tx, *privKey)
+		return tx
+	}
+	tip := bc.tip
+
+	// 区块内两笔交易花费同一个输出
+	if _, err := bc.MineBlock([]*Transaction{spend(subsidy - 1), spend(subsidy - 2)}, address); !errors.Is(err, ErrDoubleSpend) {
+		t.Errorf("Expected ErrDoubleSpend for a double spend inside the block, got %v", err)
+	}
+
+	// 低于共识最低手续费
+	bc.SetMinBlockTxFee(5)
+	if _, err := bc.MineBlock([]*Transaction{spend(subsidy - 1)}, address); err == nil || !strings.Contains(err.Error(), "consensus minimum") {
+		t.Errorf("Expected a transaction below the consensus minimum fee to be rejected, got %v", err)
+	}
+	bc.SetMinBlockTxFee(0)
+
+	// coinbase 超出区块奖励
+	overpaid := NewCoinbaseTXWithReward(address, "overpaid", bc.BlockSubsidy()+1)
+	if _, err := bc.MineBlock([]*Transaction{overpaid}, ""); err == nil || !strings.Contains(err.Error(), "allowed reward") {
+		t.Errorf("Expected an overpaying coinbase to be rejected, got %v", err)
+	}
+
+	if !bytes.Equal(bc.tip, tip) || bc.GetBestHeight() != 0 {
+		t.Error("Rejected blocks should not change the tip")
+	}
+	if _, err := bc.MineBlock([]*Transaction{spend(subsidy - 1)}, address); err != nil {
+		t.Errorf("Expected a valid block to be mined, got %v", err)
+	}
+}
+
+// TestNewMerkleTree_DoesNotMutateInput 测试奇数个数据构建默克尔树时不修改调用方的切片
+func TestNewMerkleTree_DoesNotMutateInput(t *testing.T) {
+	backing := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("spare")}
+	data := backing[:3]
+
+	root := NewMerkleTree(data).RootNode.Data
+
+	if len(data) != 3 {
+		t.Errorf("Expected input length 3, got %d", len(data))
+	}
+	if string(backing[3]) != "spare" {
+		t.Errorf("Expected spare capacity to be untouched, got %q", backing[3])
+	}
+
+	expected := NewMerkleTree([][]byte{[]byte("a"), []byte("b"), []byte("c")}).RootNode.Data
+	if !bytes.Equal(root, expected) {
+		t.Errorf("Expected root %x, got %x", expected, root)
+	}
+}
+
+// TestNewMerkleTree_EmptyAndSingle 测试零个和一个交易时的默克尔根
+func TestNewMerkleTree_EmptyAndSingle(t *testing.T) {
+	emptyHash := sha256.Sum256([]byte{})
+	if root := NewMerkleTree(nil).RootNode.Data; !bytes.Equal(root, emptyHash[:]) {
+		t.Errorf("Expected empty root %x, got %x", emptyHash, root)
+	}
+
+	block := &Block{}
+	if root := block.HashTransactions(); !bytes.Equal(root, emptyHash[:]) {
+		t.Errorf("Expected transaction-less block root %x, got %x", emptyHash, root)
+	}
+
+	// 单个交易会与自身配对
+	leaf := sha256.Sum256([]byte("only"))
+	expected := sha256.Sum256(append(leaf[:], leaf[:]...))
+	if root := NewMerkleTree([][]byte{[]byte("only")}).RootNode.Data; !bytes.Equal(root, expected[:]) {
+		t.Errorf("Expected single root %x, got %x", expected, root)
+	}
+}
+
+// TestBlockchain_GetRecentBlockHashes 测试获取最近的区块哈希
+func TestBlockchain_GetRecentBlockHashes(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	var mined [][]byte
+	for i := 0; i < 10; i++ {
+		block := mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, fmt.Sprintf("recent %d", i))})
+		mined = append(mined, block.Hash)
+	}
+
+	recent := bc.GetRecentBlockHashes(3)
+	if len(recent) != 3 {
+		t.Fatalf("Expected 3 hashes, got %d", len(recent))
+	}
+	for i, hash := range recent {
+		expected := mined[len(mined)-1-i]
+		if !bytes.Equal(hash, expected) {
+			t.Errorf("Hash %d: expected %x, got %x", i, expected, hash)
+		}
+	}
+
+	if all := bc.GetRecentBlockHashes(100); len(all) != 11 {
+		t.Errorf("Expected 11 hashes when n exceeds chain length, got %d", len(all))
+	}
+	if none := bc.GetRecentBlockHashes(0); len(none) != 0 {
+		t.Errorf("Expected no hashes for n=0, got %d", len(none))
+	}
+}
+
+// TestBlockchain_BlockLocator 测试区块定位器的构造与分叉点查找
+func TestBlockchain_BlockLocator(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	for i := 0; i < 15; i++ {
+		mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, fmt.Sprintf("locator %d", i))})
+	}
+
+	// 前 10 个哈希连续，之后间隔翻倍，最后是创世区块
+	all := bc.GetBlockHashes()
+	locator := bc.GetBlockLocator()
+	expectedIndexes := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 11, 15}
+	if len(locator) != len(expectedIndexes) {
+		t.Fatalf("Expected locator of %d hashes, got %d", len(expectedIndexes), len(locator))
+	}
+	for i, index := range expectedIndexes {
+		if !bytes.Equal(locator[i], all[index]) {
+			t.Errorf("Locator entry %d: expected block %d", i, index)
+		}
+	}
+
+	// 对端链从主链倒数第 3 个区块分叉出两个区块
+	forkPoint := all[2]
+	forkHeight := len(all) - 3
+	b1 := NewBlock([]*Transaction{NewCoinbaseTX(address, "fork 1")}, forkPoint, forkHeight+1)
+	if err := bc.AddBlock(b1); err != nil {
+		t.Fatalf("Failed to add fork block: %v", err)
+	}
+	b2 := NewBlock([]*Transaction{NewCoinbaseTX(address, "fork 2")}, b1.Hash, forkHeight+2)
+	if err := bc.AddBlock(b2); err != nil {
+		t.Fatalf("Failed to add fork block: %v", err)
+	}
+	if !bytes.Equal(bc.tip, all[0]) {
+		t.Fatal("Equal-height fork should not replace the tip")
+	}
+
+	peerLocator := blockLocator(bc.IteratorFrom(b2.Hash))
+	if fork := bc.FindForkPoint(peerLocator); !bytes.Equal(fork, forkPoint) {
+		t.Errorf("Expected fork point %x, got %x", forkPoint, fork)
+	}
+	after := bc.GetBlockHashesAfterLocator(peerLocator)
+	if len(after) != 2 || !bytes.Equal(after[0], all[0]) || !bytes.Equal(after[1], all[1]) {
+		t.Errorf("Expected the two main-chain blocks after the fork point, got %d hashes", len(after))
+	}
+
+	// 对端与本地链完全一致时无需返回区块
+	if after := bc.GetBlockHashesAfterLocator(locator); len(after) != 0 {
+		t.Errorf("Expected no hashes for an identical chain, got %d", len(after))
+	}
+
+	// 没有共同区块时返回整条链
+	if fork := bc.FindForkPoint([][]byte{[]byte("unknown")}); fork != nil {
+		t.Errorf("Expected no fork point, got %x", fork)
+	}
+	if after := bc.GetBlockHashesAfterLocator([][]byte{[]byte("unknown")}); len(after) != len(all) {
+		t.Errorf("Expected full chain for unknown locator, got %d hashes", len(after))
+	}
+}
+
+// TestBlockchain_GetBlockTransactions 测试根据区块哈希获取区块内的交易
+func TestBlockchain_GetBlockTransactions(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	addressKey, address := newTestKey(t)
+	otherKey, other := newTestKey(t)
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+	utxoSet := UTXOSet{Blockchain: bc}
+
+	utxoSet.Update(mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(other, "")}))
+
+	first, err := NewUTXOTransaction(address, other, 30, &utxoSet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	bc.SignTransaction(first, *addressKey)
+	second, err := NewUTXOTransaction(other, address, 10, &utxoSet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	bc.SignTransaction(second, *otherKey)
+	coinbase := NewCoinbaseTX(address, "block txs")
+	block := mustMineBlock(t, bc, []*Transaction{coinbase, first, second})
+
+	txs, err := bc.GetBlockTransactions(block.Hash)
+	if err != nil {
+		t.Fatalf("Failed to get block transactions: %v", err)
+	}
+	expected := []*Transaction{coinbase, first, second}
+	if len(txs) != len(expected) {
+		t.Fatalf("Expected %d transactions, got %d", len(expected), len(txs))
+	}
+	for i, tx := range txs {
+		if !bytes.Equal(tx.ID, expected[i].ID) {
+			t.Errorf("Transaction %d: expected %x, got %x", i, expected[i].ID, tx.ID)
+		}
+	}
+
+	if _, err := bc.GetBlockTransactions([]byte("missing")); !errors.Is(err, ErrBlockNotFound) {
+		t.Errorf("Expected ErrBlockNotFound, got %v", err)
+	}
+}
+
+// TestBlockchain_GenesisPremine 测试创世预挖量与区块补贴分别生效
+func TestBlockchain_GenesisPremine(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	miner := PubKeyHashToAddress(HashPubKey([]byte("premine_miner")))
+	const premine = 1000000
+	const blockSubsidy = 50
+
+	bc, err := NewBlockchainWithOptions(address, testNodeID, &Options{Genesis: &GenesisConfig{Reward: premine}})
+	if err != nil {
+		t.Fatalf("Failed to create blockchain: %v", err)
+	}
+	defer bc.DB.Close()
+	bc.SetBlockSubsidy(blockSubsidy)
+
+	genesis, _ := bc.GetBlock(bc.tip)
+	if value := genesis.Transactions[0].Vout[0].Value; value != premine {
+		t.Errorf("Expected genesis reward %d, got %d", premine, value)
+	}
+	if genesis.Transactions[0].Vin[0].ScriptSig != genesisCoinbaseData {
+		t.Errorf("Expected default genesis data, got %q", genesis.Transactions[0].Vin[0].ScriptSig)
+	}
+
+	block, err := bc.MineBlock(nil, miner)
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+	if value := block.Transactions[0].Vout[0].Value; value != blockSubsidy {
+		t.Errorf("Expected block subsidy %d, got %d", blockSubsidy, value)
+	}
+	if supply := bc.TotalSupply(); supply != premine+blockSubsidy {
+		t.Errorf("Expected supply %d, got %d", premine+blockSubsidy, supply)
+	}
+
+	utxoSet := UTXOSet{Blockchain: bc}
+	utxoSet.Reindex()
+	balances := utxoSet.GetBalances([]string{address, miner})
+	if balances[address] != premine || balances[miner] != blockSubsidy {
+		t.Errorf("Unexpected balances: %v", balances)
+	}
+
+	// 区块补贴按配置验证，默认补贴超出允许的奖励
+	overpaid := NewBlock([]*Transaction{NewCoinbaseTX(miner, "default subsidy")}, bc.tip, bc.GetBestHeight()+1)
+	if err := bc.ValidateBlock(overpaid); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Expected coinbase above the configured subsidy to be rejected, got %v", err)
+	}
+	if err := bc.ValidateBlock(NewBlock([]*Transaction{NewCoinbaseTXWithReward(miner, "configured subsidy", blockSubsidy)}, bc.tip, bc.GetBestHeight()+1)); err != nil {
+		t.Errorf("Expected coinbase at the configured subsidy to be valid, got %v", err)
+	}
+
+	bc.SetBlockSubsidy(0)
+	if bc.BlockSubsidy() != subsidy {
+		t.Errorf("Expected default subsidy %d, got %d", subsidy, bc.BlockSubsidy())
+	}
+}
+
+// TestNewBlockchain_MissingBlocksBucket 测试打开缺少区块数据的已有数据库时返回错误而不是 panic
+func TestNewBlockchain_MissingBlocksBucket(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	dbFile := fmt.Sprintf("blockchain_%s.db", testNodeID)
+	db, err := bbolt.Open(dbFile, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte(utxoBucket))
+		return err
+	}); err != nil {
+		t.Fatalf("Failed to create bucket: %v", err)
+	}
+	db.Close()
+
+	for _, opts := range []*Options{nil, {ReadOnly: true}} {
+		for _, address := range []string{"", "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"} {
+			bc, err := NewBlockchainWithOptions(address, testNodeID, opts)
+			if !errors.Is(err, ErrCorruptDB) {
+				t.Errorf("Expected ErrCorruptDB for address %q, got %v", address, err)
+			}
+			if bc != nil {
+				bc.DB.Close()
+			}
+		}
+	}
+	if _, err := os.Stat(dbFile); err != nil {
+		t.Errorf("Expected the existing database file to be kept, got %v", err)
+	}
+
+	// 没有数据库且未提供地址时返回错误，并且不留下空文件
+	os.Remove(dbFile)
+	if _, err := NewBlockchainWithOptions("", testNodeID, nil); err == nil {
+		t.Error("Expected an error when creating a blockchain without an address")
+	}
+	if _, err := os.Stat(dbFile); !os.IsNotExist(err) {
+		t.Errorf("Expected no database file to be left behind, got %v", err)
+	}
+}
+
+// TestBlockchain_MinTargetBits 测试难度调整和区块验证都不会低于难度下限
+func TestBlockchain_MinTargetBits(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	if bc.MinTargetBits() != targetBits {
+		t.Fatalf("Expected default floor %d, got %d", targetBits, bc.MinTargetBits())
+	}
+
+	floor := targetBits + 2
+	bc.SetMinTargetBits(floor)
+
+	// 一段极快的出块之后长时间停滞，难度先升后降，始终不低于下限
+	bits := floor
+	expected := RetargetInterval * TargetBlockTime
+	for i := 0; i < 5; i++ {
+		bits = bc.RetargetBits(bits, time.Second)
+	}
+	if bits != floor+5 {
+		t.Errorf("Expected fast blocks to raise target bits to %d, got %d", floor+5, bits)
+	}
+	for i := 0; i < 50; i++ {
+		bits = bc.RetargetBits(bits, 100*expected)
+		if bits < floor {
+			t.Fatalf("Target bits %d dropped below the floor %d after %d slow periods", bits, floor, i+1)
+		}
+	}
+	if bits != floor {
+		t.Errorf("Expected target bits to settle at the floor %d, got %d", floor, bits)
+	}
+
+	// 低于下限挖出的区块无法通过验证
+	weak := NewBlockWithTargetBits([]*Transaction{NewCoinbaseTX(address, "weak")}, bc.tip, bc.GetBestHeight()+1, floor-1)
+	if err := bc.ValidateBlock(weak); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Expected block below the difficulty floor to be rejected, got %v", err)
+	}
+	strong := NewBlockWithTargetBits([]*Transaction{NewCoinbaseTX(address, "strong")}, bc.tip, bc.GetBestHeight()+1, floor)
+	if err := bc.ValidateBlock(strong); err != nil {
+		t.Errorf("Expected block at the difficulty floor to pass validation, got %v", err)
+	}
+
+	// 模板和挖矿使用不低于下限的难度，一个周期的快速出块后难度上调
+	template, err := bc.GetBlockTemplate(address)
+	if err != nil {
+		t.Fatalf("Failed to get block template: %v", err)
+	}
+	if template.TargetBits != floor {
+		t.Errorf("Expected template target bits %d, got %d", floor, template.TargetBits)
+	}
+	var mined *Block
+	for bc.GetBestHeight() < RetargetInterval {
+		mined = mustMineBlock(t, bc, []*Transaction{NewCoinbaseTX(address, fmt.Sprintf("period %d", bc.GetBestHeight()+1))})
+		if mined.Height < RetargetInterval && mined.TargetBits != floor {
+			t.Fatalf("Expected block %d to be mined at the floor %d, got %d", mined.Height, floor, mined.TargetBits)
+		}
+	}
+	if mined.TargetBits != floor+1 {
+		t.Errorf("Expected retargeted block to use target bits %d, got %d", floor+1, mined.TargetBits)
+	}
+}
+
+// fixedClock 返回固定时间的测试时钟
+type fixedClock struct {
+	now time.Time
+}
+
+// Now 返回固定时间
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+// TestBlockchain_MaxFutureBlockTime 测试区块时间戳按注入的时钟和允许偏差验证
+func TestBlockchain_MaxFutureBlockTime(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	if bc.MaxFutureBlockTime() != DefaultMaxFutureBlockTime {
+		t.Fatalf("Expected default drift %v, got %v", DefaultMaxFutureBlockTime, bc.MaxFutureBlockTime())
+	}
+
+	drift := 10 * time.Minute
+	bc.SetMaxFutureBlockTime(drift)
+	clock := &fixedClock{}
+	bc.SetClock(clock)
+
+	block := NewBlock([]*Transaction{NewCoinbaseTX(address, "future")}, bc.tip, bc.GetBestHeight()+1)
+	blockTime := time.Unix(block.Timestamp, 0)
+
+	// 时间戳恰好等于时钟加偏差时允许
+	clock.now = blockTime.Add(-drift)
+	if err := bc.ValidateBlock(block); err != nil {
+		t.Errorf("Expected block at the drift boundary to be accepted, got %v", err)
+	}
+
+	// 超过边界一秒即拒绝
+	clock.now = blockTime.Add(-drift - time.Second)
+	if err := bc.ValidateBlock(block); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Expected block past the drift boundary to be rejected, got %v", err)
+	}
+}
+
+// TestBlockchain_IsTipStale 测试链末端时间戳早于允许时长时被判定为过期
+func TestBlockchain_IsTipStale(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	tip, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to load tip: %v", err)
+	}
+	tipTime := time.Unix(tip.Timestamp, 0)
+	clock := &fixedClock{now: tipTime.Add(10 * time.Minute)}
+	bc.SetClock(clock)
+
+	if bc.IsTipStale(30 * time.Minute) {
+		t.Error("Expected a 10 minute old tip not to be stale")
+	}
+
+	clock.now = tipTime.Add(2 * time.Hour)
+	if !bc.IsTipStale(30 * time.Minute) {
+		t.Error("Expected a 2 hour old tip to be stale")
+	}
+}
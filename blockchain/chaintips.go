@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"bytes"
+	"log"
+	"sort"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	ChainTipActive    = "active"     // 当前主链的链尖
+	ChainTipValidFork = "valid-fork" // 可追溯到创世区块的分叉链尖
+	ChainTipOrphan    = "orphan"     // 祖先区块缺失的链尖
+)
+
+// ChainTip 描述一个链尖
+type ChainTip struct {
+	Hash   []byte // 链尖区块哈希
+	Height int    // 链尖区块高度
+	Status string // 链尖状态
+}
+
+// GetChainTips 返回所有已知链尖，包括主链和分叉，按高度从高到低排序
+func (bc *Blockchain) GetChainTips() []ChainTip {
+	var tips []ChainTip
+
+	err := bc.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		activeHash := b.Get([]byte("l"))
+
+		blocks := make(map[string]*Block)
+		hasChild := make(map[string]bool)
+
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if bytes.Equal(k, []byte("l")) {
+				continue
+			}
+			block := DeserializeBlock(v)
+			blocks[string(block.Hash)] = block
+			hasChild[string(block.PrevBlockHash)] = true
+		}
+
+		for hash, block := range blocks {
+			if hasChild[hash] {
+				continue
+			}
+
+			status := ChainTipValidFork
+			if bytes.Equal(block.Hash, activeHash) {
+				status = ChainTipActive
+			} else if !reachesGenesis(blocks, block) {
+				status = ChainTipOrphan
+			}
+
+			tips = append(tips, ChainTip{Hash: block.Hash, Height: block.Height, Status: status})
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i].Height > tips[j].Height
+	})
+
+	return tips
+}
+
+// reachesGenesis 检查区块的祖先是否都存在并能追溯到创世区块
+func reachesGenesis(blocks map[string]*Block, block *Block) bool {
+	for len(block.PrevBlockHash) > 0 {
+		parent, ok := blocks[string(block.PrevBlockHash)]
+		if !ok {
+			return false
+		}
+		block = parent
+	}
+
+	return true
+}
@@ -0,0 +1,29 @@
+package blockchain
+
+import "time"
+
+// Clock 提供当前时间，测试中可以替换为可控的时钟
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock 使用系统时间的默认时钟
+type systemClock struct{}
+
+// Now 返回系统当前时间
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock 设置区块链使用的时钟，为 nil 时使用系统时间
+func (bc *Blockchain) SetClock(clock Clock) {
+	bc.clock = clock
+}
+
+// now 返回区块链时钟的当前时间
+func (bc *Blockchain) now() time.Time {
+	if bc.clock == nil {
+		return systemClock{}.Now()
+	}
+	return bc.clock.Now()
+}
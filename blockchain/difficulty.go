@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// RetargetInterval 每隔多少个区块调整一次难度
+	RetargetInterval = 10
+	// TargetBlockTime 期望的平均出块间隔
+	TargetBlockTime = 10 * time.Second
+	// maxTargetBits 难度调整允许的最大目标位数
+	maxTargetBits = 64
+)
+
+// SetMinTargetBits 设置难度下限，难度调整和区块验证都不会低于该目标位数
+// 小于等于 0 时使用默认难度
+func (bc *Blockchain) SetMinTargetBits(bits int) {
+	if bits <= 0 {
+		bits = targetBits
+	}
+	if bits > maxTargetBits {
+		bits = maxTargetBits
+	}
+	bc.minTargetBits = bits
+}
+
+// MinTargetBits 返回难度下限
+func (bc *Blockchain) MinTargetBits() int {
+	if bc.minTargetBits <= 0 {
+		return targetBits
+	}
+	return bc.minTargetBits
+}
+
+// RetargetBits 根据上一个调整周期实际耗时计算下一个周期的目标位数
+// 耗时不足期望的一半时提高一位，超过两倍时降低一位，结果不低于难度下限
+func (bc *Blockchain) RetargetBits(prevBits int, actualTimespan time.Duration) int {
+	expected := RetargetInterval * TargetBlockTime
+
+	bits := prevBits
+	if actualTimespan < expected/2 {
+		bits++
+	} else if actualTimespan > expected*2 {
+		bits--
+	}
+
+	if minBits := bc.MinTargetBits(); bits < minBits {
+		bits = minBits
+	}
+	if bits > maxTargetBits {
+		bits = maxTargetBits
+	}
+
+	return bits
+}
+
+// NextTargetBits 返回以 parent 为父区块的下一个区块应使用的目标位数
+// 每 RetargetInterval 个区块按上一周期的实际耗时调整一次，其余区块沿用父区块的难度
+func (bc *Blockchain) NextTargetBits(parent *Block) (int, error) {
+	bits := NewProofOfWork(parent).TargetBits()
+
+	if height := parent.Height + 1; height%RetargetInterval == 0 {
+		first := *parent
+		for i := 1; i < RetargetInterval; i++ {
+			prev, err := bc.GetBlock(first.PrevBlockHash)
+			if err != nil {
+				return 0, fmt.Errorf("failed to find block %d of the retarget period: %w", first.Height-1, err)
+			}
+			first = prev
+		}
+
+		actualTimespan := time.Duration(parent.Timestamp-first.Timestamp) * time.Second
+		return bc.RetargetBits(bits, actualTimespan), nil
+	}
+
+	if minBits := bc.MinTargetBits(); bits < minBits {
+		bits = minBits
+	}
+	return bits, nil
+}
@@ -0,0 +1,30 @@
+package blockchain
+
+import "errors"
+
+var (
+	// ErrBlockExists 区块已存在于本地链中
+	ErrBlockExists = errors.New("Block already exists.")
+	// ErrReorgTooDeep 区块引起的链重组深度超过上限
+	ErrReorgTooDeep = errors.New("Reorganization exceeds the maximum depth.")
+	// ErrBlockNotFound 本地链中没有该区块
+	ErrBlockNotFound = errors.New("Block is not found.")
+	// ErrInvalidBlock 区块未通过验证
+	ErrInvalidBlock = errors.New("Invalid block.")
+	// ErrTransactionNotFound 本地链中没有该交易
+	ErrTransactionNotFound = errors.New("Transaction is not found.")
+	// ErrNotEnoughFunds 可花费余额不足以支付交易金额
+	ErrNotEnoughFunds = errors.New("Not enough funds.")
+	// ErrInvalidAmount 交易金额必须为正数
+	ErrInvalidAmount = errors.New("Amount must be positive.")
+	// ErrInvalidAddress 地址格式或校验和无效
+	ErrInvalidAddress = errors.New("Address is not valid.")
+	// ErrInvalidTransaction 交易未通过签名验证
+	ErrInvalidTransaction = errors.New("Invalid transaction.")
+	// ErrDoubleSpend 同一输出被多次花费
+	ErrDoubleSpend = errors.New("Output is spent more than once.")
+	// ErrCorruptDB 数据库文件已存在但缺少区块数据，可能是初始化中断
+	ErrCorruptDB = errors.New("Blockchain database is corrupt or partially initialized.")
+	// ErrDatabaseLocked 数据库文件被其他进程（通常是运行中的节点）以读写模式打开
+	ErrDatabaseLocked = errors.New("Blockchain database is locked by another process.")
+)
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// 交易记录的方向
+const (
+	HistoryReceived = "received" // 地址收到币
+	HistorySent     = "sent"     // 地址花费了币，金额为扣除找零后的净支出
+)
+
+// TxHistoryEntry 地址的一条交易记录
+type TxHistoryEntry struct {
+	TxID      []byte // 交易ID
+	Direction string // HistoryReceived 或 HistorySent
+	Amount    int    // 收到或净支出的金额
+	Height    int    // 交易所在区块的高度
+}
+
+// GetAddressHistory 扫描主链，按区块高度从低到高返回与地址相关的交易记录
+func (bc *Blockchain) GetAddressHistory(address string) ([]TxHistoryEntry, error) {
+	if !ValidateAddress(address) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, address)
+	}
+	pubKeyHash := AddressToPubKeyHash(address)
+
+	// 区块迭代器从链尖向创世区块遍历，先收集再按高度正序处理
+	var blocks []*Block
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+		blocks = append(blocks, block)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	var history []TxHistoryEntry
+	owned := make(map[string]int) // 属于该地址的输出 txid:vout -> 金额
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+
+		for _, tx := range block.Transactions {
+			spent := 0
+			if !tx.IsCoinbase() {
+				for _, vin := range tx.Vin {
+					key := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+					if value, ok := owned[key]; ok {
+						spent += value
+						delete(owned, key)
+					}
+				}
+			}
+
+			received := 0
+			for outIdx, out := range tx.Vout {
+				if bytes.Equal(out.ScriptPubKey, pubKeyHash) {
+					received += out.Value
+					owned[fmt.Sprintf("%x:%d", tx.ID, outIdx)] = out.Value
+				}
+			}
+
+			switch {
+			case spent > received:
+				history = append(history, TxHistoryEntry{tx.ID, HistorySent, spent - received, block.Height})
+			case received > spent:
+				history = append(history, TxHistoryEntry{tx.ID, HistoryReceived, received - spent, block.Height})
+			case spent > 0:
+				// 全部转回给自己
+				history = append(history, TxHistoryEntry{tx.ID, HistorySent, 0, block.Height})
+			}
+		}
+	}
+
+	return history, nil
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+// locatorDenseCount 区块定位器中间隔为 1 的最近区块数，之后间隔按指数增长
+const locatorDenseCount = 10
+
+// GetBlockLocator 返回当前主链的区块定位器
+// 从链尖开始，前 10 个哈希逐个选取，之后间隔每次翻倍，最后总是包含创世区块
+func (bc *Blockchain) GetBlockLocator() [][]byte {
+	return blockLocator(bc.Iterator())
+}
+
+// blockLocator 从迭代器的起点开始构造区块定位器
+func blockLocator(bci *BlockchainIterator) [][]byte {
+	var locator [][]byte
+	step := 1
+	next := 0
+
+	for index := 0; ; index++ {
+		block := bci.Next()
+		isGenesis := len(block.PrevBlockHash) == 0
+
+		if index == next || isGenesis {
+			locator = append(locator, block.Hash)
+			if len(locator) >= locatorDenseCount {
+				step *= 2
+			}
+			next = index + step
+		}
+
+		if isGenesis {
+			break
+		}
+	}
+
+	return locator
+}
+
+// FindForkPoint 返回主链上与定位器最后一个共同区块的哈希
+// 定位器中没有任何主链区块时返回 nil
+func (bc *Blockchain) FindForkPoint(locator [][]byte) []byte {
+	fork, _ := bc.locateBlocks(locator)
+	return fork
+}
+
+// GetBlockHashesAfterLocator 返回主链上位于共同区块之后的区块哈希，链尖在前
+// 定位器中没有任何主链区块时返回整条链
+func (bc *Blockchain) GetBlockHashesAfterLocator(locator [][]byte) [][]byte {
+	_, hashes := bc.locateBlocks(locator)
+	return hashes
+}
+
+// locateBlocks 从链尖向前遍历主链，直到遇到定位器中的区块
+func (bc *Blockchain) locateBlocks(locator [][]byte) ([]byte, [][]byte) {
+	known := make(map[string]bool, len(locator))
+	for _, hash := range locator {
+		known[string(hash)] = true
+	}
+
+	var hashes [][]byte
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		if known[string(block.Hash)] {
+			return block.Hash, hashes
+		}
+
+		hashes = append(hashes, block.Hash)
+
+		if len(block.PrevBlockHash) == 0 {
+			return nil, hashes
+		}
+	}
+}
@@ -17,20 +17,25 @@ type MerkleNode struct {
 }
 
 // NewMerkleTree 从数据序列中创建一个新的默克尔树
+// 数据为空时返回以空数据哈希为根的树
 func NewMerkleTree(data [][]byte) *MerkleTree {
-	var nodes []MerkleNode
-
-	// If number of transactions is odd, duplicate the last one to make it even
-	// 如果交易数量是奇数，则复制最后一个以使其变为偶数
-	if len(data)%2 != 0 {
-		data = append(data, data[len(data)-1])
+	if len(data) == 0 {
+		return &MerkleTree{NewMerkleNode(nil, nil, []byte{})}
 	}
 
+	nodes := make([]MerkleNode, 0, len(data)+1)
+
 	for _, datum := range data {
 		node := NewMerkleNode(nil, nil, datum)
 		nodes = append(nodes, *node)
 	}
 
+	// If number of transactions is odd, duplicate the last one to make it even
+	// 如果交易数量是奇数，则复制最后一个叶子节点以使其变为偶数，不修改调用方的 data
+	if len(nodes)%2 != 0 {
+		nodes = append(nodes, nodes[len(nodes)-1])
+	}
+
 	for len(nodes) > 1 {
 		var newLevel []MerkleNode
 
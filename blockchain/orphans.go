@@ -0,0 +1,61 @@
+package blockchain
+
+import (
+	"bytes"
+
+	"go.etcd.io/bbolt"
+)
+
+// orphansBucket 记录祖先缺失的孤儿区块，键为父区块哈希加孤儿区块哈希
+const orphansBucket = "orphans"
+
+// addOrphan 记录祖先缺失的孤儿区块，父区块接入主链或分叉后由 connectOrphans 接入
+func addOrphan(tx *bbolt.Tx, block *Block) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(orphansBucket))
+	if err != nil {
+		return err
+	}
+
+	return b.Put(append(append([]byte{}, block.PrevBlockHash...), block.Hash...), []byte{})
+}
+
+// connectOrphans 接入以 block 为祖先的孤儿区块并从孤儿索引中移除，返回其中高度最高的区块
+// 没有孤儿后代时返回 block 本身
+func connectOrphans(tx *bbolt.Tx, block *Block) (*Block, error) {
+	best := block
+	orphans := tx.Bucket([]byte(orphansBucket))
+	if orphans == nil {
+		return best, nil
+	}
+	blocks := tx.Bucket([]byte(blocksBucket))
+
+	queue := []*Block{block}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		var keys [][]byte
+		c := orphans.Cursor()
+		for k, _ := c.Seek(parent.Hash); k != nil && bytes.HasPrefix(k, parent.Hash); k, _ = c.Next() {
+			keys = append(keys, append([]byte{}, k...))
+		}
+
+		for _, key := range keys {
+			if err := orphans.Delete(key); err != nil {
+				return nil, err
+			}
+			data := blocks.Get(key[len(parent.Hash):])
+			if data == nil {
+				continue
+			}
+
+			child := DeserializeBlock(data)
+			if child.Height > best.Height {
+				best = child
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	return best, nil
+}
@@ -4,36 +4,134 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
+	"sync"
 )
 
 // targetBits 定义了工作量证明的难度
 const targetBits = 2
 
+// 内置的工作量证明哈希算法
+const (
+	HashSHA256       = "sha256"  // 单次 SHA256，未记录算法的区块默认使用
+	HashDoubleSHA256 = "sha256d" // 两次 SHA256
+)
+
+// HashFunc 工作量证明使用的哈希算法
+type HashFunc func(data []byte) []byte
+
+var (
+	hashFuncs = map[string]HashFunc{
+		HashSHA256:       sha256Hash,
+		HashDoubleSHA256: doubleSHA256Hash,
+	}
+	hashFuncsMutex sync.RWMutex
+)
+
+// RegisterHashFunc 注册工作量证明哈希算法，例如 scrypt
+func RegisterHashFunc(name string, fn HashFunc) error {
+	if name == "" || fn == nil {
+		return fmt.Errorf("hash algorithm name and function are required")
+	}
+
+	hashFuncsMutex.Lock()
+	defer hashFuncsMutex.Unlock()
+
+	if _, exists := hashFuncs[name]; exists {
+		return fmt.Errorf("hash algorithm %s already registered", name)
+	}
+	hashFuncs[name] = fn
+
+	return nil
+}
+
+// lookupHashFunc 查找哈希算法，空名称表示默认的 SHA256
+func lookupHashFunc(name string) (HashFunc, bool) {
+	if name == "" {
+		name = HashSHA256
+	}
+
+	hashFuncsMutex.RLock()
+	defer hashFuncsMutex.RUnlock()
+
+	fn, ok := hashFuncs[name]
+	return fn, ok
+}
+
+// sha256Hash 计算单次 SHA256
+func sha256Hash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// doubleSHA256Hash 计算两次 SHA256
+func doubleSHA256Hash(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
 // ProofOfWork 结构保存了指向区块的指针和证明的目标值
 type ProofOfWork struct {
 	block  *Block
 	target *big.Int
+	bits   int
+	hash   HashFunc
 }
 
-// NewProofOfWork 创建一个新的工作量证明对象
+// NewProofOfWork 创建一个新的工作量证明对象，使用区块记录的难度
 func NewProofOfWork(b *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	bits := b.TargetBits
+	if bits <= 0 {
+		bits = targetBits // 未记录难度的旧区块使用默认难度
+	}
+
+	hash, _ := lookupHashFunc(b.HashAlgorithm) // 未知算法的区块无法通过验证
 
-	pow := &ProofOfWork{b, target}
+	pow := &ProofOfWork{b, targetForBits(bits), bits, hash}
 	return pow
 }
 
+// targetForBits 根据目标位数计算目标值
+func targetForBits(bits int) *big.Int {
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-bits))
+
+	return target
+}
+
+// TargetBits 返回工作量证明的目标位数
+func (pow *ProofOfWork) TargetBits() int {
+	return pow.bits
+}
+
+// Difficulty 返回相对于默认难度的难度倍数，默认难度为 1
+func (pow *ProofOfWork) Difficulty() float64 {
+	ratio := new(big.Float).Quo(new(big.Float).SetInt(targetForBits(targetBits)), new(big.Float).SetInt(pow.target))
+	difficulty, _ := ratio.Float64()
+
+	return difficulty
+}
+
 // prepareData 准备用于哈希计算的数据
+// 数据包含目标位数，HeightBlockVersion 及以上版本还包含版本号和区块高度，
+// 修改其中任何一项都会使工作量证明失效；旧版本区块按原格式计算以保持有效
 func (pow *ProofOfWork) prepareData(nonce int) []byte {
+	var versioned []byte
+	if pow.block.Version >= HeightBlockVersion {
+		versioned = append(IntToHex(int64(pow.block.Version)), IntToHex(int64(pow.block.Height))...)
+	}
+
 	data := bytes.Join(
 		[][]byte{
 			pow.block.PrevBlockHash,
 			pow.block.HashTransactions(),
 			IntToHex(pow.block.Timestamp),
-			IntToHex(int64(targetBits)),
+			versioned,
+			IntToHex(int64(pow.bits)),
+			[]byte(pow.block.HashAlgorithm), // 算法名写入哈希数据，默认算法为空以兼容旧区块
 			IntToHex(int64(nonce)),
 		},
 		[]byte{},
@@ -41,16 +139,28 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 	return data
 }
 
+// Hash 使用区块记录的算法计算指定 nonce 的哈希，算法未知时返回 nil
+func (pow *ProofOfWork) Hash(nonce int) []byte {
+	if pow.hash == nil {
+		return nil
+	}
+
+	return pow.hash(pow.prepareData(nonce))
+}
+
 // Run 执行工作量证明，即“挖矿”
 func (pow *ProofOfWork) Run() (int, []byte) {
 	var hashInt big.Int
-	var hash [32]byte
+	var hash []byte
 	nonce := 0
 
+	if pow.hash == nil {
+		log.Panicf("ERROR: Unknown hash algorithm %s", pow.block.HashAlgorithm)
+	}
+
 	fmt.Printf("Mining the block containing transactions...\n")
 	for nonce < math.MaxInt64 {
-		data := pow.prepareData(nonce)
-		hash = sha256.Sum256(data)
+		hash = pow.Hash(nonce)
 		fmt.Printf("\r%x", hash)
 
 		hashInt.SetBytes(hash[:])
@@ -63,16 +173,18 @@ func (pow *ProofOfWork) Run() (int, []byte) {
 	}
 	fmt.Print("\n\n")
 
-	return nonce, hash[:]
+	return nonce, hash
 }
 
 // Validate 验证工作量证明是否有效
 func (pow *ProofOfWork) Validate() bool {
 	var hashInt big.Int
 
-	data := pow.prepareData(pow.block.Nonce)
-	hash := sha256.Sum256(data)
-	hashInt.SetBytes(hash[:])
+	hash := pow.Hash(pow.block.Nonce)
+	if hash == nil {
+		return false
+	}
+	hashInt.SetBytes(hash)
 
 	isValid := hashInt.Cmp(pow.target) == -1
 
@@ -0,0 +1,39 @@
+package blockchain
+
+// GenesisConfig 创世区块配置，创世奖励与之后每个区块的补贴相互独立
+type GenesisConfig struct {
+	Reward int    // 创世区块 coinbase 的奖励（预挖量），小于等于 0 时使用默认区块补贴
+	Data   string // 创世区块 coinbase 的数据，为空时使用默认数据
+}
+
+// coinbase 按配置创建支付给 address 的创世 coinbase 交易，配置为空时使用默认配置
+func (g *GenesisConfig) coinbase(address string) *Transaction {
+	reward := subsidy
+	data := genesisCoinbaseData
+	if g != nil {
+		if g.Reward > 0 {
+			reward = g.Reward
+		}
+		if g.Data != "" {
+			data = g.Data
+		}
+	}
+
+	return NewCoinbaseTXWithReward(address, data, reward)
+}
+
+// SetBlockSubsidy 设置创世区块之后每个区块的 coinbase 补贴，小于等于 0 时使用默认值
+func (bc *Blockchain) SetBlockSubsidy(amount int) {
+	if amount <= 0 {
+		amount = subsidy
+	}
+	bc.blockSubsidy = amount
+}
+
+// BlockSubsidy 返回创世区块之后每个区块的 coinbase 补贴
+func (bc *Blockchain) BlockSubsidy() int {
+	if bc.blockSubsidy <= 0 {
+		return subsidy
+	}
+	return bc.blockSubsidy
+}
@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// maxTemplateTransactions 区块模板最多包含的内存池交易数
+const maxTemplateTransactions = 1000
+
+// TxSource 能按优先级提供候选交易的内存池
+type TxSource interface {
+	GetPrioritizedTransactions() []*Transaction
+}
+
+// BlockTemplate 供外部矿工使用的候选区块
+type BlockTemplate struct {
+	Version       int            // 候选区块版本号
+	PrevBlockHash []byte         // 父区块哈希
+	Height        int            // 候选区块高度
+	TargetBits    int            // 工作量证明难度
+	Target        *big.Int       // 区块哈希必须小于的目标值
+	Timestamp     int64          // 候选区块时间戳
+	Transactions  []*Transaction // 区块交易，第一笔为 coinbase
+	MerkleRoot    []byte         // 交易的默克尔根
+}
+
+// GetBlockTemplate 构建以当前链尖为父区块的候选区块，奖励支付给 minerAddress
+func (bc *Blockchain) GetBlockTemplate(minerAddress string) (*BlockTemplate, error) {
+	if !ValidateAddress(minerAddress) {
+		return nil, fmt.Errorf("ERROR: Miner address %s is not valid", minerAddress)
+	}
+
+	tip, err := bc.GetBlock(bc.lastHash())
+	if err != nil {
+		return nil, err
+	}
+	height := tip.Height + 1
+
+	bits, err := bc.NextTargetBits(&tip)
+	if err != nil {
+		return nil, err
+	}
+
+	// coinbase 数据包含高度，保证不同区块的 coinbase 交易 ID 不同
+	coinbase := NewCoinbaseTXWithReward(minerAddress, fmt.Sprintf("Reward to %s at height %d", minerAddress, height), bc.BlockSubsidy())
+	transactions := append([]*Transaction{coinbase}, bc.selectTemplateTransactions()...)
+
+	template := &BlockTemplate{
+		Version:       CurrentBlockVersion,
+		PrevBlockHash: tip.Hash,
+		Height:        height,
+		TargetBits:    bits,
+		Target:        targetForBits(bits),
+		Timestamp:     bc.now().Unix(),
+		Transactions:  transactions,
+	}
+	template.MerkleRoot = template.Block().HashTransactions()
+
+	return template, nil
+}
+
+// selectTemplateTransactions 按内存池优先级选出有效、满足共识最低手续费且互不冲突的交易
+// 交易可以花费排在它之前的已选交易的输出
+func (bc *Blockchain) selectTemplateTransactions() []*Transaction {
+	source, ok := bc.mempool.(TxSource)
+	if !ok {
+		return nil
+	}
+
+	var selected []*Transaction
+	spent := make(map[string]bool)
+	inBlock := make(map[string]*Transaction)
+
+Candidates:
+	for _, tx := range source.GetPrioritizedTransactions() {
+		if len(selected) >= maxTemplateTransactions {
+			break
+		}
+		if tx.IsCoinbase() {
+			continue
+		}
+		if err := bc.validateTransactionWith(tx, inBlock); err != nil {
+			continue
+		}
+		if err := bc.validateMinFee(tx, inBlock); err != nil {
+			continue
+		}
+
+		outpoints := make([]string, 0, len(tx.Vin))
+		for _, vin := range tx.Vin {
+			outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+			if spent[outpoint] {
+				continue Candidates
+			}
+			outpoints = append(outpoints, outpoint)
+		}
+
+		for _, outpoint := range outpoints {
+			spent[outpoint] = true
+		}
+		selected = append(selected, tx)
+		inBlock[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	return selected
+}
+
+// Block 返回尚未完成工作量证明的候选区块，矿工需填入 Nonce 和 Hash
+func (t *BlockTemplate) Block() *Block {
+	return &Block{
+		Version:       t.Version,
+		Timestamp:     t.Timestamp,
+		Transactions:  t.Transactions,
+		PrevBlockHash: t.PrevBlockHash,
+		Hash:          []byte{},
+		Nonce:         0,
+		Height:        t.Height,
+		TargetBits:    t.TargetBits,
+	}
+}
+
+// SubmitBlock 验证外部矿工提交的区块并接入本地链
+func (bc *Blockchain) SubmitBlock(block *Block) error {
+	if err := bc.ValidateBlock(block); err != nil {
+		return err
+	}
+
+	return bc.AddBlock(block)
+}
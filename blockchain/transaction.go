@@ -3,17 +3,29 @@ package blockchain
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
 )
 
+// subsidy 每个区块的 coinbase 奖励
+const subsidy = 100
+
 // TXInput 结构:
 type TXInput struct {
 	Txid      []byte // 引用来源交易的 ID (哈希)
 	Vout      int    // 引用来源交易的某个输出的索引
 	ScriptSig string // 解锁脚本，这里我们可以简化为发送方的地址
+	Signature []byte // 数字签名 r|s，未签名的输入为空
+	PubKey    []byte // 签名者的完整公钥 X|Y
 }
 
 // TXOutput 结构:
@@ -89,28 +101,143 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
-// NewTXOutput 创建新的交易输出
-func NewTXOutput(value int, address string) *TXOutput {
-	pubKeyHash := Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+// String 返回交易的可读表示
+func (tx *Transaction) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
+
+	for i, input := range tx.Vin {
+		lines = append(lines, fmt.Sprintf("     Input %d:", i))
+		if tx.IsCoinbase() {
+			lines = append(lines, fmt.Sprintf("       coinbase: %s", input.ScriptSig))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("       TXID:      %x", input.Txid))
+		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
+	}
+
+	for i, output := range tx.Vout {
+		lines = append(lines, fmt.Sprintf("     Output %d:", i))
+		lines = append(lines, fmt.Sprintf("       Value:     %d", output.Value))
+		lines = append(lines, fmt.Sprintf("       Address:   %s", PubKeyHashToAddress(output.ScriptPubKey)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Fee 根据引用的前序交易计算交易手续费
+func (tx *Transaction) Fee(prevTXs map[string]Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	inputValue := 0
+	for _, vin := range tx.Vin {
+		prevTX, ok := prevTXs[hex.EncodeToString(vin.Txid)]
+		if !ok {
+			return 0, fmt.Errorf("ERROR: Previous transaction %x is not found", vin.Txid)
+		}
+		if vin.Vout < 0 || vin.Vout >= len(prevTX.Vout) {
+			return 0, fmt.Errorf("ERROR: Output %d of transaction %x does not exist", vin.Vout, vin.Txid)
+		}
+		value := prevTX.Vout[vin.Vout].Value
+		if value < 0 || value > math.MaxInt-inputValue {
+			return 0, fmt.Errorf("ERROR: Input values of transaction %x overflow", tx.ID)
+		}
+		inputValue += value
+	}
+
+	outputValue, err := sumOutputValues(tx.Vout)
+	if err != nil {
+		return 0, err
+	}
+
+	if inputValue < outputValue {
+		return 0, fmt.Errorf("ERROR: Outputs %d exceed inputs %d", outputValue, inputValue)
+	}
+
+	return inputValue - outputValue, nil
+}
+
+// CoinAge 计算交易的币龄：各输入引用输出的金额乘以其在 currentHeight 时的确认数之和
+// 引用未确认交易的输入不计入币龄
+func (tx *Transaction) CoinAge(utxoSet *UTXOSet, currentHeight int) int {
+	if tx.IsCoinbase() {
+		return 0
+	}
+
+	coinAge := 0
+	for _, vin := range tx.Vin {
+		prevTX, height, ok := utxoSet.Blockchain.findConfirmedTransaction(vin.Txid)
+		if !ok || height > currentHeight || vin.Vout < 0 || vin.Vout >= len(prevTX.Vout) {
+			continue
+		}
+
+		coinAge += prevTX.Vout[vin.Vout].Value * (currentHeight - height + 1)
+	}
+
+	return coinAge
+}
+
+// ValidateOutputValues 检查交易输出金额非负且总额不会溢出
+func (tx *Transaction) ValidateOutputValues() error {
+	_, err := sumOutputValues(tx.Vout)
+	return err
+}
+
+// sumOutputValues 计算输出总额，拒绝负数金额和溢出
+func sumOutputValues(outputs []TXOutput) (int, error) {
+	total := 0
+	for i, out := range outputs {
+		if out.Value < 0 {
+			return 0, fmt.Errorf("ERROR: Output %d has negative value %d", i, out.Value)
+		}
+		if out.Value > math.MaxInt-total {
+			return 0, fmt.Errorf("ERROR: Output values overflow at output %d", i)
+		}
+		total += out.Value
+	}
+
+	return total, nil
+}
+
+// NewTXOutput 创建新的交易输出，金额为负时返回 ErrInvalidAmount
+func NewTXOutput(value int, address string) (*TXOutput, error) {
+	if value < 0 {
+		return nil, fmt.Errorf("%w: output value %d is negative", ErrInvalidAmount, value)
+	}
+
+	version, pubKeyHash, err := Base58CheckDecode(address)
+	if err != nil {
+		return nil, err
+	}
+	if version != MainnetAddressVersion && version != TestnetAddressVersion {
+		return nil, fmt.Errorf("%w: unknown version byte 0x%02x", ErrInvalidAddress, version)
+	}
 	
 	return &TXOutput{
 		Value:        value,
 		ScriptPubKey: pubKeyHash,
-	}
+	}, nil
 }
 
-// NewCoinbaseTX 创建并返回一个 Coinbase 交易
+// NewCoinbaseTX 创建并返回一个奖励为默认区块补贴的 Coinbase 交易
 func NewCoinbaseTX(to, data string) *Transaction {
+	return NewCoinbaseTXWithReward(to, data, subsidy)
+}
+
+// NewCoinbaseTXWithReward 创建并返回一个奖励为 reward 的 Coinbase 交易
+func NewCoinbaseTXWithReward(to, data string, reward int) *Transaction {
 	if data == "" {
 		data = fmt.Sprintf("Reward to %s", to)
 	}
 
 	// Coinbase 交易没有输入，Txid 为空，Vout 为 -1
-	in := TXInput{[]byte{}, -1, data}
+	in := TXInput{Txid: []byte{}, Vout: -1, ScriptSig: data}
 	pubKeyHash := Base58Decode([]byte(to))
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	out := TXOutput{100, pubKeyHash} // 奖励 100 个币
+	out := TXOutput{reward, pubKeyHash}
 
 	tx := Transaction{nil, []TXInput{in}, []TXOutput{out}}
 	tx.ID = tx.Hash()
@@ -118,6 +245,41 @@ func NewCoinbaseTX(to, data string) *Transaction {
 	return &tx
 }
 
+// NewCoinbaseTXMulti 创建将奖励拆分到多个地址的 Coinbase 交易，输出按地址排序
+func NewCoinbaseTXMulti(outputs map[string]int, data string) (*Transaction, error) {
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("coinbase has no outputs")
+	}
+
+	addresses := make([]string, 0, len(outputs))
+	for address := range outputs {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var vout []TXOutput
+	for _, address := range addresses {
+		if outputs[address] <= 0 {
+			return nil, fmt.Errorf("%w: %d to %s", ErrInvalidAmount, outputs[address], address)
+		}
+		out, err := NewTXOutput(outputs[address], address)
+		if err != nil {
+			return nil, err
+		}
+		vout = append(vout, *out)
+	}
+
+	if data == "" {
+		data = fmt.Sprintf("Reward to %s", strings.Join(addresses, ","))
+	}
+
+	in := TXInput{Txid: []byte{}, Vout: -1, ScriptSig: data}
+	tx := Transaction{nil, []TXInput{in}, vout}
+	tx.ID = tx.Hash()
+
+	return &tx, nil
+}
+
 // Serialize 序列化交易
 func (tx *Transaction) Serialize() []byte {
 	var res bytes.Buffer
@@ -131,6 +293,21 @@ func (tx *Transaction) Serialize() []byte {
 	return res.Bytes()
 }
 
+// SerializedSize 返回交易序列化后的字节数，用于手续费率和内存池字节数计算
+func (tx *Transaction) SerializedSize() int {
+	return len(tx.Serialize())
+}
+
+// FeeRate 返回交易的手续费率（手续费/字节）
+func (tx *Transaction) FeeRate(prevTXs map[string]Transaction) (float64, error) {
+	fee, err := tx.Fee(prevTXs)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(fee) / float64(tx.SerializedSize()), nil
+}
+
 // DeserializeTransaction 反序列化交易
 func DeserializeTransaction(data []byte) *Transaction {
 	var transaction Transaction
@@ -144,15 +321,107 @@ func DeserializeTransaction(data []byte) *Transaction {
 	return &transaction
 }
 
-// Sign 签名交易（简化版本）
+// Sign 使用私钥对交易的每个输入签名
+// 每个输入的签名数据都包含其引用输出的锁定脚本，输入无法被改绑到其他输出
 func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
-	// 这是一个简化的签名实现
-	// 在实际应用中，应该使用ECDSA等加密算法进行签名
+	if tx.IsCoinbase() {
+		return
+	}
+
+	for _, vin := range tx.Vin {
+		prevTX, ok := prevTXs[hex.EncodeToString(vin.Txid)]
+		if !ok || vin.Vout < 0 || vin.Vout >= len(prevTX.Vout) {
+			log.Panic("ERROR: Previous transaction is not correct")
+		}
+	}
+
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+	for inID, vin := range tx.Vin {
+		prevOut := prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout]
+
+		r, s, err := ecdsa.Sign(rand.Reader, &privKey, tx.signatureHash(inID, prevOut.ScriptPubKey))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		signature := append(r.FillBytes(make([]byte, signatureScalarLen)), s.FillBytes(make([]byte, signatureScalarLen))...)
+		tx.Vin[inID].Signature = signature
+		tx.Vin[inID].PubKey = pubKey
+	}
 }
 
-// Verify 验证交易（简化版本）
+// Verify 验证交易输入的签名
+// 每个非 coinbase 输入都必须由引用输出锁定的公钥签名，签名数据包含引用输出的锁定脚本
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
-	// 这是一个简化的验证实现
-	// 在实际应用中，应该验证数字签名
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	for inID, vin := range tx.Vin {
+		prevTX, ok := prevTXs[hex.EncodeToString(vin.Txid)]
+		if !ok || vin.Vout < 0 || vin.Vout >= len(prevTX.Vout) {
+			return false
+		}
+		prevOut := prevTX.Vout[vin.Vout]
+
+		// 公钥必须与引用输出的锁定脚本对应
+		if !bytes.Equal(HashPubKey(vin.PubKey), prevOut.ScriptPubKey) {
+			return false
+		}
+
+		pubKey, ok := parsePubKey(vin.PubKey)
+		if !ok || len(vin.Signature) != 2*signatureScalarLen {
+			return false
+		}
+		r := new(big.Int).SetBytes(vin.Signature[:signatureScalarLen])
+		s := new(big.Int).SetBytes(vin.Signature[signatureScalarLen:])
+
+		if !ecdsa.Verify(pubKey, tx.signatureHash(inID, prevOut.ScriptPubKey), r, s) {
+			return false
+		}
+	}
+
 	return true
 }
+
+// signatureScalarLen 签名中 r、s 的固定长度
+const signatureScalarLen = 32
+
+// signatureHash 计算第 inID 个输入的待签名哈希
+// 副本清空所有输入的签名和公钥，并把被签名输入的公钥位置换成引用输出的锁定脚本
+func (tx *Transaction) signatureHash(inID int, prevScriptPubKey []byte) []byte {
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inID].PubKey = prevScriptPubKey
+
+	return txCopy.Hash()
+}
+
+// TrimmedCopy 创建一个用于签名的交易副本，清空所有输入的 Signature 和 PubKey 字段
+func (tx *Transaction) TrimmedCopy() Transaction {
+	inputs := make([]TXInput, 0, len(tx.Vin))
+	for _, vin := range tx.Vin {
+		inputs = append(inputs, TXInput{Txid: vin.Txid, Vout: vin.Vout, ScriptSig: vin.ScriptSig})
+	}
+
+	outputs := make([]TXOutput, 0, len(tx.Vout))
+	for _, vout := range tx.Vout {
+		outputs = append(outputs, TXOutput{vout.Value, vout.ScriptPubKey})
+	}
+
+	return Transaction{nil, inputs, outputs}
+}
+
+// parsePubKey 将 X|Y 形式的公钥还原为 P256 公钥
+// 坐标按 big.Int.Bytes 编码，长度可能不足 32 字节，因此取使点位于曲线上的切分位置
+func parsePubKey(pubKey []byte) (*ecdsa.PublicKey, bool) {
+	curve := elliptic.P256()
+	for split := max(1, len(pubKey)-signatureScalarLen); split <= signatureScalarLen && split < len(pubKey); split++ {
+		x := new(big.Int).SetBytes(pubKey[:split])
+		y := new(big.Int).SetBytes(pubKey[split:])
+		if curve.IsOnCurve(x, y) {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+		}
+	}
+
+	return nil, false
+}
@@ -1,6 +1,15 @@
 package blockchain
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -102,3 +111,505 @@ func TestTXOutputs_Serialize(t *testing.T) {
 		t.Errorf("Expected value 30, got %d", deserialized.Outputs[1].Value)
 	}
 }
+
+// TestTransaction_String 测试交易的可读表示
+func TestTransaction_String(t *testing.T) {
+	from := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	to := PubKeyHashToAddress(HashPubKey([]byte("recipient_public_key")))
+
+	coinbase := NewCoinbaseTX(from, "reward")
+	if output := coinbase.String(); !strings.Contains(output, "coinbase") {
+		t.Errorf("Expected coinbase output to mention coinbase, got:\n%s", output)
+	}
+
+	spend := &Transaction{
+		Vin:  []TXInput{{Txid: coinbase.ID, Vout: 0, ScriptSig: from}},
+		Vout: []TXOutput{newTestOutput(40, to)},
+	}
+	spend.ID = spend.Hash()
+
+	output := spend.String()
+	if !strings.Contains(output, to) {
+		t.Errorf("Expected spend output to contain destination %s, got:\n%s", to, output)
+	}
+	if strings.Contains(output, "coinbase") {
+		t.Errorf("Spend should not be rendered as coinbase, got:\n%s", output)
+	}
+
+	if PubKeyHashToAddress(AddressToPubKeyHash(from)) != from {
+		t.Error("Address should round-trip through its pubkey hash")
+	}
+}
+
+// TestBlockchain_VerifyTransactionValueConservation 测试输入输出金额守恒检查
+func TestBlockchain_VerifyTransactionValueConservation(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, address := newTestKey(t)
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	coinbase := genesis.Transactions[0]
+
+	spend := func(value int) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: coinbase.ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{newTestOutput(value, address)},
+		}
+		tx.ID = tx.Hash()
+		bc.SignTransaction(tx, *privKey)
+		return tx
+	}
+
+	if !bc.VerifyTransaction(spend(100)) {
+		t.Error("Balanced transaction should pass verification")
+	}
+
+	if bc.VerifyTransaction(spend(150)) {
+		t.Error("Transaction creating value should be rejected")
+	}
+
+	withFee := spend(90)
+	if !bc.VerifyTransaction(withFee) {
+		t.Error("Transaction paying a fee should pass verification")
+	}
+	fee, err := withFee.Fee(map[string]Transaction{hex.EncodeToString(coinbase.ID): *coinbase})
+	if err != nil || fee != 10 {
+		t.Errorf("Expected fee 10, got %d (err: %v)", fee, err)
+	}
+}
+
+// TestTransaction_OutputValueLimits 测试负数与溢出的输出金额被拒绝
+func TestTransaction_OutputValueLimits(t *testing.T) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	prev := NewCoinbaseTX(address, "prev")
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): *prev}
+	pubKeyHash := AddressToPubKeyHash(address)
+
+	t.Run("NegativeOutput", func(t *testing.T) {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: prev.ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{{Value: 150, ScriptPubKey: pubKeyHash}, {Value: -50, ScriptPubKey: pubKeyHash}},
+		}
+		tx.ID = tx.Hash()
+
+		if err := tx.ValidateOutputValues(); err == nil {
+			t.Error("Expected negative output to be rejected")
+		}
+		if _, err := tx.Fee(prevTXs); err == nil {
+			t.Error("Expected fee calculation to reject negative output")
+		}
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: prev.ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{{Value: math.MaxInt, ScriptPubKey: pubKeyHash}, {Value: 2, ScriptPubKey: pubKeyHash}},
+		}
+		tx.ID = tx.Hash()
+
+		if err := tx.ValidateOutputValues(); err == nil {
+			t.Error("Expected overflowing outputs to be rejected")
+		}
+	})
+
+	t.Run("NewTXOutputNegative", func(t *testing.T) {
+		if _, err := NewTXOutput(-1, address); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Expected ErrInvalidAmount for a negative value, got %v", err)
+		}
+	})
+}
+
+// newTestOutput 创建锁定到指定地址的测试输出，地址无效时直接 panic
+func newTestOutput(value int, address string) TXOutput {
+	out, err := NewTXOutput(value, address)
+	if err != nil {
+		panic(err)
+	}
+
+	return *out
+}
+
+// TestNewTXOutput_InvalidAddress 测试格式错误的地址返回错误而不是 panic
+func TestNewTXOutput_InvalidAddress(t *testing.T) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	if _, err := NewTXOutput(10, address); err != nil {
+		t.Fatalf("Expected valid address to be accepted, got %v", err)
+	}
+
+	// 最后一个字符被修改，校验和不再匹配
+	corrupted := address[:len(address)-1] + "w"
+
+	for name, invalid := range map[string]string{
+		"TooShort":        "1z",
+		"Empty":           "",
+		"InvalidChecksum": corrupted,
+	} {
+		t.Run(name, func(t *testing.T) {
+			out, err := NewTXOutput(10, invalid)
+			if !errors.Is(err, ErrInvalidAddress) {
+				t.Errorf("Expected ErrInvalidAddress for %q, got %v", invalid, err)
+			}
+			if out != nil {
+				t.Errorf("Expected no output for %q", invalid)
+			}
+		})
+	}
+}
+
+// TestTransaction_CoinAge 测试币龄随输入确认数增长
+func TestTransaction_CoinAge(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	bc := NewBlockchain(address, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		block := NewBlock([]*Transaction{NewCoinbaseTX(address, fmt.Sprintf("block %d", i))}, bc.tip, i)
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+	latest, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		t.Fatalf("Failed to get tip block: %v", err)
+	}
+
+	spend := func(prev *Transaction) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{Txid: prev.ID, Vout: 0, ScriptSig: address}},
+			Vout: []TXOutput{newTestOutput(prev.Vout[0].Value-1, address)},
+		}
+		tx.ID = tx.Hash()
+		return tx
+	}
+
+	utxoSet := &UTXOSet{Blockchain: bc}
+	oldCoin := genesis.Transactions[0]
+	newCoin := latest.Transactions[0]
+
+	oldAge := spend(oldCoin).CoinAge(utxoSet, 2)
+	newAge := spend(newCoin).CoinAge(utxoSet, 2)
+
+	if oldAge != oldCoin.Vout[0].Value*3 {
+		t.Errorf("Expected coin age %d for genesis input, got %d", oldCoin.Vout[0].Value*3, oldAge)
+	}
+	if newAge != newCoin.Vout[0].Value {
+		t.Errorf("Expected coin age %d for tip input, got %d", newCoin.Vout[0].Value, newAge)
+	}
+
+	unconfirmed := &Transaction{ID: []byte("unconfirmed"), Vout: []TXOutput{newTestOutput(10, address)}}
+	if age := spend(unconfirmed).CoinAge(utxoSet, 2); age != 0 {
+		t.Errorf("Expected zero coin age for unconfirmed input, got %d", age)
+	}
+}
+
+// TestTransaction_SerializedSizeAndFeeRate 测试交易大小与序列化长度一致，手续费率为手续费除以大小
+func TestTransaction_SerializedSizeAndFeeRate(t *testing.T) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	prev := &Transaction{ID: []byte("prev"), Vout: []TXOutput{newTestOutput(100, address)}}
+	tx := &Transaction{
+		Vin:  []TXInput{{Txid: prev.ID, Vout: 0, ScriptSig: address}},
+		Vout: []TXOutput{newTestOutput(70, address)},
+	}
+	tx.ID = tx.Hash()
+
+	size := tx.SerializedSize()
+	if size != len(tx.Serialize()) {
+		t.Fatalf("Expected size %d, got %d", len(tx.Serialize()), size)
+	}
+
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): *prev}
+	rate, err := tx.FeeRate(prevTXs)
+	if err != nil {
+		t.Fatalf("Failed to compute fee rate: %v", err)
+	}
+	if expected := 30 / float64(size); rate != expected {
+		t.Errorf("Expected fee rate %f, got %f", expected, rate)
+	}
+
+	if _, err := tx.FeeRate(map[string]Transaction{}); err == nil {
+		t.Error("Expected an error when previous transactions are missing")
+	}
+}
+
+// TestTransaction_SignCommitsToPrevOutput 测试签名绑定引用的输出，改绑到其他输出后验证失败
+func TestTransaction_SignCommitsToPrevOutput(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+	owner := PubKeyHashToAddress(HashPubKey(pubKey))
+	attacker := PubKeyHashToAddress(bytes.Repeat([]byte{0xaa}, 20))
+
+	bc := NewBlockchain(owner, testNodeID)
+	defer bc.DB.Close()
+
+	genesis, _ := bc.GetBlock(bc.tip)
+	for _, to := range []string{owner, attacker} {
+		block := NewBlock([]*Transaction{NewCoinbaseTX(to, "coins for "+to)}, bc.tip, bc.GetBestHeight()+1)
+		if err := bc.AddBlock(block); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+	secondOwned, _ := bc.FindTransaction(NewCoinbaseTX(owner, "coins for "+owner).ID)
+	attackerCoin, _ := bc.FindTransaction(NewCoinbaseTX(attacker, "coins for "+attacker).ID)
+
+	tx := &Transaction{
+		Vin:  []TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0}},
+		Vout: []TXOutput{newTestOutput(subsidy, attacker)},
+	}
+	tx.ID = tx.Hash()
+	bc.SignTransaction(tx, *privKey)
+
+	if !bc.VerifyTransaction(tx) {
+		t.Fatal("Expected signed transaction to verify")
+	}
+
+	// rebind 复制已签名的交易，并把输入改为引用另一个输出
+	rebind := func(prev Transaction) *Transaction {
+		moved := *tx
+		moved.Vin = []TXInput{tx.Vin[0]}
+		moved.Vin[0].Txid = prev.ID
+		return &moved
+	}
+	if bc.VerifyTransaction(rebind(secondOwned)) {
+		t.Error("Signature should not be valid for another output of the same key")
+	}
+	if bc.VerifyTransaction(rebind(attackerCoin)) {
+		t.Error("Signature should not be valid for an attacker-controlled output")
+	}
+
+	tampered := *tx
+	tampered.Vout = []TXOutput{newTestOutput(subsidy, owner)}
+	if bc.VerifyTransaction(&tampered) {
+		t.Error("Signature should not be valid after changing the outputs")
+	}
+
+	// 去掉签名和公钥后改绑到其他输出同样被拒绝
+	stripped := rebind(secondOwned)
+	stripped.Vin[0].Signature = nil
+	stripped.Vin[0].PubKey = nil
+	if bc.VerifyTransaction(stripped) {
+		t.Error("Unsigned input should not verify")
+	}
+}
+
+// TestNewCoinbaseTXMulti 测试拆分到多个地址的 coinbase 交易
+func TestNewCoinbaseTXMulti(t *testing.T) {
+	miner := PubKeyHashToAddress(HashPubKey([]byte("split_miner")))
+	dev := PubKeyHashToAddress(HashPubKey([]byte("split_dev")))
+
+	tx, err := NewCoinbaseTXMulti(map[string]int{miner: subsidy - 10, dev: 10}, "")
+	if err != nil {
+		t.Fatalf("Failed to create split coinbase: %v", err)
+	}
+	if !tx.IsCoinbase() {
+		t.Fatal("Split coinbase should be a coinbase transaction")
+	}
+	if len(tx.Vout) != 2 {
+		t.Fatalf("Expected 2 outputs, got %d", len(tx.Vout))
+	}
+
+	total := 0
+	paid := make(map[string]int)
+	for _, out := range tx.Vout {
+		total += out.Value
+		for _, address := range []string{miner, dev} {
+			if out.IsLockedWithKey(AddressToPubKeyHash(address)) {
+				paid[address] += out.Value
+			}
+		}
+	}
+	if total != subsidy {
+		t.Errorf("Expected split to sum to %d, got %d", subsidy, total)
+	}
+	if paid[miner] != subsidy-10 || paid[dev] != 10 {
+		t.Errorf("Unexpected split: %v", paid)
+	}
+
+	// 相同输入得到相同的交易 ID
+	again, _ := NewCoinbaseTXMulti(map[string]int{dev: 10, miner: subsidy - 10}, "")
+	if !bytes.Equal(tx.ID, again.ID) {
+		t.Error("Split coinbase should be deterministic regardless of map order")
+	}
+
+	if _, err := NewCoinbaseTXMulti(nil, ""); err == nil {
+		t.Error("Expected error for a coinbase without outputs")
+	}
+	if _, err := NewCoinbaseTXMulti(map[string]int{miner: 0}, ""); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount, got %v", err)
+	}
+	if _, err := NewCoinbaseTXMulti(map[string]int{"invalid": 10}, ""); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+}
+
+// TestBlockchain_ChainedTransactionsInBlock 测试同一区块内子交易花费父交易的输出
+func TestBlockchain_ChainedTransactionsInBlock(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+	owner := PubKeyHashToAddress(HashPubKey(pubKey))
+	otherKey, other := newTestKey(t)
+
+	bc := NewBlockchain(owner, testNodeID)
+	defer bc.DB.Close()
+	genesis, _ := bc.GetBlock(bc.tip)
+
+	parent := &Transaction{
+		Vin:  []TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0, PubKey: pubKey}},
+		Vout: []TXOutput{newTestOutput(60, owner), newTestOutput(40, other)},
+	}
+	parent.ID = parent.Hash()
+	bc.SignTransaction(parent, *privKey)
+
+	child := &Transaction{
+		Vin:  []TXInput{{Txid: parent.ID, Vout: 0, PubKey: pubKey}},
+		Vout: []TXOutput{newTestOutput(60, other)},
+	}
+	child.ID = child.Hash()
+	child.Sign(*privKey, map[string]Transaction{hex.EncodeToString(parent.ID): *parent})
+
+	if bc.VerifyTransaction(child) {
+		t.Error("Child should not verify against the chain alone")
+	}
+	if !bc.VerifyTransactionInBlock(child, []*Transaction{parent}) {
+		t.Fatal("Child should verify when its parent precedes it in the block")
+	}
+
+	// 子交易排在父交易之前的区块无效
+	misordered := NewBlock([]*Transaction{NewCoinbaseTX(owner, "misordered"), child, parent}, bc.tip, 1)
+	if err := bc.ValidateBlock(misordered); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("Expected block with child before parent to be rejected, got %v", err)
+	}
+
+	block := NewBlock([]*Transaction{NewCoinbaseTX(owner, "chained"), parent, child}, bc.tip, 1)
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block with chained transactions: %v", err)
+	}
+	if !bytes.Equal(bc.tip, block.Hash) {
+		t.Fatal("Block with chained transactions should become the tip")
+	}
+
+	// 重建后父交易被花费的输出不会重新出现
+	utxoSet := UTXOSet{Blockchain: bc}
+	utxoSet.Reindex()
+	balances := utxoSet.GetBalances([]string{owner, other})
+	if balances[owner] != subsidy || balances[other] != 100 {
+		t.Errorf("Expected owner %d and other 100 after reindex, got %v", subsidy, balances)
+	}
+
+	// MineBlock 同样接受同区块内的链式交易
+	grandchild := &Transaction{
+		Vin:  []TXInput{{Txid: child.ID, Vout: 0}},
+		Vout: []TXOutput{newTestOutput(60, owner)},
+	}
+	grandchild.ID = grandchild.Hash()
+	grandchild.Sign(*otherKey, map[string]Transaction{hex.EncodeToString(child.ID): *child})
+	next := &Transaction{
+		Vin:  []TXInput{{Txid: grandchild.ID, Vout: 0}},
+		Vout: []TXOutput{newTestOutput(60, other)},
+	}
+	next.ID = next.Hash()
+	next.Sign(*privKey, map[string]Transaction{hex.EncodeToString(grandchild.ID): *grandchild})
+	if _, err := bc.MineBlock([]*Transaction{grandchild, next}, owner); err != nil {
+		t.Errorf("Expected MineBlock to accept chained transactions, got %v", err)
+	}
+}
+
+// TestBlockchain_ValidateTransaction 测试交易的各项验证规则
+func TestBlockchain_ValidateTransaction(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+	owner := PubKeyHashToAddress(HashPubKey(pubKey))
+	other := PubKeyHashToAddress(HashPubKey([]byte("validate_other")))
+
+	bc := NewBlockchain(owner, testNodeID)
+	defer bc.DB.Close()
+	genesis, _ := bc.GetBlock(bc.tip)
+	coinbaseID := genesis.Transactions[0].ID
+	prevTXs := map[string]Transaction{hex.EncodeToString(coinbaseID): *genesis.Transactions[0]}
+
+	newSpend := func(vin []TXInput, vout []TXOutput) *Transaction {
+		tx := &Transaction{Vin: vin, Vout: vout}
+		tx.ID = tx.Hash()
+		return tx
+	}
+	signed := func(vout []TXOutput) *Transaction {
+		tx := newSpend([]TXInput{{Txid: coinbaseID, Vout: 0, PubKey: pubKey}}, vout)
+		tx.Sign(*privKey, prevTXs)
+		return tx
+	}
+
+	valid := signed([]TXOutput{newTestOutput(60, other), newTestOutput(30, owner)})
+	if err := bc.ValidateTransaction(valid); err != nil {
+		t.Fatalf("Expected valid transaction to pass, got %v", err)
+	}
+	if err := bc.ValidateTransaction(NewCoinbaseTX(owner, "validate")); err != nil {
+		t.Errorf("Expected coinbase transaction to pass, got %v", err)
+	}
+
+	tampered := signed([]TXOutput{newTestOutput(60, other)})
+	tampered.Vout[0].Value = 70
+
+	noID := newSpend([]TXInput{{Txid: coinbaseID, Vout: 0}}, []TXOutput{newTestOutput(10, other)})
+	noID.ID = nil
+
+	tests := []struct {
+		name string
+		tx   *Transaction
+		err  error
+	}{
+		{"NoID", noID, ErrInvalidTransaction},
+		{"NoInputs", newSpend(nil, []TXOutput{newTestOutput(10, other)}), ErrInvalidTransaction},
+		{"NoOutputs", newSpend([]TXInput{{Txid: coinbaseID, Vout: 0}}, nil), ErrInvalidTransaction},
+		{"NegativeOutput", newSpend([]TXInput{{Txid: coinbaseID, Vout: 0}}, []TXOutput{{Value: -1, ScriptPubKey: HashPubKey(pubKey)}}), ErrInvalidTransaction},
+		{"CoinbaseInput", newSpend([]TXInput{{Txid: coinbaseID, Vout: 0}, {Txid: []byte{}, Vout: -1}}, []TXOutput{newTestOutput(10, other)}), ErrInvalidTransaction},
+		{"DuplicateInput", newSpend([]TXInput{{Txid: coinbaseID, Vout: 0}, {Txid: coinbaseID, Vout: 0}}, []TXOutput{newTestOutput(150, other)}), ErrDoubleSpend},
+		{"UnknownInput", newSpend([]TXInput{{Txid: []byte("missing"), Vout: 0}}, []TXOutput{newTestOutput(10, other)}), ErrTransactionNotFound},
+		{"OutputsExceedInputs", newSpend([]TXInput{{Txid: coinbaseID, Vout: 0}}, []TXOutput{newTestOutput(subsidy+1, other)}), ErrInvalidTransaction},
+		{"BadSignature", tampered, ErrInvalidTransaction},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := bc.ValidateTransaction(tc.tx)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Expected %v, got %v", tc.err, err)
+			}
+			if !errors.Is(err, ErrInvalidTransaction) {
+				t.Errorf("Expected error to match ErrInvalidTransaction, got %v", err)
+			}
+			if bc.VerifyTransaction(tc.tx) {
+				t.Error("VerifyTransaction should agree with ValidateTransaction")
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"go.etcd.io/bbolt"
+)
+
+const txIndexBucket = "txindex"
+
+// TxPool 内存池查询接口，用于判断交易是否处于未确认状态
+type TxPool interface {
+	HasTransaction(txID []byte) bool
+}
+
+// SetMempool 设置用于查询未确认交易的内存池
+func (bc *Blockchain) SetMempool(pool TxPool) {
+	bc.mempool = pool
+}
+
+// indexBlockTransactions 在交易索引中记录区块内每笔交易所在的区块
+func indexBlockTransactions(tx *bbolt.Tx, block *Block) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(txIndexBucket))
+	if err != nil {
+		return err
+	}
+
+	for _, transaction := range block.Transactions {
+		if err := b.Put(transaction.ID, block.Hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unindexBlockTransactions 从交易索引中移除指向该区块的交易记录
+func unindexBlockTransactions(tx *bbolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(txIndexBucket))
+	if b == nil {
+		return nil
+	}
+
+	for _, transaction := range block.Transactions {
+		if !bytes.Equal(b.Get(transaction.ID), block.Hash) {
+			continue
+		}
+		if err := b.Delete(transaction.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateTxIndexForTip 链尖从 oldTip 切换到 newTip 时更新交易索引
+// 从分叉点之后被放弃的主链区块先移出索引，再索引新分支上的区块，两条分支都有的交易指向新分支
+func updateTxIndexForTip(tx *bbolt.Tx, oldTip, newTip *Block) error {
+	blocks := tx.Bucket([]byte(blocksBucket))
+
+	var abandoned, connected []*Block
+	main, fork := oldTip, newTip
+	for !bytes.Equal(main.Hash, fork.Hash) {
+		var next **Block
+		var hash []byte
+		if main.Height >= fork.Height {
+			abandoned = append(abandoned, main)
+			next, hash = &main, main.PrevBlockHash
+		} else {
+			connected = append(connected, fork)
+			next, hash = &fork, fork.PrevBlockHash
+		}
+
+		data := blocks.Get(hash)
+		if len(hash) == 0 || data == nil {
+			return fmt.Errorf("block %x is not connected to the chain", newTip.Hash)
+		}
+		*next = DeserializeBlock(data)
+	}
+
+	for _, block := range abandoned {
+		if err := unindexBlockTransactions(tx, block); err != nil {
+			return err
+		}
+	}
+	for _, block := range connected {
+		if err := indexBlockTransactions(tx, block); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReindexTransactions 根据主链区块重建交易索引，返回已索引的交易数量
+func (bc *Blockchain) ReindexTransactions() int {
+	count := 0
+
+	err := bc.DB.Update(func(tx *bbolt.Tx) error {
+		err := tx.DeleteBucket([]byte(txIndexBucket))
+		if err != nil && err != bbolt.ErrBucketNotFound {
+			return err
+		}
+
+		blocks := tx.Bucket([]byte(blocksBucket))
+		hash := blocks.Get([]byte("l"))
+		for len(hash) > 0 {
+			block := DeserializeBlock(blocks.Get(hash))
+			if err := indexBlockTransactions(tx, block); err != nil {
+				return err
+			}
+			count += len(block.Transactions)
+			hash = block.PrevBlockHash
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return count
+}
+
+// findConfirmedTransaction 通过交易索引查找已确认的交易及其所在区块高度
+func (bc *Blockchain) findConfirmedTransaction(txid []byte) (*Transaction, int, bool) {
+	var found *Transaction
+	height := 0
+
+	err := bc.DB.View(func(tx *bbolt.Tx) error {
+		index := tx.Bucket([]byte(txIndexBucket))
+		if index == nil {
+			return nil
+		}
+
+		blockHash := index.Get(txid)
+		if blockHash == nil {
+			return nil
+		}
+
+		blockData := tx.Bucket([]byte(blocksBucket)).Get(blockHash)
+		if blockData == nil {
+			return nil
+		}
+
+		block := DeserializeBlock(blockData)
+		for _, transaction := range block.Transactions {
+			if bytes.Equal(transaction.ID, txid) {
+				found = transaction
+				height = block.Height
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != nil || found == nil {
+		return nil, 0, false
+	}
+
+	return found, height, true
+}
+
+// GetTransactionConfirmations 返回交易的确认数
+// 交易仅在内存池中时返回 0，交易未知时返回错误
+func (bc *Blockchain) GetTransactionConfirmations(txid []byte) (int, error) {
+	var blockHeight int
+	found := false
+
+	err := bc.DB.View(func(tx *bbolt.Tx) error {
+		index := tx.Bucket([]byte(txIndexBucket))
+		if index == nil {
+			return nil
+		}
+
+		blockHash := index.Get(txid)
+		if blockHash == nil {
+			return nil
+		}
+
+		blockData := tx.Bucket([]byte(blocksBucket)).Get(blockHash)
+		if blockData == nil {
+			return fmt.Errorf("indexed block %x is missing", blockHash)
+		}
+		blockHeight = DeserializeBlock(blockData).Height
+		found = true
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if found {
+		return bc.GetBestHeight() - blockHeight + 1, nil
+	}
+
+	if bc.mempool != nil && bc.mempool.HasTransaction(txid) {
+		return 0, nil
+	}
+
+	return 0, ErrTransactionNotFound
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"fmt"
 	"log"
 	"math/big"
 
@@ -12,6 +13,11 @@ import (
 
 const addressChecksumLen = 4
 
+const (
+	MainnetAddressVersion = byte(0x00) // 主网地址版本号
+	TestnetAddressVersion = byte(0x6f) // 测试网地址版本号
+)
+
 // Base58Encode 将字节数组编码为 Base58 格式
 func Base58Encode(input []byte) []byte {
 	var result []byte
@@ -74,8 +80,28 @@ func Base58Decode(input []byte) []byte {
 	return decodedWithZeros
 }
 
-// ValidateAddress 检查地址是否有效
+// Base58CheckDecode 解码带版本号和校验和的 Base58 地址，返回版本号和公钥哈希
+func Base58CheckDecode(address string) (byte, []byte, error) {
+	decoded := Base58Decode([]byte(address))
+	if len(decoded) < addressChecksumLen+1 {
+		return 0, nil, fmt.Errorf("%w: %q decodes to %d bytes", ErrInvalidAddress, address, len(decoded))
+	}
+
+	payload := decoded[:len(decoded)-addressChecksumLen]
+	if !bytes.Equal(decoded[len(decoded)-addressChecksumLen:], checksum(payload)) {
+		return 0, nil, fmt.Errorf("%w: %q has an invalid checksum", ErrInvalidAddress, address)
+	}
+
+	return payload[0], payload[1:], nil
+}
+
+// ValidateAddress 检查地址是否为有效的主网地址
 func ValidateAddress(address string) bool {
+	return ValidateAddressWithVersion(address, MainnetAddressVersion)
+}
+
+// ValidateAddressWithVersion 检查地址校验和及版本号是否有效
+func ValidateAddressWithVersion(address string, allowedVersion byte) bool {
 	pubKeyHash := Base58Decode([]byte(address))
 
 	// 检查地址长度是否足够
@@ -85,12 +111,23 @@ func ValidateAddress(address string) bool {
 
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
 	version := pubKeyHash[0]
+	if version != allowedVersion {
+		return false
+	}
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
 	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
 
 	return bytes.Compare(actualChecksum, targetChecksum) == 0
 }
 
+// PubKeyHashToAddress 将公钥哈希编码为主网地址
+func PubKeyHashToAddress(pubKeyHash []byte) string {
+	payload := append([]byte{MainnetAddressVersion}, pubKeyHash...)
+	payload = append(payload, checksum(payload)...)
+
+	return string(Base58Encode(payload))
+}
+
 // checksum 为公钥哈希生成校验和
 func checksum(payload []byte) []byte {
 	firstSHA := sha256.Sum256(payload)
@@ -51,6 +51,26 @@ func TestValidateAddress(t *testing.T) {
 	}
 }
 
+// TestValidateAddressWithVersion 测试地址版本号校验
+func TestValidateAddressWithVersion(t *testing.T) {
+	pubKeyHash := HashPubKey([]byte("test_public_key"))
+	payload := append([]byte{TestnetAddressVersion}, pubKeyHash...)
+	testnetAddress := string(Base58Encode(append(payload, checksum(payload)...)))
+
+	if ValidateAddress(testnetAddress) {
+		t.Errorf("Testnet address %s should fail mainnet validation", testnetAddress)
+	}
+
+	if !ValidateAddressWithVersion(testnetAddress, TestnetAddressVersion) {
+		t.Errorf("Testnet address %s should pass testnet validation", testnetAddress)
+	}
+
+	mainnetAddress := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+	if ValidateAddressWithVersion(mainnetAddress, TestnetAddressVersion) {
+		t.Errorf("Mainnet address %s should fail testnet validation", mainnetAddress)
+	}
+}
+
 // TestHashPubKey 测试公钥哈希
 func TestHashPubKey(t *testing.T) {
 	pubKey := []byte("test_public_key")
@@ -111,3 +131,55 @@ func TestIntToHex(t *testing.T) {
 		}
 	}
 }
+
+// TestAddressToPubKeyHash 测试缓存与非缓存的地址解码结果一致
+func TestAddressToPubKeyHash(t *testing.T) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+
+	uncached := decodeAddressPubKeyHash(address)
+	first := AddressToPubKeyHash(address)
+	second := AddressToPubKeyHash(address)
+
+	if string(first) != string(uncached) || string(second) != string(uncached) {
+		t.Errorf("Cached pubkey hash %x does not match uncached %x", first, uncached)
+	}
+
+	if _, ok := pubKeyHashCache.get(address); !ok {
+		t.Error("Address should be cached after lookup")
+	}
+
+	cache := newAddressCache(2)
+	cache.add("a", []byte{1})
+	cache.add("b", []byte{2})
+	cache.get("a")
+	cache.add("c", []byte{3})
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("Least recently used entry should be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Error("Recently used entry should be kept")
+	}
+	if cache.len() != 2 {
+		t.Errorf("Expected cache length 2, got %d", cache.len())
+	}
+}
+
+// BenchmarkAddressToPubKeyHash 对比缓存与非缓存的地址解码
+func BenchmarkAddressToPubKeyHash(b *testing.B) {
+	address := "17BJsKiaasXt4S7EKe9PhtZAZF74VJZwGv"
+
+	b.Run("Uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decodeAddressPubKeyHash(address)
+		}
+	})
+
+	b.Run("Cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			AddressToPubKeyHash(address)
+		}
+	})
+}
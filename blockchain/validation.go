@@ -0,0 +1,416 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// medianTimeSpan 计算中位时间所用的区块数
+	medianTimeSpan = 11
+	// DefaultMaxFutureBlockTime 区块时间戳允许超前本地时钟的默认偏差
+	DefaultMaxFutureBlockTime = 2 * time.Hour
+)
+
+// SetMaxFutureBlockTime 设置区块时间戳允许超前区块链时钟的最大偏差，小于等于 0 时使用默认值
+func (bc *Blockchain) SetMaxFutureBlockTime(drift time.Duration) {
+	if drift <= 0 {
+		drift = DefaultMaxFutureBlockTime
+	}
+	bc.maxFutureBlockTime = drift
+}
+
+// MaxFutureBlockTime 返回区块时间戳允许超前区块链时钟的最大偏差
+func (bc *Blockchain) MaxFutureBlockTime() time.Duration {
+	if bc.maxFutureBlockTime <= 0 {
+		return DefaultMaxFutureBlockTime
+	}
+	return bc.maxFutureBlockTime
+}
+
+// SetMinBlockTxFee 设置区块内非 coinbase 交易的共识最低手续费，小于等于 0 时不限制
+// 默认不限制，矿工可以打包低于转发手续费的交易
+func (bc *Blockchain) SetMinBlockTxFee(fee int) {
+	if fee < 0 {
+		fee = 0
+	}
+	bc.minBlockTxFee = fee
+}
+
+// invalidBlockError 区块验证失败的原因，同时匹配 ErrInvalidBlock 和具体原因
+type invalidBlockError struct {
+	err error
+}
+
+// Error 返回具体的验证失败原因
+func (e *invalidBlockError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap 使 errors.Is 同时匹配 ErrInvalidBlock 和具体原因
+func (e *invalidBlockError) Unwrap() []error {
+	return []error{ErrInvalidBlock, e.err}
+}
+
+// ValidateBlock 对区块进行完整验证：工作量证明、与本地链的高度连续性以及区块内所有交易
+// 验证失败时返回的错误匹配 ErrInvalidBlock
+func (bc *Blockchain) ValidateBlock(block *Block) error {
+	if err := bc.checkBlock(block); err != nil {
+		return &invalidBlockError{err}
+	}
+
+	return nil
+}
+
+// checkBlock 执行 ValidateBlock 的各项检查
+func (bc *Blockchain) checkBlock(block *Block) error {
+	if block == nil || len(block.Hash) == 0 {
+		return fmt.Errorf("block is empty")
+	}
+
+	// 工作量证明：哈希必须满足目标值，且与区块头数据一致
+	pow := NewProofOfWork(block)
+	if !pow.Validate() {
+		return fmt.Errorf("block %x has invalid proof of work (nonce %d)", block.Hash, block.Nonce)
+	}
+	hash := pow.Hash(block.Nonce)
+	if !bytes.Equal(hash, block.Hash) {
+		return fmt.Errorf("block hash %x does not match header hash %x", block.Hash, hash)
+	}
+
+	// 高度连续性：父区块必须已在本地链中，且高度恰好加一
+	parent, err := bc.GetBlock(block.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("parent block %x of block %x not found", block.PrevBlockHash, block.Hash)
+	}
+	if block.Height != parent.Height+1 {
+		return fmt.Errorf("block %x has height %d, expected %d", block.Hash, block.Height, parent.Height+1)
+	}
+
+	// 版本：旧链上已有的旧版本区块仍可同步，但版本化区块之后不能再接旧版本区块，
+	// 否则矿工可以用旧版本绕过对高度的工作量证明承诺
+	if parent.Version >= CurrentBlockVersion && block.Version < CurrentBlockVersion {
+		return fmt.Errorf("block %x has version %d, expected at least %d", block.Hash, block.Version, CurrentBlockVersion)
+	}
+
+	// 难度不得低于按父区块调整后的目标位数，也就不会低于难度下限
+	requiredBits, err := bc.NextTargetBits(&parent)
+	if err != nil {
+		return err
+	}
+	if pow.TargetBits() < requiredBits {
+		return fmt.Errorf("block %x has target bits %d, below required %d", block.Hash, pow.TargetBits(), requiredBits)
+	}
+
+	// 时间戳：不能超前本地时间太多，也不能早于之前若干区块的中位时间
+	if err := bc.validateTimestamp(block, &parent); err != nil {
+		return err
+	}
+
+	// 交易验证
+	if len(block.Transactions) == 0 {
+		return fmt.Errorf("block %x has no transactions", block.Hash)
+	}
+	// 以当前链尖为父区块时，链上引用的输出还必须未被花费
+	return bc.checkBlockTransactions(block, bytes.Equal(block.PrevBlockHash, bc.lastHash()))
+}
+
+// checkBlockTransactions 执行区块级的交易检查：coinbase 位置、区块内双花、
+// 每笔交易的有效性和共识最低手续费，以及 coinbase 金额上限
+// checkUnspent 为 true 时链上引用的输出还必须在 UTXO 集合中
+func (bc *Blockchain) checkBlockTransactions(block *Block, checkUnspent bool) error {
+	if len(block.Transactions) == 0 {
+		return nil
+	}
+	if err := validateCoinbasePlacement(block); err != nil {
+		return err
+	}
+	if err := validateNoDoubleSpend(block); err != nil {
+		return err
+	}
+	// 交易可以花费同一区块中排在它之前的交易的输出
+	inBlock := make(map[string]*Transaction, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		if err := bc.validateTransaction(tx, inBlock, checkUnspent); err != nil {
+			return fmt.Errorf("block %x contains invalid transaction %x at index %d: %w", block.Hash, tx.ID, i, err)
+		}
+		if err := bc.validateMinFee(tx, inBlock); err != nil {
+			return fmt.Errorf("block %x contains transaction %x at index %d: %v", block.Hash, tx.ID, i, err)
+		}
+		inBlock[hex.EncodeToString(tx.ID)] = tx
+	}
+	if err := bc.validateCoinbaseValue(block); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateCoinbaseValue 检查 coinbase 输出总额不超过区块奖励加区块内交易手续费
+// 未领取全部手续费的区块仍然有效
+func (bc *Blockchain) validateCoinbaseValue(block *Block) error {
+	if !block.Transactions[0].IsCoinbase() {
+		return nil
+	}
+
+	fees, err := bc.blockFees(block)
+	if err != nil {
+		return err
+	}
+	allowed := bc.BlockSubsidy() + fees
+
+	total := 0
+	for _, out := range block.Transactions[0].Vout {
+		total += out.Value
+	}
+	if total > allowed {
+		return fmt.Errorf("block %x coinbase pays %d, exceeding the allowed reward %d", block.Hash, total, allowed)
+	}
+
+	return nil
+}
+
+// blockFees 返回区块内非 coinbase 交易的手续费总和
+func (bc *Blockchain) blockFees(block *Block) (int, error) {
+	fees := 0
+	inBlock := make(map[string]*Transaction, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			fee, err := bc.transactionFee(tx, inBlock)
+			if err != nil {
+				return 0, fmt.Errorf("block %x contains transaction %x: %v", block.Hash, tx.ID, err)
+			}
+			fees += fee
+		}
+		inBlock[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	return fees, nil
+}
+
+// validateMinFee 在启用共识最低手续费时检查交易手续费不低于下限
+func (bc *Blockchain) validateMinFee(tx *Transaction, inBlock map[string]*Transaction) error {
+	if bc.minBlockTxFee <= 0 || tx.IsCoinbase() {
+		return nil
+	}
+
+	fee, err := bc.transactionFee(tx, inBlock)
+	if err != nil {
+		return err
+	}
+	if fee < bc.minBlockTxFee {
+		return fmt.Errorf("fee %d is below the consensus minimum %d", fee, bc.minBlockTxFee)
+	}
+
+	return nil
+}
+
+// transactionFee 根据引用的同区块交易或链上交易计算非 coinbase 交易的手续费
+func (bc *Blockchain) transactionFee(tx *Transaction, inBlock map[string]*Transaction) (int, error) {
+	prevTXs, err := bc.findPrevTransactions(tx, inBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	return tx.Fee(prevTXs)
+}
+
+// ValidateTransaction 对交易进行完整验证：结构、输入不重复、签名以及输入总额不小于输出总额
+// 引用的交易在链上查找，验证失败时返回的错误匹配 ErrInvalidTransaction
+func (bc *Blockchain) ValidateTransaction(tx *Transaction) error {
+	return bc.validateTransactionWith(tx, nil)
+}
+
+// ValidateTransactionInBlock 与 ValidateTransaction 相同，但允许交易花费 preceding 中交易的输出
+// 用于同一区块中排在前面的交易或内存池中尚未上链的父交易
+func (bc *Blockchain) ValidateTransactionInBlock(tx *Transaction, preceding []*Transaction) error {
+	inBlock := make(map[string]*Transaction, len(preceding))
+	for _, prev := range preceding {
+		inBlock[hex.EncodeToString(prev.ID)] = prev
+	}
+
+	return bc.validateTransactionWith(tx, inBlock)
+}
+
+// validateTransactionWith 执行交易验证，引用的交易先在 inBlock 中查找，再在链上查找
+// 链上引用的输出必须仍在 UTXO 集合中，因此只适用于以当前链尖为父区块的交易
+func (bc *Blockchain) validateTransactionWith(tx *Transaction, inBlock map[string]*Transaction) error {
+	return bc.validateTransaction(tx, inBlock, true)
+}
+
+// validateTransaction 执行交易验证，checkUnspent 为 false 时不检查链上引用的输出是否已被花费，
+// 用于不在当前链尖上的分叉区块，其输出是否可用由 UTXO 集合无法判断
+func (bc *Blockchain) validateTransaction(tx *Transaction, inBlock map[string]*Transaction, checkUnspent bool) error {
+	if err := checkTransactionStructure(tx); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTransaction, err)
+	}
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	prevTXs, err := bc.findPrevTransactions(tx, inBlock)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTransaction, err)
+	}
+	if checkUnspent {
+		if err := bc.checkInputsUnspent(tx, inBlock); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTransaction, err)
+		}
+	}
+
+	// 输入总额必须不小于输出总额，差额即为手续费
+	if _, err := tx.Fee(prevTXs); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTransaction, err)
+	}
+
+	if !tx.Verify(prevTXs) {
+		return fmt.Errorf("%w: transaction %x has an invalid signature", ErrInvalidTransaction, tx.ID)
+	}
+
+	return nil
+}
+
+// checkInputsUnspent 检查交易引用的链上输出仍在 UTXO 集合中，inBlock 中的交易输出不检查
+// 尚未建立 UTXO 集合的旧数据库跳过该检查
+func (bc *Blockchain) checkInputsUnspent(tx *Transaction, inBlock map[string]*Transaction) error {
+	bc.utxoMutex.RLock()
+	defer bc.utxoMutex.RUnlock()
+
+	return bc.DB.View(func(dbTx *bbolt.Tx) error {
+		b := dbTx.Bucket([]byte(utxoBucket))
+		if b == nil {
+			return nil
+		}
+
+		for _, vin := range tx.Vin {
+			if _, ok := inBlock[hex.EncodeToString(vin.Txid)]; ok {
+				continue
+			}
+			if b.Get(vin.Txid) == nil {
+				return fmt.Errorf("%w: transaction %x spends output %x:%d that is already spent", ErrDoubleSpend, tx.ID, vin.Txid, vin.Vout)
+			}
+		}
+
+		return nil
+	})
+}
+
+// checkTransactionStructure 执行不依赖链状态的交易结构检查
+func checkTransactionStructure(tx *Transaction) error {
+	if tx == nil || len(tx.ID) == 0 {
+		return fmt.Errorf("transaction has no ID")
+	}
+	if len(tx.Vin) == 0 {
+		return fmt.Errorf("transaction %x has no inputs", tx.ID)
+	}
+	if len(tx.Vout) == 0 {
+		return fmt.Errorf("transaction %x has no outputs", tx.ID)
+	}
+	if err := tx.ValidateOutputValues(); err != nil {
+		return fmt.Errorf("transaction %x: %v", tx.ID, err)
+	}
+	if tx.IsCoinbase() {
+		return nil
+	}
+	if hasCoinbaseInput(tx) {
+		return fmt.Errorf("transaction %x mixes coinbase inputs with regular inputs", tx.ID)
+	}
+
+	spent := make(map[string]bool, len(tx.Vin))
+	for _, vin := range tx.Vin {
+		outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+		if spent[outpoint] {
+			return fmt.Errorf("%w: transaction %x spends output %s twice", ErrDoubleSpend, tx.ID, outpoint)
+		}
+		spent[outpoint] = true
+	}
+
+	return nil
+}
+
+// validateTimestamp 检查区块时间戳不超过区块链时钟加允许偏差，且不早于父区块起的中位时间
+func (bc *Blockchain) validateTimestamp(block *Block, parent *Block) error {
+	limit := bc.now().Add(bc.MaxFutureBlockTime()).Unix()
+	if block.Timestamp > limit {
+		return fmt.Errorf("block %x timestamp %d is too far in the future (limit %d)", block.Hash, block.Timestamp, limit)
+	}
+
+	median := bc.medianTimePast(parent)
+	if block.Timestamp < median {
+		return fmt.Errorf("block %x timestamp %d is before median time past %d", block.Hash, block.Timestamp, median)
+	}
+
+	return nil
+}
+
+// medianTimePast 返回从 block 开始向前最多 medianTimeSpan 个区块时间戳的中位数
+func (bc *Blockchain) medianTimePast(block *Block) int64 {
+	timestamps := make([]int64, 0, medianTimeSpan)
+	current := *block
+	for len(timestamps) < medianTimeSpan {
+		timestamps = append(timestamps, current.Timestamp)
+		if len(current.PrevBlockHash) == 0 {
+			break
+		}
+
+		prev, err := bc.GetBlock(current.PrevBlockHash)
+		if err != nil {
+			break
+		}
+		current = prev
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+// validateNoDoubleSpend 检查区块内没有两个输入花费同一个输出
+func validateNoDoubleSpend(block *Block) error {
+	spent := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.Vin {
+			outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+			if spent[outpoint] {
+				return fmt.Errorf("%w: block %x spends output %s twice", ErrDoubleSpend, block.Hash, outpoint)
+			}
+			spent[outpoint] = true
+		}
+	}
+
+	return nil
+}
+
+// validateCoinbasePlacement 检查区块中只有第一笔交易可以是 coinbase
+// 不发放奖励的区块可以没有 coinbase
+func validateCoinbasePlacement(block *Block) error {
+	hasCoinbase := block.Transactions[0].IsCoinbase()
+
+	for i, tx := range block.Transactions[1:] {
+		if hasCoinbaseInput(tx) {
+			if !hasCoinbase {
+				return fmt.Errorf("first transaction %x of block %x is not a coinbase", block.Transactions[0].ID, block.Hash)
+			}
+			return fmt.Errorf("block %x contains coinbase transaction %x at index %d", block.Hash, tx.ID, i+1)
+		}
+	}
+
+	return nil
+}
+
+// hasCoinbaseInput 检查交易是否含有 coinbase 形式的输入（空 Txid 或 Vout 为 -1）
+func hasCoinbaseInput(tx *Transaction) bool {
+	for _, vin := range tx.Vin {
+		if len(vin.Txid) == 0 || vin.Vout == -1 {
+			return true
+		}
+	}
+	return false
+}
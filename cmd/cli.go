@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"mini-coin-go/blockchain"
 	"mini-coin-go/network"
+	"mini-coin-go/network/security"
 	"mini-coin-go/wallet"
 )
 
@@ -21,10 +28,24 @@ func (cli *CLI) printUsage() {
 	fmt.Println("  createblockchain -address ADDRESS - Create a blockchain and send genesis reward to ADDRESS")
 	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
 	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
+	fmt.Println("  getblockheader -hash HASH - Print the header of the block with HASH")
+	fmt.Println("  getblocktxs -hash HASH - List the transactions in the block with HASH")
+	fmt.Println("  getchaintips - List all known chain tips, including forks")
+	fmt.Println("  getconnectioncount [-node HOST:PORT] - Print the number of connections the running node is handling")
+	fmt.Println("  getdifficulty - Print the proof-of-work difficulty of the chain tip")
+	fmt.Println("  getnewaddress - Print a wallet address that has never received funds, creating one if needed")
+	fmt.Println("  getsupply - Print the total amount of coins issued so far")
 	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
-	fmt.Println("  printchain - Print all the blocks of the blockchain")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT - Send AMOUNT of coins from FROM address to TO")
-	fmt.Println("  startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var.")
+	fmt.Println("  listconnections [-node HOST:PORT] - List the running node's connections with their age, idle time and usage count")
+	fmt.Println("  pingpeer -address HOST:PORT [-auth] - Check connectivity and the version (and optionally authenticated) handshake with a peer")
+	fmt.Println("  printchain [-limit N] [-from HASH] - Print the blocks of the blockchain, newest first")
+	fmt.Println("  reindex - Rebuild the UTXO set and transaction index from the block data")
+	fmt.Println("  resendwallettransactions - Ask the local node to rebroadcast its unconfirmed transactions")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT [-newchange] - Send AMOUNT of coins from FROM address to TO")
+	fmt.Println("  status [-node HOST:PORT] - Print a JSON report of the running node's chain height, mempool, peers and uptime")
+	fmt.Println("  signmessage -address ADDRESS -message TEXT - Sign TEXT with the wallet key of ADDRESS")
+	fmt.Println("  verifymessage -address ADDRESS -message TEXT -signature SIG - Verify a message signature for ADDRESS")
+	fmt.Println("  startnode -miner ADDRESS[:WEIGHT][,...] [-bind HOST] [-advertise HOST:PORT] [-maxblocktxs N] [-auth] - Start a node with ID specified in NODE_ID env. var.")
 }
 
 // validateArgs 确保命令行参数有效
@@ -35,6 +56,26 @@ func (cli *CLI) validateArgs() {
 	}
 }
 
+// readOnlyOpenTimeout 只读打开区块链时等待数据库文件锁的时间
+var readOnlyOpenTimeout = 5 * time.Second
+
+// openReadOnlyBlockchain 以只读模式打开区块链，供只查询的命令使用
+// 运行中的节点以读写模式持有数据库的排他锁，此时无法打开，需要先停止节点
+func openReadOnlyBlockchain(nodeID string) *blockchain.Blockchain {
+	bc, err := blockchain.NewBlockchainWithOptions("", nodeID, &blockchain.Options{
+		ReadOnly: true,
+		Timeout:  readOnlyOpenTimeout,
+	})
+	if errors.Is(err, blockchain.ErrDatabaseLocked) {
+		log.Panicf("ERROR: %v Is node %s running? Stop it before running query commands.", err, nodeID)
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return bc
+}
+
 // createBlockchain 创建区块链
 func (cli *CLI) createBlockchain(address string, nodeID string) {
 	if !blockchain.ValidateAddress(address) {
@@ -43,7 +84,7 @@ func (cli *CLI) createBlockchain(address string, nodeID string) {
 	bc := blockchain.NewBlockchain(address, nodeID)
 	defer bc.DB.Close()
 
-	UTXOSet := blockchain.UTXOSet{bc}
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
 	UTXOSet.Reindex()
 
 	fmt.Println("Done!")
@@ -63,13 +104,12 @@ func (cli *CLI) getBalance(address string, nodeID string) {
 	if !blockchain.ValidateAddress(address) {
 		log.Panic("ERROR: Address is not valid")
 	}
-	bc := blockchain.NewBlockchain("", nodeID)
-	UTXOSet := blockchain.UTXOSet{bc}
+	bc := openReadOnlyBlockchain(nodeID)
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
 	defer bc.DB.Close()
 
 	balance := 0
-	pubKeyHash := blockchain.Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	pubKeyHash := blockchain.AddressToPubKeyHash(address)
 	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
 
 	for _, out := range UTXOs {
@@ -92,17 +132,71 @@ func (cli *CLI) listAddresses(nodeID string) {
 	}
 }
 
-// printChain 打印区块链
-func (cli *CLI) printChain(nodeID string) {
-	bc := blockchain.NewBlockchain("", nodeID)
+// getNewAddress 输出一个从未收到过币的钱包地址，没有时创建新地址
+func (cli *CLI) getNewAddress(nodeID string) {
+	bc := openReadOnlyBlockchain(nodeID)
 	defer bc.DB.Close()
+
+	wallets, _ := wallet.NewWallets(nodeID)
+	address, created := wallets.GetUnusedAddress(bc)
+	if created {
+		wallets.SaveToFile(nodeID)
+	}
+
+	fmt.Println(address)
+}
+
+// pingPeer 诊断与指定节点的连通性，auth 为 true 时要求对方证明节点身份
+func (cli *CLI) pingPeer(address string, auth bool) {
+	if address == "" {
+		log.Panic("ERROR: Peer address is required")
+	}
+
+	var nodeAuth *security.NodeAuth
+	if auth {
+		var err error
+		nodeAuth, err = security.NewNodeAuth("pingpeer")
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	result, err := network.PingPeerWithAuth(address, 0, nodeAuth)
+	if err != nil {
+		fmt.Printf("Ping %s failed: %v\n", address, err)
+		return
+	}
+
+	fmt.Printf("Connected to %s in %v\n", result.Address, result.ConnectTime)
+	fmt.Printf("Handshake RTT: %v\n", result.RTT)
+	fmt.Printf("Peer version: %d\n", result.Version)
+	fmt.Printf("Peer best height: %d\n", result.BestHeight)
+	if auth {
+		fmt.Printf("Peer authenticated as: %s\n", result.PeerID)
+	}
+}
+
+// printChain 打印区块链，limit 大于 0 时最多打印 limit 个区块，fromHash 非空时从该区块开始
+func (cli *CLI) printChain(nodeID string, limit int, fromHash string) {
+	bc := openReadOnlyBlockchain(nodeID)
+	defer bc.DB.Close()
+
 	bci := bc.Iterator()
+	if fromHash != "" {
+		hash, err := hex.DecodeString(fromHash)
+		if err != nil {
+			log.Panic("ERROR: Block hash is not valid")
+		}
+		if _, err := bc.GetBlock(hash); err != nil {
+			log.Panic(err)
+		}
+		bci = bc.IteratorFrom(hash)
+	}
 
-	for {
+	for printed := 0; limit <= 0 || printed < limit; printed++ {
 		block := bci.Next()
 
-		fmt.Printf("============ Block %x ============\n", block.Hash)
-		fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
+		fmt.Println(block)
 		pow := blockchain.NewProofOfWork(block)
 		fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
 		for _, tx := range block.Transactions {
@@ -116,8 +210,170 @@ func (cli *CLI) printChain(nodeID string) {
 	}
 }
 
+// getBlockHeader 打印指定区块的区块头
+func (cli *CLI) getBlockHeader(hashHex, nodeID string) {
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		log.Panic("ERROR: Block hash is not valid")
+	}
+
+	bc := openReadOnlyBlockchain(nodeID)
+	defer bc.DB.Close()
+
+	header, err := bc.GetBlockHeader(hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Hash: %x\n", header.Hash)
+	fmt.Printf("Height: %d\n", header.Height)
+	fmt.Printf("Timestamp: %s\n", time.Unix(header.Timestamp, 0).Format("2006-01-02 15:04:05"))
+	fmt.Printf("Prev. block: %x\n", header.PrevBlockHash)
+	fmt.Printf("Merkle root: %x\n", header.MerkleRoot)
+	fmt.Printf("Nonce: %d\n", header.Nonce)
+	fmt.Printf("Target bits: %d\n", header.TargetBits)
+	fmt.Printf("Transactions: %d\n", header.TxCount)
+}
+
+// getBlockTxs 打印指定区块中每笔交易的 ID 和摘要
+func (cli *CLI) getBlockTxs(hashHex, nodeID string) {
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		log.Panic("ERROR: Block hash is not valid")
+	}
+
+	bc := openReadOnlyBlockchain(nodeID)
+	defer bc.DB.Close()
+
+	txs, err := bc.GetBlockTransactions(hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, tx := range txs {
+		total := 0
+		for _, out := range tx.Vout {
+			total += out.Value
+		}
+
+		kind := "transfer"
+		if tx.IsCoinbase() {
+			kind = "coinbase"
+		}
+		fmt.Printf("%x %s inputs: %d outputs: %d value: %d\n", tx.ID, kind, len(tx.Vin), len(tx.Vout), total)
+	}
+}
+
+// getChainTips 打印所有已知链尖
+func (cli *CLI) getChainTips(nodeID string) {
+	bc := openReadOnlyBlockchain(nodeID)
+	defer bc.DB.Close()
+
+	for _, tip := range bc.GetChainTips() {
+		fmt.Printf("%x height: %d status: %s\n", tip.Hash, tip.Height, tip.Status)
+	}
+}
+
+// getDifficulty 打印链尖区块的工作量证明难度
+func (cli *CLI) getDifficulty(nodeID string) {
+	bc := openReadOnlyBlockchain(nodeID)
+	defer bc.DB.Close()
+
+	tip := bc.Iterator().Next()
+	pow := blockchain.NewProofOfWork(tip)
+
+	fmt.Printf("Target bits: %d\n", pow.TargetBits())
+	fmt.Printf("Difficulty: %.2f\n", pow.Difficulty())
+}
+
+// getSupply 打印当前已发行的币量
+func (cli *CLI) getSupply(nodeID string) {
+	bc := openReadOnlyBlockchain(nodeID)
+	defer bc.DB.Close()
+
+	fmt.Printf("Height: %d\n", bc.GetBestHeight())
+	fmt.Printf("Total supply: %d\n", bc.TotalSupply())
+}
+
+// resendWalletTransactions 请求本地节点向已知节点重新广播内存池中的交易
+func (cli *CLI) resendWalletTransactions(nodeID string) {
+	address := fmt.Sprintf("localhost:%s", nodeID)
+	network.SendResend(address)
+
+	fmt.Printf("Requested %s to rebroadcast its mempool\n", address)
+}
+
+// queryConnections 查询运行中节点的连接报告，address 为空时查询本机的 NODE_ID 节点
+func queryConnections(nodeID, address string) *network.ConnectionsReport {
+	if address == "" {
+		address = fmt.Sprintf("localhost:%s", nodeID)
+	}
+
+	report, err := network.QueryConnections(address, 0)
+	if err != nil {
+		log.Panicf("ERROR: Failed to query node %s, is it running? %v", address, err)
+	}
+	return report
+}
+
+// getConnectionCount 输出运行中节点的连接数
+func (cli *CLI) getConnectionCount(nodeID, address string) {
+	fmt.Println(queryConnections(nodeID, address).Count)
+}
+
+// listConnections 列出运行中节点每个连接的远程地址、存在时长、空闲时长和使用次数
+func (cli *CLI) listConnections(nodeID, address string) {
+	report := queryConnections(nodeID, address)
+	if len(report.Connections) == 0 {
+		fmt.Println("No connections")
+		return
+	}
+
+	for _, info := range report.Connections {
+		age := time.Duration(info.Age * float64(time.Second))
+		idle := time.Duration(info.IdleTime * float64(time.Second))
+		fmt.Printf("%s  age: %s  idle: %s  uses: %d\n", info.RemoteAddr,
+			age.Round(time.Second), idle.Round(time.Second), info.UsageCount)
+	}
+}
+
+// status 以 JSON 格式打印运行中节点的状态报告，address 为空时查询本机的 NODE_ID 节点
+func (cli *CLI) status(nodeID, address string) {
+	if address == "" {
+		address = fmt.Sprintf("localhost:%s", nodeID)
+	}
+
+	report, err := network.QueryStatus(address, 0)
+	if err != nil {
+		log.Panicf("ERROR: Failed to query node %s, is it running? %v", address, err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(string(data))
+}
+
+// reindex 根据区块数据重建 UTXO 集合
+func (cli *CLI) reindex(nodeID string) {
+	bc := blockchain.NewBlockchain("", nodeID)
+	defer bc.DB.Close()
+
+	fmt.Printf("Rebuilding UTXO set from %d blocks...\n", bc.GetBestHeight()+1)
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+	UTXOSet.Reindex()
+
+	count := UTXOSet.CountTransactions()
+	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
+
+	fmt.Println("Rebuilding transaction index...")
+	indexed := bc.ReindexTransactions()
+	fmt.Printf("Done! Indexed %d transactions.\n", indexed)
+}
+
 // send 发送交易
-func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
+func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow, newChange bool) {
 	if !blockchain.ValidateAddress(from) {
 		log.Panic("ERROR: Sender address is not valid")
 	}
@@ -126,16 +382,42 @@ func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
 	}
 
 	bc := blockchain.NewBlockchain("", nodeID)
-	UTXOSet := blockchain.UTXOSet{bc}
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
 	defer bc.DB.Close()
 
-	tx := blockchain.NewUTXOTransaction(from, to, amount, &UTXOSet)
+	wallets, err := wallet.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
 
-	if mineNow {
-		cbTx := blockchain.NewCoinbaseTX(from, "")
-		txs := []*blockchain.Transaction{cbTx, tx}
+	var changeSource blockchain.ChangeAddressSource
+	if newChange {
+		changeSource = wallets
+	}
+	tx, err := blockchain.NewUTXOTransactionWithChange(from, to, amount, &UTXOSet, changeSource)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	if newChange {
+		// 保存新生成的找零地址，否则找零无法花费
+		wallets.SaveToFile(nodeID)
+	}
+
+	senderWallet := wallets.GetWallet(from)
+	if len(senderWallet.PrivKey) == 0 {
+		log.Panic("ERROR: Sender address is not in the wallet")
+	}
+	bc.SignTransaction(tx, *senderWallet.PrivateKey())
 
-		newBlock := bc.MineBlock(txs)
+	if wallets.IsAddressUsed(to, bc) {
+		fmt.Printf("WARNING: Recipient address %s has already received funds; reusing addresses reduces privacy\n", to)
+	}
+
+	if mineNow {
+		newBlock, err := bc.MineBlock([]*blockchain.Transaction{tx}, from)
+		if err != nil {
+			log.Panic(err)
+		}
 		UTXOSet.Update(newBlock)
 	} else {
 		network.SendTx(network.KnownNodes[0], tx)
@@ -144,17 +426,105 @@ func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
 	fmt.Println("Success!")
 }
 
+// signMessage 使用钱包私钥对消息签名
+func (cli *CLI) signMessage(address, message, nodeID string) {
+	if !blockchain.ValidateAddress(address) {
+		log.Panic("ERROR: Address is not valid")
+	}
+
+	wallets, err := wallet.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.Wallets[address] == nil {
+		log.Panic("ERROR: Wallet for the address is not found")
+	}
+
+	signature, err := wallets.GetWallet(address).SignMessage(message)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Signature: %s\n", base64.StdEncoding.EncodeToString(signature))
+}
+
+// verifyMessage 验证消息签名
+func (cli *CLI) verifyMessage(address, message, signature string) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		log.Panic("ERROR: Signature is not valid Base64")
+	}
+
+	if wallet.VerifyMessage(address, message, sig) {
+		fmt.Println("Signature is valid.")
+	} else {
+		fmt.Println("Signature is invalid.")
+	}
+}
+
 // startNode 启动一个节点
-func (cli *CLI) startNode(nodeID, minerAddress string) {
+func (cli *CLI) startNode(nodeID, minerAddress, bindHost, advertisedAddr string, maxBlockTxs int, auth bool) {
 	fmt.Printf("Starting node %s\n", nodeID)
+
+	config := &network.ServerConfig{
+		BindHost:       bindHost,
+		AdvertisedAddr: advertisedAddr,
+		MaxBlockTxs:    maxBlockTxs,
+	}
+	if auth {
+		nodeAuth, err := security.NewNodeAuth(nodeID)
+		if err != nil {
+			log.Panic(err)
+		}
+		config.Auth = nodeAuth
+	}
 	if len(minerAddress) > 0 {
-		if blockchain.ValidateAddress(minerAddress) {
-			fmt.Println("Mining is on. Address to receive rewards: ", minerAddress)
+		payouts, err := parsePayoutAddresses(minerAddress)
+		if err != nil {
+			log.Panic(err)
+		}
+		for _, payout := range payouts {
+			if !blockchain.ValidateAddress(payout.Address) {
+				log.Panic("Wrong miner address!")
+			}
+		}
+
+		if len(payouts) > 1 {
+			config.PayoutAddresses = payouts
+			fmt.Println("Mining is on. Rewards rotate through: ", minerAddress)
 		} else {
-			log.Panic("Wrong miner address!")
+			fmt.Println("Mining is on. Address to receive rewards: ", payouts[0].Address)
+		}
+		minerAddress = payouts[0].Address
+	}
+	network.StartServerWithConfig(nodeID, minerAddress, config)
+}
+
+// parsePayoutAddresses 解析以逗号分隔的奖励地址列表，每项格式为 ADDRESS 或 ADDRESS:WEIGHT
+func parsePayoutAddresses(value string) ([]network.PayoutAddress, error) {
+	var payouts []network.PayoutAddress
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		payout := network.PayoutAddress{Address: item, Weight: 1}
+		if i := strings.LastIndex(item, ":"); i >= 0 {
+			weight, err := strconv.Atoi(item[i+1:])
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid payout weight in %q", item)
+			}
+			payout = network.PayoutAddress{Address: item[:i], Weight: weight}
 		}
+		payouts = append(payouts, payout)
+	}
+
+	if len(payouts) == 0 {
+		return nil, fmt.Errorf("no payout address given")
 	}
-	network.StartServer(nodeID, minerAddress)
+
+	return payouts, nil
 }
 
 // Run 解析命令行参数并执行相应的命令
@@ -170,18 +540,51 @@ func (cli *CLI) Run() {
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	getBlockHeaderCmd := flag.NewFlagSet("getblockheader", flag.ExitOnError)
+	getBlockTxsCmd := flag.NewFlagSet("getblocktxs", flag.ExitOnError)
+	getChainTipsCmd := flag.NewFlagSet("getchaintips", flag.ExitOnError)
+	getConnectionCountCmd := flag.NewFlagSet("getconnectioncount", flag.ExitOnError)
+	getDifficultyCmd := flag.NewFlagSet("getdifficulty", flag.ExitOnError)
+	getNewAddressCmd := flag.NewFlagSet("getnewaddress", flag.ExitOnError)
+	getSupplyCmd := flag.NewFlagSet("getsupply", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	listConnectionsCmd := flag.NewFlagSet("listconnections", flag.ExitOnError)
+	pingPeerCmd := flag.NewFlagSet("pingpeer", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	reindexCmd := flag.NewFlagSet("reindex", flag.ExitOnError)
+	resendWalletTransactionsCmd := flag.NewFlagSet("resendwallettransactions", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	signMessageCmd := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
+	verifyMessageCmd := flag.NewFlagSet("verifymessage", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
 
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	getBlockHeaderHash := getBlockHeaderCmd.String("hash", "", "The hash of the block")
+	getBlockTxsHash := getBlockTxsCmd.String("hash", "", "The hash of the block")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
-	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining and send reward to ADDRESS")
+	sendNewChange := sendCmd.Bool("newchange", false, "Send change to a new wallet address instead of FROM")
+	signMessageAddress := signMessageCmd.String("address", "", "The address whose key signs the message")
+	signMessageText := signMessageCmd.String("message", "", "The message to sign")
+	verifyMessageAddress := verifyMessageCmd.String("address", "", "The address that signed the message")
+	verifyMessageText := verifyMessageCmd.String("message", "", "The signed message")
+	verifyMessageSignature := verifyMessageCmd.String("signature", "", "The Base64 encoded signature")
+	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining and send reward to ADDRESS, or rotate through ADDR[:WEIGHT],...")
+	startNodeBind := startNodeCmd.String("bind", "", "Host to listen on (default 0.0.0.0)")
+	startNodeAdvertise := startNodeCmd.String("advertise", "", "Address announced to peers (default localhost:NODE_ID)")
+	startNodeMaxBlockTxs := startNodeCmd.Int("maxblocktxs", 0, "Maximum mempool transactions per mined block (default 1000)")
+	statusNode := statusCmd.String("node", "", "The running node to query (default localhost:NODE_ID)")
+	getConnectionCountNode := getConnectionCountCmd.String("node", "", "The running node to query (default localhost:NODE_ID)")
+	listConnectionsNode := listConnectionsCmd.String("node", "", "The running node to query (default localhost:NODE_ID)")
+	startNodeAuth := startNodeCmd.Bool("auth", false, "Answer authentication challenges in the version handshake with a node identity")
+	pingPeerAddress := pingPeerCmd.String("address", "", "The peer HOST:PORT to check")
+	pingPeerAuth := pingPeerCmd.Bool("auth", false, "Require the peer to prove its node identity")
+	printChainLimit := printChainCmd.Int("limit", 0, "Print at most N blocks (0 means all)")
+	printChainFrom := printChainCmd.String("from", "", "Start printing from the block with HASH")
 
 	switch os.Args[1] {
 	case "createblockchain":
@@ -199,21 +602,91 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "getblockheader":
+		err := getBlockHeaderCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getblocktxs":
+		err := getBlockTxsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getchaintips":
+		err := getChainTipsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getconnectioncount":
+		err := getConnectionCountCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getdifficulty":
+		err := getDifficultyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getnewaddress":
+		err := getNewAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getsupply":
+		err := getSupplyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "listaddresses":
 		err := listAddressesCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "listconnections":
+		err := listConnectionsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "pingpeer":
+		err := pingPeerCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "printchain":
 		err := printChainCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "reindex":
+		err := reindexCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "resendwallettransactions":
+		err := resendWalletTransactionsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "send":
 		err := sendCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "signmessage":
+		err := signMessageCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "status":
+		err := statusCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifymessage":
+		err := verifyMessageCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "startnode":
 		err := startNodeCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -244,12 +717,64 @@ func (cli *CLI) Run() {
 		cli.getBalance(*getBalanceAddress, nodeID)
 	}
 
+	if getBlockHeaderCmd.Parsed() {
+		if *getBlockHeaderHash == "" {
+			getBlockHeaderCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getBlockHeader(*getBlockHeaderHash, nodeID)
+	}
+
+	if getBlockTxsCmd.Parsed() {
+		if *getBlockTxsHash == "" {
+			getBlockTxsCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getBlockTxs(*getBlockTxsHash, nodeID)
+	}
+
+	if getChainTipsCmd.Parsed() {
+		cli.getChainTips(nodeID)
+	}
+
+	if getConnectionCountCmd.Parsed() {
+		cli.getConnectionCount(nodeID, *getConnectionCountNode)
+	}
+
+	if getDifficultyCmd.Parsed() {
+		cli.getDifficulty(nodeID)
+	}
+
+	if getNewAddressCmd.Parsed() {
+		cli.getNewAddress(nodeID)
+	}
+
+	if getSupplyCmd.Parsed() {
+		cli.getSupply(nodeID)
+	}
+
 	if listAddressesCmd.Parsed() {
 		cli.listAddresses(nodeID)
 	}
 
+	if listConnectionsCmd.Parsed() {
+		cli.listConnections(nodeID, *listConnectionsNode)
+	}
+
+	if pingPeerCmd.Parsed() {
+		cli.pingPeer(*pingPeerAddress, *pingPeerAuth)
+	}
+
 	if printChainCmd.Parsed() {
-		cli.printChain(nodeID)
+		cli.printChain(nodeID, *printChainLimit, *printChainFrom)
+	}
+
+	if reindexCmd.Parsed() {
+		cli.reindex(nodeID)
+	}
+
+	if resendWalletTransactionsCmd.Parsed() {
+		cli.resendWalletTransactions(nodeID)
 	}
 
 	if sendCmd.Parsed() {
@@ -257,10 +782,30 @@ func (cli *CLI) Run() {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
-		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
+		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine, *sendNewChange)
+	}
+
+	if signMessageCmd.Parsed() {
+		if *signMessageAddress == "" || *signMessageText == "" {
+			signMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signMessage(*signMessageAddress, *signMessageText, nodeID)
+	}
+
+	if statusCmd.Parsed() {
+		cli.status(nodeID, *statusNode)
+	}
+
+	if verifyMessageCmd.Parsed() {
+		if *verifyMessageAddress == "" || *verifyMessageText == "" || *verifyMessageSignature == "" {
+			verifyMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.verifyMessage(*verifyMessageAddress, *verifyMessageText, *verifyMessageSignature)
 	}
 
 	if startNodeCmd.Parsed() {
-		cli.startNode(nodeID, *startNodeMiner)
+		cli.startNode(nodeID, *startNodeMiner, *startNodeBind, *startNodeAdvertise, *startNodeMaxBlockTxs, *startNodeAuth)
 	}
 }
@@ -2,14 +2,22 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"mini-coin-go/blockchain"
+	"mini-coin-go/network"
 	"mini-coin-go/wallet"
+
+	"go.etcd.io/bbolt"
 )
 
 const (
@@ -166,6 +174,58 @@ func TestCLI_GetBalance(t *testing.T) {
 	}
 }
 
+// TestCLI_GetBalanceWithOpenHandle 测试只读句柄打开数据库时 getbalance 仍可运行，
+// 运行中的节点以读写模式持有数据库时给出明确的错误
+func TestCLI_GetBalanceWithOpenHandle(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	os.Args = []string{"main", "createwallet"}
+	cli := CLI{}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	address := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "createblockchain", "-address", address}
+	captureOutput(func() { cli.Run() })
+
+	getBalance := func() (output string, panicked interface{}) {
+		os.Args = []string{"main", "getbalance", "-address", address}
+		output = captureOutput(func() {
+			defer func() { panicked = recover() }()
+			cli.Run()
+		})
+		return output, panicked
+	}
+
+	// 其他只读句柄只持有共享锁
+	reader := openReadOnlyBlockchain(testNodeID)
+	output, panicked := getBalance()
+	reader.DB.Close()
+	if panicked != nil {
+		t.Fatalf("getbalance failed alongside a read-only handle: %v", panicked)
+	}
+	if !strings.Contains(output, "Balance of") || !strings.Contains(output, "100") {
+		t.Errorf("Expected balance of 100 for address %s, got: %s", address, output)
+	}
+
+	// 运行中的节点持有排他锁，bbolt 不允许同时打开只读句柄
+	oldTimeout := readOnlyOpenTimeout
+	readOnlyOpenTimeout = 100 * time.Millisecond
+	defer func() { readOnlyOpenTimeout = oldTimeout }()
+
+	node := blockchain.NewBlockchain("", testNodeID)
+	defer node.DB.Close()
+
+	_, panicked = getBalance()
+	if panicked == nil || !strings.Contains(fmt.Sprint(panicked), "Is node "+testNodeID+" running?") {
+		t.Errorf("Expected a clear error while a node holds the database, got %v", panicked)
+	}
+}
+
 // TestCLI_Send 测试发送交易功能
 func TestCLI_Send(t *testing.T) {
 	setupTestEnvironment()
@@ -270,3 +330,443 @@ func TestCLI_PrintChain(t *testing.T) {
 		t.Errorf("Expected blockchain output, got: %s", output)
 	}
 }
+
+// TestCLI_PrintChainLimit 测试 printchain 的 -limit 和 -from 参数
+func TestCLI_PrintChainLimit(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	// 创建两个钱包和区块链
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	addresses := wallets.GetAddresses()
+
+	os.Args = []string{"main", "createblockchain", "-address", addresses[0]}
+	captureOutput(func() { cli.Run() })
+
+	// 挖出几个新区块
+	for i := 0; i < 3; i++ {
+		os.Args = []string{"main", "send", "-from", addresses[0], "-to", addresses[1], "-amount", "1", "-mine"}
+		captureOutput(func() { cli.Run() })
+	}
+
+	os.Args = []string{"main", "printchain", "-limit", "2"}
+	output := captureOutput(func() { cli.Run() })
+	if count := strings.Count(output, "============ Block"); count != 2 {
+		t.Errorf("Expected exactly 2 blocks with -limit 2, got %d", count)
+	}
+
+	// 从创世区块开始打印只应输出一个区块
+	bc := blockchain.NewBlockchain("", testNodeID)
+	hashes := bc.GetBlockHashes()
+	bc.DB.Close()
+	genesisHash := hashes[len(hashes)-1]
+
+	os.Args = []string{"main", "printchain", "-from", hex.EncodeToString(genesisHash)}
+	output = captureOutput(func() { cli.Run() })
+	if count := strings.Count(output, "============ Block"); count != 1 {
+		t.Errorf("Expected exactly 1 block starting from genesis, got %d", count)
+	}
+	if !strings.Contains(output, hex.EncodeToString(genesisHash)) {
+		t.Errorf("Expected genesis block %x in output, got: %s", genesisHash, output)
+	}
+}
+
+// TestCLI_Reindex 测试 reindex 命令能够修复损坏的 UTXO 集合
+func TestCLI_Reindex(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	addresses := wallets.GetAddresses()
+	fromAddress := addresses[0]
+	toAddress := addresses[1]
+
+	os.Args = []string{"main", "createblockchain", "-address", fromAddress}
+	captureOutput(func() { cli.Run() })
+	os.Args = []string{"main", "send", "-from", fromAddress, "-to", toAddress, "-amount", "5", "-mine"}
+	captureOutput(func() { cli.Run() })
+
+	// 破坏 chainstate：清空后写入一个伪造的输出
+	bc := blockchain.NewBlockchain("", testNodeID)
+	err := bc.DB.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket([]byte("chainstate")); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket([]byte("chainstate"))
+		if err != nil {
+			return err
+		}
+		out, err := blockchain.NewTXOutput(999, toAddress)
+		if err != nil {
+			return err
+		}
+		fake := blockchain.TXOutputs{Outputs: []blockchain.TXOutput{*out}}
+		return b.Put([]byte("corrupted"), fake.Serialize())
+	})
+	bc.DB.Close()
+	if err != nil {
+		t.Fatalf("Failed to corrupt chainstate: %v", err)
+	}
+
+	os.Args = []string{"main", "getbalance", "-address", toAddress}
+	output := captureOutput(func() { cli.Run() })
+	if !strings.Contains(output, "999") {
+		t.Fatalf("Expected corrupted balance of 999, got: %s", output)
+	}
+
+	os.Args = []string{"main", "reindex"}
+	output = captureOutput(func() { cli.Run() })
+	if !strings.Contains(output, "Rebuilding UTXO set from 2 blocks") || !strings.Contains(output, "Done!") {
+		t.Errorf("Expected reindex progress output, got: %s", output)
+	}
+
+	os.Args = []string{"main", "getbalance", "-address", fromAddress}
+	output = captureOutput(func() { cli.Run() })
+	if !strings.Contains(output, ": 195") {
+		t.Errorf("Expected balance of 195 for %s after reindex, got: %s", fromAddress, output)
+	}
+
+	os.Args = []string{"main", "getbalance", "-address", toAddress}
+	output = captureOutput(func() { cli.Run() })
+	if !strings.Contains(output, ": 5\n") {
+		t.Errorf("Expected balance of 5 for %s after reindex, got: %s", toAddress, output)
+	}
+}
+
+// TestCLI_SignVerifyMessage 测试消息签名与验证命令
+func TestCLI_SignVerifyMessage(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+
+	wallets, _ := wallet.NewWallets(testNodeID)
+	address := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "signmessage", "-address", address, "-message", "hello"}
+	output := captureOutput(func() { cli.Run() })
+	if !strings.Contains(output, "Signature: ") {
+		t.Fatalf("Expected signature in output, got: %s", output)
+	}
+	signature := strings.TrimSpace(output[strings.Index(output, "Signature: ")+len("Signature: "):])
+
+	os.Args = []string{"main", "verifymessage", "-address", address, "-message", "hello", "-signature", signature}
+	output = captureOutput(func() { cli.Run() })
+	if !strings.Contains(output, "Signature is valid.") {
+		t.Errorf("Expected valid signature, got: %s", output)
+	}
+
+	os.Args = []string{"main", "verifymessage", "-address", address, "-message", "hello!", "-signature", signature}
+	output = captureOutput(func() { cli.Run() })
+	if !strings.Contains(output, "Signature is invalid.") {
+		t.Errorf("Expected invalid signature for tampered message, got: %s", output)
+	}
+}
+
+// TestCLI_GetChainTips 测试 getchaintips 命令
+func TestCLI_GetChainTips(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	address := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "createblockchain", "-address", address}
+	captureOutput(func() { cli.Run() })
+
+	os.Args = []string{"main", "getchaintips"}
+	output := captureOutput(func() { cli.Run() })
+
+	if !strings.Contains(output, "height: 0 status: active") {
+		t.Errorf("Expected active genesis tip, got: %s", output)
+	}
+}
+
+// TestCLI_GetDifficulty 测试 getdifficulty 命令
+func TestCLI_GetDifficulty(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	address := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "createblockchain", "-address", address}
+	captureOutput(func() { cli.Run() })
+
+	os.Args = []string{"main", "getdifficulty"}
+	output := captureOutput(func() { cli.Run() })
+
+	if !strings.Contains(output, "Target bits: 2") || !strings.Contains(output, "Difficulty: 1.00") {
+		t.Errorf("Expected default difficulty, got: %s", output)
+	}
+}
+
+// TestParsePayoutAddresses 测试解析带权重的奖励地址列表
+func TestParsePayoutAddresses(t *testing.T) {
+	payouts, err := parsePayoutAddresses("addrA, addrB:3")
+	if err != nil {
+		t.Fatalf("Failed to parse payout addresses: %v", err)
+	}
+	if len(payouts) != 2 || payouts[0].Address != "addrA" || payouts[0].Weight != 1 ||
+		payouts[1].Address != "addrB" || payouts[1].Weight != 3 {
+		t.Errorf("Unexpected payout addresses: %+v", payouts)
+	}
+
+	if _, err := parsePayoutAddresses("addrA:0"); err == nil {
+		t.Error("Expected zero weight to be rejected")
+	}
+	if _, err := parsePayoutAddresses(" , "); err == nil {
+		t.Error("Expected empty list to be rejected")
+	}
+}
+
+// TestCLI_GetSupply 测试 getsupply 命令
+func TestCLI_GetSupply(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	address := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "createblockchain", "-address", address}
+	captureOutput(func() { cli.Run() })
+
+	os.Args = []string{"main", "getsupply"}
+	output := captureOutput(func() { cli.Run() })
+
+	if !strings.Contains(output, "Total supply: 100") {
+		t.Errorf("Expected genesis supply, got: %s", output)
+	}
+}
+
+// TestCLI_GetNewAddress 测试 getnewaddress 只返回未收到过币的地址
+func TestCLI_GetNewAddress(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	funded := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "createblockchain", "-address", funded}
+	captureOutput(func() { cli.Run() })
+
+	os.Args = []string{"main", "getnewaddress"}
+	output := strings.TrimSpace(captureOutput(func() { cli.Run() }))
+	if output == funded || !blockchain.ValidateAddress(output) {
+		t.Fatalf("Expected a new valid address, got: %s", output)
+	}
+
+	// 新地址已保存到钱包文件，再次调用时复用
+	wallets, _ = wallet.NewWallets(testNodeID)
+	if len(wallets.Wallets) != 2 || wallets.Wallets[output] == nil {
+		t.Errorf("Expected %s saved to the wallet file, got %v", output, wallets.GetAddresses())
+	}
+	os.Args = []string{"main", "getnewaddress"}
+	if again := strings.TrimSpace(captureOutput(func() { cli.Run() })); again != output {
+		t.Errorf("Expected unused address %s to be returned again, got: %s", output, again)
+	}
+
+	// 向已收到过币的地址发送时给出警告
+	os.Args = []string{"main", "send", "-from", funded, "-to", funded, "-amount", "5", "-mine"}
+	if output := captureOutput(func() { cli.Run() }); !strings.Contains(output, "WARNING") {
+		t.Errorf("Expected an address reuse warning, got: %s", output)
+	}
+}
+
+// TestCLI_GetBlockHeader 测试 getblockheader 命令
+func TestCLI_GetBlockHeader(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	address := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "createblockchain", "-address", address}
+	captureOutput(func() { cli.Run() })
+
+	bc := blockchain.NewBlockchain("", testNodeID)
+	genesis := bc.Iterator().Next()
+	bc.DB.Close()
+
+	os.Args = []string{"main", "getblockheader", "-hash", hex.EncodeToString(genesis.Hash)}
+	output := captureOutput(func() { cli.Run() })
+
+	if !strings.Contains(output, fmt.Sprintf("Merkle root: %x", genesis.HashTransactions())) || !strings.Contains(output, "Height: 0") {
+		t.Errorf("Expected genesis header, got: %s", output)
+	}
+}
+
+// TestCLI_GetBlockTxs 测试 getblocktxs 命令
+func TestCLI_GetBlockTxs(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	cli := CLI{}
+	os.Args = []string{"main", "createwallet"}
+	captureOutput(func() { cli.Run() })
+	wallets, _ := wallet.NewWallets(testNodeID)
+	address := wallets.GetAddresses()[0]
+
+	os.Args = []string{"main", "createblockchain", "-address", address}
+	captureOutput(func() { cli.Run() })
+
+	bc := blockchain.NewBlockchain("", testNodeID)
+	genesis := bc.Iterator().Next()
+	bc.DB.Close()
+
+	os.Args = []string{"main", "getblocktxs", "-hash", hex.EncodeToString(genesis.Hash)}
+	output := captureOutput(func() { cli.Run() })
+
+	expected := fmt.Sprintf("%x coinbase inputs: 1 outputs: 1", genesis.Transactions[0].ID)
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected genesis coinbase summary %q, got: %s", expected, output)
+	}
+}
+
+// TestCLI_Status 测试 status 命令查询运行中的节点并输出 JSON 状态报告
+func TestCLI_Status(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	// 模拟运行中的节点，回复 getstatus 请求
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start mock node: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request, _ := io.ReadAll(conn)
+		if network.BytesToCommand(request) != "getstatus" {
+			return
+		}
+		conn.Write(append(network.CommandToBytes("status"), []byte(`{"height":3,"mempool_size":0,"peer_count":1,"uptime":"1s"}`)...))
+	}()
+
+	cli := CLI{}
+	os.Args = []string{"main", "status", "-node", listener.Addr().String()}
+	output := captureOutput(func() { cli.Run() })
+
+	var report map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", output, err)
+	}
+	if report["height"] != float64(3) {
+		t.Errorf("Expected the node's height 3, got %v", report["height"])
+	}
+	for _, key := range []string{"peer_count", "mempool_size", "uptime"} {
+		if _, ok := report[key]; !ok {
+			t.Errorf("Expected report to include %s", key)
+		}
+	}
+}
+
+// TestCLI_ConnectionIntrospection 测试 getconnectioncount 和 listconnections 命令查询运行中节点的连接
+func TestCLI_ConnectionIntrospection(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	// 模拟运行中的节点，回复 getconns 请求
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start mock node: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			request, _ := io.ReadAll(conn)
+			if network.BytesToCommand(request) == "getconns" {
+				conn.Write(append(network.CommandToBytes("conns"), []byte(`{"count":2,"connections":[`+
+					`{"remote_addr":"127.0.0.1:4001","age":90,"idle_time":5,"usage_count":1},`+
+					`{"remote_addr":"127.0.0.1:4002","age":3,"idle_time":0,"usage_count":2}]}`)...))
+			}
+			conn.Close()
+		}
+	}()
+
+	cli := CLI{}
+	os.Args = []string{"main", "getconnectioncount", "-node", listener.Addr().String()}
+	if output := strings.TrimSpace(captureOutput(func() { cli.Run() })); output != "2" {
+		t.Errorf("Expected 2 connections, got: %s", output)
+	}
+
+	os.Args = []string{"main", "listconnections", "-node", listener.Addr().String()}
+	output := captureOutput(func() { cli.Run() })
+	for _, expected := range []string{
+		"127.0.0.1:4001  age: 1m30s  idle: 5s  uses: 1",
+		"127.0.0.1:4002  age: 3s  idle: 0s  uses: 2",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in listing, got: %s", expected, output)
+		}
+	}
+}
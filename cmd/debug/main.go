@@ -36,7 +36,7 @@ func debugTransaction() {
 	bc := blockchain.NewBlockchain(addressA, "debug")
 	defer bc.DB.Close()
 
-	utxoSet := blockchain.UTXOSet{bc}
+	utxoSet := blockchain.UTXOSet{Blockchain: bc}
 	utxoSet.Reindex()
 
 	// 检查初始余额
@@ -58,7 +58,12 @@ func debugTransaction() {
 		fmt.Printf("  TxID: %s (长度:%d), Outputs: %v\n", txid, len(txid), outs)
 	}
 
-	tx := blockchain.NewUTXOTransaction(addressA, addressB, 10, &utxoSet)
+	tx, err := blockchain.NewUTXOTransaction(addressA, addressB, 10, &utxoSet)
+	if err != nil {
+		log.Panic(err)
+	}
+	walletA := wallets.GetWallet(addressA)
+	bc.SignTransaction(tx, *walletA.PrivateKey())
 	fmt.Printf("交易ID: %x\n", tx.ID)
 
 	// 打印交易详情
@@ -73,7 +78,9 @@ func debugTransaction() {
 	}
 
 	// 挖矿（不给奖励）
-	bc.MineBlock([]*blockchain.Transaction{tx})
+	if _, err := bc.MineBlock([]*blockchain.Transaction{tx}, ""); err != nil {
+		log.Panic(err)
+	}
 	utxoSet.Reindex()
 
 	// 检查交易后余额
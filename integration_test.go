@@ -11,8 +11,6 @@ import (
 	"mini-coin-go/blockchain"
 	"mini-coin-go/network"
 	"mini-coin-go/wallet"
-
-	"go.etcd.io/bbolt"
 )
 
 // TestBlockchainNetworkIntegration 完整的区块链网络集成测试
@@ -67,12 +65,6 @@ func TestBlockchainNetworkIntegration(t *testing.T) {
 	newBlock := mineBlockWithTransactions(t, bc, nodeAAddress, []*blockchain.Transaction{tx})
 	log.Printf("节点A挖出新区块，高度: %d, 哈希: %x", newBlock.Height, newBlock.Hash)
 
-	// 将新区块添加到区块链
-	err := bc.AddBlock(newBlock)
-	if err != nil {
-		t.Fatalf("添加区块失败: %v", err)
-	}
-
 	// 等待区块链状态更新
 	time.Sleep(100 * time.Millisecond)
 
@@ -203,7 +195,12 @@ func createTransaction(t *testing.T, bc *blockchain.Blockchain, from, to string,
 	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
 	UTXOSet.Reindex()
 
-	tx := blockchain.NewUTXOTransaction(from, to, amount, &UTXOSet)
+	tx, err := blockchain.NewUTXOTransaction(from, to, amount, &UTXOSet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	fromWallet := senderWallet.GetWallet(from)
+	bc.SignTransaction(tx, *fromWallet.PrivateKey())
 
 	return tx
 }
@@ -239,31 +236,14 @@ func clearMempool() {
 	mempool = make(map[string]*blockchain.Transaction)
 }
 
-// mineBlockWithTransactions 挖掘包含指定交易的区块
+// mineBlockWithTransactions 挖掘包含指定交易的区块，奖励支付给矿工地址
 func mineBlockWithTransactions(t *testing.T, bc *blockchain.Blockchain, minerAddress string, transactions []*blockchain.Transaction) *blockchain.Block {
-	// 创建coinbase交易（挖矿奖励）
-	coinbaseTx := blockchain.NewCoinbaseTX(minerAddress, "")
-
-	// 将coinbase交易添加到交易列表开头
-	allTransactions := []*blockchain.Transaction{coinbaseTx}
-	allTransactions = append(allTransactions, transactions...)
-
-	// 获取前一个区块的哈希（使用与 MineBlock 相同的逻辑）
-	var lastHash []byte
-	err := bc.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("blocks"))
-		lastHash = b.Get([]byte("l"))
-		return nil
-	})
+	newBlock, err := bc.MineBlock(transactions, minerAddress)
 	if err != nil {
-		log.Panic(err)
+		t.Fatalf("挖掘区块失败: %v", err)
 	}
-	height := bc.GetBestHeight() + 1
-
-	// 创建新区块
-	newBlock := blockchain.NewBlock(allTransactions, lastHash, height)
 
-	log.Printf("挖掘区块成功，矿工: %s, 高度: %d, 交易数: %d", minerAddress, height, len(allTransactions))
+	log.Printf("挖掘区块成功，矿工: %s, 高度: %d, 交易数: %d", minerAddress, newBlock.Height, len(newBlock.Transactions))
 
 	// 清空内存池（交易已被打包）
 	clearMempool()
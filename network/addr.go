@@ -0,0 +1,230 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/peer"
+	"mini-coin-go/network/security"
+)
+
+const (
+	// maxAddrPerMessage 单条 addr 消息最多携带的地址数，超出的消息整体拒绝
+	maxAddrPerMessage = 1000
+	// defaultMaxGetAddrResponse 回复 getaddr 时默认最多返回的地址数
+	defaultMaxGetAddrResponse = 100
+)
+
+var (
+	// addrAuth 设置后 addr 消息必须由已认证节点签名
+	addrAuth      *security.NodeAuth
+	addrAuthMutex sync.RWMutex
+	// maxGetAddrResponse 回复 getaddr 时最多返回的地址数
+	maxGetAddrResponse      = defaultMaxGetAddrResponse
+	maxGetAddrResponseMutex sync.RWMutex
+)
+
+// SetMaxGetAddrResponse 设置回复 getaddr 时最多返回的地址数，小于等于 0 时使用默认值，不超过单条 addr 消息的上限
+func SetMaxGetAddrResponse(limit int) {
+	if limit <= 0 {
+		limit = defaultMaxGetAddrResponse
+	}
+	if limit > maxAddrPerMessage {
+		limit = maxAddrPerMessage
+	}
+
+	maxGetAddrResponseMutex.Lock()
+	defer maxGetAddrResponseMutex.Unlock()
+
+	maxGetAddrResponse = limit
+}
+
+// getMaxGetAddrResponse 返回回复 getaddr 时最多返回的地址数
+func getMaxGetAddrResponse() int {
+	maxGetAddrResponseMutex.RLock()
+	defer maxGetAddrResponseMutex.RUnlock()
+
+	return maxGetAddrResponse
+}
+
+// SendGetAddr 向节点请求其已知节点列表，并在同一连接上读取 addr 回复，可作为 peer.Discovery 的 PeerRequester
+// 回复中的地址加入已知节点和节点管理器
+func SendGetAddr(address string) {
+	if isSelfAddress(address) {
+		return
+	}
+
+	payload, err := GobEncode(GetAddr{AddrFrom: nodeAddress})
+	if err != nil {
+		log.Panic(err)
+	}
+	reply, err := requestReply(address, append(CommandToBytes("getaddr"), payload...), 0)
+	if err != nil {
+		log.Printf("getaddr to %s failed: %v", address, err)
+		return
+	}
+	if command := BytesToCommand(reply[:commandLength]); command != "addr" {
+		log.Printf("Unexpected %s reply to getaddr from %s", command, address)
+		return
+	}
+	if err := handleAddr(reply); err != nil {
+		log.Printf("Invalid addr reply from %s: %v", address, err)
+	}
+}
+
+// handleGetAddr 在请求连接上以随机抽取的已知节点回复 getaddr，数量不超过配置的上限和请求的数量
+// 只回复请求方所在的连接，不会向消息中声明的地址发送数据
+func handleGetAddr(conn net.Conn, request []byte, _ *blockchain.Blockchain) error {
+	var payload GetAddr
+	if err := gob.NewDecoder(bytes.NewReader(request[commandLength:])).Decode(&payload); err != nil {
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	limit := getMaxGetAddrResponse()
+	if payload.MaxCount > 0 && payload.MaxCount < limit {
+		limit = payload.MaxCount
+	}
+
+	sample := sampleKnownNodes(limit, payload.AddrFrom)
+	if len(sample) == 0 {
+		return nil
+	}
+
+	reply := Addr{AddrList: sample, AddrFrom: nodeAddress}
+	if err := signAddr(&reply); err != nil {
+		return err
+	}
+	data, err := GobEncode(reply)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(append(CommandToBytes("addr"), data...)); err != nil {
+		return fmt.Errorf("failed to send addr reply: %v", err)
+	}
+
+	return nil
+}
+
+// addKnownAddrs 把 addr 消息中的地址加入已知节点，设置了节点管理器时也加入节点管理器
+func addKnownAddrs(addrList []string) {
+	manager := getPeerManager()
+	for _, addr := range addrList {
+		if isSelfAddress(addr) {
+			continue
+		}
+		if !nodeIsKnown(addr) {
+			KnownNodes = append(KnownNodes, addr)
+		}
+		if manager != nil && manager.GetPeer(addr) == nil {
+			if p := peer.NewPeerFromAddress(addr); p != nil {
+				manager.AddPeer(p)
+			}
+		}
+	}
+}
+
+// sampleKnownNodes 随机抽取至多 limit 个已知节点，不包括自身和 exclude
+func sampleKnownNodes(limit int, exclude string) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, node := range KnownNodes {
+		if node == exclude || isSelfAddress(node) || seen[node] {
+			continue
+		}
+		seen[node] = true
+		candidates = append(candidates, node)
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// SetAddrAuth 设置用于签名和验证 addr 消息的节点认证，为 nil 时不要求签名
+func SetAddrAuth(auth *security.NodeAuth) {
+	addrAuthMutex.Lock()
+	defer addrAuthMutex.Unlock()
+
+	addrAuth = auth
+}
+
+// getAddrAuth 返回当前的 addr 消息认证
+func getAddrAuth() *security.NodeAuth {
+	addrAuthMutex.RLock()
+	defer addrAuthMutex.RUnlock()
+
+	return addrAuth
+}
+
+// addrSigningBytes 返回 addr 消息中被签名的内容
+func addrSigningBytes(addrFrom string, addrList []string) []byte {
+	return []byte(addrFrom + "\n" + strings.Join(addrList, "\n"))
+}
+
+// signAddr 在启用认证时为 addr 消息签名
+func signAddr(payload *Addr) error {
+	auth := getAddrAuth()
+	if auth == nil {
+		return nil
+	}
+
+	signature, err := auth.SignMessage(addrSigningBytes(payload.AddrFrom, payload.AddrList))
+	if err != nil {
+		return err
+	}
+	payload.Signature = signature
+	return nil
+}
+
+// verifyAddr 检查 addr 消息的地址数量和格式，启用认证时还要求消息由已认证节点签名
+func verifyAddr(payload *Addr) error {
+	if len(payload.AddrList) == 0 {
+		return fmt.Errorf("addr message has no addresses")
+	}
+	if len(payload.AddrList) > maxAddrPerMessage {
+		return fmt.Errorf("addr message has %d addresses, limit is %d", len(payload.AddrList), maxAddrPerMessage)
+	}
+	for _, addr := range payload.AddrList {
+		if err := validateNodeAddress(addr); err != nil {
+			return err
+		}
+	}
+
+	if auth := getAddrAuth(); auth != nil {
+		if len(payload.Signature) == 0 {
+			return fmt.Errorf("addr message from %q is not signed", payload.AddrFrom)
+		}
+		if err := auth.VerifyMessageSignature(payload.AddrFrom, addrSigningBytes(payload.AddrFrom, payload.AddrList), payload.Signature); err != nil {
+			return fmt.Errorf("addr message from %q rejected: %v", payload.AddrFrom, err)
+		}
+	}
+
+	return nil
+}
+
+// validateNodeAddress 检查节点地址是否为 host:port 格式且端口有效
+func validateNodeAddress(addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return fmt.Errorf("invalid node address %q", addr)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port in node address %q", addr)
+	}
+
+	return nil
+}
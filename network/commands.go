@@ -0,0 +1,94 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"mini-coin-go/blockchain"
+)
+
+// CommandHandler 处理一条完整请求（含命令头）的函数
+type CommandHandler func(request []byte, bc *blockchain.Blockchain) error
+
+var (
+	// commandHandlers 按命令名注册的处理函数
+	commandHandlers = map[string]CommandHandler{
+		"addr":      withoutChain(handleAddr),
+		"block":     handleBlock,
+		"inv":       handleInv,
+		"getblocks": handleGetBlocks,
+		"getdata":   handleGetData,
+		"notfound":  withoutChain(handleNotFound),
+		"tx":        handleTx,
+		"version":   handleVersion,
+	}
+	// connHandlers 需要请求连接的内置命令，注册同名命令会替换它们
+	connHandlers = map[string]connHandler{
+		"getaddr":   handleGetAddr,
+		"getconns":  localOnly(handleGetConns),
+		"getstatus": localOnly(handleGetStatus),
+		"resend":    localOnly(ignoreConn(withoutChain(handleResend))),
+	}
+	// unknownCommandHandler 未注册命令的回退处理函数
+	unknownCommandHandler = defaultUnknownCommandHandler
+	commandHandlersMutex  sync.RWMutex
+)
+
+// withoutChain 将不需要区块链的处理函数适配为 CommandHandler
+func withoutChain(handler func(request []byte) error) CommandHandler {
+	return func(request []byte, _ *blockchain.Blockchain) error {
+		return handler(request)
+	}
+}
+
+// defaultUnknownCommandHandler 默认的未知命令处理：打印提示并丢弃消息
+func defaultUnknownCommandHandler(request []byte, _ *blockchain.Blockchain) error {
+	fmt.Println("Unknown command!")
+	return nil
+}
+
+// RegisterCommand 注册命令处理函数，已注册的同名命令会被替换
+func RegisterCommand(command string, handler CommandHandler) error {
+	if command == "" || len(command) > commandLength {
+		return fmt.Errorf("command name %q must be 1-%d bytes", command, commandLength)
+	}
+	if handler == nil {
+		return fmt.Errorf("command %s has no handler", command)
+	}
+
+	commandHandlersMutex.Lock()
+	defer commandHandlersMutex.Unlock()
+
+	delete(connHandlers, command)
+	commandHandlers[command] = handler
+	return nil
+}
+
+// SetUnknownCommandHandler 设置未注册命令的回退处理函数，为 nil 时恢复默认行为
+func SetUnknownCommandHandler(handler CommandHandler) {
+	if handler == nil {
+		handler = defaultUnknownCommandHandler
+	}
+
+	commandHandlersMutex.Lock()
+	defer commandHandlersMutex.Unlock()
+
+	unknownCommandHandler = handler
+}
+
+// dispatchCommand 将从 conn 读到的请求交给命令对应的处理函数，未注册的命令交给回退处理函数
+func dispatchCommand(conn net.Conn, command string, request []byte, bc *blockchain.Blockchain) error {
+	commandHandlersMutex.RLock()
+	if handler, ok := connHandlers[command]; ok {
+		commandHandlersMutex.RUnlock()
+		return handler(conn, request, bc)
+	}
+	handler, ok := commandHandlers[command]
+	if !ok {
+		handler = unknownCommandHandler
+	}
+	commandHandlersMutex.RUnlock()
+
+	return handler(request, bc)
+}
@@ -1,7 +1,9 @@
 package connection
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"time"
@@ -9,17 +11,27 @@ import (
 
 // Connection 表示一个网络连接
 type Connection struct {
-	ID          string        // 连接唯一标识
-	Conn        net.Conn      // 底层网络连接
-	RemoteAddr  string        // 远程地址
-	CreatedAt   time.Time     // 创建时间
-	LastUsed    time.Time     // 最后使用时间
-	IsActive    bool          // 是否活跃
-	IsBusy      bool          // 是否忙碌
-	UsageCount  int           // 使用次数
-	mutex       sync.RWMutex  // 读写锁
+	ID            string       // 连接唯一标识
+	Conn          net.Conn     // 底层网络连接
+	RemoteAddr    string       // 远程地址
+	CreatedAt     time.Time    // 创建时间
+	LastUsed      time.Time    // 最后使用时间
+	IsActive      bool         // 是否活跃
+	IsBusy        bool         // 是否忙碌
+	UsageCount    int          // 使用次数
+	BytesSent     int64        // 已发送字节数
+	BytesReceived int64        // 已接收字节数
+	mutex         sync.RWMutex // 读写锁
+	sendMutex     sync.Mutex   // 保证帧按序号顺序写出
+	sendSeq       uint64       // 最后发送的帧序号
+	recvSeq       uint64       // 最后接收的帧序号
 }
 
+const (
+	frameHeaderLen = 12               // 帧头长度（4 字节负载长度 + 8 字节序号）
+	maxFrameSize   = 32 * 1024 * 1024 // 单帧最大负载
+)
+
 // NewConnection 创建新连接
 func NewConnection(conn net.Conn) *Connection {
 	return &Connection{
@@ -70,6 +82,84 @@ func (c *Connection) Close() error {
 	return nil
 }
 
+// Send 以长度前缀帧的形式发送数据，并累计发送字节数
+func (c *Connection) Send(data []byte) error {
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("数据帧过大: %d 字节", len(data))
+	}
+
+	// 序号的分配与写出在同一把锁内完成，并发发送时帧仍按序号顺序到达
+	c.sendMutex.Lock()
+	c.sendSeq++
+	frame := make([]byte, frameHeaderLen+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	binary.BigEndian.PutUint64(frame[4:], c.sendSeq)
+	copy(frame[frameHeaderLen:], data)
+
+	n, err := c.Conn.Write(frame)
+	c.sendMutex.Unlock()
+
+	c.mutex.Lock()
+	c.BytesSent += int64(n)
+	c.LastUsed = time.Now()
+	c.mutex.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("发送数据失败: %v", err)
+	}
+	return nil
+}
+
+// Receive 读取一个长度前缀帧并返回其负载，同时累计接收字节数
+func (c *Connection) Receive() ([]byte, error) {
+	header := make([]byte, frameHeaderLen)
+	n, err := io.ReadFull(c.Conn, header)
+	c.addBytesReceived(n)
+	if err != nil {
+		return nil, fmt.Errorf("读取帧头失败: %v", err)
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("数据帧过大: %d 字节", size)
+	}
+
+	data := make([]byte, size)
+	n, err = io.ReadFull(c.Conn, data)
+	c.addBytesReceived(n)
+	if err != nil {
+		return nil, fmt.Errorf("读取数据失败: %v", err)
+	}
+
+	// 序号必须逐帧递增，乱序或重放的帧被拒绝；负载已读出，后续帧的边界不受影响
+	if err := c.checkSequence(binary.BigEndian.Uint64(header[4:])); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// checkSequence 检查帧序号是否恰好是上一帧序号加一
+func (c *Connection) checkSequence(seq uint64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if seq != c.recvSeq+1 {
+		return fmt.Errorf("帧序号无效: 期望 %d，收到 %d", c.recvSeq+1, seq)
+	}
+	c.recvSeq = seq
+	return nil
+}
+
+// addBytesReceived 累计接收字节数
+func (c *Connection) addBytesReceived(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.BytesReceived += int64(n)
+	c.LastUsed = time.Now()
+}
+
 // IsExpired 检查连接是否过期
 func (c *Connection) IsExpired(maxIdleTime time.Duration) bool {
 	c.mutex.RLock()
@@ -112,15 +202,17 @@ func (c *Connection) GetInfo() map[string]interface{} {
 	defer c.mutex.RUnlock()
 	
 	return map[string]interface{}{
-		"id":          c.ID,
-		"remote_addr": c.RemoteAddr,
-		"created_at":  c.CreatedAt,
-		"last_used":   c.LastUsed,
-		"is_active":   c.IsActive,
-		"is_busy":     c.IsBusy,
-		"usage_count": c.UsageCount,
-		"age":         time.Since(c.CreatedAt).Seconds(),
-		"idle_time":   time.Since(c.LastUsed).Seconds(),
+		"id":             c.ID,
+		"remote_addr":    c.RemoteAddr,
+		"created_at":     c.CreatedAt,
+		"last_used":      c.LastUsed,
+		"is_active":      c.IsActive,
+		"is_busy":        c.IsBusy,
+		"usage_count":    c.UsageCount,
+		"bytes_sent":     c.BytesSent,
+		"bytes_received": c.BytesReceived,
+		"age":            time.Since(c.CreatedAt).Seconds(),
+		"idle_time":      time.Since(c.LastUsed).Seconds(),
 	}
 }
 
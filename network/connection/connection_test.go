@@ -1,7 +1,11 @@
 package connection
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"net"
+	"sync"
 	"testing"
 	"time"
 )
@@ -82,3 +86,405 @@ func TestPoolConfig(t *testing.T) {
 		}
 	})
 }
+
+// startEchoServer 启动一个按帧回显数据的测试服务器
+func startEchoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start echo server: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(c *Connection) {
+				defer c.Close()
+				for {
+					data, err := c.Receive()
+					if err != nil {
+						return
+					}
+					if err := c.Send(data); err != nil {
+						return
+					}
+				}
+			}(NewConnection(netConn))
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// TestConnectionByteCounters 测试连接的收发字节计数
+func TestConnectionByteCounters(t *testing.T) {
+	address := startEchoServer(t)
+
+	config := DefaultPoolConfig()
+	config.MaxConnections = 2
+	pool := NewPool(address, config)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("Failed to start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	conn, err := pool.GetConnection(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+
+	payloads := [][]byte{bytes.Repeat([]byte("a"), 100), bytes.Repeat([]byte("b"), 28)}
+	expected := int64(0)
+	for _, payload := range payloads {
+		if err := conn.Send(payload); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		echo, err := conn.Receive()
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if !bytes.Equal(echo, payload) {
+			t.Errorf("Expected echo of %d bytes, got %d bytes", len(payload), len(echo))
+		}
+		expected += int64(len(payload) + frameHeaderLen)
+	}
+
+	info := conn.GetInfo()
+	if info["bytes_sent"] != expected {
+		t.Errorf("Expected bytes_sent %d, got %v", expected, info["bytes_sent"])
+	}
+	if info["bytes_received"] != expected {
+		t.Errorf("Expected bytes_received %d, got %v", expected, info["bytes_received"])
+	}
+
+	pool.ReturnConnection(conn)
+	stats := pool.GetStats()
+	if stats.BytesSent != expected || stats.BytesReceived != expected {
+		t.Errorf("Expected pool bytes %d/%d, got %d/%d", expected, expected, stats.BytesSent, stats.BytesReceived)
+	}
+}
+
+// TestPoolDNSCache 测试主机名解析结果在 TTL 内被复用
+func TestPoolDNSCache(t *testing.T) {
+	address := startEchoServer(t)
+	_, port, _ := net.SplitHostPort(address)
+
+	config := DefaultPoolConfig()
+	config.DNSCacheTTL = time.Minute
+	pool := NewPool(net.JoinHostPort("peer.example", port), config)
+
+	lookups := 0
+	pool.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		if host != "peer.example" {
+			t.Errorf("Unexpected lookup for %s", host)
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		conn, err := pool.createConnection(ctx)
+		if err != nil {
+			t.Fatalf("Dial %d failed: %v", i, err)
+		}
+		conn.Close()
+	}
+	if lookups != 1 {
+		t.Errorf("Expected one lookup within the TTL, got %d", lookups)
+	}
+
+	// 缓存过期后重新解析
+	pool.resolvedAt = time.Now().Add(-2 * time.Minute)
+	conn, err := pool.createConnection(ctx)
+	if err != nil {
+		t.Fatalf("Dial after expiry failed: %v", err)
+	}
+	conn.Close()
+	if lookups != 2 {
+		t.Errorf("Expected a new lookup after the TTL expired, got %d", lookups)
+	}
+}
+
+// startStoppableServer 在指定地址启动测试服务器，返回的函数会关闭监听和所有已接受的连接
+func startStoppableServer(t *testing.T, address string) (string, func()) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	var mutex sync.Mutex
+	var accepted []net.Conn
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			accepted = append(accepted, netConn)
+			mutex.Unlock()
+		}
+	}()
+
+	stop := func() {
+		listener.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, c := range accepted {
+			c.Close()
+		}
+	}
+	t.Cleanup(stop)
+
+	return listener.Addr().String(), stop
+}
+
+// TestManagerReconnect 测试对端下线再恢复后，管理器自动为空连接池重建连接
+func TestManagerReconnect(t *testing.T) {
+	address, stop := startStoppableServer(t, "127.0.0.1:0")
+
+	config := DefaultPoolConfig()
+	config.MaxConnections = 2
+	config.ConnectTimeout = time.Second
+	config.HealthCheckInterval = 50 * time.Millisecond
+	config.RetryInterval = 20 * time.Millisecond
+	config.MaxRetries = 5
+
+	manager := NewManager(config)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	defer manager.Stop()
+
+	conn, err := manager.GetConnection(context.Background(), address)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	manager.ReturnConnection(conn)
+
+	waitForConnections := func(check func(int) bool) bool {
+		deadline := time.Now().Add(3 * time.Second)
+		for time.Now().Before(deadline) {
+			if check(manager.GetPoolStats(address).TotalConnections) {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// 对端下线，健康检查清空连接池
+	stop()
+	if !waitForConnections(func(n int) bool { return n == 0 }) {
+		t.Fatal("Expected pool to empty after the peer went down")
+	}
+
+	// 对端恢复，重连任务重新建立连接
+	startStoppableServer(t, address)
+	if !waitForConnections(func(n int) bool { return n > 0 }) {
+		t.Fatal("Expected pool to reconnect after the peer came back")
+	}
+}
+
+// TestPoolKeepAlive 测试拨号器按配置开启 TCP 保活
+func TestPoolKeepAlive(t *testing.T) {
+	if DefaultPoolConfig().KeepAlive <= 0 {
+		t.Error("Default KeepAlive should be enabled")
+	}
+
+	address := startEchoServer(t)
+
+	config := DefaultPoolConfig()
+	config.KeepAlive = 7 * time.Second
+	pool := NewPool(address, config)
+
+	if keepAlive := pool.newDialer().KeepAlive; keepAlive != 7*time.Second {
+		t.Errorf("Expected dialer KeepAlive 7s, got %v", keepAlive)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	conn, err := pool.createConnection(ctx)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, ok := conn.Conn.(*net.TCPConn); !ok {
+		t.Errorf("Expected a TCP connection, got %T", conn.Conn)
+	}
+
+	config.KeepAlive = -1
+	if keepAlive := pool.newDialer().KeepAlive; keepAlive >= 0 {
+		t.Errorf("Expected negative KeepAlive to disable keep-alive, got %v", keepAlive)
+	}
+}
+
+// TestPoolOutstandingCount 测试借出和归还连接时的未归还计数
+func TestPoolOutstandingCount(t *testing.T) {
+	address := startEchoServer(t)
+
+	pool := NewPool(address, DefaultPoolConfig())
+	if err := pool.Start(); err != nil {
+		t.Fatalf("Failed to start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first, err := pool.GetConnection(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	second, err := pool.GetConnection(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	if count := pool.OutstandingCount(); count != 2 {
+		t.Errorf("Expected 2 outstanding connections, got %d", count)
+	}
+
+	pool.ReturnConnection(first)
+	if count := pool.OutstandingCount(); count != 1 {
+		t.Errorf("Expected 1 outstanding connection after return, got %d", count)
+	}
+
+	pool.ReturnConnection(second)
+	if count := pool.OutstandingCount(); count != 0 {
+		t.Errorf("Expected no outstanding connections, got %d", count)
+	}
+
+	// 借出数连续增长时累计增长次数
+	var leaked []*Connection
+	for i := 0; i < outstandingGrowthChecks; i++ {
+		conn, err := pool.GetConnection(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+		leaked = append(leaked, conn)
+		pool.checkOutstanding()
+	}
+	if pool.outstandingGrowth != outstandingGrowthChecks {
+		t.Errorf("Expected growth streak %d, got %d", outstandingGrowthChecks, pool.outstandingGrowth)
+	}
+
+	// 健康检查移除的借出连接不再计入未归还数
+	leaked[0].Close()
+	pool.performHealthCheck()
+	if count := pool.OutstandingCount(); count != len(leaked)-1 {
+		t.Errorf("Expected %d outstanding connections after removing an unhealthy one, got %d", len(leaked)-1, count)
+	}
+
+	// 停止连接池后清空借出记录
+	pool.Stop()
+	if count := pool.OutstandingCount(); count != 0 {
+		t.Errorf("Expected no outstanding connections after stop, got %d", count)
+	}
+	if pool.outstandingGrowth != 0 {
+		t.Errorf("Expected growth streak reset after stop, got %d", pool.outstandingGrowth)
+	}
+}
+
+// TestConnectionSequence 测试乱序或重放的帧被拒绝
+func TestConnectionSequence(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	receiver := NewConnection(server)
+	defer receiver.Close()
+
+	// writeFrame 直接写出指定序号的帧，模拟中间人篡改
+	writeFrame := func(seq uint64, payload []byte) {
+		frame := make([]byte, frameHeaderLen+len(payload))
+		binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+		binary.BigEndian.PutUint64(frame[4:], seq)
+		copy(frame[frameHeaderLen:], payload)
+		go client.Write(frame)
+	}
+
+	writeFrame(1, []byte("first"))
+	if data, err := receiver.Receive(); err != nil || string(data) != "first" {
+		t.Fatalf("Expected first frame, got %q (%v)", data, err)
+	}
+
+	tests := []struct {
+		name string
+		seq  uint64
+	}{
+		{"Replayed", 1},
+		{"OutOfOrder", 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			writeFrame(tc.seq, []byte("bad"))
+			if _, err := receiver.Receive(); err == nil {
+				t.Errorf("Expected frame with sequence %d to be rejected", tc.seq)
+			}
+		})
+	}
+
+	writeFrame(2, []byte("second"))
+	if data, err := receiver.Receive(); err != nil || string(data) != "second" {
+		t.Errorf("Expected next in-order frame to be accepted, got %q (%v)", data, err)
+	}
+
+	// Send 发出的帧序号从 1 开始递增
+	sender := NewConnection(client)
+	peer := NewConnection(server)
+	for _, payload := range []string{"a", "b"} {
+		go sender.Send([]byte(payload))
+		if data, err := peer.Receive(); err != nil || string(data) != payload {
+			t.Fatalf("Expected %q, got %q (%v)", payload, data, err)
+		}
+	}
+}
+
+// TestManagerTrack 测试登记的连接计入统计信息和连接列表，取消登记后移除
+func TestManagerTrack(t *testing.T) {
+	address := startEchoServer(t)
+	manager := NewManager(nil)
+
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		manager.Track(conn).Use()
+		conns = append(conns, conn)
+	}
+
+	stats := manager.GetManagerStats()
+	if stats["total_connections"] != 2 || stats["inbound_connections"] != 2 {
+		t.Errorf("Expected 2 tracked connections, got %v", stats)
+	}
+	infos := manager.GetConnectionInfo()
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 connections in listing, got %d", len(infos))
+	}
+	for _, info := range infos {
+		if info["remote_addr"] != address || info["usage_count"] != 1 {
+			t.Errorf("Unexpected connection info: %v", info)
+		}
+	}
+
+	manager.Untrack(conns[0])
+	manager.Untrack(conns[0])
+	if total := manager.GetManagerStats()["total_connections"]; total != 1 {
+		t.Errorf("Expected 1 connection after untracking, got %v", total)
+	}
+	if infos := manager.GetConnectionInfo(); len(infos) != 1 {
+		t.Errorf("Expected 1 connection in listing after untracking, got %d", len(infos))
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"sort"
 	"sync"
 	"time"
 )
@@ -14,6 +16,15 @@ type Manager struct {
 	config      *PoolConfig         // 默认配置
 	mutex       sync.RWMutex        // 读写锁
 	isRunning   bool               // 是否运行中
+	stopCh      chan struct{}      // 停止重连任务的信号
+	reconnects  map[string]*reconnectState // 地址 -> 重连状态，仅由重连任务访问
+	inbound     map[net.Conn]*Connection   // 对方主动建立、由调用方负责关闭的连接
+}
+
+// reconnectState 空连接池的重连状态
+type reconnectState struct {
+	attempts    int       // 已失败的重连次数
+	nextAttempt time.Time // 下次允许重连的时间
 }
 
 // NewManager 创建连接管理器
@@ -23,9 +34,11 @@ func NewManager(config *PoolConfig) *Manager {
 	}
 	
 	return &Manager{
-		pools:     make(map[string]*Pool),
-		config:    config,
-		isRunning: false,
+		pools:      make(map[string]*Pool),
+		config:     config,
+		isRunning:  false,
+		reconnects: make(map[string]*reconnectState),
+		inbound:    make(map[net.Conn]*Connection),
 	}
 }
 
@@ -39,6 +52,8 @@ func (m *Manager) Start() error {
 	}
 	
 	m.isRunning = true
+	m.stopCh = make(chan struct{})
+	go m.reconnectLoop(m.stopCh)
 	log.Println("连接管理器已启动")
 	
 	return nil
@@ -54,6 +69,7 @@ func (m *Manager) Stop() error {
 	}
 	
 	m.isRunning = false
+	close(m.stopCh)
 	
 	// 停止所有连接池
 	for address, pool := range m.pools {
@@ -174,29 +190,74 @@ func (m *Manager) GetAllPoolStats() map[string]*PoolStats {
 	return stats
 }
 
+// Track 登记对方主动建立的连接，使其计入统计信息和连接列表
+// 管理器不会复用或关闭登记的连接，调用方关闭连接前应调用 Untrack
+func (m *Manager) Track(conn net.Conn) *Connection {
+	tracked := NewConnection(conn)
+	
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	m.inbound[conn] = tracked
+	return tracked
+}
+
+// Untrack 取消登记 Track 记录的连接，未登记的连接直接忽略
+func (m *Manager) Untrack(conn net.Conn) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	delete(m.inbound, conn)
+}
+
+// GetConnectionInfo 获取连接池中的连接和登记的连接的信息，按远程地址排序
+func (m *Manager) GetConnectionInfo() []map[string]interface{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	
+	var infos []map[string]interface{}
+	for _, pool := range m.pools {
+		infos = append(infos, pool.GetConnectionInfo()...)
+	}
+	for _, conn := range m.inbound {
+		infos = append(infos, conn.GetInfo())
+	}
+	
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i]["remote_addr"].(string) < infos[j]["remote_addr"].(string)
+	})
+	return infos
+}
+
 // GetManagerStats 获取管理器统计信息
 func (m *Manager) GetManagerStats() map[string]interface{} {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 	
-	totalConnections := 0
+	totalConnections := len(m.inbound)
 	totalActiveConnections := 0
 	totalIdleConnections := 0
+	var totalBytesSent, totalBytesReceived int64
 	
 	for _, pool := range m.pools {
 		stats := pool.GetStats()
 		totalConnections += stats.TotalConnections
 		totalActiveConnections += stats.ActiveConnections
 		totalIdleConnections += stats.IdleConnections
+		totalBytesSent += stats.BytesSent
+		totalBytesReceived += stats.BytesReceived
 	}
 	
 	return map[string]interface{}{
 		"is_running":             m.isRunning,
 		"total_pools":           len(m.pools),
 		"total_connections":     totalConnections,
+		"inbound_connections":   len(m.inbound),
 		"total_active_connections": totalActiveConnections,
 		"total_idle_connections":   totalIdleConnections,
 		"max_connections_per_pool": m.config.MaxConnections,
+		"total_bytes_sent":         totalBytesSent,
+		"total_bytes_received":     totalBytesReceived,
 	}
 }
 
@@ -277,6 +338,79 @@ func (m *Manager) CleanupIdlePools() {
 	}
 }
 
+// reconnectLoop 定期为没有存活连接的连接池重建连接
+func (m *Manager) reconnectLoop(stopCh chan struct{}) {
+	interval := m.GetPoolConfig().RetryInterval
+	if interval <= 0 {
+		interval = DefaultPoolConfig().RetryInterval
+	}
+	
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ticker.C:
+			m.reconnectEmptyPools()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// reconnectEmptyPools 尝试重连空连接池，失败后按指数退避，超过最大重试次数后放弃
+func (m *Manager) reconnectEmptyPools() {
+	m.mutex.RLock()
+	pools := make(map[string]*Pool, len(m.pools))
+	for address, pool := range m.pools {
+		pools[address] = pool
+	}
+	config := m.config
+	m.mutex.RUnlock()
+	
+	// 已移除的连接池不再需要重连状态
+	for address := range m.reconnects {
+		if _, exists := pools[address]; !exists {
+			delete(m.reconnects, address)
+		}
+	}
+	
+	now := time.Now()
+	for address, pool := range pools {
+		if address == "" || pool.GetStats().TotalConnections > 0 {
+			delete(m.reconnects, address)
+			continue
+		}
+		
+		state, exists := m.reconnects[address]
+		if !exists {
+			state = &reconnectState{}
+			m.reconnects[address] = state
+		}
+		if state.attempts >= config.MaxRetries || now.Before(state.nextAttempt) {
+			continue
+		}
+		
+		ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
+		err := pool.Reconnect(ctx)
+		cancel()
+		
+		if err == nil {
+			log.Printf("连接池重连成功: %s", address)
+			delete(m.reconnects, address)
+			continue
+		}
+		
+		state.attempts++
+		state.nextAttempt = now.Add(config.RetryInterval << uint(state.attempts))
+		if state.attempts >= config.MaxRetries {
+			log.Printf("连接池重连失败 %d 次，放弃重连 %s: %v", state.attempts, address, err)
+		} else {
+			log.Printf("连接池重连失败 %s (第 %d 次): %v", address, state.attempts, err)
+		}
+	}
+}
+
 // SetPoolConfig 设置连接池配置
 func (m *Manager) SetPoolConfig(config *PoolConfig) {
 	m.mutex.Lock()
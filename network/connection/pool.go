@@ -17,6 +17,8 @@ type PoolConfig struct {
 	HealthCheckInterval time.Duration // 健康检查间隔
 	RetryInterval       time.Duration // 重试间隔
 	MaxRetries          int           // 最大重试次数
+	DNSCacheTTL         time.Duration // 主机名解析结果的缓存时间，0 表示每次连接都重新解析
+	KeepAlive           time.Duration // TCP 保活探测间隔，0 使用系统默认值，负数关闭保活
 }
 
 // DefaultPoolConfig 默认连接池配置
@@ -28,9 +30,14 @@ func DefaultPoolConfig() *PoolConfig {
 		HealthCheckInterval: 30 * time.Second,
 		RetryInterval:       5 * time.Second,
 		MaxRetries:          3,
+		DNSCacheTTL:         time.Minute,
+		KeepAlive:           30 * time.Second,
 	}
 }
 
+// outstandingGrowthChecks 借出连接数连续增长多少次健康检查后发出泄漏警告
+const outstandingGrowthChecks = 3
+
 // Pool 连接池
 type Pool struct {
 	address      string                 // 目标地址
@@ -42,6 +49,15 @@ type Pool struct {
 	stopCh       chan bool              // 停止信号
 	healthTicker *time.Ticker           // 健康检查定时器
 	stats        *PoolStats             // 统计信息
+
+	borrowed          map[string]bool // 已借出但尚未归还的连接 ID
+	lastOutstanding   int             // 上次健康检查时的借出连接数
+	outstandingGrowth int             // 借出连接数连续增长的次数
+
+	lookupHost   func(ctx context.Context, host string) ([]string, error) // 主机名解析函数
+	resolvedAddr string                                                   // 缓存的解析结果（IP:端口）
+	resolvedAt   time.Time                                                // 解析结果的缓存时间
+	resolveMutex sync.Mutex
 }
 
 // PoolStats 连接池统计信息
@@ -53,6 +69,8 @@ type PoolStats struct {
 	TotalRequests       int64         // 总请求数
 	SuccessfulRequests  int64         // 成功请求数
 	AverageResponseTime time.Duration // 平均响应时间
+	BytesSent           int64         // 累计发送字节数
+	BytesReceived       int64         // 累计接收字节数
 	mutex               sync.RWMutex  // 读写锁
 }
 
@@ -65,11 +83,13 @@ func NewPool(address string, config *PoolConfig) *Pool {
 	pool := &Pool{
 		address:     address,
 		connections: make(map[string]*Connection),
+		borrowed:    make(map[string]bool),
 		available:   make(chan *Connection, config.MaxConnections),
 		config:      config,
 		isRunning:   false,
 		stopCh:      make(chan bool),
 		stats:       &PoolStats{},
+		lookupHost:  net.DefaultResolver.LookupHost,
 	}
 
 	return pool
@@ -120,10 +140,13 @@ func (p *Pool) Stop() error {
 		p.healthTicker.Stop()
 	}
 
-	// 关闭所有连接
+	// 关闭所有连接，借出的连接随之失效
 	for _, conn := range p.connections {
 		conn.Close()
 	}
+	p.borrowed = make(map[string]bool)
+	p.lastOutstanding = 0
+	p.outstandingGrowth = 0
 
 	// 清空可用连接队列
 	for len(p.available) > 0 {
@@ -158,6 +181,7 @@ func (p *Pool) GetConnection(ctx context.Context) (*Connection, error) {
 			p.stats.mutex.Lock()
 			p.stats.SuccessfulRequests++
 			p.stats.mutex.Unlock()
+			p.markBorrowed(conn)
 			return conn, nil
 		}
 		// 连接不健康，关闭并创建新连接
@@ -172,7 +196,28 @@ func (p *Pool) GetConnection(ctx context.Context) (*Connection, error) {
 	}
 
 	// 创建新连接
-	return p.createConnection(ctx)
+	conn, err := p.createConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.markBorrowed(conn)
+	return conn, nil
+}
+
+// markBorrowed 记录借出的连接
+func (p *Pool) markBorrowed(conn *Connection) {
+	p.mutex.Lock()
+	p.borrowed[conn.ID] = true
+	p.mutex.Unlock()
+}
+
+// OutstandingCount 返回已借出但尚未归还的连接数
+func (p *Pool) OutstandingCount() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return len(p.borrowed)
 }
 
 // ReturnConnection 归还连接
@@ -183,6 +228,10 @@ func (p *Pool) ReturnConnection(conn *Connection) {
 
 	conn.Release()
 
+	p.mutex.Lock()
+	delete(p.borrowed, conn.ID)
+	p.mutex.Unlock()
+
 	// 如果连接仍然健康且池未满，则归还到池中
 	if conn.IsHealthy() && len(p.available) < p.config.MaxConnections {
 		select {
@@ -200,6 +249,21 @@ func (p *Pool) ReturnConnection(conn *Connection) {
 	}
 }
 
+// Reconnect 建立一个新连接并放入可用队列
+func (p *Pool) Reconnect(ctx context.Context) error {
+	if !p.isRunning {
+		return fmt.Errorf("连接池未运行")
+	}
+
+	conn, err := p.createConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.ReturnConnection(conn)
+	return nil
+}
+
 // preCreateConnections 预创建连接
 func (p *Pool) preCreateConnections() {
 	initialCount := min(3, p.config.MaxConnections/2)
@@ -229,10 +293,20 @@ func (p *Pool) createConnection(ctx context.Context) (*Connection, error) {
 		return nil, fmt.Errorf("连接数已达上限: %d", p.config.MaxConnections)
 	}
 
+	dialAddr, err := p.resolveAddress(ctx)
+	if err != nil {
+		p.stats.mutex.Lock()
+		p.stats.FailedConnections++
+		p.stats.mutex.Unlock()
+		return nil, fmt.Errorf("地址解析失败: %v", err)
+	}
+
 	// 创建网络连接
-	var dialer net.Dialer
-	netConn, err := dialer.DialContext(ctx, "tcp", p.address)
+	netConn, err := p.newDialer().DialContext(ctx, "tcp", dialAddr)
 	if err != nil {
+		// 缓存的地址可能已失效，下次连接时重新解析
+		p.invalidateResolvedAddress()
+
 		p.stats.mutex.Lock()
 		p.stats.FailedConnections++
 		p.stats.mutex.Unlock()
@@ -250,13 +324,68 @@ func (p *Pool) createConnection(ctx context.Context) (*Connection, error) {
 	return conn, nil
 }
 
-// removeConnection 移除连接
+// newDialer 按配置创建拨号器，开启 TCP 保活以避免空闲连接被 NAT 丢弃
+func (p *Pool) newDialer() *net.Dialer {
+	return &net.Dialer{KeepAlive: p.config.KeepAlive}
+}
+
+// resolveAddress 返回用于拨号的地址，在 DNSCacheTTL 内复用主机名的解析结果
+func (p *Pool) resolveAddress(ctx context.Context) (string, error) {
+	if p.config.DNSCacheTTL <= 0 {
+		return p.address, nil
+	}
+
+	host, port, err := net.SplitHostPort(p.address)
+	if err != nil || net.ParseIP(host) != nil {
+		return p.address, nil
+	}
+
+	p.resolveMutex.Lock()
+	defer p.resolveMutex.Unlock()
+
+	if p.resolvedAddr != "" && time.Since(p.resolvedAt) < p.config.DNSCacheTTL {
+		return p.resolvedAddr, nil
+	}
+
+	addrs, err := p.lookupHost(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("主机 %s 没有可用地址", host)
+	}
+
+	p.resolvedAddr = net.JoinHostPort(addrs[0], port)
+	p.resolvedAt = time.Now()
+
+	return p.resolvedAddr, nil
+}
+
+// invalidateResolvedAddress 清除缓存的解析结果
+func (p *Pool) invalidateResolvedAddress() {
+	p.resolveMutex.Lock()
+	defer p.resolveMutex.Unlock()
+
+	p.resolvedAddr = ""
+}
+
+// removeConnection 移除连接，已借出的连接不再计入未归还数
 func (p *Pool) removeConnection(connID string) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
+	delete(p.borrowed, connID)
 	if conn, exists := p.connections[connID]; exists {
 		delete(p.connections, connID)
+
+		// 保留已移除连接的流量统计
+		conn.mutex.RLock()
+		p.stats.mutex.Lock()
+		p.stats.BytesSent += conn.BytesSent
+		p.stats.BytesReceived += conn.BytesReceived
+		p.stats.mutex.Unlock()
+		conn.mutex.RUnlock()
+
 		log.Printf("移除连接: %s", conn.ID)
 	}
 }
@@ -305,6 +434,27 @@ func (p *Pool) performHealthCheck() {
 	if len(unhealthyConns) > 0 {
 		log.Printf("健康检查: 移除 %d 个不健康连接", len(unhealthyConns))
 	}
+
+	p.checkOutstanding()
+}
+
+// checkOutstanding 借出连接数连续增长时发出可能存在连接泄漏的警告
+func (p *Pool) checkOutstanding() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	outstanding := len(p.borrowed)
+	if outstanding > p.lastOutstanding {
+		p.outstandingGrowth++
+	} else {
+		p.outstandingGrowth = 0
+	}
+	p.lastOutstanding = outstanding
+
+	if p.outstandingGrowth >= outstandingGrowthChecks {
+		log.Printf("警告: 连接池 %s 的借出连接数连续 %d 次增长，当前 %d 个未归还，可能存在连接泄漏",
+			p.address, p.outstandingGrowth, outstanding)
+	}
 }
 
 // updateAverageResponseTime 更新平均响应时间
@@ -330,13 +480,19 @@ func (p *Pool) GetStats() *PoolStats {
 
 	activeCount := 0
 	idleCount := 0
+	bytesSent := p.stats.BytesSent
+	bytesReceived := p.stats.BytesReceived
 
 	for _, conn := range p.connections {
+		conn.mutex.RLock()
 		if conn.IsBusy {
 			activeCount++
 		} else {
 			idleCount++
 		}
+		bytesSent += conn.BytesSent
+		bytesReceived += conn.BytesReceived
+		conn.mutex.RUnlock()
 	}
 
 	return &PoolStats{
@@ -347,6 +503,8 @@ func (p *Pool) GetStats() *PoolStats {
 		TotalRequests:       p.stats.TotalRequests,
 		SuccessfulRequests:  p.stats.SuccessfulRequests,
 		AverageResponseTime: p.stats.AverageResponseTime,
+		BytesSent:           bytesSent,
+		BytesReceived:       bytesReceived,
 	}
 }
 
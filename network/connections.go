@@ -0,0 +1,78 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/connection"
+)
+
+// ConnectionInfo 运行中节点的一个连接
+type ConnectionInfo struct {
+	RemoteAddr string  `json:"remote_addr"`
+	Age        float64 `json:"age"`       // 连接存在的秒数
+	IdleTime   float64 `json:"idle_time"` // 最后一次使用后经过的秒数
+	UsageCount int     `json:"usage_count"`
+}
+
+// ConnectionsReport 运行中节点的连接数和连接列表
+type ConnectionsReport struct {
+	Count       int              `json:"count"`
+	Connections []ConnectionInfo `json:"connections"`
+}
+
+// buildConnectionsReport 由连接管理器的统计信息和连接信息生成连接报告
+// 没有连接管理器时报告零个连接
+func buildConnectionsReport(manager *connection.Manager) *ConnectionsReport {
+	report := &ConnectionsReport{Connections: []ConnectionInfo{}}
+	if manager == nil {
+		return report
+	}
+
+	report.Count = manager.GetManagerStats()["total_connections"].(int)
+	for _, info := range manager.GetConnectionInfo() {
+		report.Connections = append(report.Connections, ConnectionInfo{
+			RemoteAddr: info["remote_addr"].(string),
+			Age:        info["age"].(float64),
+			IdleTime:   info["idle_time"].(float64),
+			UsageCount: info["usage_count"].(int),
+		})
+	}
+	return report
+}
+
+// handleGetConns 在请求连接上回复运行中节点的连接报告，查询本身的连接不计入
+func handleGetConns(conn net.Conn, request []byte, bc *blockchain.Blockchain) error {
+	manager := getConnManager()
+	if manager != nil {
+		manager.Untrack(conn)
+	}
+
+	data, err := json.Marshal(buildConnectionsReport(manager))
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(append(CommandToBytes("conns"), data...))
+	return err
+}
+
+// QueryConnections 向本机运行中的节点查询连接数和连接列表
+func QueryConnections(address string, timeout time.Duration) (*ConnectionsReport, error) {
+	reply, err := requestReply(address, CommandToBytes("getconns"), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if command := BytesToCommand(reply[:commandLength]); command != "conns" {
+		return nil, fmt.Errorf("unexpected reply %s to getconns", command)
+	}
+
+	var report ConnectionsReport
+	if err := json.Unmarshal(reply[commandLength:], &report); err != nil {
+		return nil, fmt.Errorf("failed to decode connections report: %v", err)
+	}
+	return &report, nil
+}
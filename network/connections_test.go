@@ -0,0 +1,68 @@
+package network
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mini-coin-go/network/connection"
+)
+
+// TestQueryConnections 测试 CLI 查询运行中节点正在处理的连接，查询本身的连接不计入
+func TestQueryConnections(t *testing.T) {
+	bc := newTestBlockchain(t)
+	address := startTestNode(t, bc)
+
+	defer SetConnManager(getConnManager())
+	SetConnManager(nil)
+	report, err := QueryConnections(address, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to query connections: %v", err)
+	}
+	if report.Count != 0 || len(report.Connections) != 0 {
+		t.Errorf("Expected no connections without a manager, got %+v", report)
+	}
+
+	manager := connection.NewManager(nil)
+	SetConnManager(manager)
+
+	// 两个尚未发送请求的连接仍在由节点处理
+	var peers []string
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial(protocol, address)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		peers = append(peers, conn.LocalAddr().String())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for manager.GetManagerStats()["total_connections"] != len(peers) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	report, err = QueryConnections(address, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to query connections: %v", err)
+	}
+	if report.Count != len(peers) || len(report.Connections) != len(peers) {
+		t.Fatalf("Expected %d connections, got %+v", len(peers), report)
+	}
+	for _, info := range report.Connections {
+		if info.RemoteAddr != peers[0] && info.RemoteAddr != peers[1] {
+			t.Errorf("Unexpected connection %s", info.RemoteAddr)
+		}
+		if info.UsageCount != 1 || info.Age < 0 || info.IdleTime < 0 {
+			t.Errorf("Unexpected connection info: %+v", info)
+		}
+	}
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	err = dispatchCommand(server, "getconns", CommandToBytes("getconns"), bc)
+	if err == nil || !strings.Contains(err.Error(), "non-local") {
+		t.Errorf("Expected a non-local connections request to be rejected, got: %v", err)
+	}
+}
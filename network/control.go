@@ -0,0 +1,71 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"mini-coin-go/blockchain"
+)
+
+// defaultQueryTimeout 查询运行中节点时默认的超时时间
+const defaultQueryTimeout = 5 * time.Second
+
+// connHandler 需要访问请求连接的命令处理函数，可以在同一连接上回复或检查请求方地址
+type connHandler func(conn net.Conn, request []byte, bc *blockchain.Blockchain) error
+
+// ignoreConn 将不需要请求连接的处理函数适配为 connHandler
+func ignoreConn(handler CommandHandler) connHandler {
+	return func(_ net.Conn, request []byte, bc *blockchain.Blockchain) error {
+		return handler(request, bc)
+	}
+}
+
+// localOnly 只接受来自本机回环地址的请求，用于 CLI 查询和控制运行中的节点
+func localOnly(handler connHandler) connHandler {
+	return func(conn net.Conn, request []byte, bc *blockchain.Blockchain) error {
+		if !isLoopbackConn(conn) {
+			return fmt.Errorf("%s command from non-local address %s rejected", BytesToCommand(request[:commandLength]), conn.RemoteAddr())
+		}
+		return handler(conn, request, bc)
+	}
+}
+
+// isLoopbackConn 判断连接的对端是否为本机回环地址
+func isLoopbackConn(conn net.Conn) bool {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	return ok && addr.IP.IsLoopback()
+}
+
+// requestReply 向节点发送请求并在同一连接上读取回复
+func requestReply(address string, request []byte, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+
+	conn, err := net.DialTimeout(protocol, address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", address, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	// 关闭写方向，对方读到 EOF 后才会处理请求
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reply: %v", err)
+	}
+	if len(reply) < commandLength {
+		return nil, fmt.Errorf("no reply from %s", address)
+	}
+
+	return reply, nil
+}
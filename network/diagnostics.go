@@ -0,0 +1,175 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"mini-coin-go/network/peer"
+	"mini-coin-go/network/security"
+)
+
+// defaultPingTimeout 默认的握手超时时间
+const defaultPingTimeout = 5 * time.Second
+
+var (
+	// handshakeAuth 设置后用于应答 version 消息中的认证挑战
+	handshakeAuth      *security.NodeAuth
+	handshakeAuthMutex sync.RWMutex
+)
+
+// SetHandshakeAuth 设置应答 version 认证挑战的节点身份，为 nil 时不应答
+func SetHandshakeAuth(auth *security.NodeAuth) {
+	handshakeAuthMutex.Lock()
+	defer handshakeAuthMutex.Unlock()
+
+	handshakeAuth = auth
+}
+
+// getHandshakeAuth 返回当前的节点身份
+func getHandshakeAuth() *security.NodeAuth {
+	handshakeAuthMutex.RLock()
+	defer handshakeAuthMutex.RUnlock()
+
+	return handshakeAuth
+}
+
+// PingResult 节点连通性诊断结果
+type PingResult struct {
+	Address     string        // 目标节点地址
+	ConnectTime time.Duration // 建立 TCP 连接的耗时
+	RTT         time.Duration // version 握手的往返时间
+	Version     int           // 对方报告的协议版本
+	BestHeight  int           // 对方报告的最佳区块高度
+	Services    uint64        // 对方公布的服务标志
+	PeerID      string        // 通过认证时对方的节点 ID
+}
+
+// PingPeer 连接节点并完成 version 握手，报告往返时间和对方的最佳高度
+// 发送的 version 携带高度 -1，对方只回复自己的 version，不会把探测地址记为已知节点
+func PingPeer(address string, timeout time.Duration) (*PingResult, error) {
+	return PingPeerWithAuth(address, timeout, nil)
+}
+
+// PingPeerWithAuth 与 PingPeer 相同，auth 非空时还要求对方用节点身份签名随机挑战
+func PingPeerWithAuth(address string, timeout time.Duration, auth *security.NodeAuth) (*PingResult, error) {
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer address %s: %v", address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer port %s: %v", portStr, err)
+	}
+
+	p := peer.NewPeer(host, port)
+	if err := p.Ping(); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout(protocol, address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", address, err)
+	}
+	defer conn.Close()
+
+	// 在与对方相连的本地地址上监听，等待对方回复 version
+	localHost, _, _ := net.SplitHostPort(conn.LocalAddr().String())
+	ln, err := net.Listen(protocol, net.JoinHostPort(localHost, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for reply: %v", err)
+	}
+	defer ln.Close()
+
+	version := Version{Version: protocolVersion, BestHeight: -1, AddrFrom: ln.Addr().String(), Services: localServices}
+	if auth != nil {
+		if version.Challenge, err = auth.GenerateChallenge(); err != nil {
+			return nil, err
+		}
+	}
+	payload, err := GobEncode(version)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(append(CommandToBytes("version"), payload...)); err != nil {
+		return nil, fmt.Errorf("failed to send version: %v", err)
+	}
+	conn.Close()
+
+	reply, err := waitForVersion(ln, time.Now().Add(timeout))
+	if err != nil {
+		return nil, err
+	}
+	rtt := time.Since(start)
+
+	result := &PingResult{
+		Address:     address,
+		ConnectTime: p.PingTime,
+		RTT:         rtt,
+		Version:     reply.Version,
+		BestHeight:  reply.BestHeight,
+		Services:    reply.Services,
+	}
+	if auth != nil {
+		if err := verifyVersionAuth(auth, reply, version.Challenge); err != nil {
+			return nil, fmt.Errorf("peer %s failed authentication: %v", address, err)
+		}
+		result.PeerID = reply.Auth.NodeID
+	}
+
+	return result, nil
+}
+
+// verifyVersionAuth 检查 version 回复是否用对方的节点身份签名了 challenge
+func verifyVersionAuth(auth *security.NodeAuth, reply *Version, challenge []byte) error {
+	if reply.Auth == nil {
+		return fmt.Errorf("no answer to the authentication challenge")
+	}
+	if !bytes.Equal(reply.Auth.Challenge, challenge) {
+		return fmt.Errorf("answer does not match the authentication challenge")
+	}
+
+	return auth.VerifyAuthMessage(reply.Auth)
+}
+
+// waitForVersion 在截止时间前等待对方发回 version 消息
+func waitForVersion(ln net.Listener, deadline time.Time) (*Version, error) {
+	if tcp, ok := ln.(*net.TCPListener); ok {
+		tcp.SetDeadline(deadline)
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil, fmt.Errorf("no version reply: %v", err)
+		}
+
+		conn.SetReadDeadline(deadline)
+		request, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil || len(request) < commandLength {
+			continue
+		}
+		if BytesToCommand(request[:commandLength]) != "version" {
+			continue
+		}
+
+		var reply Version
+		if err := gob.NewDecoder(bytes.NewReader(request[commandLength:])).Decode(&reply); err != nil {
+			return nil, fmt.Errorf("failed to decode version reply: %v", err)
+		}
+
+		return &reply, nil
+	}
+}
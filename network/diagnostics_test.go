@@ -0,0 +1,116 @@
+package network
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/security"
+)
+
+// startTestNode 用 handleConnection 服务测试连接，清理时等待所有处理完成后再返回
+func startTestNode(t *testing.T, bc *blockchain.Blockchain) string {
+	ln, err := net.Listen(protocol, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start mock server: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handleConnection(conn, bc)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		wg.Wait()
+	})
+
+	return ln.Addr().String()
+}
+
+// TestPingPeer 测试对运行中节点的握手诊断
+func TestPingPeer(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	oldKnownNodes := KnownNodes
+	t.Cleanup(func() { KnownNodes = oldKnownNodes })
+	KnownNodes = []string{"localhost:3000"}
+
+	address := startTestNode(t, bc)
+
+	result, err := PingPeer(address, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Expected ping to succeed, got: %v", err)
+	}
+	if result.BestHeight != bc.GetBestHeight() {
+		t.Errorf("Expected best height %d, got %d", bc.GetBestHeight(), result.BestHeight)
+	}
+	if result.RTT <= 0 {
+		t.Errorf("Expected positive round-trip time, got %v", result.RTT)
+	}
+	if len(KnownNodes) != 1 {
+		t.Errorf("Expected the probe address not to be recorded, got known nodes %v", KnownNodes)
+	}
+}
+
+// TestPingPeer_Auth 测试要求认证时只有配置了节点身份的节点能通过诊断
+func TestPingPeer_Auth(t *testing.T) {
+	bc := newTestBlockchain(t)
+	t.Cleanup(func() { SetHandshakeAuth(nil) })
+
+	address := startTestNode(t, bc)
+
+	auth, err := security.NewNodeAuth("pinger")
+	if err != nil {
+		t.Fatalf("Failed to create node auth: %v", err)
+	}
+	if _, err := PingPeerWithAuth(address, 2*time.Second, auth); err == nil || !strings.Contains(err.Error(), "failed authentication") {
+		t.Errorf("Expected a node without identity to fail authentication, got: %v", err)
+	}
+
+	nodeAuth, err := security.NewNodeAuth("test-node")
+	if err != nil {
+		t.Fatalf("Failed to create node auth: %v", err)
+	}
+	SetHandshakeAuth(nodeAuth)
+
+	result, err := PingPeerWithAuth(address, 2*time.Second, auth)
+	if err != nil {
+		t.Fatalf("Expected authenticated ping to succeed, got: %v", err)
+	}
+	if result.PeerID != "test-node" || !auth.IsAuthenticated("test-node") {
+		t.Errorf("Expected peer to authenticate as test-node, got %q", result.PeerID)
+	}
+}
+
+// TestPingPeer_DeadAddress 测试目标节点不可达时返回错误
+func TestPingPeer_DeadAddress(t *testing.T) {
+	ln, err := net.Listen(protocol, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve address: %v", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	if _, err := PingPeer(address, time.Second); err == nil {
+		t.Error("Expected ping to a dead address to fail")
+	}
+
+	if _, err := PingPeer("not-an-address", time.Second); err == nil || !strings.Contains(err.Error(), "invalid peer address") {
+		t.Errorf("Expected invalid address error, got: %v", err)
+	}
+}
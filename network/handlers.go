@@ -3,14 +3,15 @@ package network
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
-	"log"
 
 	"mini-coin-go/blockchain"
+	netsync "mini-coin-go/network/sync"
 )
 
 // handleVersion handles the version command
-func handleVersion(request []byte, bc *blockchain.Blockchain) {
+func handleVersion(request []byte, bc *blockchain.Blockchain) error {
 	var buff bytes.Buffer
 	var payload Version
 
@@ -18,14 +19,41 @@ func handleVersion(request []byte, bc *blockchain.Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	if payload.AddrFrom == "" {
+		return fmt.Errorf("version message has no sender address")
+	}
+
+	if isSelfAddress(payload.AddrFrom) {
+		return fmt.Errorf("version message from own address %s, ignoring self-connection", payload.AddrFrom)
+	}
+
+	version, err := negotiateVersion(payload.Version)
+	if err != nil {
+		return fmt.Errorf("version message from %s rejected: %v", payload.AddrFrom, err)
 	}
 
 	myBestHeight := bc.GetBestHeight()
 	foreignerBestHeight := payload.BestHeight
 
+	// 高度 -1 表示不持有区块的探测节点（见 PingPeer）
+	if foreignerBestHeight < -1 || foreignerBestHeight > myBestHeight+maxHeightLead {
+		return fmt.Errorf("version message from %s reports implausible best height %d", payload.AddrFrom, foreignerBestHeight)
+	}
+	if foreignerBestHeight == -1 {
+		// 探测节点只等待一次回复，不记录其临时地址
+		return sendVersionWithAuth(payload.AddrFrom, bc, payload.Challenge)
+	}
+	setPeerHeight(payload.AddrFrom, foreignerBestHeight)
+	setPeerHandshake(payload.AddrFrom, version, payload.Services)
+	recordPeer(payload.AddrFrom, foreignerBestHeight, payload.Services)
+
 	if myBestHeight < foreignerBestHeight {
-		SendGetBlocks(payload.AddrFrom)
+		if syncPeer := selectSyncPeer(payload.AddrFrom, payload.Services, myBestHeight); syncPeer != "" {
+			SendGetBlocks(syncPeer, bc.GetBlockLocator())
+		}
 	} else if myBestHeight > foreignerBestHeight {
 		sendVersion(payload.AddrFrom, bc)
 	}
@@ -33,10 +61,12 @@ func handleVersion(request []byte, bc *blockchain.Blockchain) {
 	if !nodeIsKnown(payload.AddrFrom) {
 		KnownNodes = append(KnownNodes, payload.AddrFrom)
 	}
+
+	return nil
 }
 
 // handleAddr handles the addr command
-func handleAddr(request []byte) {
+func handleAddr(request []byte) error {
 	var buff bytes.Buffer
 	var payload Addr
 
@@ -44,15 +74,21 @@ func handleAddr(request []byte) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("failed to decode payload: %v", err)
 	}
 
-	KnownNodes = append(KnownNodes, payload.AddrList...)
+	if err := verifyAddr(&payload); err != nil {
+		return err
+	}
+
+	addKnownAddrs(payload.AddrList)
 	fmt.Printf("there are %d known nodes\n", len(KnownNodes))
+
+	return nil
 }
 
 // handleBlock handles the block command
-func handleBlock(request []byte, bc *blockchain.Blockchain) {
+func handleBlock(request []byte, bc *blockchain.Blockchain) error {
 	var buff bytes.Buffer
 	var payload BlockData
 
@@ -60,33 +96,51 @@ func handleBlock(request []byte, bc *blockchain.Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	if len(payload.Block) == 0 {
+		return fmt.Errorf("block message has no block data")
 	}
 
 	blockData := payload.Block
 	block := blockchain.DeserializeBlock(blockData)
+	clearPendingData("block", block.Hash)
 
 	fmt.Println("Recevied a new block!")
-	if err := bc.AddBlock(block); err != nil {
-		log.Printf("Failed to add block: %v", err)
-		return
+	// A block we already have still counts as delivered, so the download keeps going.
+	// Blocks that fail validation are dropped without touching the chain.
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		fmt.Printf("Block %x already exists\n", block.Hash)
+	} else if err := bc.ValidateBlock(block); errors.Is(err, blockchain.ErrInvalidBlock) {
+		fmt.Printf("Dropped invalid block %x: %v\n", block.Hash, err)
+		// Without the parent the block may still be valid, so only a provably invalid block costs the sender
+		if _, err := bc.GetBlock(block.PrevBlockHash); err == nil {
+			penalizePeer(payload.AddrFrom, invalidBlockPenalty)
+		}
+	} else if err := bc.AddBlock(block); err == blockchain.ErrBlockExists {
+		fmt.Printf("Block %x already exists\n", block.Hash)
+	} else if err != nil {
+		return fmt.Errorf("failed to add block: %v", err)
+	} else {
+		fmt.Printf("Added block %x\n", block.Hash)
 	}
 
-	fmt.Printf("Added block %x\n", block.Hash)
-
 	if len(blocksInTransit) > 0 {
 		blockHash := blocksInTransit[0]
 		SendGetData(payload.AddrFrom, "block", blockHash)
 
 		blocksInTransit = blocksInTransit[1:]
 	} else {
-		UTXOSet := blockchain.UTXOSet{bc}
+		UTXOSet := blockchain.UTXOSet{Blockchain: bc}
 		UTXOSet.Reindex()
 	}
+
+	return nil
 }
 
 // handleInv handles the inv command
-func handleInv(request []byte, bc *blockchain.Blockchain) {
+func handleInv(request []byte, bc *blockchain.Blockchain) error {
 	var buff bytes.Buffer
 	var payload Inv
 
@@ -94,15 +148,24 @@ func handleInv(request []byte, bc *blockchain.Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	if len(payload.Items) == 0 {
+		return fmt.Errorf("inv message has no items")
 	}
 
 	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
 
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
+		// Inventories list the tip first; download oldest first so that every
+		// block arrives after its parent and can be validated
+		blocksInTransit = make([][]byte, 0, len(payload.Items))
+		for i := len(payload.Items) - 1; i >= 0; i-- {
+			blocksInTransit = append(blocksInTransit, payload.Items[i])
+		}
 
-		blockHash := payload.Items[0]
+		blockHash := blocksInTransit[0]
 		SendGetData(payload.AddrFrom, "block", blockHash)
 
 		newInTransit := [][]byte{}
@@ -121,10 +184,12 @@ func handleInv(request []byte, bc *blockchain.Blockchain) {
 			SendGetData(payload.AddrFrom, "tx", txID)
 		}
 	}
+
+	return nil
 }
 
 // handleGetBlocks handles the getblocks command
-func handleGetBlocks(request []byte, bc *blockchain.Blockchain) {
+func handleGetBlocks(request []byte, bc *blockchain.Blockchain) error {
 	var buff bytes.Buffer
 	var payload GetBlocks
 
@@ -132,15 +197,31 @@ func handleGetBlocks(request []byte, bc *blockchain.Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	if payload.AddrFrom == "" {
+		return fmt.Errorf("getblocks message has no sender address")
+	}
+
+	var blocks [][]byte
+	if len(payload.Locator) == 0 {
+		blocks = bc.GetBlockHashes()
+	} else {
+		blocks = bc.GetBlockHashesAfterLocator(payload.Locator)
 	}
 
-	blocks := bc.GetBlockHashes()
+	// 对方已拥有全部主链区块
+	if len(blocks) == 0 {
+		return nil
+	}
 	SendInv(payload.AddrFrom, "block", blocks)
+
+	return nil
 }
 
 // handleGetData handles the getdata command
-func handleGetData(request []byte, bc *blockchain.Blockchain) {
+func handleGetData(request []byte, bc *blockchain.Blockchain) error {
 	var buff bytes.Buffer
 	var payload GetData
 
@@ -148,13 +229,18 @@ func handleGetData(request []byte, bc *blockchain.Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	if len(payload.ID) == 0 {
+		return fmt.Errorf("getdata message has no id")
 	}
 
 	if payload.Type == "block" {
 		block, err := bc.GetBlock([]byte(payload.ID))
 		if err != nil {
-			return
+			sendNotFound(payload.AddrFrom, "block", payload.ID)
+			return nil
 		}
 
 		SendBlock(payload.AddrFrom, &block)
@@ -162,14 +248,149 @@ func handleGetData(request []byte, bc *blockchain.Blockchain) {
 
 	if payload.Type == "tx" {
 		txID := string(payload.ID)
-		tx := mempool[txID]
+		tx, ok := mempool[txID]
+		if !ok {
+			fmt.Printf("Transaction %x is not in mempool\n", payload.ID)
+			sendNotFound(payload.AddrFrom, "tx", payload.ID)
+			return nil
+		}
 
 		SendTx(payload.AddrFrom, &tx)
 	}
+
+	return nil
+}
+
+// handleNotFound handles the notfound command
+// 向尚未回复 notfound 的其他已知节点重新请求，没有可用节点时放弃
+func handleNotFound(request []byte) error {
+	var buff bytes.Buffer
+	var payload NotFound
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	if len(payload.ID) == 0 {
+		return fmt.Errorf("notfound message has no id")
+	}
+
+	// Only a reply from the node we are waiting on for this item can trigger a retry
+	key := dataKey(payload.Type, payload.ID)
+	pendingDataMutex.Lock()
+	if pendingData[key] != payload.AddrFrom {
+		pendingDataMutex.Unlock()
+		return fmt.Errorf("unsolicited notfound for %s %x from %s", payload.Type, payload.ID, payload.AddrFrom)
+	}
+	fmt.Printf("%s does not have %s %x\n", payload.AddrFrom, payload.Type, payload.ID)
+	notFoundPeers[key] = append(notFoundPeers[key], payload.AddrFrom)
+	tried := notFoundPeers[key]
+	pendingDataMutex.Unlock()
+
+	for _, node := range KnownNodes {
+		if node == nodeAddress || containsAddr(tried, node) {
+			continue
+		}
+		if payload.Type == "block" && !offersBlocks(node) {
+			continue
+		}
+
+		SendGetData(node, payload.Type, payload.ID)
+		return nil
+	}
+
+	fmt.Printf("No other node has %s %x, giving up\n", payload.Type, payload.ID)
+	clearPendingData(payload.Type, payload.ID)
+
+	if payload.Type == "block" {
+		newInTransit := [][]byte{}
+		for _, b := range blocksInTransit {
+			if !bytes.Equal(b, payload.ID) {
+				newInTransit = append(newInTransit, b)
+			}
+		}
+		blocksInTransit = newInTransit
+	}
+
+	return nil
+}
+
+// handleResend handles the resend command by announcing every mempool transaction to the known nodes.
+// It is registered as a local-only command, so only the node's own CLI can trigger a rebroadcast.
+func handleResend(request []byte) error {
+	var buff bytes.Buffer
+	var payload Resend
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	var txIDs [][]byte
+	for _, tx := range mempool {
+		txIDs = append(txIDs, tx.ID)
+	}
+	if len(txIDs) == 0 {
+		fmt.Println("Mempool is empty, nothing to rebroadcast")
+		return nil
+	}
+
+	for _, node := range KnownNodes {
+		if node != nodeAddress {
+			SendInv(node, "tx", txIDs)
+		}
+	}
+	fmt.Printf("Rebroadcast %d transactions to known nodes\n", len(txIDs))
+
+	return nil
+}
+
+// mempoolParents 返回交易输入引用的、仍在内存池中的交易
+func mempoolParents(tx *blockchain.Transaction) []*blockchain.Transaction {
+	var parents []*blockchain.Transaction
+	for _, vin := range tx.Vin {
+		if parent, ok := mempool[string(vin.Txid)]; ok {
+			parents = append(parents, &parent)
+		}
+	}
+
+	return parents
+}
+
+// acceptTx validates tx against the chain and the mempool and adds it to the mempool,
+// returning why it was rejected otherwise
+func acceptTx(bc *blockchain.Blockchain, tx *blockchain.Transaction) (netsync.AcceptResult, error) {
+	if _, ok := mempool[string(tx.ID)]; ok {
+		return netsync.TxDuplicate, fmt.Errorf("transaction %x is already in the mempool", tx.ID)
+	}
+	// A coinbase is only valid as the first transaction of a block, so it must never be mined from the mempool
+	if tx.IsCoinbase() {
+		return netsync.TxInvalid, fmt.Errorf("coinbase transaction %x cannot be relayed", tx.ID)
+	}
+	if err := bc.ValidateTransactionInBlock(tx, mempoolParents(tx)); err != nil {
+		return netsync.TxInvalid, err
+	}
+	for _, pooled := range mempool {
+		for _, spent := range pooled.Vin {
+			for _, vin := range tx.Vin {
+				if bytes.Equal(spent.Txid, vin.Txid) && spent.Vout == vin.Vout {
+					return netsync.TxConflict, fmt.Errorf("transaction %x spends the same output as %x", tx.ID, pooled.ID)
+				}
+			}
+		}
+	}
+	mempool[string(tx.ID)] = *tx
+
+	return netsync.TxAccepted, nil
 }
 
 // handleTx handles the tx command
-func handleTx(request []byte, bc *blockchain.Blockchain) {
+func handleTx(request []byte, bc *blockchain.Blockchain) error {
 	var buff bytes.Buffer
 	var payload Tx
 
@@ -177,12 +398,19 @@ func handleTx(request []byte, bc *blockchain.Blockchain) {
 	dec := gob.NewDecoder(&buff)
 	err := dec.Decode(&payload)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("failed to decode payload: %v", err)
+	}
+
+	if len(payload.Transaction) == 0 {
+		return fmt.Errorf("tx message has no transaction data")
 	}
 
 	txData := payload.Transaction
 	tx := blockchain.DeserializeTransaction(txData)
-	mempool[string(tx.ID)] = *tx
+	clearPendingData("tx", tx.ID)
+	if result, err := acceptTx(bc, tx); err != nil {
+		return fmt.Errorf("rejected transaction %x (%s): %v", tx.ID, result, err)
+	}
 
 	if nodeAddress == KnownNodes[0] {
 		for _, node := range KnownNodes {
@@ -191,47 +419,45 @@ func handleTx(request []byte, bc *blockchain.Blockchain) {
 			}
 		}
 	} else {
-		if len(mempool) >= 2 && len(miningAddress) > 0 {
-		MineTransactions:
-			var txs []*blockchain.Transaction
-
-			for id := range mempool {
-				tx := mempool[id]
-				if bc.VerifyTransaction(&tx) {
-					txs = append(txs, &tx)
+		if len(mempool) >= 2 && isMining() {
+			for len(mempool) > 0 {
+				newBlock, err := mineMempool(bc)
+				if err != nil {
+					return fmt.Errorf("failed to mine block: %v", err)
+				}
+				if newBlock == nil {
+					fmt.Println("All transactions are invalid! Waiting for new ones...")
+					return nil
 				}
-			}
-
-			if len(txs) == 0 {
-				fmt.Println("All transactions are invalid! Waiting for new ones...")
-				return
-			}
-
-			cbTx := blockchain.NewCoinbaseTX(miningAddress, "")
-			txs = append(txs, cbTx)
-
-			newBlock := bc.MineBlock(txs)
-			UTXOSet := blockchain.UTXOSet{bc}
-			UTXOSet.Reindex()
-
-			fmt.Println("New block is mined!")
 
-			for _, tx := range txs {
-				txID := string(tx.ID)
-				delete(mempool, txID)
-			}
+				fmt.Println("New block is mined!")
 
-			for _, node := range KnownNodes {
-				if node != nodeAddress {
-					SendInv(node, "block", [][]byte{newBlock.Hash})
+				for _, node := range KnownNodes {
+					if node != nodeAddress {
+						SendInv(node, "block", [][]byte{newBlock.Hash})
+					}
 				}
 			}
+		}
+	}
 
-			if len(mempool) > 0 {
-				goto MineTransactions
-			}
+	return nil
+}
+
+// containsAddr checks if addr is in the list
+func containsAddr(list []string, addr string) bool {
+	for _, item := range list {
+		if item == addr {
+			return true
 		}
 	}
+
+	return false
+}
+
+// isSelfAddress reports whether addr is this node's own advertised address
+func isSelfAddress(addr string) bool {
+	return nodeAddress != "" && addr == nodeAddress
 }
 
 // nodeIsKnown checks if a node is already in the KnownNodes list
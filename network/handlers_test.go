@@ -0,0 +1,884 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/peer"
+	"mini-coin-go/network/security"
+	netsync "mini-coin-go/network/sync"
+)
+
+// testKey 测试链挖矿奖励地址的私钥，用于对测试交易签名
+var testKey, testAddress = newTestKey()
+
+// newTestKey 生成测试用私钥及其地址
+func newTestKey() (*ecdsa.PrivateKey, string) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+
+	return privKey, blockchain.PubKeyHashToAddress(blockchain.HashPubKey(pubKey))
+}
+
+// signTestTx 用测试私钥对花费 prevs 输出的交易签名
+func signTestTx(tx *blockchain.Transaction, prevs ...*blockchain.Transaction) {
+	prevTXs := make(map[string]blockchain.Transaction)
+	for _, prev := range prevs {
+		prevTXs[hex.EncodeToString(prev.ID)] = *prev
+	}
+	tx.Sign(*testKey, prevTXs)
+}
+
+// newTestBlockchain 创建测试用区块链，测试结束后自动清理
+func newTestBlockchain(t *testing.T) *blockchain.Blockchain {
+	cleanupTestFiles()
+	bc := blockchain.NewBlockchain(testAddress, testNodeID)
+	t.Cleanup(func() {
+		bc.DB.Close()
+		cleanupTestFiles()
+	})
+
+	return bc
+}
+
+// startTestPeer 启动一个记录收到的所有请求的测试节点
+func startTestPeer(t *testing.T) (string, chan []byte) {
+	listener, err := net.Listen(protocol, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start test peer: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []byte, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			request, _ := io.ReadAll(conn)
+			conn.Close()
+			received <- request
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+// waitForRequest 等待测试节点收到请求，超时返回 nil
+func waitForRequest(received chan []byte, timeout time.Duration) []byte {
+	select {
+	case request := <-received:
+		return request
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+// buildRequest 构造带命令头的请求
+func buildRequest(t *testing.T, command string, data interface{}) []byte {
+	payload, err := GobEncode(data)
+	if err != nil {
+		t.Fatalf("Failed to encode %s payload: %v", command, err)
+	}
+	return append(CommandToBytes(command), payload...)
+}
+
+// decodePayload 解码请求中的负载
+func decodePayload(t *testing.T, request []byte, payload interface{}) {
+	dec := gob.NewDecoder(bytes.NewReader(request[commandLength:]))
+	if err := dec.Decode(payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+}
+
+// TestHandleGetData_Transaction 测试请求交易时只发送内存池中存在的交易
+func TestHandleGetData_Transaction(t *testing.T) {
+	peerAddr, received := startTestPeer(t)
+
+	tx := blockchain.NewCoinbaseTX(testAddress, "mempool tx")
+	mempool[string(tx.ID)] = *tx
+	defer delete(mempool, string(tx.ID))
+
+	t.Run("UnknownTransaction", func(t *testing.T) {
+		request := buildRequest(t, "getdata", GetData{peerAddr, "tx", []byte("nonexistent")})
+		handleGetData(request, nil)
+
+		// 不应发送空交易，只应回复 notfound
+		response := waitForRequest(received, time.Second)
+		if response == nil {
+			t.Fatal("Expected a notfound response")
+		}
+		if command := BytesToCommand(response[:commandLength]); command != "notfound" {
+			t.Fatalf("Expected notfound command, got %s", command)
+		}
+
+		var payload NotFound
+		decodePayload(t, response, &payload)
+		if payload.Type != "tx" || string(payload.ID) != "nonexistent" {
+			t.Errorf("Unexpected notfound payload: %+v", payload)
+		}
+	})
+
+	t.Run("KnownTransaction", func(t *testing.T) {
+		request := buildRequest(t, "getdata", GetData{peerAddr, "tx", tx.ID})
+		handleGetData(request, nil)
+
+		response := waitForRequest(received, time.Second)
+		if response == nil {
+			t.Fatal("Expected transaction to be sent")
+		}
+		if command := BytesToCommand(response[:commandLength]); command != "tx" {
+			t.Fatalf("Expected tx command, got %s", command)
+		}
+
+		var payload Tx
+		decodePayload(t, response, &payload)
+		sent := blockchain.DeserializeTransaction(payload.Transaction)
+		if !bytes.Equal(sent.ID, tx.ID) {
+			t.Errorf("Expected transaction %x, got %x", tx.ID, sent.ID)
+		}
+	})
+}
+
+// TestHandleGetData_UnknownBlock 测试请求未知区块时回复 notfound
+func TestHandleGetData_UnknownBlock(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, received := startTestPeer(t)
+
+	request := buildRequest(t, "getdata", GetData{peerAddr, "block", []byte("unknown-hash")})
+	handleGetData(request, bc)
+
+	response := waitForRequest(received, time.Second)
+	if response == nil {
+		t.Fatal("Expected a notfound response rather than silence")
+	}
+	if command := BytesToCommand(response[:commandLength]); command != "notfound" {
+		t.Fatalf("Expected notfound command, got %s", command)
+	}
+
+	var payload NotFound
+	decodePayload(t, response, &payload)
+	if payload.Type != "block" || string(payload.ID) != "unknown-hash" {
+		t.Errorf("Unexpected notfound payload: %+v", payload)
+	}
+}
+
+// TestHandleNotFound 测试收到 notfound 后向其他节点重试，全部失败后放弃
+func TestHandleNotFound(t *testing.T) {
+	firstAddr, firstReceived := startTestPeer(t)
+	secondAddr, secondReceived := startTestPeer(t)
+
+	oldKnownNodes, oldInTransit := KnownNodes, blocksInTransit
+	defer func() { KnownNodes, blocksInTransit = oldKnownNodes, oldInTransit }()
+	KnownNodes = []string{firstAddr, secondAddr}
+	blocksInTransit = [][]byte{[]byte("missing-block")}
+
+	// 没有请求过的数据项不会触发重试
+	err := handleNotFound(buildRequest(t, "notfound", NotFound{firstAddr, "block", []byte("missing-block")}))
+	if err == nil {
+		t.Error("Expected an unsolicited notfound to be rejected")
+	}
+	if response := waitForRequest(secondReceived, 300*time.Millisecond); response != nil {
+		t.Fatalf("Expected no retry for an unsolicited notfound, got %s", BytesToCommand(response[:commandLength]))
+	}
+
+	SendGetData(firstAddr, "block", []byte("missing-block"))
+	if waitForRequest(firstReceived, time.Second) == nil {
+		t.Fatal("Expected getdata to be sent to the first node")
+	}
+
+	// 只有被请求的节点回复的 notfound 有效
+	if err := handleNotFound(buildRequest(t, "notfound", NotFound{secondAddr, "block", []byte("missing-block")})); err == nil {
+		t.Error("Expected notfound from a node that was not asked to be rejected")
+	}
+
+	// 第一个节点没有该区块，应转向第二个节点请求
+	if err := handleNotFound(buildRequest(t, "notfound", NotFound{firstAddr, "block", []byte("missing-block")})); err != nil {
+		t.Fatalf("Failed to handle notfound: %v", err)
+	}
+
+	response := waitForRequest(secondReceived, time.Second)
+	if response == nil {
+		t.Fatal("Expected getdata to be retried with another node")
+	}
+	var payload GetData
+	decodePayload(t, response, &payload)
+	if payload.Type != "block" || string(payload.ID) != "missing-block" {
+		t.Errorf("Unexpected getdata payload: %+v", payload)
+	}
+
+	// 第二个节点也没有，应放弃并移出待传输列表
+	if err := handleNotFound(buildRequest(t, "notfound", NotFound{secondAddr, "block", []byte("missing-block")})); err != nil {
+		t.Fatalf("Failed to handle notfound: %v", err)
+	}
+
+	if response := waitForRequest(firstReceived, 300*time.Millisecond); response != nil {
+		t.Errorf("Expected no further requests, got %s", BytesToCommand(response[:commandLength]))
+	}
+	if len(blocksInTransit) != 0 {
+		t.Errorf("Expected block to be removed from transit, got %d items", len(blocksInTransit))
+	}
+	if err := handleNotFound(buildRequest(t, "notfound", NotFound{secondAddr, "block", []byte("missing-block")})); err == nil {
+		t.Error("Expected notfound for an abandoned request to be rejected")
+	}
+}
+
+// TestHandleBlock_Existing 测试收到已有区块时仍继续请求下一个待传输区块
+func TestHandleBlock_Existing(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, received := startTestPeer(t)
+
+	oldInTransit := blocksInTransit
+	defer func() { blocksInTransit = oldInTransit }()
+	blocksInTransit = [][]byte{[]byte("next-block")}
+
+	genesis, err := bc.GetBlock(bc.GetBlockHashes()[0])
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	if err := handleBlock(buildRequest(t, "block", BlockData{peerAddr, genesis.Serialize()}), bc); err != nil {
+		t.Fatalf("Expected an existing block to be accepted, got %v", err)
+	}
+
+	response := waitForRequest(received, time.Second)
+	if response == nil {
+		t.Fatal("Expected the next block in transit to be requested")
+	}
+	var payload GetData
+	decodePayload(t, response, &payload)
+	if payload.Type != "block" || string(payload.ID) != "next-block" {
+		t.Errorf("Unexpected getdata payload: %+v", payload)
+	}
+	if len(blocksInTransit) != 0 {
+		t.Errorf("Expected block to be removed from transit, got %d items", len(blocksInTransit))
+	}
+}
+
+// TestHandleBlock_Invalid 测试未通过验证的区块被丢弃，且仍继续请求下一个待传输区块
+func TestHandleBlock_Invalid(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, received := startTestPeer(t)
+
+	oldInTransit := blocksInTransit
+	defer func() { blocksInTransit = oldInTransit }()
+	blocksInTransit = [][]byte{[]byte("next-block")}
+
+	tip := bc.GetBlockHashes()[0]
+	block := blockchain.NewBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(testAddress, "invalid")}, tip, bc.GetBestHeight()+1)
+	block.Timestamp++
+	if err := handleBlock(buildRequest(t, "block", BlockData{peerAddr, block.Serialize()}), bc); err != nil {
+		t.Fatalf("Expected an invalid block to be dropped quietly, got %v", err)
+	}
+
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		t.Error("Invalid block should not be stored")
+	}
+	if !bytes.Equal(bc.GetBlockHashes()[0], tip) {
+		t.Error("Tip should not change after an invalid block")
+	}
+	if response := waitForRequest(received, time.Second); response == nil {
+		t.Error("Expected the next block in transit to be requested")
+	}
+}
+
+// TestHandleBlock_InvalidBansPeer 测试反复发送无效区块的节点被封禁，之后拒绝其连接
+func TestHandleBlock_InvalidBansPeer(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, _ := startTestPeer(t)
+
+	manager := peer.NewManager("non_existent_config.json")
+	defer manager.Stop()
+	filter := security.NewBlacklistFilter()
+	manager.SetBlacklist(filter, 0, 0)
+	manager.AddPeer(peer.NewPeerFromAddress(peerAddr))
+	SetPeerManager(manager)
+	defer SetPeerManager(nil)
+	SetBlacklist(filter)
+	defer SetBlacklist(nil)
+
+	// 对端IP为本机的连接，封禁前允许接入
+	ln, err := net.Listen(protocol, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	client, err := net.Dial(protocol, ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer conn.Close()
+	if !allowConn(conn) {
+		t.Fatal("Expected connection to be allowed before the ban")
+	}
+
+	tip := bc.GetBlockHashes()[0]
+	for i := 0; i < 3; i++ {
+		block := blockchain.NewBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(testAddress, fmt.Sprintf("invalid %d", i))}, tip, bc.GetBestHeight()+1)
+		block.Timestamp++
+		if err := handleBlock(buildRequest(t, "block", BlockData{peerAddr, block.Serialize()}), bc); err != nil {
+			t.Fatalf("Expected an invalid block to be dropped quietly, got %v", err)
+		}
+	}
+
+	if !manager.IsBanned("127.0.0.1") {
+		t.Fatal("Expected the peer to be banned after repeated invalid blocks")
+	}
+	if allowConn(conn) {
+		t.Error("Expected connections from a banned IP to be rejected")
+	}
+}
+
+// TestHandleInv_OldestFirst 测试区块清单按从旧到新的顺序下载
+func TestHandleInv_OldestFirst(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, received := startTestPeer(t)
+
+	oldInTransit := blocksInTransit
+	defer func() { blocksInTransit = oldInTransit }()
+
+	items := [][]byte{[]byte("block-3"), []byte("block-2"), []byte("block-1")}
+	if err := handleInv(buildRequest(t, "inv", Inv{peerAddr, "block", items}), bc); err != nil {
+		t.Fatalf("Failed to handle inv: %v", err)
+	}
+
+	response := waitForRequest(received, time.Second)
+	if response == nil {
+		t.Fatal("Expected a getdata request")
+	}
+	var payload GetData
+	decodePayload(t, response, &payload)
+	if string(payload.ID) != "block-1" {
+		t.Errorf("Expected the oldest block to be requested first, got %s", payload.ID)
+	}
+	if len(blocksInTransit) != 2 || string(blocksInTransit[0]) != "block-2" || string(blocksInTransit[1]) != "block-3" {
+		t.Errorf("Unexpected blocks in transit: %q", blocksInTransit)
+	}
+}
+
+// TestHandlers_EmptyPayloads 测试空负载不会导致服务器崩溃
+func TestHandlers_EmptyPayloads(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	cases := []struct {
+		name    string
+		request []byte
+		handle  func([]byte) error
+	}{
+		{"EmptyInv", buildRequest(t, "inv", Inv{"localhost:3001", "block", nil}), func(r []byte) error { return handleInv(r, bc) }},
+		{"EmptyGetData", buildRequest(t, "getdata", GetData{"localhost:3001", "tx", nil}), func(r []byte) error { return handleGetData(r, bc) }},
+		{"EmptyTx", buildRequest(t, "tx", Tx{"localhost:3001", nil}), func(r []byte) error { return handleTx(r, bc) }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.handle(c.request); err == nil {
+				t.Error("Expected an error for empty payload")
+			}
+
+			// 通过完整的连接处理流程发送，确认不会 panic
+			client, server := net.Pipe()
+			go func() {
+				client.Write(c.request)
+				client.Close()
+			}()
+			handleConnection(server, bc)
+		})
+	}
+
+	t.Run("TruncatedRequest", func(t *testing.T) {
+		client, server := net.Pipe()
+		go func() {
+			client.Write([]byte("inv"))
+			client.Close()
+		}()
+		handleConnection(server, bc)
+	})
+}
+
+// TestHandleTx_RejectsInvalid 测试未通过验证的交易不会进入内存池
+func TestHandleTx_RejectsInvalid(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	out, err := blockchain.NewTXOutput(10, testAddress)
+	if err != nil {
+		t.Fatalf("Failed to create output: %v", err)
+	}
+	tx := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: []byte("missing"), Vout: 0}},
+		Vout: []blockchain.TXOutput{*out},
+	}
+	tx.ID = tx.Hash()
+
+	if err := handleTx(buildRequest(t, "tx", Tx{"localhost:3001", tx.Serialize()}), bc); err == nil {
+		t.Fatal("Expected transaction with unknown input to be rejected")
+	}
+	if _, ok := mempool[string(tx.ID)]; ok {
+		delete(mempool, string(tx.ID))
+		t.Error("Rejected transaction should not be added to the mempool")
+	}
+}
+
+// TestHandleVersion_HeightBounds 测试 version 消息中的高度检查与记录
+func TestHandleVersion_HeightBounds(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, received := startTestPeer(t)
+
+	oldKnownNodes := KnownNodes
+	defer func() { KnownNodes = oldKnownNodes }()
+
+	// 合理的高度会被记录，并向对方请求区块
+	err := handleVersion(buildRequest(t, "version", Version{Version: 1, BestHeight: bc.GetBestHeight() + 5, AddrFrom: peerAddr, Services: localServices}), bc)
+	if err != nil {
+		t.Fatalf("Expected reasonable height to be accepted, got: %v", err)
+	}
+	if height, ok := GetPeerHeight(peerAddr); !ok || height != bc.GetBestHeight()+5 {
+		t.Errorf("Expected recorded height %d, got %d (recorded %v)", bc.GetBestHeight()+5, height, ok)
+	}
+	request := waitForRequest(received, time.Second)
+	if request == nil || BytesToCommand(request[:commandLength]) != "getblocks" {
+		t.Fatal("Expected getblocks request for a peer with a higher chain")
+	}
+	var getBlocks GetBlocks
+	decodePayload(t, request, &getBlocks)
+	if len(getBlocks.Locator) == 0 || !bytes.Equal(getBlocks.Locator[0], bc.GetBlockLocator()[0]) {
+		t.Error("Expected getblocks request to carry the local block locator")
+	}
+
+	// 荒谬的高度被拒绝，不会触发 getblocks
+	for _, height := range []int{-5, bc.GetBestHeight() + maxHeightLead + 1} {
+		err := handleVersion(buildRequest(t, "version", Version{Version: 1, BestHeight: height, AddrFrom: peerAddr, Services: localServices}), bc)
+		if err == nil || !strings.Contains(err.Error(), "implausible") {
+			t.Errorf("Expected height %d to be rejected, got: %v", height, err)
+		}
+	}
+	if request := waitForRequest(received, 300*time.Millisecond); request != nil {
+		t.Errorf("Expected no request after rejected version, got %s", BytesToCommand(request[:commandLength]))
+	}
+	if height, _ := GetPeerHeight(peerAddr); height != bc.GetBestHeight()+5 {
+		t.Errorf("Rejected version should not update recorded height, got %d", height)
+	}
+}
+
+// TestSelfConnection 测试节点自身地址在节点列表中时不会被连接
+func TestSelfConnection(t *testing.T) {
+	bc := newTestBlockchain(t)
+	selfAddr, received := startTestPeer(t)
+
+	oldNodeAddress, oldKnownNodes := nodeAddress, KnownNodes
+	defer func() { nodeAddress, KnownNodes = oldNodeAddress, oldKnownNodes }()
+
+	nodeAddress = selfAddr
+	KnownNodes = []string{selfAddr}
+
+	// 拨号时跳过自身地址
+	sendVersion(selfAddr, bc)
+	for _, node := range KnownNodes {
+		SendAddr(node)
+	}
+	if request := waitForRequest(received, 300*time.Millisecond); request != nil {
+		t.Fatalf("Expected own address never to be dialed, got %s", BytesToCommand(request[:commandLength]))
+	}
+
+	// 接受连接时拒绝来自自身地址的 version，addr 中的自身地址不会加入节点列表
+	KnownNodes = []string{"localhost:3000"}
+	err := handleVersion(buildRequest(t, "version", Version{Version: 1, BestHeight: bc.GetBestHeight(), AddrFrom: selfAddr, Services: localServices}), bc)
+	if err == nil || !strings.Contains(err.Error(), "self-connection") {
+		t.Errorf("Expected version from own address to be rejected, got: %v", err)
+	}
+	if err := handleAddr(buildRequest(t, "addr", Addr{AddrList: []string{selfAddr, "localhost:3001"}})); err != nil {
+		t.Fatalf("Failed to handle addr: %v", err)
+	}
+	if nodeIsKnown(selfAddr) {
+		t.Error("Expected own address not to be added to known nodes")
+	}
+	if !nodeIsKnown("localhost:3001") {
+		t.Error("Expected other addresses from addr to be added")
+	}
+}
+
+// TestHandleAddr_Validation 测试格式错误或超量的地址列表被拒绝，合法列表去重后合并
+func TestHandleAddr_Validation(t *testing.T) {
+	oldKnownNodes := KnownNodes
+	defer func() { KnownNodes = oldKnownNodes }()
+	KnownNodes = []string{"localhost:3000"}
+
+	oversized := make([]string, maxAddrPerMessage+1)
+	for i := range oversized {
+		oversized[i] = fmt.Sprintf("10.0.%d.%d:3000", i/256, i%256)
+	}
+	for name, list := range map[string][]string{
+		"malformed": {"localhost:3001", "not-an-address"},
+		"bad port":  {"localhost:99999"},
+		"no host":   {":3001"},
+		"oversized": oversized,
+	} {
+		if err := handleAddr(buildRequest(t, "addr", Addr{AddrList: list})); err == nil {
+			t.Errorf("Expected %s addr list to be rejected", name)
+		}
+	}
+	if len(KnownNodes) != 1 {
+		t.Fatalf("Expected rejected addr messages not to change known nodes, got %v", KnownNodes)
+	}
+
+	list := []string{"localhost:3000", "localhost:3001", "localhost:3001", "127.0.0.1:3002"}
+	if err := handleAddr(buildRequest(t, "addr", Addr{AddrList: list})); err != nil {
+		t.Fatalf("Expected valid addr list to be accepted, got: %v", err)
+	}
+	expected := []string{"localhost:3000", "localhost:3001", "127.0.0.1:3002"}
+	if strings.Join(KnownNodes, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected known nodes %v, got %v", expected, KnownNodes)
+	}
+}
+
+// TestHandleAddr_Signed 测试启用认证后只接受已认证节点签名的 addr 消息
+func TestHandleAddr_Signed(t *testing.T) {
+	oldKnownNodes := KnownNodes
+	defer func() { KnownNodes = oldKnownNodes }()
+	KnownNodes = []string{"localhost:3000"}
+
+	local, err := security.NewNodeAuth("localhost:3000")
+	if err != nil {
+		t.Fatalf("Failed to create node auth: %v", err)
+	}
+	sender, err := security.NewNodeAuth("localhost:3005")
+	if err != nil {
+		t.Fatalf("Failed to create node auth: %v", err)
+	}
+	if err := local.AddPeer("localhost:3005", sender.GetPublicKey()); err != nil {
+		t.Fatalf("Failed to add peer: %v", err)
+	}
+	SetAddrAuth(local)
+	defer SetAddrAuth(nil)
+
+	payload := Addr{AddrList: []string{"localhost:3006"}, AddrFrom: "localhost:3005"}
+	if err := handleAddr(buildRequest(t, "addr", payload)); err == nil {
+		t.Error("Expected unsigned addr message to be rejected")
+	}
+
+	payload.Signature, err = sender.SignMessage(addrSigningBytes(payload.AddrFrom, payload.AddrList))
+	if err != nil {
+		t.Fatalf("Failed to sign addr message: %v", err)
+	}
+	forged := payload
+	forged.AddrList = []string{"localhost:6666"}
+	if err := handleAddr(buildRequest(t, "addr", forged)); err == nil {
+		t.Error("Expected addr message with a tampered list to be rejected")
+	}
+	if err := handleAddr(buildRequest(t, "addr", payload)); err != nil {
+		t.Fatalf("Expected signed addr message to be accepted, got: %v", err)
+	}
+	if !nodeIsKnown("localhost:3006") || nodeIsKnown("localhost:6666") {
+		t.Errorf("Unexpected known nodes after signed addr: %v", KnownNodes)
+	}
+}
+
+// TestHandleGetAddr 测试 getaddr 在请求连接上回复随机抽取的已知节点，数量不超过配置的上限，
+// 不会向消息中声明的地址发送数据，回复中的地址加入节点管理器
+func TestHandleGetAddr(t *testing.T) {
+	node := startTestNode(t, newTestBlockchain(t))
+	claimedAddr, claimedReceived := startTestPeer(t)
+
+	oldKnownNodes := KnownNodes
+	defer func() { KnownNodes = oldKnownNodes }()
+	KnownNodes = []string{claimedAddr}
+	for i := 0; i < 50; i++ {
+		KnownNodes = append(KnownNodes, fmt.Sprintf("10.0.0.%d:3000", i))
+	}
+
+	SetMaxGetAddrResponse(10)
+	defer SetMaxGetAddrResponse(0)
+
+	for _, tc := range []struct {
+		maxCount int
+		expected int
+	}{
+		{0, 10},   // 使用配置的上限
+		{3, 3},    // 请求的数量更少
+		{500, 10}, // 请求超过上限时截断
+	} {
+		request := buildRequest(t, "getaddr", GetAddr{AddrFrom: claimedAddr, MaxCount: tc.maxCount})
+		reply, err := requestReply(node, request, time.Second)
+		if err != nil {
+			t.Fatalf("Failed to request addresses: %v", err)
+		}
+		if command := BytesToCommand(reply[:commandLength]); command != "addr" {
+			t.Fatalf("Expected addr reply to getaddr with max %d, got %s", tc.maxCount, command)
+		}
+		var payload Addr
+		decodePayload(t, reply, &payload)
+
+		if len(payload.AddrList) != tc.expected {
+			t.Errorf("Expected %d addresses for max %d, got %d", tc.expected, tc.maxCount, len(payload.AddrList))
+		}
+		seen := make(map[string]bool)
+		for _, addr := range payload.AddrList {
+			if addr == claimedAddr {
+				t.Error("Expected the requester to be excluded from the reply")
+			}
+			if seen[addr] || !nodeIsKnown(addr) {
+				t.Errorf("Unexpected address %s in reply", addr)
+			}
+			seen[addr] = true
+		}
+	}
+
+	if request := waitForRequest(claimedReceived, 300*time.Millisecond); request != nil {
+		t.Errorf("Expected no data sent to the claimed sender, got %s", BytesToCommand(request[:commandLength]))
+	}
+
+	// SendGetAddr 把回复中的地址加入节点管理器
+	manager := peer.NewManager("non_existent_config.json")
+	defer manager.Stop()
+	SetPeerManager(manager)
+	defer SetPeerManager(nil)
+
+	before := len(manager.GetAllPeers())
+	SendGetAddr(node)
+	if added := len(manager.GetAllPeers()) - before; added != 10 {
+		t.Errorf("Expected 10 peers learned from the addr reply, got %d", added)
+	}
+}
+
+// TestHandleVersion_SyncPeerServices 测试只向提供完整区块服务的节点请求区块，握手的服务同步到节点管理器
+func TestHandleVersion_SyncPeerServices(t *testing.T) {
+	bc := newTestBlockchain(t)
+	lightAddr, lightReceived := startTestPeer(t)
+	fullAddr, fullReceived := startTestPeer(t)
+
+	oldKnownNodes := KnownNodes
+	defer func() { KnownNodes = oldKnownNodes }()
+
+	// 没有节点管理器时，不提供完整区块的发送方不会被请求区块
+	light := Version{Version: protocolVersion, BestHeight: bc.GetBestHeight() + 5, AddrFrom: lightAddr, Services: peer.ServiceNodeBloom}
+	if err := handleVersion(buildRequest(t, "version", light), bc); err != nil {
+		t.Fatalf("Expected version to be accepted, got: %v", err)
+	}
+	if request := waitForRequest(lightReceived, 300*time.Millisecond); request != nil {
+		t.Errorf("Expected no request to a peer without full blocks, got %s", BytesToCommand(request[:commandLength]))
+	}
+
+	// 有节点管理器时选择提供完整区块且高度最高的节点
+	manager := peer.NewManager("non_existent_config.json")
+	defer manager.Stop()
+	SetPeerManager(manager)
+	defer SetPeerManager(nil)
+
+	full := peer.NewPeerFromAddress(fullAddr)
+	full.UpdateBestHeight(bc.GetBestHeight() + 3)
+	manager.AddPeer(full)
+
+	if err := handleVersion(buildRequest(t, "version", light), bc); err != nil {
+		t.Fatalf("Expected version to be accepted, got: %v", err)
+	}
+	if p := manager.GetPeer(lightAddr); p == nil || p.GetServices() != peer.ServiceNodeBloom || p.GetBestHeight() != light.BestHeight {
+		t.Fatalf("Expected handshake services and height to be recorded in the peer manager, got %v", p)
+	}
+	request := waitForRequest(fullReceived, time.Second)
+	if request == nil || BytesToCommand(request[:commandLength]) != "getblocks" {
+		t.Fatal("Expected getblocks to be sent to the full-block peer")
+	}
+	if request := waitForRequest(lightReceived, 300*time.Millisecond); request != nil {
+		t.Errorf("Expected no request to a peer without full blocks, got %s", BytesToCommand(request[:commandLength]))
+	}
+}
+
+// TestHandleVersion_ProtocolNegotiation 测试 version 消息中的协议版本协商
+func TestHandleVersion_ProtocolNegotiation(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, _ := startTestPeer(t)
+
+	oldKnownNodes := KnownNodes
+	defer func() { KnownNodes = oldKnownNodes }()
+
+	// 相同版本被接受并记录
+	if err := handleVersion(buildRequest(t, "version", Version{Version: protocolVersion, BestHeight: bc.GetBestHeight(), AddrFrom: peerAddr, Services: localServices}), bc); err != nil {
+		t.Fatalf("Expected matching version to be accepted, got: %v", err)
+	}
+	if version, ok := GetPeerVersion(peerAddr); !ok || version != protocolVersion {
+		t.Errorf("Expected negotiated version %d, got %d (recorded %v)", protocolVersion, version, ok)
+	}
+	if services, _ := GetPeerServices(peerAddr); services != localServices {
+		t.Errorf("Expected recorded services %d, got %d", localServices, services)
+	}
+
+	// 更新的版本降级到本节点的版本
+	newerAddr := "localhost:39001"
+	if err := handleVersion(buildRequest(t, "version", Version{Version: protocolVersion + 1, BestHeight: bc.GetBestHeight(), AddrFrom: newerAddr, Services: localServices}), bc); err != nil {
+		t.Fatalf("Expected newer version to be accepted, got: %v", err)
+	}
+	if version, _ := GetPeerVersion(newerAddr); version != protocolVersion {
+		t.Errorf("Expected newer peer to be downgraded to %d, got %d", protocolVersion, version)
+	}
+
+	// 过旧的版本被拒绝，不会被记录
+	oldAddr := "localhost:39002"
+	err := handleVersion(buildRequest(t, "version", Version{Version: minProtocolVersion - 1, BestHeight: bc.GetBestHeight(), AddrFrom: oldAddr, Services: localServices}), bc)
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("Expected unsupported version to be rejected, got: %v", err)
+	}
+	if _, ok := GetPeerVersion(oldAddr); ok {
+		t.Error("Rejected peer should not have a negotiated version")
+	}
+	if nodeIsKnown(oldAddr) {
+		t.Error("Rejected peer should not be added to known nodes")
+	}
+}
+
+// TestHandleResend 测试 resend 命令向已知节点重新公布内存池交易
+func TestHandleResend(t *testing.T) {
+	peerAddr, received := startTestPeer(t)
+
+	oldKnownNodes := KnownNodes
+	defer func() { KnownNodes = oldKnownNodes }()
+	KnownNodes = []string{peerAddr}
+
+	tx := blockchain.NewCoinbaseTX(testAddress, "resend tx")
+	mempool[string(tx.ID)] = *tx
+	defer delete(mempool, string(tx.ID))
+
+	if err := handleResend(buildRequest(t, "resend", Resend{"localhost:3001"})); err != nil {
+		t.Fatalf("handleResend failed: %v", err)
+	}
+
+	request := waitForRequest(received, time.Second)
+	if request == nil {
+		t.Fatal("Expected an inv message")
+	}
+	if command := BytesToCommand(request[:commandLength]); command != "inv" {
+		t.Fatalf("Expected inv command, got %s", command)
+	}
+
+	var payload Inv
+	decodePayload(t, request, &payload)
+	if payload.Type != "tx" || len(payload.Items) != 1 || !bytes.Equal(payload.Items[0], tx.ID) {
+		t.Errorf("Unexpected inv payload: %+v", payload)
+	}
+
+	// 非本机的 resend 请求被拒绝，不会触发重新广播
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	err := dispatchCommand(server, "resend", buildRequest(t, "resend", Resend{"10.0.0.1:3001"}), nil)
+	if err == nil || !strings.Contains(err.Error(), "non-local") {
+		t.Errorf("Expected a non-local resend to be rejected, got: %v", err)
+	}
+	if request := waitForRequest(received, 300*time.Millisecond); request != nil {
+		t.Errorf("Expected no rebroadcast for a rejected resend, got %s", BytesToCommand(request[:commandLength]))
+	}
+}
+
+// TestHandleGetBlocks_Locator 测试根据对方的区块定位器只返回分叉点之后的区块
+func TestHandleGetBlocks_Locator(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, received := startTestPeer(t)
+
+	genesis := bc.GetBlockHashes()[0]
+	a1, err := bc.MineBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(testAddress, "A1")}, "")
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+	a2, err := bc.MineBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(testAddress, "A2")}, "")
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+	a3, err := bc.MineBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(testAddress, "A3")}, "")
+	if err != nil {
+		t.Fatalf("Failed to mine block: %v", err)
+	}
+
+	// 对端链在 A1 之后分叉，定位器中的分叉区块本地未知
+	locator := [][]byte{[]byte("B3"), []byte("B2"), a1.Hash, genesis}
+	if err := handleGetBlocks(buildRequest(t, "getblocks", GetBlocks{peerAddr, locator}), bc); err != nil {
+		t.Fatalf("handleGetBlocks failed: %v", err)
+	}
+
+	response := waitForRequest(received, time.Second)
+	if response == nil || BytesToCommand(response[:commandLength]) != "inv" {
+		t.Fatal("Expected an inv response")
+	}
+	var inv Inv
+	decodePayload(t, response, &inv)
+	if len(inv.Items) != 2 || !bytes.Equal(inv.Items[0], a3.Hash) || !bytes.Equal(inv.Items[1], a2.Hash) {
+		t.Errorf("Expected blocks after the fork point [A3 A2], got %d items", len(inv.Items))
+	}
+
+	// 对端已拥有本地链尖时不发送 inv
+	upToDate := [][]byte{a3.Hash, a2.Hash, a1.Hash, genesis}
+	if err := handleGetBlocks(buildRequest(t, "getblocks", GetBlocks{peerAddr, upToDate}), bc); err != nil {
+		t.Fatalf("handleGetBlocks failed: %v", err)
+	}
+	if response := waitForRequest(received, 300*time.Millisecond); response != nil {
+		t.Errorf("Expected no response for an up-to-date peer, got %s", BytesToCommand(response[:commandLength]))
+	}
+}
+
+// TestAcceptTx_Results 测试 tx 消息路径对重复和双花交易返回对应的处理结果
+func TestAcceptTx_Results(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	genesis, err := bc.GetBlock(bc.GetBlockHashes()[0])
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	coinbase := genesis.Transactions[0]
+	newSpend := func(fee int) *blockchain.Transaction {
+		out, err := blockchain.NewTXOutput(coinbase.Vout[0].Value-fee, testAddress)
+		if err != nil {
+			t.Fatalf("Failed to create output: %v", err)
+		}
+		tx := &blockchain.Transaction{
+			Vin:  []blockchain.TXInput{{Txid: coinbase.ID, Vout: 0, ScriptSig: testAddress}},
+			Vout: []blockchain.TXOutput{*out},
+		}
+		tx.ID = tx.Hash()
+		signTestTx(tx, coinbase)
+		return tx
+	}
+
+	first := newSpend(1)
+	defer delete(mempool, string(first.ID))
+
+	tests := []struct {
+		name   string
+		tx     *blockchain.Transaction
+		result netsync.AcceptResult
+	}{
+		{"Accepted", first, netsync.TxAccepted},
+		{"Duplicate", first, netsync.TxDuplicate},
+		{"Conflict", newSpend(2), netsync.TxConflict},
+		{"Invalid", newSpend(-1), netsync.TxInvalid},
+		{"Coinbase", blockchain.NewCoinbaseTX(testAddress, "loose coinbase"), netsync.TxInvalid},
+	}
+	for _, tt := range tests {
+		result, err := acceptTx(bc, tt.tx)
+		if result != tt.result {
+			t.Errorf("%s: expected result %s, got %s (err: %v)", tt.name, tt.result, result, err)
+		}
+		if (err == nil) != (tt.result == netsync.TxAccepted) {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+	if len(mempool) != 1 {
+		t.Errorf("Expected only the accepted transaction in the mempool, got %d", len(mempool))
+	}
+}
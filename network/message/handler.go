@@ -2,25 +2,48 @@ package message
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"runtime/debug"
 	"sync"
 	"time"
 )
 
+const (
+	// submitRetryInterval SubmitContext 等待队列空间时的重试间隔
+	submitRetryInterval = 10 * time.Millisecond
+	// defaultReapInterval 默认回收卡在处理中状态的消息的检查间隔
+	defaultReapInterval = 10 * time.Second
+)
+
 // HandlerFunc 消息处理函数类型
 type HandlerFunc func(*Message) error
 
 // Handler 消息处理器
 type Handler struct {
-	queues        map[string]*Queue      // 队列映射（按类型）
-	handlers      map[string]HandlerFunc // 处理函数映射
-	workers       int                    // 工作协程数量
-	isRunning     bool                   // 是否运行中
-	stopCh        chan bool              // 停止信号
-	mutex         sync.RWMutex           // 读写锁
-	cleanupTicker *time.Ticker           // 清理定时器
-	stats         *HandlerStats          // 统计信息
+	queues        map[string]*Queue        // 队列映射（按类型）
+	handlers      map[string]HandlerFunc   // 处理函数映射
+	timeouts      map[string]time.Duration // 按类型配置的处理超时
+	workers       int                      // 工作协程数量
+	isRunning     bool                     // 是否运行中
+	stopCh        chan bool                // 停止信号
+	mutex         sync.RWMutex             // 读写锁
+	cleanupTicker *time.Ticker             // 清理定时器
+	stats         *HandlerStats            // 统计信息
+	scaling       *ScalingConfig           // 自动扩缩容配置，nil 表示固定工作协程数
+	workerStops   []chan bool              // 每个工作协程的退出信号
+	nextWorkerID  int                      // 下一个工作协程ID
+	reapInterval  time.Duration            // 回收卡住消息的检查间隔
+}
+
+// ScalingConfig 工作协程自动扩缩容配置
+type ScalingConfig struct {
+	MinWorkers    int           // 最少工作协程数
+	MaxWorkers    int           // 最多工作协程数
+	HighWatermark int           // 待处理消息数超过该值时扩容
+	LowWatermark  int           // 待处理消息数不超过该值时缩容
+	CheckInterval time.Duration // 检查间隔
 }
 
 // HandlerStats 处理器统计信息
@@ -39,12 +62,25 @@ func NewHandler(workers int) *Handler {
 	}
 
 	return &Handler{
-		queues:   make(map[string]*Queue),
-		handlers: make(map[string]HandlerFunc),
-		workers:  workers,
-		stopCh:   make(chan bool),
-		stats:    &HandlerStats{},
+		queues:       make(map[string]*Queue),
+		handlers:     make(map[string]HandlerFunc),
+		timeouts:     make(map[string]time.Duration),
+		workers:      workers,
+		stopCh:       make(chan bool),
+		stats:        &HandlerStats{},
+		reapInterval: defaultReapInterval,
+	}
+}
+
+// SetReapInterval 设置回收卡在处理中状态的消息的检查间隔，需在 Start 之前调用，小于等于 0 时使用默认值
+func (h *Handler) SetReapInterval(interval time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if interval <= 0 {
+		interval = defaultReapInterval
 	}
+	h.reapInterval = interval
 }
 
 // Start 启动消息处理器
@@ -60,13 +96,25 @@ func (h *Handler) Start() error {
 	log.Printf("启动消息处理器，工作协程数: %d", h.workers)
 
 	// 启动工作协程
-	for i := 0; i < h.workers; i++ {
-		go h.worker(i)
+	initialWorkers := h.workers
+	if h.scaling != nil && h.scaling.MinWorkers > initialWorkers {
+		initialWorkers = h.scaling.MinWorkers
+	}
+	for i := 0; i < initialWorkers; i++ {
+		h.spawnWorker()
 	}
 
 	// 启动清理任务
 	h.startCleanup()
 
+	// 启动卡住消息的回收任务
+	go h.reapTask(h.reapInterval)
+
+	// 启动自动扩缩容
+	if h.scaling != nil {
+		go h.scalingTask()
+	}
+
 	return nil
 }
 
@@ -80,6 +128,7 @@ func (h *Handler) Stop() error {
 	}
 
 	h.isRunning = false
+	h.workerStops = nil
 
 	// 安全关闭通道
 	select {
@@ -113,6 +162,32 @@ func (h *Handler) RegisterHandler(messageType string, handler HandlerFunc) {
 	log.Printf("注册消息处理器: %s", messageType)
 }
 
+// RegisterHandlerWithTimeout 注册消息处理函数，并为该类型设置处理超时
+// 类型超时优先于消息自身的 Timeout
+func (h *Handler) RegisterHandlerWithTimeout(messageType string, handler HandlerFunc, timeout time.Duration) {
+	h.RegisterHandler(messageType, handler)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if timeout > 0 {
+		h.timeouts[messageType] = timeout
+	} else {
+		delete(h.timeouts, messageType)
+	}
+}
+
+// messageTimeout 返回消息的处理超时
+func (h *Handler) messageTimeout(message *Message) time.Duration {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if timeout, exists := h.timeouts[message.Type]; exists {
+		return timeout
+	}
+	return message.Timeout
+}
+
 // Submit 提交消息进行处理
 func (h *Handler) Submit(message *Message) error {
 	if !h.isRunning {
@@ -130,8 +205,113 @@ func (h *Handler) Submit(message *Message) error {
 	return queue.Enqueue(message)
 }
 
+// SubmitContext 提交消息，队列已满时等待出现空间，直到 ctx 被取消
+func (h *Handler) SubmitContext(ctx context.Context, message *Message) error {
+	ticker := time.NewTicker(submitRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		err := h.Submit(message)
+		var queueErr *QueueError
+		if !errors.As(err, &queueErr) || !queueErr.IsCode(ErrQueueFull) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("等待队列空间时取消: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnableAutoScaling 启用工作协程自动扩缩容，需在 Start 之前调用
+func (h *Handler) EnableAutoScaling(config *ScalingConfig) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if config.MinWorkers <= 0 {
+		config.MinWorkers = h.workers
+	}
+	if config.MaxWorkers < config.MinWorkers {
+		config.MaxWorkers = config.MinWorkers
+	}
+	if config.HighWatermark <= 0 {
+		config.HighWatermark = 100
+	}
+	if config.LowWatermark < 0 || config.LowWatermark >= config.HighWatermark {
+		config.LowWatermark = config.HighWatermark / 2
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = time.Second
+	}
+
+	h.scaling = config
+}
+
+// GetWorkerCount 获取当前工作协程数量
+func (h *Handler) GetWorkerCount() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.workerStops)
+}
+
+// spawnWorker 启动一个新的工作协程，调用方需持有写锁
+func (h *Handler) spawnWorker() {
+	quit := make(chan bool)
+	h.workerStops = append(h.workerStops, quit)
+	go h.worker(h.nextWorkerID, quit)
+	h.nextWorkerID++
+}
+
+// retireWorker 停止最近启动的工作协程，调用方需持有写锁
+func (h *Handler) retireWorker() {
+	last := len(h.workerStops) - 1
+	close(h.workerStops[last])
+	h.workerStops = h.workerStops[:last]
+}
+
+// scalingTask 根据待处理消息数调整工作协程数量
+func (h *Handler) scalingTask() {
+	ticker := time.NewTicker(h.scaling.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		case <-ticker.C:
+			h.adjustWorkers()
+		}
+	}
+}
+
+// adjustWorkers 执行一次扩缩容检查
+func (h *Handler) adjustWorkers() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.isRunning {
+		return
+	}
+
+	pending := 0
+	for _, queue := range h.queues {
+		pending += queue.GetPendingCount()
+	}
+
+	current := len(h.workerStops)
+	if pending > h.scaling.HighWatermark && current < h.scaling.MaxWorkers {
+		h.spawnWorker()
+		log.Printf("待处理消息 %d 超过高水位，工作协程扩容至 %d", pending, current+1)
+	} else if pending <= h.scaling.LowWatermark && current > h.scaling.MinWorkers {
+		h.retireWorker()
+		log.Printf("待处理消息 %d 低于低水位，工作协程缩容至 %d", pending, current-1)
+	}
+}
+
 // worker 工作协程
-func (h *Handler) worker(workerID int) {
+func (h *Handler) worker(workerID int, quit chan bool) {
 	log.Printf("工作协程 %d 已启动", workerID)
 	defer log.Printf("工作协程 %d 已停止", workerID)
 
@@ -142,12 +322,26 @@ func (h *Handler) worker(workerID int) {
 		select {
 		case <-h.stopCh:
 			return
+		case <-quit:
+			return
 		case <-ticker.C:
-			h.processMessages(workerID)
+			h.safeProcessMessages(workerID)
 		}
 	}
 }
 
+// safeProcessMessages 处理消息并从 panic 中恢复，避免工作协程退出；
+// 中途放弃的消息会停留在处理中状态，由回收任务重新入队
+func (h *Handler) safeProcessMessages(workerID int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("工作协程 %d 处理消息时 panic: %v\n%s", workerID, r, debug.Stack())
+		}
+	}()
+
+	h.processMessages(workerID)
+}
+
 // processMessages 处理消息
 func (h *Handler) processMessages(workerID int) {
 	h.mutex.RLock()
@@ -180,12 +374,18 @@ func (h *Handler) handleMessage(message *Message, handler HandlerFunc, queue *Qu
 	start := time.Now()
 
 	// 创建超时上下文
-	ctx, cancel := context.WithTimeout(context.Background(), message.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), h.messageTimeout(message))
 	defer cancel()
 
 	// 在新协程中处理消息，支持超时控制
 	done := make(chan error, 1)
 	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("工作协程 %d 的处理函数 panic %s: %v\n%s", workerID, message.ID, r, debug.Stack())
+				done <- fmt.Errorf("处理函数 panic: %v", r)
+			}
+		}()
 		done <- handler(message)
 	}()
 
@@ -276,7 +476,7 @@ func (h *Handler) GetTotalStats() map[string]interface{} {
 
 	return map[string]interface{}{
 		"is_running":           h.isRunning,
-		"workers":              h.workers,
+		"workers":              len(h.workerStops),
 		"registered_types":     len(h.handlers),
 		"total_pending":        totalPending,
 		"total_processing":     totalProcessing,
@@ -371,6 +571,40 @@ func (h *Handler) performCleanup() {
 	}
 }
 
+// reapTask 定期回收各队列中处理超时仍未完成的消息
+func (h *Handler) reapTask(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.reapStuckMessages()
+		case <-h.stopCh:
+			return
+		}
+	}
+}
+
+// reapStuckMessages 回收处理时间超过超时的消息，这些消息的工作协程可能已经崩溃
+func (h *Handler) reapStuckMessages() int {
+	h.mutex.RLock()
+	queues := make(map[string]*Queue)
+	for msgType, queue := range h.queues {
+		queues[msgType] = queue
+	}
+	h.mutex.RUnlock()
+
+	total := 0
+	for msgType, queue := range queues {
+		if reaped := queue.ReapStuck(h.messageTimeout); reaped > 0 {
+			log.Printf("回收卡在处理中的消息 %s: %d", msgType, reaped)
+			total += reaped
+		}
+	}
+	return total
+}
+
 // IsRunning 检查是否正在运行
 func (h *Handler) IsRunning() bool {
 	h.mutex.RLock()
@@ -1,6 +1,8 @@
 package message
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -220,3 +222,247 @@ func TestMessageCreation(t *testing.T) {
 		}
 	})
 }
+
+// waitFor 轮询等待条件成立
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return condition()
+}
+
+// TestHandlerAutoScaling 测试工作协程根据队列深度自动扩缩容
+func TestHandlerAutoScaling(t *testing.T) {
+	handler := NewHandler(1)
+	handler.EnableAutoScaling(&ScalingConfig{
+		MinWorkers:    1,
+		MaxWorkers:    4,
+		HighWatermark: 5,
+		LowWatermark:  0,
+		CheckInterval: 20 * time.Millisecond,
+	})
+	handler.RegisterHandler("burst", func(msg *Message) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Failed to start handler: %v", err)
+	}
+	defer handler.Stop()
+
+	if count := handler.GetWorkerCount(); count != 1 {
+		t.Fatalf("Expected 1 worker at start, got %d", count)
+	}
+
+	// 突发大量消息
+	for i := 0; i < 60; i++ {
+		msg := NewMessage("burst", []byte("data"), "addr")
+		msg.ID = fmt.Sprintf("burst-%d", i)
+		if err := handler.Submit(msg); err != nil {
+			t.Fatalf("Failed to submit message: %v", err)
+		}
+	}
+
+	if !waitFor(2*time.Second, func() bool { return handler.GetWorkerCount() > 1 }) {
+		t.Fatalf("Expected worker count to rise above 1, got %d", handler.GetWorkerCount())
+	}
+	if count := handler.GetWorkerCount(); count > 4 {
+		t.Errorf("Worker count %d exceeds max of 4", count)
+	}
+
+	// 队列消化完后应缩回最小值
+	if !waitFor(5*time.Second, func() bool { return handler.GetWorkerCount() == 1 }) {
+		t.Errorf("Expected worker count to fall back to 1, got %d", handler.GetWorkerCount())
+	}
+	if processed := handler.GetStats().TotalProcessed; processed != 60 {
+		t.Errorf("Expected 60 processed messages, got %d", processed)
+	}
+}
+
+// TestHandlerTypeTimeout 测试按消息类型配置的处理超时
+func TestHandlerTypeTimeout(t *testing.T) {
+	handler := NewHandler(2)
+	slow := func(msg *Message) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}
+	handler.RegisterHandlerWithTimeout("block", slow, time.Second)
+	handler.RegisterHandlerWithTimeout("ping", slow, 20*time.Millisecond)
+
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Failed to start handler: %v", err)
+	}
+	defer handler.Stop()
+
+	// 消息自身超时很短，但类型超时足够宽松
+	blockMsg := NewMessage("block", []byte("data"), "addr")
+	blockMsg.Timeout = 10 * time.Millisecond
+	pingMsg := NewMessage("ping", []byte("data"), "addr")
+	pingMsg.ID = blockMsg.ID + "-ping"
+	pingMsg.MaxRetries = 0
+
+	if err := handler.Submit(blockMsg); err != nil {
+		t.Fatalf("Failed to submit block message: %v", err)
+	}
+	if err := handler.Submit(pingMsg); err != nil {
+		t.Fatalf("Failed to submit ping message: %v", err)
+	}
+
+	queueStats := func(msgType string) *QueueStats {
+		return handler.GetQueueStats()[msgType]
+	}
+	if !waitFor(2*time.Second, func() bool {
+		return queueStats("block").ProcessedMessages == 1 && queueStats("ping").FailedMessages == 1
+	}) {
+		t.Errorf("Expected block to succeed and ping to time out, got block processed=%d, ping failed=%d",
+			queueStats("block").ProcessedMessages, queueStats("ping").FailedMessages)
+	}
+}
+
+// TestHandlerSubmitContext 测试队列已满时 SubmitContext 等待空间或随 ctx 超时返回
+func TestHandlerSubmitContext(t *testing.T) {
+	handler := NewHandler(1)
+	release := make(chan struct{})
+	handler.RegisterHandler("slow", func(msg *Message) error {
+		<-release
+		return nil
+	})
+
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Failed to start handler: %v", err)
+	}
+	defer handler.Stop()
+
+	// 工作协程阻塞在第一条消息上，继续提交直到队列已满
+	first := NewMessage("slow", []byte("data"), "addr")
+	first.ID = "first"
+	if err := handler.Submit(first); err != nil {
+		t.Fatalf("Failed to submit message: %v", err)
+	}
+	if !waitFor(time.Second, func() bool { return handler.GetQueueStats()["slow"].ProcessingMessages == 1 }) {
+		t.Fatal("Expected the worker to pick up the first message")
+	}
+	submitted := 0
+	for {
+		msg := NewMessage("slow", []byte("data"), "addr")
+		msg.ID = fmt.Sprintf("slow-%d", submitted)
+		if err := handler.Submit(msg); err != nil {
+			var queueErr *QueueError
+			if !errors.As(err, &queueErr) || !queueErr.IsCode(ErrQueueFull) {
+				t.Fatalf("Expected queue full error, got %v", err)
+			}
+			break
+		}
+		submitted++
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	waiting := NewMessage("slow", []byte("data"), "addr")
+	waiting.ID = "waiting"
+	if err := handler.SubmitContext(ctx, waiting); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected SubmitContext to time out on a full queue, got %v", err)
+	}
+
+	// 放行工作协程后出现空间，提交成功
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handler.SubmitContext(ctx, waiting); err != nil {
+		t.Errorf("Expected SubmitContext to succeed once space frees, got %v", err)
+	}
+}
+
+// TestQueueReapStuck 测试工作协程崩溃后停留在处理中的消息被回收而不是丢失
+func TestQueueReapStuck(t *testing.T) {
+	queue := NewQueue(10)
+	timeout := func(*Message) time.Duration { return 20 * time.Millisecond }
+
+	retry := NewMessage("test", []byte("retry"), "addr")
+	retry.ID = "retry"
+	retry.Timeout = time.Minute
+	final := NewMessage("test", []byte("final"), "addr")
+	final.ID = "final"
+	final.MaxRetries = 0
+	queue.Enqueue(retry)
+	queue.Enqueue(final)
+
+	// 取出后不再标记结果，模拟处理中途崩溃的工作协程
+	queue.Dequeue()
+	queue.Dequeue()
+	if reaped := queue.ReapStuck(timeout); reaped != 0 {
+		t.Fatalf("Expected no messages reaped before the timeout, got %d", reaped)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if reaped := queue.ReapStuck(timeout); reaped != 2 {
+		t.Fatalf("Expected 2 stuck messages reaped, got %d", reaped)
+	}
+	if count := queue.GetProcessingCount(); count != 0 {
+		t.Errorf("Expected no processing messages after reaping, got %d", count)
+	}
+	if count := queue.GetPendingCount(); count != 1 {
+		t.Errorf("Expected the retryable message to be requeued, got %d pending", count)
+	}
+	if count := queue.GetFailedCount(); count != 1 {
+		t.Errorf("Expected the exhausted message to be failed, got %d failed", count)
+	}
+	if stats := queue.GetStats(); stats.ProcessingMessages != 0 || stats.PendingMessages != 1 {
+		t.Errorf("Unexpected stats after reaping: processing=%d pending=%d", stats.ProcessingMessages, stats.PendingMessages)
+	}
+}
+
+// TestHandlerPanickingHandler 测试处理函数 panic 时消息按失败处理而不是丢失，工作协程继续处理后续消息
+func TestHandlerPanickingHandler(t *testing.T) {
+	handler := NewHandler(1)
+	handler.RegisterHandler("panic", func(msg *Message) error {
+		if string(msg.Payload) == "bad" {
+			panic("boom")
+		}
+		return nil
+	})
+
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Failed to start handler: %v", err)
+	}
+	defer handler.Stop()
+
+	msg := NewMessage("panic", []byte("bad"), "addr")
+	msg.ID = "bad"
+	msg.MaxRetries = 0
+	if err := handler.Submit(msg); err != nil {
+		t.Fatalf("Failed to submit message: %v", err)
+	}
+
+	stats := func() *QueueStats { return handler.GetQueueStats()["panic"] }
+	if !waitFor(2*time.Second, func() bool { return stats().FailedMessages == 1 }) {
+		t.Fatalf("Expected panicking message to be marked failed, got failed=%d processing=%d",
+			stats().FailedMessages, stats().ProcessingMessages)
+	}
+	if stats().ProcessingMessages != 0 {
+		t.Errorf("Expected no message left in processing, got %d", stats().ProcessingMessages)
+	}
+
+	// 同一个工作协程仍在运行，能继续处理消息
+	for i := 0; i < 3; i++ {
+		good := NewMessage("panic", []byte("good"), "addr")
+		good.ID = fmt.Sprintf("good-%d", i)
+		if err := handler.Submit(good); err != nil {
+			t.Fatalf("Failed to submit message: %v", err)
+		}
+	}
+	if !waitFor(2*time.Second, func() bool { return stats().ProcessedMessages == 3 }) {
+		t.Errorf("Expected worker to keep processing after a panic, got %d processed", stats().ProcessedMessages)
+	}
+	if count := handler.GetWorkerCount(); count != 1 {
+		t.Errorf("Expected 1 worker after a panic, got %d", count)
+	}
+	if failed := handler.GetStats().TotalFailed; failed != 1 {
+		t.Errorf("Expected 1 failed message in handler stats, got %d", failed)
+	}
+}
@@ -123,6 +123,7 @@ type Queue struct {
 	pending     map[string]*Message    // 待处理消息映射
 	processing  map[string]*Message    // 处理中消息映射
 	failed      map[string]*Message    // 失败消息映射
+	startedAt   map[string]time.Time   // 处理中消息的开始时间
 	mutex       sync.RWMutex           // 读写锁
 	maxSize     int                    // 最大队列大小
 	stats       *QueueStats            // 统计信息
@@ -150,6 +151,7 @@ func NewQueue(maxSize int) *Queue {
 		pending:    make(map[string]*Message),
 		processing: make(map[string]*Message),
 		failed:     make(map[string]*Message),
+		startedAt:  make(map[string]time.Time),
 		maxSize:    maxSize,
 		stats:      &QueueStats{},
 	}
@@ -196,6 +198,7 @@ func (q *Queue) Dequeue() *Message {
 	message := heap.Pop(&q.messages).(*Message)
 	delete(q.pending, message.ID)
 	q.processing[message.ID] = message
+	q.startedAt[message.ID] = time.Now()
 	
 	q.stats.mutex.Lock()
 	q.stats.PendingMessages--
@@ -212,6 +215,7 @@ func (q *Queue) MarkProcessed(messageID string, processTime time.Duration) {
 	
 	if message, exists := q.processing[messageID]; exists {
 		delete(q.processing, messageID)
+		delete(q.startedAt, messageID)
 		
 		q.stats.mutex.Lock()
 		q.stats.ProcessedMessages++
@@ -229,26 +233,63 @@ func (q *Queue) MarkFailed(messageID string, reason string) {
 	defer q.mutex.Unlock()
 	
 	if message, exists := q.processing[messageID]; exists {
-		delete(q.processing, messageID)
-		message.IncrementRetries()
-		
-		if message.CanRetry() {
-			// 重新加入队列
-			heap.Push(&q.messages, message)
-			q.pending[message.ID] = message
-			q.stats.mutex.Lock()
-			q.stats.PendingMessages++
-			q.stats.ProcessingMessages--
-			q.stats.mutex.Unlock()
-		} else {
-			// 标记为失败
-			q.failed[message.ID] = message
-			q.stats.mutex.Lock()
-			q.stats.FailedMessages++
-			q.stats.ProcessingMessages--
-			q.stats.mutex.Unlock()
+		q.failProcessingLocked(message)
+	}
+}
+
+// failProcessingLocked 将处理中的消息重新加入队列，无法重试时标记为失败，调用方需持有写锁
+func (q *Queue) failProcessingLocked(message *Message) {
+	delete(q.processing, message.ID)
+	delete(q.startedAt, message.ID)
+	message.IncrementRetries()
+
+	q.stats.mutex.Lock()
+	defer q.stats.mutex.Unlock()
+
+	if message.CanRetry() {
+		// 重新加入队列
+		heap.Push(&q.messages, message)
+		q.pending[message.ID] = message
+		q.stats.PendingMessages++
+	} else {
+		// 标记为失败
+		q.failed[message.ID] = message
+		q.stats.FailedMessages++
+	}
+	q.stats.ProcessingMessages--
+}
+
+// ReapStuck 回收处理时间超过超时的消息，工作协程崩溃时这些消息会一直停留在处理中状态。
+// timeout 返回每条消息允许的处理时间，回收的消息按失败处理：可重试时重新入队，否则标记为失败
+func (q *Queue) ReapStuck(timeout func(*Message) time.Duration) int {
+	q.mutex.RLock()
+	candidates := make(map[*Message]time.Time, len(q.processing))
+	for id, message := range q.processing {
+		candidates[message] = q.startedAt[id]
+	}
+	q.mutex.RUnlock()
+
+	// 在锁外计算超时，避免与调用方的锁形成嵌套
+	var stuck []*Message
+	for message, startedAt := range candidates {
+		if time.Since(startedAt) > timeout(message) {
+			stuck = append(stuck, message)
+		}
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	reaped := 0
+	for _, message := range stuck {
+		// 期间已完成或被重新取出的消息不再回收
+		if q.processing[message.ID] != message || !q.startedAt[message.ID].Equal(candidates[message]) {
+			continue
 		}
+		q.failProcessingLocked(message)
+		reaped++
 	}
+	return reaped
 }
 
 // GetPendingCount 获取待处理消息数量
@@ -307,6 +348,7 @@ func (q *Queue) Clear() {
 	q.pending = make(map[string]*Message)
 	q.processing = make(map[string]*Message)
 	q.failed = make(map[string]*Message)
+	q.startedAt = make(map[string]time.Time)
 	
 	q.stats.mutex.Lock()
 	q.stats.PendingMessages = 0
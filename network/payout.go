@@ -0,0 +1,143 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"mini-coin-go/blockchain"
+)
+
+// PayoutAddress 带权重的挖矿奖励地址
+type PayoutAddress struct {
+	Address string // 奖励地址
+	Weight  int    // 权重，每轮中该地址获得的区块数，默认 1
+}
+
+// payoutRotation 按权重轮换的奖励地址调度表
+type payoutRotation struct {
+	schedule []string
+	next     int
+	mutex    sync.Mutex
+}
+
+// payouts 矿工奖励地址轮换表，为空时使用 miningAddress
+var payouts = &payoutRotation{}
+
+// defaultMaxBlockTransactions 每个区块默认最多打包的内存池交易数
+const defaultMaxBlockTransactions = 1000
+
+// maxBlockTransactions 每个区块最多打包的内存池交易数，不含 coinbase
+var maxBlockTransactions = defaultMaxBlockTransactions
+
+// SetMaxBlockTransactions 设置每个区块最多打包的交易数，小于等于 0 时使用默认值
+func SetMaxBlockTransactions(max int) {
+	if max <= 0 {
+		max = defaultMaxBlockTransactions
+	}
+
+	maxBlockTransactions = max
+}
+
+// SetPayoutAddresses 设置矿工奖励地址列表，每个区块按权重轮流支付给其中一个地址
+func SetPayoutAddresses(addresses []PayoutAddress) error {
+	var schedule []string
+	for _, payout := range addresses {
+		if !blockchain.ValidateAddress(payout.Address) {
+			return fmt.Errorf("invalid payout address %s", payout.Address)
+		}
+
+		weight := payout.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		for i := 0; i < weight; i++ {
+			schedule = append(schedule, payout.Address)
+		}
+	}
+
+	payouts.mutex.Lock()
+	defer payouts.mutex.Unlock()
+
+	payouts.schedule = schedule
+	payouts.next = 0
+
+	return nil
+}
+
+// nextPayoutAddress 返回下一个区块的奖励地址
+func nextPayoutAddress() string {
+	payouts.mutex.Lock()
+	defer payouts.mutex.Unlock()
+
+	if len(payouts.schedule) == 0 {
+		return miningAddress
+	}
+
+	address := payouts.schedule[payouts.next]
+	payouts.next = (payouts.next + 1) % len(payouts.schedule)
+
+	return address
+}
+
+// isMining 判断当前节点是否开启了挖矿
+func isMining() bool {
+	payouts.mutex.Lock()
+	defer payouts.mutex.Unlock()
+
+	return len(miningAddress) > 0 || len(payouts.schedule) > 0
+}
+
+// mineBlock 挖出包含给定交易的新区块，奖励支付给下一个奖励地址
+func mineBlock(bc *blockchain.Blockchain, txs []*blockchain.Transaction) (*blockchain.Block, error) {
+	return bc.MineBlock(txs, nextPayoutAddress())
+}
+
+// mineMempool 从内存池中选取至多 maxBlockTransactions 个有效交易挖出一个区块
+// 打包的交易从内存池中移除，其余交易留给下一个区块；没有有效交易时返回 nil
+func mineMempool(bc *blockchain.Blockchain) (*blockchain.Block, error) {
+	ids := make([]string, 0, len(mempool))
+	for id := range mempool {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// 多轮选取，使花费内存池中父交易输出的子交易排在父交易之后
+	var txs []*blockchain.Transaction
+	selected := make(map[string]bool)
+	for progress := true; progress && len(txs) < maxBlockTransactions; {
+		progress = false
+		for _, id := range ids {
+			if len(txs) >= maxBlockTransactions {
+				break
+			}
+			if selected[id] {
+				continue
+			}
+
+			tx := mempool[id]
+			if bc.VerifyTransactionInBlock(&tx, txs) {
+				txs = append(txs, &tx)
+				selected[id] = true
+				progress = true
+			}
+		}
+	}
+
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	newBlock, err := mineBlock(bc, txs)
+	if err != nil {
+		return nil, err
+	}
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+	UTXOSet.Reindex()
+
+	for _, tx := range txs {
+		delete(mempool, string(tx.ID))
+	}
+
+	return newBlock, nil
+}
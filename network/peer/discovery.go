@@ -6,22 +6,82 @@ import (
 	"log"
 	"math/rand"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	defaultBootstrapDelay        = 5 * time.Second  // 默认首次连接种子节点前的等待时间
+	defaultSeedRetryInterval     = 10 * time.Minute // 默认种子节点重试间隔
+	defaultPeerLossCheckInterval = 5 * time.Second  // 默认节点丢失检测间隔
+)
+
 // Discovery 节点发现服务
 type Discovery struct {
 	manager     *Manager
 	isRunning   bool
 	stopChannel chan bool
+
+	bootstrapDelay        time.Duration // 首次连接种子节点前的等待时间
+	seedRetryInterval     time.Duration // 种子节点重试间隔
+	peerLossCheckInterval time.Duration // 节点丢失检测间隔
+	seedAttempts          int64         // 已尝试连接种子节点的次数
+	peerRequester         PeerRequester // 向节点请求节点列表的函数，为空时使用模拟数据
+	newTicker             tickerFunc    // 创建定时器，测试中可替换为手动触发
 }
 
+// tickerFunc 创建按指定间隔触发的定时器，返回触发通道和停止函数
+type tickerFunc func(interval time.Duration) (<-chan time.Time, func())
+
+// realTicker 使用 time.Ticker 实现的定时器
+func realTicker(interval time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// PeerRequester 向指定地址的节点请求节点列表，由网络层实现（如发送 getaddr 消息）
+type PeerRequester func(address string)
+
 // NewDiscovery 创建节点发现服务
 func NewDiscovery(manager *Manager) *Discovery {
 	return &Discovery{
 		manager:     manager,
 		stopChannel: make(chan bool),
+
+		bootstrapDelay:        defaultBootstrapDelay,
+		seedRetryInterval:     defaultSeedRetryInterval,
+		peerLossCheckInterval: defaultPeerLossCheckInterval,
+		newTicker:             realTicker,
+	}
+}
+
+// SetBootstrapDelay 设置首次连接种子节点前的等待时间，需在 Start 之前调用
+func (d *Discovery) SetBootstrapDelay(delay time.Duration) {
+	if delay <= 0 {
+		delay = defaultBootstrapDelay
+	}
+	d.bootstrapDelay = delay
+}
+
+// SetSeedRetryInterval 设置种子节点重试间隔，需在 Start 之前调用
+func (d *Discovery) SetSeedRetryInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSeedRetryInterval
 	}
+	d.seedRetryInterval = interval
+}
+
+// SetPeerLossCheckInterval 设置节点丢失检测间隔，需在 Start 之前调用
+func (d *Discovery) SetPeerLossCheckInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultPeerLossCheckInterval
+	}
+	d.peerLossCheckInterval = interval
+}
+
+// SetPeerRequester 设置向节点请求节点列表的函数，需在 Start 之前调用
+func (d *Discovery) SetPeerRequester(requester PeerRequester) {
+	d.peerRequester = requester
 }
 
 // Start 启动节点发现服务
@@ -52,12 +112,12 @@ func (d *Discovery) Stop() {
 
 // periodicDiscovery 定期节点发现
 func (d *Discovery) periodicDiscovery() {
-	ticker := time.NewTicker(2 * time.Minute)
-	defer ticker.Stop()
+	tick, stop := d.newTicker(2 * time.Minute)
+	defer stop()
 	
 	for {
 		select {
-		case <-ticker.C:
+		case <-tick:
 			d.discoverNewPeers()
 		case <-d.stopChannel:
 			return
@@ -67,18 +127,35 @@ func (d *Discovery) periodicDiscovery() {
 
 // bootstrapDiscovery 引导节点发现
 func (d *Discovery) bootstrapDiscovery() {
-	// 首次启动时立即尝试发现
-	time.Sleep(5 * time.Second)
+	// 首次启动时等待片刻后尝试发现
+	delay, stopDelay := d.newTicker(d.bootstrapDelay)
+	select {
+	case <-delay:
+		stopDelay()
+	case <-d.stopChannel:
+		stopDelay()
+		return
+	}
 	d.connectToSeedNodes()
 	
-	// 然后定期重试
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
+	// 然后定期重试，所有已连接节点丢失时立即重试
+	retryTick, stopRetry := d.newTicker(d.seedRetryInterval)
+	defer stopRetry()
+	lossTick, stopLoss := d.newTicker(d.peerLossCheckInterval)
+	defer stopLoss()
 	
+	hadPeers := false
 	for {
 		select {
-		case <-ticker.C:
+		case <-retryTick:
 			d.connectToSeedNodes()
+		case <-lossTick:
+			connected := len(d.manager.GetConnectedPeers()) > 0
+			if hadPeers && !connected {
+				log.Println("已丢失所有连接节点，立即重试种子节点")
+				d.connectToSeedNodes()
+			}
+			hadPeers = connected
 		case <-d.stopChannel:
 			return
 		}
@@ -87,12 +164,12 @@ func (d *Discovery) bootstrapDiscovery() {
 
 // peerExchangeDiscovery 节点交换发现
 func (d *Discovery) peerExchangeDiscovery() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+	tick, stop := d.newTicker(5 * time.Minute)
+	defer stop()
 	
 	for {
 		select {
-		case <-ticker.C:
+		case <-tick:
 			d.exchangePeersWithConnected()
 		case <-d.stopChannel:
 			return
@@ -122,10 +199,12 @@ func (d *Discovery) discoverNewPeers() {
 // connectToSeedNodes 连接到种子节点
 func (d *Discovery) connectToSeedNodes() {
 	log.Println("尝试连接种子节点...")
+	atomic.AddInt64(&d.seedAttempts, 1)
 	
 	allPeers := d.manager.GetAllPeers()
 	for _, peer := range allPeers {
-		if peer.GetStatus() == StatusDisconnected {
+		status := peer.GetStatus()
+		if status == StatusDisconnected || status == StatusFailed {
 			go d.attemptConnection(peer)
 		}
 	}
@@ -187,11 +266,14 @@ func (d *Discovery) attemptConnection(peer *Peer) {
 
 // requestPeersFrom 从指定节点请求节点列表
 func (d *Discovery) requestPeersFrom(peer *Peer) {
-	// 这里应该发送节点列表请求消息
-	// 由于这需要与network层集成，暂时模拟
 	log.Printf("从节点 %s 请求节点列表", peer.GetFullAddress())
-	
-	// 模拟收到一些新节点地址
+
+	if d.peerRequester != nil {
+		d.peerRequester(peer.GetFullAddress())
+		return
+	}
+
+	// 未接入网络层时模拟收到一些新节点地址
 	d.simulateDiscoveredPeers()
 }
 
@@ -273,6 +355,7 @@ func (d *Discovery) GetDiscoveryStats() map[string]interface{} {
 		"is_running":      d.isRunning,
 		"connected_peers": len(d.manager.GetConnectedPeers()),
 		"total_peers":     len(d.manager.GetAllPeers()),
+		"seed_attempts":   atomic.LoadInt64(&d.seedAttempts),
 	}
 }
 
@@ -15,13 +15,23 @@ import (
 
 // Manager 节点管理器
 type Manager struct {
-	peers           map[string]*Peer // 所有节点
-	maxPeers        int              // 最大节点数
-	seedNodes       []string         // 种子节点
-	mutex           sync.RWMutex     // 读写锁
-	heartbeatTicker *time.Ticker     // 心跳定时器
-	cleanupTicker   *time.Ticker     // 清理定时器
-	configFile      string           // 配置文件路径
+	peers           map[string]*Peer     // 所有节点
+	maxPeers        int                  // 最大节点数
+	seedNodes       []string             // 种子节点
+	mutex           sync.RWMutex         // 读写锁
+	heartbeatTicker *time.Ticker         // 心跳定时器
+	cleanupTicker   *time.Ticker         // 清理定时器
+	configFile      string               // 配置文件路径
+	blacklist       Blacklist            // 黑名单，评分过低的节点会被加入
+	banThreshold    int                  // 触发封禁的评分阈值
+	banDuration     time.Duration        // 封禁持续时间
+	bannedIPs       map[string]time.Time // IP -> 封禁到期时间
+}
+
+// Blacklist 节点黑名单接口，由 security.BlacklistFilter 实现
+type Blacklist interface {
+	AddToBlacklist(ip string, duration time.Duration)
+	RemoveFromBlacklist(ip string)
 }
 
 // PeerConfig 节点配置
@@ -36,6 +46,7 @@ func NewManager(configFile string) *Manager {
 		peers:      make(map[string]*Peer),
 		maxPeers:   50,
 		configFile: configFile,
+		bannedIPs:  make(map[string]time.Time),
 	}
 
 	// 加载配置
@@ -52,26 +63,84 @@ func NewManager(configFile string) *Manager {
 
 // loadConfig 加载配置文件
 func (m *Manager) loadConfig() {
-	data, err := ioutil.ReadFile(m.configFile)
+	config, err := m.readConfig()
 	if err != nil {
-		log.Printf("无法读取配置文件 %s: %v，使用默认配置", m.configFile, err)
+		log.Printf("%v，使用默认配置", err)
 		m.seedNodes = []string{"localhost:3000"}
 		return
 	}
 
+	m.seedNodes = config.SeedNodes
+	if config.MaxPeers > 0 {
+		m.maxPeers = config.MaxPeers
+	}
+
+	log.Printf("加载配置成功：种子节点 %v，最大节点数 %d", m.seedNodes, m.maxPeers)
+}
+
+// readConfig 读取并解析配置文件
+func (m *Manager) readConfig() (*PeerConfig, error) {
+	data, err := ioutil.ReadFile(m.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取配置文件 %s: %v", m.configFile, err)
+	}
+
 	var config PeerConfig
 	if err := json.Unmarshal(data, &config); err != nil {
-		log.Printf("配置文件格式错误: %v，使用默认配置", err)
-		m.seedNodes = []string{"localhost:3000"}
-		return
+		return nil, fmt.Errorf("配置文件格式错误: %v", err)
 	}
 
-	m.seedNodes = config.SeedNodes
+	return &config, nil
+}
+
+// ReloadConfig 重新读取配置文件，合并新增的种子节点并更新最大节点数，
+// 已有的节点和种子节点保持不变，可由 SIGHUP 信号处理或 CLI 调用
+func (m *Manager) ReloadConfig() error {
+	config, err := m.readConfig()
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	var added []string
+	for _, seedAddr := range config.SeedNodes {
+		if !containsString(m.seedNodes, seedAddr) {
+			m.seedNodes = append(m.seedNodes, seedAddr)
+			added = append(added, seedAddr)
+		}
+	}
 	if config.MaxPeers > 0 {
 		m.maxPeers = config.MaxPeers
 	}
+	maxPeers := m.maxPeers
+	m.mutex.Unlock()
 
-	log.Printf("加载配置成功：种子节点 %v，最大节点数 %d", m.seedNodes, m.maxPeers)
+	for _, seedAddr := range added {
+		m.AddPeer(NewPeerFromAddress(seedAddr))
+	}
+
+	log.Printf("重新加载配置：新增种子节点 %v，最大节点数 %d", added, maxPeers)
+	return nil
+}
+
+// GetSeedNodes 返回当前的种子节点列表
+func (m *Manager) GetSeedNodes() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	seeds := make([]string, len(m.seedNodes))
+	copy(seeds, m.seedNodes)
+	return seeds
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
 // initSeedNodes 初始化种子节点
@@ -184,6 +253,27 @@ func (m *Manager) GetBestPeers(count int) []*Peer {
 	return peers
 }
 
+// GetHighestPeer 获取最佳区块高度最高的可连接节点，高度相同时取评分更高者
+// 只考虑提供完整区块服务的节点，没有可用节点时返回 nil
+func (m *Manager) GetHighestPeer() *Peer {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var highest *Peer
+	for _, peer := range m.peers {
+		if !peer.CanConnect() || !peer.HasServices(ServiceNodeNetwork) {
+			continue
+		}
+
+		if highest == nil || peer.GetBestHeight() > highest.GetBestHeight() ||
+			(peer.GetBestHeight() == highest.GetBestHeight() && peer.GetScore() > highest.GetScore()) {
+			highest = peer
+		}
+	}
+
+	return highest
+}
+
 // GetRandomPeers 获取随机节点列表
 func (m *Manager) GetRandomPeers(count int) []*Peer {
 	m.mutex.RLock()
@@ -274,6 +364,7 @@ func (m *Manager) performHeartbeat() {
 		go func(p *Peer) {
 			if err := p.Ping(); err != nil {
 				log.Printf("节点心跳失败 %s: %v", p.GetFullAddress(), err)
+				m.checkPeerBan(p)
 			}
 		}(peer)
 	}
@@ -288,6 +379,8 @@ func (m *Manager) cleanupTask() {
 
 // performCleanup 执行清理
 func (m *Manager) performCleanup() {
+	m.expireBans(time.Now())
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -308,6 +401,92 @@ func (m *Manager) performCleanup() {
 	log.Printf("清理完成，当前节点数: %d", len(m.peers))
 }
 
+// SetBlacklist 设置黑名单，评分低于阈值的节点IP会被封禁
+func (m *Manager) SetBlacklist(blacklist Blacklist, threshold int, duration time.Duration) {
+	if threshold <= 0 {
+		threshold = 10
+	}
+	if duration <= 0 {
+		duration = time.Hour
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.blacklist = blacklist
+	m.banThreshold = threshold
+	m.banDuration = duration
+}
+
+// PenalizePeer 降低节点评分，评分低于阈值时封禁节点IP
+func (m *Manager) PenalizePeer(address string, delta int) bool {
+	peer := m.GetPeer(address)
+	if peer == nil {
+		return false
+	}
+
+	peer.DecreaseScore(delta)
+	return m.checkPeerBan(peer)
+}
+
+// checkPeerBan 检查节点评分，必要时加入黑名单
+func (m *Manager) checkPeerBan(peer *Peer) bool {
+	m.mutex.Lock()
+	blacklist := m.blacklist
+	if blacklist == nil || peer.GetScore() >= m.banThreshold {
+		m.mutex.Unlock()
+		return false
+	}
+
+	ip := peer.GetHost()
+	if _, banned := m.bannedIPs[ip]; banned {
+		m.mutex.Unlock()
+		return true
+	}
+
+	duration := m.banDuration
+	m.bannedIPs[ip] = time.Now().Add(duration)
+	m.mutex.Unlock()
+
+	blacklist.AddToBlacklist(ip, duration)
+	log.Printf("节点评分过低，封禁IP: %s，评分: %d", ip, peer.GetScore())
+
+	return true
+}
+
+// expireBans 解除已到期的封禁
+func (m *Manager) expireBans(now time.Time) {
+	m.mutex.Lock()
+	blacklist := m.blacklist
+
+	var expired []string
+	for ip, until := range m.bannedIPs {
+		if !now.Before(until) {
+			expired = append(expired, ip)
+			delete(m.bannedIPs, ip)
+		}
+	}
+	m.mutex.Unlock()
+
+	if blacklist == nil {
+		return
+	}
+
+	for _, ip := range expired {
+		blacklist.RemoveFromBlacklist(ip)
+		log.Printf("节点封禁已到期: %s", ip)
+	}
+}
+
+// IsBanned 检查IP是否处于封禁状态
+func (m *Manager) IsBanned(ip string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	until, exists := m.bannedIPs[ip]
+	return exists && time.Now().Before(until)
+}
+
 // GetStats 获取统计信息
 func (m *Manager) GetStats() map[string]interface{} {
 	m.mutex.RLock()
@@ -322,6 +501,7 @@ func (m *Manager) GetStats() map[string]interface{} {
 	}
 	stats["status_count"] = statusCount
 	stats["max_peers"] = m.maxPeers
+	stats["banned_ips"] = len(m.bannedIPs)
 
 	return stats
 }
@@ -35,6 +35,20 @@ func (s PeerStatus) String() string {
 	}
 }
 
+// 节点服务标志，在 version 消息中交换
+const (
+	// ServiceNodeNetwork 节点可以提供完整区块
+	ServiceNodeNetwork uint64 = 1 << iota
+	// ServiceNodeBloom 节点支持布隆过滤器
+	ServiceNodeBloom
+)
+
+// 节点评分范围，所有评分变更都会被限制在 [MinScore, MaxScore] 内
+const (
+	MinScore = 0
+	MaxScore = 100
+)
+
 // Peer 表示网络中的一个节点
 type Peer struct {
 	ID             string        // 节点唯一标识
@@ -64,7 +78,7 @@ func NewPeer(address string, port int) *Peer {
 		Score:          50, // 默认评分
 		FailedAttempts: 0,
 		Version:        1,
-		Services:       1, // 基础服务
+		Services:       ServiceNodeNetwork,
 		UserAgent:      "mini-coin-go/1.0",
 	}
 }
@@ -95,13 +109,10 @@ func (p *Peer) UpdateStatus(status PeerStatus) {
 	if status == StatusConnected {
 		p.ConnectedAt = time.Now()
 		p.FailedAttempts = 0
-		p.Score += 10 // 连接成功增加评分
+		p.setScoreLocked(p.Score + 10) // 连接成功增加评分
 	} else if status == StatusFailed {
 		p.FailedAttempts++
-		p.Score -= 5 // 连接失败减少评分
-		if p.Score < 0 {
-			p.Score = 0
-		}
+		p.setScoreLocked(p.Score - 5) // 连接失败减少评分
 	}
 
 	fmt.Printf("节点 %s 状态变更: %s -> %s\n", p.ID, oldStatus, status)
@@ -121,6 +132,32 @@ func (p *Peer) UpdateBestHeight(height int) {
 	p.BestHeight = height
 }
 
+// GetBestHeight 获取节点的最佳区块高度
+func (p *Peer) GetBestHeight() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.BestHeight
+}
+
+// UpdateServices 更新节点支持的服务标志
+func (p *Peer) UpdateServices(services uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.Services = services
+}
+
+// GetServices 获取节点支持的服务标志
+func (p *Peer) GetServices() uint64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.Services
+}
+
+// HasServices 判断节点是否支持 services 中的全部服务
+func (p *Peer) HasServices(services uint64) bool {
+	return p.GetServices()&services == services
+}
+
 // UpdatePingTime 更新延迟时间
 func (p *Peer) UpdatePingTime(duration time.Duration) {
 	p.mutex.Lock()
@@ -167,21 +204,29 @@ func (p *Peer) SetStatus(status PeerStatus) {
 	p.UpdateStatus(status)
 }
 
-// IncreaseScore 增加节点评分
+// IncreaseScore 增加节点评分，最高不超过 MaxScore
 func (p *Peer) IncreaseScore(delta int) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
-	p.Score += delta
+	p.setScoreLocked(p.Score + delta)
 }
 
-// DecreaseScore 减少节点评分
+// DecreaseScore 减少节点评分，最低不低于 MinScore
 func (p *Peer) DecreaseScore(delta int) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
-	p.Score -= delta
-	if p.Score < 0 {
-		p.Score = 0
+	p.setScoreLocked(p.Score - delta)
+}
+
+// setScoreLocked 将评分限制在 [MinScore, MaxScore] 内后保存，调用方需持有锁
+func (p *Peer) setScoreLocked(score int) {
+	if score < MinScore {
+		score = MinScore
+	}
+	if score > MaxScore {
+		score = MaxScore
 	}
+	p.Score = score
 }
 
 // GetHost 获取节点地址
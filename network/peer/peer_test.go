@@ -1,9 +1,15 @@
 package peer
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"mini-coin-go/network/security"
 )
 
 // TestPeer 测试节点基础功能
@@ -72,6 +78,36 @@ func TestPeer(t *testing.T) {
 		}
 	})
 
+	t.Run("PeerScoreBounds", func(t *testing.T) {
+		peer := NewPeer("localhost", 3002)
+
+		// 减少到 0 以下时限制为 MinScore
+		peer.DecreaseScore(peer.GetScore() + 20)
+		if peer.GetScore() != MinScore {
+			t.Errorf("Expected score clamped to %d, got %d", MinScore, peer.GetScore())
+		}
+		peer.SetStatus(StatusFailed)
+		if peer.GetScore() != MinScore {
+			t.Errorf("Expected failed connection to keep score at %d, got %d", MinScore, peer.GetScore())
+		}
+
+		// 增加到上限以上时限制为 MaxScore
+		peer.IncreaseScore(MaxScore + 50)
+		if peer.GetScore() != MaxScore {
+			t.Errorf("Expected score clamped to %d, got %d", MaxScore, peer.GetScore())
+		}
+		peer.SetStatus(StatusConnected)
+		if peer.GetScore() != MaxScore {
+			t.Errorf("Expected successful connection to keep score at %d, got %d", MaxScore, peer.GetScore())
+		}
+
+		// 负的增量同样受下限约束
+		peer.IncreaseScore(-2 * MaxScore)
+		if peer.GetScore() != MinScore {
+			t.Errorf("Expected negative delta clamped to %d, got %d", MinScore, peer.GetScore())
+		}
+	})
+
 	t.Run("PeerConnection", func(t *testing.T) {
 		peer := NewPeer("localhost", 3003)
 
@@ -307,3 +343,295 @@ func TestPeerConfigLoading(t *testing.T) {
 		}
 	})
 }
+
+// TestPeerManagerReloadConfig 测试重新加载配置时合并新种子节点并保留已有节点
+func TestPeerManagerReloadConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "peer_config.json")
+	if err := os.WriteFile(configFile, []byte(`{"seed_nodes": ["localhost:3000"], "max_peers": 10}`), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	manager := NewManager(configFile)
+	defer manager.Stop()
+
+	manager.AddPeer(NewPeer("localhost", 3010))
+
+	updated := `{"seed_nodes": ["localhost:3000", "localhost:3001", "localhost:3002"], "max_peers": 20}`
+	if err := os.WriteFile(configFile, []byte(updated), 0644); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := manager.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+
+	for _, addr := range []string{"localhost:3000", "localhost:3001", "localhost:3002", "localhost:3010"} {
+		if manager.GetPeer(addr) == nil {
+			t.Errorf("Expected peer %s after reload", addr)
+		}
+	}
+	if seeds := manager.GetSeedNodes(); len(seeds) != 3 {
+		t.Errorf("Expected 3 seed nodes after reload, got %v", seeds)
+	}
+	if maxPeers := manager.GetStats()["max_peers"].(int); maxPeers != 20 {
+		t.Errorf("Expected max_peers 20 after reload, got %d", maxPeers)
+	}
+
+	// 配置文件损坏时保留当前配置
+	if err := os.WriteFile(configFile, []byte(`{invalid json`), 0644); err != nil {
+		t.Fatalf("Failed to corrupt config file: %v", err)
+	}
+	if err := manager.ReloadConfig(); err == nil {
+		t.Error("Expected ReloadConfig to fail on an invalid config file")
+	}
+	if seeds := manager.GetSeedNodes(); len(seeds) != 3 {
+		t.Errorf("Expected seed nodes to be kept after a failed reload, got %v", seeds)
+	}
+}
+
+// TestPeerManagerBan 测试评分过低的节点被加入黑名单
+func TestPeerManagerBan(t *testing.T) {
+	manager := NewManager("non_existent_config.json")
+	defer manager.Stop()
+
+	blacklist := security.NewBlacklistFilter()
+	manager.SetBlacklist(blacklist, 20, time.Minute)
+
+	peer := NewPeer("10.0.0.5", 3000)
+	manager.AddPeer(peer)
+	ctx := context.Background()
+
+	// 评分仍高于阈值，不应封禁
+	if manager.PenalizePeer("10.0.0.5:3000", 10) {
+		t.Error("Peer should not be banned above threshold")
+	}
+	if !blacklist.ShouldAllow(ctx, "10.0.0.5:3000", "version") {
+		t.Error("Peer IP should still be allowed")
+	}
+
+	// 评分降到阈值以下，应被封禁
+	if !manager.PenalizePeer("10.0.0.5:3000", 30) {
+		t.Error("Peer should be banned below threshold")
+	}
+	if blacklist.ShouldAllow(ctx, "10.0.0.5:3000", "version") {
+		t.Error("Banned peer IP should be blocked by blacklist")
+	}
+	if !manager.IsBanned("10.0.0.5") {
+		t.Error("Manager should report the IP as banned")
+	}
+
+	// 封禁到期后应从黑名单移除
+	manager.expireBans(time.Now().Add(2 * time.Minute))
+	if manager.IsBanned("10.0.0.5") {
+		t.Error("Ban should expire")
+	}
+	if len(blacklist.GetBlacklistIPs()) != 0 {
+		t.Errorf("Expected empty blacklist after expiry, got %v", blacklist.GetBlacklistIPs())
+	}
+}
+
+// TestPeerManagerGetHighestPeer 测试获取最佳高度最高的节点
+func TestPeerManagerGetHighestPeer(t *testing.T) {
+	manager := NewManager("non_existent_config.json")
+	defer manager.Stop()
+
+	low := NewPeer("10.0.0.1", 3000)
+	low.UpdateBestHeight(5)
+	tied := NewPeer("10.0.0.2", 3000)
+	tied.UpdateBestHeight(10)
+	best := NewPeer("10.0.0.3", 3000)
+	best.UpdateBestHeight(10)
+	best.IncreaseScore(10)
+	unreachable := NewPeer("10.0.0.4", 3000)
+	unreachable.UpdateBestHeight(50)
+	unreachable.SetStatus(StatusFailed)
+
+	for _, peer := range []*Peer{low, tied, best, unreachable} {
+		manager.AddPeer(peer)
+	}
+
+	if highest := manager.GetHighestPeer(); highest != best {
+		t.Errorf("Expected highest peer %s, got %v", best.GetFullAddress(), highest)
+	}
+
+	// 没有可连接节点时返回 nil
+	for _, peer := range manager.GetAllPeers() {
+		peer.SetStatus(StatusFailed)
+	}
+	if highest := manager.GetHighestPeer(); highest != nil {
+		t.Errorf("Expected nil when no peer is connectable, got %v", highest)
+	}
+}
+
+// TestPeerManagerGetHighestPeer_Services 测试不提供完整区块服务的节点不会被选为同步节点
+func TestPeerManagerGetHighestPeer_Services(t *testing.T) {
+	manager := NewManager("non_existent_config.json")
+	defer manager.Stop()
+
+	full := NewPeer("10.0.1.1", 3000)
+	full.UpdateBestHeight(10)
+	light := NewPeer("10.0.1.2", 3000)
+	light.UpdateBestHeight(20)
+	light.UpdateServices(ServiceNodeBloom)
+
+	manager.AddPeer(full)
+	manager.AddPeer(light)
+
+	if light.HasServices(ServiceNodeNetwork) || !full.HasServices(ServiceNodeNetwork) {
+		t.Fatal("Unexpected service flags")
+	}
+	if highest := manager.GetHighestPeer(); highest != full {
+		t.Errorf("Expected full-block peer %s, got %v", full.GetFullAddress(), highest)
+	}
+
+	// 只剩不提供完整区块服务的节点时返回 nil
+	for _, peer := range manager.GetAllPeers() {
+		peer.UpdateServices(ServiceNodeBloom)
+	}
+	if highest := manager.GetHighestPeer(); highest != nil {
+		t.Errorf("Expected nil without full-block peers, got %v", highest)
+	}
+}
+
+// manualTickers 按间隔记录发现服务创建的定时器，由测试手动触发
+type manualTickers struct {
+	mutex sync.Mutex
+	chans map[time.Duration]chan time.Time
+}
+
+// install 替换发现服务的定时器，需在 Start 之前调用
+func (m *manualTickers) install(d *Discovery) {
+	m.chans = make(map[time.Duration]chan time.Time)
+	d.newTicker = func(interval time.Duration) (<-chan time.Time, func()) {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		ch := make(chan time.Time)
+		m.chans[interval] = ch
+		return ch, func() {}
+	}
+}
+
+// tick 触发指定间隔的定时器，等待发现服务创建该定时器并接收本次触发
+func (m *manualTickers) tick(t *testing.T, interval time.Duration) {
+	t.Helper()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		m.mutex.Lock()
+		ch := m.chans[interval]
+		m.mutex.Unlock()
+		if ch != nil {
+			select {
+			case ch <- time.Now():
+				return
+			case <-deadline:
+				t.Fatalf("Ticker for %v was not read", interval)
+			}
+		}
+
+		select {
+		case <-deadline:
+			t.Fatalf("Ticker for %v was never created", interval)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// waitForSeedAttempts 等待种子节点尝试次数达到 want
+func waitForSeedAttempts(t *testing.T, d *Discovery, want int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt64(&d.seedAttempts) < want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := atomic.LoadInt64(&d.seedAttempts); got != want {
+		t.Fatalf("Expected %d seed attempts, got %d", want, got)
+	}
+}
+
+// TestDiscoveryBootstrapInterval 测试种子节点按配置的间隔重试
+func TestDiscoveryBootstrapInterval(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "missing.json"))
+	defer manager.Stop()
+
+	const delay, retry, lossCheck = time.Second, time.Minute, time.Hour
+	discovery := NewDiscovery(manager)
+	discovery.SetBootstrapDelay(delay)
+	discovery.SetSeedRetryInterval(retry)
+	discovery.SetPeerLossCheckInterval(lossCheck)
+	var tickers manualTickers
+	tickers.install(discovery)
+	discovery.Start()
+	defer discovery.Stop()
+
+	// 等待期结束后首次尝试连接种子节点
+	tickers.tick(t, delay)
+	waitForSeedAttempts(t, discovery, 1)
+
+	// 之后每个重试间隔尝试一次
+	for want := int64(2); want <= 4; want++ {
+		tickers.tick(t, retry)
+		waitForSeedAttempts(t, discovery, want)
+	}
+
+	// 从未连接过节点时，节点丢失检测不会触发重试；第二次触发确保第一次已处理完
+	tickers.tick(t, lossCheck)
+	tickers.tick(t, lossCheck)
+	if attempts := discovery.GetDiscoveryStats()["seed_attempts"].(int64); attempts != 4 {
+		t.Errorf("Expected seed attempts to follow the retry interval only, got %d", attempts)
+	}
+}
+
+// TestDiscoveryRetryOnPeerLoss 测试丢失所有连接节点后立即重试种子节点
+func TestDiscoveryRetryOnPeerLoss(t *testing.T) {
+	manager := NewManager("non_existent_config.json")
+	defer manager.Stop()
+
+	peer := NewPeer("10.0.0.9", 3000)
+	manager.AddPeer(peer)
+	peer.UpdateStatus(StatusConnected)
+
+	const delay, lossCheck = time.Second, time.Minute
+	discovery := NewDiscovery(manager)
+	discovery.SetBootstrapDelay(delay)
+	discovery.SetSeedRetryInterval(time.Hour)
+	discovery.SetPeerLossCheckInterval(lossCheck)
+	var tickers manualTickers
+	tickers.install(discovery)
+	discovery.Start()
+	defer discovery.Stop()
+
+	tickers.tick(t, delay)
+	waitForSeedAttempts(t, discovery, 1)
+
+	// 前一次检测记录到已连接节点，第二次触发确保第一次已处理完
+	tickers.tick(t, lossCheck)
+	tickers.tick(t, lossCheck)
+	for _, p := range manager.GetConnectedPeers() {
+		p.UpdateStatus(StatusDisconnected)
+	}
+	tickers.tick(t, lossCheck)
+	waitForSeedAttempts(t, discovery, 2)
+}
+
+// TestDiscoveryPeerRequester 测试设置请求函数后向节点请求节点列表时调用该函数
+func TestDiscoveryPeerRequester(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "missing.json"))
+	defer manager.Stop()
+
+	discovery := NewDiscovery(manager)
+	var requested []string
+	discovery.SetPeerRequester(func(address string) {
+		requested = append(requested, address)
+	})
+
+	discovery.requestPeersFrom(NewPeer("localhost", 3020))
+
+	if len(requested) != 1 || requested[0] != "localhost:3020" {
+		t.Errorf("Expected a peer list request to localhost:3020, got %v", requested)
+	}
+	if manager.GetPeer("127.0.0.1:3001") != nil {
+		t.Error("Expected no simulated peers when a requester is set")
+	}
+}
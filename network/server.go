@@ -2,18 +2,51 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"sync"
+	"time"
 
 	"mini-coin-go/blockchain"
+	"mini-coin-go/network/connection"
+	"mini-coin-go/network/peer"
+	"mini-coin-go/network/security"
 )
 
 const (
 	protocol = "tcp"
+	// defaultBindHost 默认监听地址
+	defaultBindHost = "0.0.0.0"
+	// maxHeightLead 节点报告的最佳高度最多领先本地链的区块数，超出视为不可信
+	maxHeightLead = 100000
+	// protocolVersion 本节点使用的协议版本，在 version 消息中发送
+	protocolVersion = 1
+	// minProtocolVersion 可以与之通信的最低协议版本
+	minProtocolVersion = 1
+	// localServices 本节点在 version 消息中公布的服务标志
+	localServices = peer.ServiceNodeNetwork
+	// defaultStaleTipAge 链末端区块超过该时长没有更新时告警
+	defaultStaleTipAge = 30 * time.Minute
+	// staleTipCheckInterval 检查链末端是否过期的间隔
+	staleTipCheckInterval = time.Minute
+	// invalidBlockPenalty 发送无效区块的节点扣除的评分
+	invalidBlockPenalty = 20
 )
 
+// ServerConfig 服务器监听配置
+type ServerConfig struct {
+	BindHost        string             // 监听地址，默认 0.0.0.0
+	AdvertisedAddr  string             // 在 version/addr 消息中公布的地址，默认 localhost:<nodeID>
+	PayoutAddresses []PayoutAddress    // 按区块轮换的挖矿奖励地址，为空时只使用 minerAddress
+	MaxBlockTxs     int                // 每个区块最多打包的内存池交易数，默认 1000
+	StaleTipAge     time.Duration      // 超过该时长没有新区块时告警，默认 30 分钟
+	Auth            *security.NodeAuth // 节点身份，设置后应答 version 握手中的认证挑战
+	PeerConfigFile  string             // 节点管理器的配置文件，为空时使用默认种子节点
+}
+
 var (
 	// nodeAddress 当前节点地址
 	nodeAddress string
@@ -25,20 +58,252 @@ var (
 	blocksInTransit = [][]byte{}
 	// mempool 内存池
 	mempool = make(map[string]blockchain.Transaction)
+	// pendingData 记录已发出 getdata 但尚未收到的数据项及被请求的节点
+	pendingData = make(map[string]string)
+	// notFoundPeers 记录每个数据项已回复 notfound 的节点
+	notFoundPeers = make(map[string][]string)
+	// pendingDataMutex 保护 pendingData 和 notFoundPeers
+	pendingDataMutex sync.Mutex
+	// peerHeights 记录各节点在 version 消息中报告的最佳高度
+	peerHeights      = make(map[string]int)
+	peerHeightsMutex sync.RWMutex
+	// peerHandshakes 记录与各节点握手时协商的协议版本和对方公布的服务
+	peerHandshakes      = make(map[string]peerHandshake)
+	peerHandshakesMutex sync.RWMutex
+	// peerManager 设置后同步握手得到的节点高度和服务，并用于选择同步节点
+	peerManager      *peer.Manager
+	peerManagerMutex sync.RWMutex
+	// blacklist 设置后拒绝被封禁IP的连接，节点管理器在节点评分过低时向其中添加IP
+	blacklist      *security.BlacklistFilter
+	blacklistMutex sync.RWMutex
+	// connManager 设置后登记节点正在处理的连接，供 CLI 查询连接数和连接列表
+	connManager      *connection.Manager
+	connManagerMutex sync.RWMutex
 )
 
-// StartServer 启动服务器
+// peerHandshake 节点在 version 握手中协商的信息
+type peerHandshake struct {
+	version  int
+	services uint64
+}
+
+// setPeerHeight 记录节点报告的最佳高度
+func setPeerHeight(addr string, height int) {
+	peerHeightsMutex.Lock()
+	defer peerHeightsMutex.Unlock()
+
+	peerHeights[addr] = height
+}
+
+// GetPeerHeight 返回节点最近一次报告的最佳高度
+func GetPeerHeight(addr string) (int, bool) {
+	peerHeightsMutex.RLock()
+	defer peerHeightsMutex.RUnlock()
+
+	height, ok := peerHeights[addr]
+	return height, ok
+}
+
+// negotiateVersion 根据对方报告的协议版本协商双方使用的版本
+// 低于 minProtocolVersion 的节点被拒绝，更新的节点降级到本节点的版本
+func negotiateVersion(peerVersion int) (int, error) {
+	if peerVersion < minProtocolVersion {
+		return 0, fmt.Errorf("protocol version %d is not supported (minimum %d)", peerVersion, minProtocolVersion)
+	}
+	if peerVersion > protocolVersion {
+		return protocolVersion, nil
+	}
+
+	return peerVersion, nil
+}
+
+// setPeerHandshake 记录与节点协商后的协议版本和对方公布的服务
+func setPeerHandshake(addr string, version int, services uint64) {
+	peerHandshakesMutex.Lock()
+	defer peerHandshakesMutex.Unlock()
+
+	peerHandshakes[addr] = peerHandshake{version: version, services: services}
+}
+
+// GetPeerVersion 返回与节点协商后的协议版本
+func GetPeerVersion(addr string) (int, bool) {
+	peerHandshakesMutex.RLock()
+	defer peerHandshakesMutex.RUnlock()
+
+	handshake, ok := peerHandshakes[addr]
+	return handshake.version, ok
+}
+
+// SetPeerManager 设置节点管理器，为 nil 时只使用 version 握手的发送方作为同步节点
+func SetPeerManager(manager *peer.Manager) {
+	peerManagerMutex.Lock()
+	defer peerManagerMutex.Unlock()
+
+	peerManager = manager
+}
+
+// getPeerManager 返回当前的节点管理器
+func getPeerManager() *peer.Manager {
+	peerManagerMutex.RLock()
+	defer peerManagerMutex.RUnlock()
+
+	return peerManager
+}
+
+// SetBlacklist 设置拒绝连接的黑名单，为 nil 时接受所有连接
+func SetBlacklist(filter *security.BlacklistFilter) {
+	blacklistMutex.Lock()
+	defer blacklistMutex.Unlock()
+
+	blacklist = filter
+}
+
+// getBlacklist 返回当前的黑名单
+func getBlacklist() *security.BlacklistFilter {
+	blacklistMutex.RLock()
+	defer blacklistMutex.RUnlock()
+
+	return blacklist
+}
+
+// allowConn 检查连接的对端IP是否被封禁
+func allowConn(conn net.Conn) bool {
+	filter := getBlacklist()
+	return filter == nil || filter.ShouldAllow(context.Background(), conn.RemoteAddr().String(), "")
+}
+
+// penalizePeer 降低发送无效数据的节点的评分，评分过低时节点管理器封禁其IP
+func penalizePeer(addr string, delta int) {
+	if manager := getPeerManager(); manager != nil && manager.PenalizePeer(addr, delta) {
+		log.Printf("Banned peer %s for sending invalid data", addr)
+	}
+}
+
+// SetConnManager 设置连接管理器，为 nil 时不登记节点处理的连接
+func SetConnManager(manager *connection.Manager) {
+	connManagerMutex.Lock()
+	defer connManagerMutex.Unlock()
+
+	connManager = manager
+}
+
+// getConnManager 返回当前的连接管理器
+func getConnManager() *connection.Manager {
+	connManagerMutex.RLock()
+	defer connManagerMutex.RUnlock()
+
+	return connManager
+}
+
+// recordPeer 把节点在 version 握手中报告的高度和服务同步到节点管理器
+func recordPeer(addr string, height int, services uint64) {
+	manager := getPeerManager()
+	if manager == nil {
+		return
+	}
+
+	p := manager.GetPeer(addr)
+	if p == nil {
+		if p = peer.NewPeerFromAddress(addr); p == nil {
+			return
+		}
+		manager.AddPeer(p)
+	}
+	p.UpdateServices(services)
+	p.UpdateBestHeight(height)
+	p.UpdateLastSeen()
+}
+
+// selectSyncPeer 选择高度超过 bestHeight 的节点下载区块，只考虑提供完整区块服务的节点，没有合适节点时返回空字符串
+// 设置了节点管理器时选择其中高度最高的节点，否则使用握手的发送方
+func selectSyncPeer(sender string, services uint64, bestHeight int) string {
+	if manager := getPeerManager(); manager != nil {
+		if best := manager.GetHighestPeer(); best != nil && best.GetBestHeight() > bestHeight {
+			return best.GetFullAddress()
+		}
+		return ""
+	}
+
+	if services&peer.ServiceNodeNetwork == 0 {
+		return ""
+	}
+	return sender
+}
+
+// offersBlocks 判断节点是否可以提供完整区块，尚未握手的节点视为可以
+func offersBlocks(addr string) bool {
+	services, ok := GetPeerServices(addr)
+	return !ok || services&peer.ServiceNodeNetwork != 0
+}
+
+// GetPeerServices 返回节点在 version 消息中公布的服务标志
+func GetPeerServices(addr string) (uint64, bool) {
+	peerHandshakesMutex.RLock()
+	defer peerHandshakesMutex.RUnlock()
+
+	handshake, ok := peerHandshakes[addr]
+	return handshake.services, ok
+}
+
+// StartServer 使用默认监听配置启动服务器
 func StartServer(nodeID, minerAddress string) {
-	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
+	StartServerWithConfig(nodeID, minerAddress, nil)
+}
+
+// StartServerWithConfig 按指定监听配置启动服务器
+func StartServerWithConfig(nodeID, minerAddress string, config *ServerConfig) {
+	startTime = time.Now()
 	miningAddress = minerAddress
-	ln, err := net.Listen(protocol, nodeAddress)
+	if config != nil {
+		SetMaxBlockTransactions(config.MaxBlockTxs)
+		SetHandshakeAuth(config.Auth)
+	}
+	if config != nil && len(config.PayoutAddresses) > 0 {
+		if err := SetPayoutAddresses(config.PayoutAddresses); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	ln, err := listen(nodeID, config)
 	if err != nil {
 		log.Panic(err)
 	}
 	defer ln.Close()
 
+	peerConfigFile := ""
+	if config != nil {
+		peerConfigFile = config.PeerConfigFile
+	}
+	manager := peer.NewManager(peerConfigFile)
+	defer manager.Stop()
+	SetPeerManager(manager)
+
+	// 评分过低的节点IP加入黑名单，封禁期间拒绝其连接
+	filter := security.NewBlacklistFilter()
+	manager.SetBlacklist(filter, 0, 0)
+	SetBlacklist(filter)
+
+	connections := connection.NewManager(nil)
+	if err := connections.Start(); err != nil {
+		log.Panic(err)
+	}
+	defer connections.Stop()
+	SetConnManager(connections)
+
+	// 节点发现通过 getaddr 向已连接的节点请求节点列表
+	discovery := peer.NewDiscovery(manager)
+	discovery.SetPeerRequester(SendGetAddr)
+	discovery.Start()
+	defer discovery.Stop()
+
 	bc := blockchain.NewBlockchain(minerAddress, nodeID)
 
+	staleTipAge := defaultStaleTipAge
+	if config != nil && config.StaleTipAge > 0 {
+		staleTipAge = config.StaleTipAge
+	}
+	go watchStaleTip(bc, staleTipAge)
+
 	// 如果当前节点不是中心节点，则向中心节点发送版本信息
 	if nodeAddress != KnownNodes[0] {
 		sendVersion(KnownNodes[0], bc)
@@ -49,43 +314,88 @@ func StartServer(nodeID, minerAddress string) {
 		if err != nil {
 			log.Panic(err)
 		}
+		if !allowConn(conn) {
+			conn.Close()
+			continue
+		}
 		go handleConnection(conn, bc)
 	}
 }
 
+// watchStaleTip 定期检查链末端是否过期，在进入和离开过期状态时记录告警
+func watchStaleTip(bc *blockchain.Blockchain, maxAge time.Duration) {
+	ticker := time.NewTicker(staleTipCheckInterval)
+	defer ticker.Stop()
+
+	stale := false
+	for range ticker.C {
+		stale = checkStaleTip(bc, maxAge, stale)
+	}
+}
+
+// checkStaleTip 检查一次链末端是否过期，wasStale 为上次检查的结果，返回本次检查的结果
+func checkStaleTip(bc *blockchain.Blockchain, maxAge time.Duration, wasStale bool) bool {
+	stale := bc.IsTipStale(maxAge)
+	if stale && !wasStale {
+		log.Printf("ALERT: no new block for more than %v (height %d), node may be isolated or the network stalled", maxAge, bc.GetBestHeight())
+	} else if !stale && wasStale {
+		log.Printf("Chain tip is fresh again at height %d", bc.GetBestHeight())
+	}
+	return stale
+}
+
+// listen 按配置监听节点端口，并设置对外公布的节点地址
+func listen(nodeID string, config *ServerConfig) (net.Listener, error) {
+	if config == nil {
+		config = &ServerConfig{}
+	}
+
+	bindHost := config.BindHost
+	if bindHost == "" {
+		bindHost = defaultBindHost
+	}
+
+	nodeAddress = config.AdvertisedAddr
+	if nodeAddress == "" {
+		nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
+	}
+
+	return net.Listen(protocol, net.JoinHostPort(bindHost, nodeID))
+}
+
 // handleConnection 处理连接
 func handleConnection(conn net.Conn, bc *blockchain.Blockchain) {
+	defer conn.Close()
+	if manager := getConnManager(); manager != nil {
+		manager.Track(conn).Use()
+		defer manager.Untrack(conn)
+	}
+
 	request, err := io.ReadAll(conn)
 	if err != nil {
-		log.Panic(err)
+		log.Printf("Failed to read request: %v", err)
+		return
+	}
+	if len(request) < commandLength {
+		log.Printf("Malformed request of %d bytes", len(request))
+		return
 	}
 	command := BytesToCommand(request[:commandLength])
 	fmt.Printf("Received %s command\n", command)
 
-	switch command {
-	case "addr":
-		handleAddr(request)
-	case "block":
-		handleBlock(request, bc)
-	case "inv":
-		handleInv(request, bc)
-	case "getblocks":
-		handleGetBlocks(request, bc)
-	case "getdata":
-		handleGetData(request, bc)
-	case "tx":
-		handleTx(request, bc)
-	case "version":
-		handleVersion(request, bc)
-	default:
-		fmt.Println("Unknown command!")
+	err = dispatchCommand(conn, command, request, bc)
+	if err != nil {
+		log.Printf("Failed to handle %s command: %v", command, err)
 	}
-
-	conn.Close()
 }
 
 // sendData sends data to a node
 func sendData(addr string, data []byte) {
+	if isSelfAddress(addr) {
+		log.Printf("Skipping self-connection to %s", addr)
+		return
+	}
+
 	conn, err := net.Dial(protocol, addr)
 	if err != nil {
 		fmt.Printf("%s is not available\n", addr)
@@ -111,25 +421,47 @@ func sendData(addr string, data []byte) {
 
 // sendVersion 向中心节点发送版本信息
 func sendVersion(addr string, bc *blockchain.Blockchain) {
+	if err := sendVersionWithAuth(addr, bc, nil); err != nil {
+		log.Panic(err)
+	}
+}
+
+// sendVersionWithAuth 发送版本信息，challenge 非空且配置了节点身份时附带对挑战的签名应答
+func sendVersionWithAuth(addr string, bc *blockchain.Blockchain, challenge []byte) error {
 	bestHeight := bc.GetBestHeight()
 	payload := Version{
-		Version:    1,
+		Version:    protocolVersion,
 		BestHeight: bestHeight,
 		AddrFrom:   nodeAddress,
+		Services:   localServices,
+	}
+	if auth := getHandshakeAuth(); auth != nil && len(challenge) > 0 {
+		authMsg, err := auth.CreateAuthMessage(challenge)
+		if err != nil {
+			return fmt.Errorf("failed to answer version challenge: %v", err)
+		}
+		payload.Auth = authMsg
 	}
 	payloadBytes, err := GobEncode(payload)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
 	request := append(CommandToBytes("version"), payloadBytes...)
 	sendData(addr, request)
+	return nil
 }
 
 // SendAddr sends an address to the target node
 func SendAddr(address string) {
-	nodes := Addr{KnownNodes}
-	nodes.AddrList = append(nodes.AddrList, nodeAddress)
+	known := KnownNodes
+	if len(known) > maxAddrPerMessage-1 {
+		known = known[:maxAddrPerMessage-1]
+	}
+	nodes := Addr{AddrList: append(append([]string{}, known...), nodeAddress), AddrFrom: nodeAddress}
+	if err := signAddr(&nodes); err != nil {
+		log.Panic(err)
+	}
 	payload, err := GobEncode(nodes)
 	if err != nil {
 		log.Panic(err)
@@ -163,9 +495,9 @@ func SendInv(address, kind string, items [][]byte) {
 	sendData(address, request)
 }
 
-// SendGetBlocks sends a getblocks request to the target node
-func SendGetBlocks(address string) {
-	payload, err := GobEncode(GetBlocks{nodeAddress})
+// SendGetBlocks sends a getblocks request carrying a block locator to the target node
+func SendGetBlocks(address string, locator [][]byte) {
+	payload, err := GobEncode(GetBlocks{nodeAddress, locator})
 	if err != nil {
 		log.Panic(err)
 	}
@@ -182,9 +514,28 @@ func SendGetData(address, kind string, id []byte) {
 	}
 	request := append(CommandToBytes("getdata"), payload...)
 
+	pendingDataMutex.Lock()
+	pendingData[dataKey(kind, id)] = address
+	pendingDataMutex.Unlock()
+
 	sendData(address, request)
 }
 
+// dataKey 返回数据项在 pendingData 和 notFoundPeers 中的键
+func dataKey(kind string, id []byte) string {
+	return kind + ":" + string(id)
+}
+
+// receivedData 数据项已收到，不再等待回复
+func clearPendingData(kind string, id []byte) {
+	pendingDataMutex.Lock()
+	defer pendingDataMutex.Unlock()
+
+	key := dataKey(kind, id)
+	delete(pendingData, key)
+	delete(notFoundPeers, key)
+}
+
 // SendTx sends a transaction to the target node
 func SendTx(addr string, tnx *blockchain.Transaction) {
 	data := Tx{nodeAddress, tnx.Serialize()}
@@ -196,3 +547,25 @@ func SendTx(addr string, tnx *blockchain.Transaction) {
 
 	sendData(addr, request)
 }
+
+// SendResend asks the target node to rebroadcast its mempool
+func SendResend(address string) {
+	payload, err := GobEncode(Resend{nodeAddress})
+	if err != nil {
+		log.Panic(err)
+	}
+	request := append(CommandToBytes("resend"), payload...)
+
+	sendData(address, request)
+}
+
+// sendNotFound sends a notfound reply for an unsatisfiable getdata request
+func sendNotFound(address, kind string, id []byte) {
+	payload, err := GobEncode(NotFound{nodeAddress, kind, id})
+	if err != nil {
+		log.Panic(err)
+	}
+	request := append(CommandToBytes("notfound"), payload...)
+
+	sendData(address, request)
+}
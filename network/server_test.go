@@ -0,0 +1,271 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mini-coin-go/blockchain"
+)
+
+// TestListen_AdvertisedAddress 测试监听地址与公布地址分离
+func TestListen_AdvertisedAddress(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peerAddr, received := startTestPeer(t)
+
+	oldNodeAddress := nodeAddress
+	defer func() { nodeAddress = oldNodeAddress }()
+
+	advertised := "203.0.113.7:3000"
+	ln, err := listen("0", &ServerConfig{BindHost: "127.0.0.1", AdvertisedAddr: advertised})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	if !strings.HasPrefix(ln.Addr().String(), "127.0.0.1:") {
+		t.Errorf("Expected to bind to 127.0.0.1, got %s", ln.Addr())
+	}
+	if nodeAddress != advertised {
+		t.Errorf("Expected node address %s, got %s", advertised, nodeAddress)
+	}
+
+	sendVersion(peerAddr, bc)
+
+	request := waitForRequest(received, 2*time.Second)
+	if request == nil {
+		t.Fatal("Expected version request")
+	}
+
+	var payload Version
+	decodePayload(t, request, &payload)
+	if payload.AddrFrom != advertised {
+		t.Errorf("Expected version AddrFrom %s, got %s", advertised, payload.AddrFrom)
+	}
+}
+
+// TestListen_Defaults 测试默认监听配置
+func TestListen_Defaults(t *testing.T) {
+	oldNodeAddress := nodeAddress
+	defer func() { nodeAddress = oldNodeAddress }()
+
+	ln, err := listen("0", nil)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	if nodeAddress != "localhost:0" {
+		t.Errorf("Expected default node address localhost:0, got %s", nodeAddress)
+	}
+}
+
+// TestMineBlock_PayoutRotation 测试挖矿奖励按权重在多个地址间轮换
+func TestMineBlock_PayoutRotation(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	defer SetPayoutAddresses(nil)
+
+	first := blockchain.PubKeyHashToAddress(bytes.Repeat([]byte{0x01}, 20))
+	second := blockchain.PubKeyHashToAddress(bytes.Repeat([]byte{0x02}, 20))
+	err := SetPayoutAddresses([]PayoutAddress{
+		{Address: first, Weight: 2},
+		{Address: second},
+	})
+	if err != nil {
+		t.Fatalf("Failed to set payout addresses: %v", err)
+	}
+	if err := SetPayoutAddresses([]PayoutAddress{{Address: "invalid"}}); err == nil {
+		t.Fatal("Expected invalid payout address to be rejected")
+	}
+
+	expected := []string{first, first, second, first, first, second}
+	for i, address := range expected {
+		block, err := mineBlock(bc, nil)
+		if err != nil {
+			t.Fatalf("Block %d: failed to mine: %v", i, err)
+		}
+
+		coinbase := block.Transactions[0]
+		if !coinbase.IsCoinbase() {
+			t.Fatalf("Block %d: expected coinbase as first transaction", i)
+		}
+		if !coinbase.Vout[0].IsLockedWithKey(blockchain.AddressToPubKeyHash(address)) {
+			t.Errorf("Block %d: expected reward to %s", i, address)
+		}
+	}
+}
+
+// TestMineMempool_MaxBlockTransactions 测试每个区块最多打包设定数量的交易，其余留在内存池
+func TestMineMempool_MaxBlockTransactions(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	oldMempool, oldMiningAddress := mempool, miningAddress
+	defer func() { mempool, miningAddress = oldMempool, oldMiningAddress }()
+	mempool = make(map[string]blockchain.Transaction)
+	miningAddress = testAddress
+
+	SetMaxBlockTransactions(3)
+	defer SetMaxBlockTransactions(0)
+
+	// 五笔依次花费上一笔输出的交易，每笔支付 1 手续费
+	genesis, err := bc.GetBlock(bc.GetBlockHashes()[0])
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	prev := genesis.Transactions[0]
+	for i := 0; i < 5; i++ {
+		out, err := blockchain.NewTXOutput(prev.Vout[0].Value-1, testAddress)
+		if err != nil {
+			t.Fatalf("Failed to create output: %v", err)
+		}
+		tx := &blockchain.Transaction{
+			Vin:  []blockchain.TXInput{{Txid: prev.ID, Vout: 0, ScriptSig: testAddress}},
+			Vout: []blockchain.TXOutput{*out},
+		}
+		tx.ID = tx.Hash()
+		signTestTx(tx, prev)
+		mempool[string(tx.ID)] = *tx
+		prev = tx
+	}
+
+	block, err := mineMempool(bc)
+	if err != nil {
+		t.Fatalf("Failed to mine mempool: %v", err)
+	}
+	if block == nil {
+		t.Fatal("Expected a block to be mined")
+	}
+	if len(block.Transactions) != 3+1 {
+		t.Errorf("Expected 3 transactions plus coinbase, got %d", len(block.Transactions))
+	}
+	if len(mempool) != 2 {
+		t.Errorf("Expected 2 transactions to remain pending, got %d", len(mempool))
+	}
+	for _, tx := range block.Transactions[1:] {
+		if _, ok := mempool[string(tx.ID)]; ok {
+			t.Errorf("Mined transaction %x is still in the mempool", tx.ID)
+		}
+	}
+}
+
+// TestMineMempool_ChainedTransactions 测试内存池中的子交易排在父交易之后一起打包
+func TestMineMempool_ChainedTransactions(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	oldMempool, oldMiningAddress := mempool, miningAddress
+	defer func() { mempool, miningAddress = oldMempool, oldMiningAddress }()
+	mempool = make(map[string]blockchain.Transaction)
+	miningAddress = testAddress
+
+	output := func(value int) blockchain.TXOutput {
+		out, err := blockchain.NewTXOutput(value, testAddress)
+		if err != nil {
+			t.Fatalf("Failed to create output: %v", err)
+		}
+		return *out
+	}
+
+	genesis, _ := bc.GetBlock(bc.GetBlockHashes()[0])
+	parent := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0}},
+		Vout: []blockchain.TXOutput{output(90)},
+	}
+	parent.ID = parent.Hash()
+	signTestTx(parent, genesis.Transactions[0])
+
+	// 让子交易的 ID 排在父交易之前，确保需要多轮选取
+	var child *blockchain.Transaction
+	for value := 90; value > 0; value-- {
+		child = &blockchain.Transaction{
+			Vin:  []blockchain.TXInput{{Txid: parent.ID, Vout: 0}},
+			Vout: []blockchain.TXOutput{output(value)},
+		}
+		child.ID = child.Hash()
+		signTestTx(child, parent)
+		if string(child.ID) < string(parent.ID) {
+			break
+		}
+	}
+	mempool[string(parent.ID)] = *parent
+	mempool[string(child.ID)] = *child
+
+	block, err := mineMempool(bc)
+	if err != nil {
+		t.Fatalf("Failed to mine mempool: %v", err)
+	}
+	if block == nil {
+		t.Fatal("Expected a block to be mined")
+	}
+	if len(block.Transactions) != 3 {
+		t.Fatalf("Expected coinbase, parent and child in the block, got %d transactions", len(block.Transactions))
+	}
+	if !bytes.Equal(block.Transactions[1].ID, parent.ID) || !bytes.Equal(block.Transactions[2].ID, child.ID) {
+		t.Error("Expected parent to be packed before its child")
+	}
+	if len(mempool) != 0 {
+		t.Errorf("Expected empty mempool, got %d transactions", len(mempool))
+	}
+}
+
+// deliverRequest 通过内存连接把请求交给 handleConnection 处理
+func deliverRequest(t *testing.T, request []byte, bc *blockchain.Blockchain) {
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		handleConnection(server, bc)
+		close(done)
+	}()
+
+	if _, err := client.Write(request); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the request to be handled")
+	}
+}
+
+// TestRegisterCommand 测试注册的命令处理函数被分发，未注册的命令交给回退处理函数
+func TestRegisterCommand(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	var custom, unknown []string
+	if err := RegisterCommand("custom", func(request []byte, _ *blockchain.Blockchain) error {
+		custom = append(custom, string(request[commandLength:]))
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to register command: %v", err)
+	}
+	SetUnknownCommandHandler(func(request []byte, _ *blockchain.Blockchain) error {
+		unknown = append(unknown, BytesToCommand(request[:commandLength]))
+		return nil
+	})
+	defer func() {
+		commandHandlersMutex.Lock()
+		delete(commandHandlers, "custom")
+		commandHandlersMutex.Unlock()
+		SetUnknownCommandHandler(nil)
+	}()
+
+	deliverRequest(t, append(CommandToBytes("custom"), []byte("payload")...), bc)
+	deliverRequest(t, CommandToBytes("mystery"), bc)
+
+	if len(custom) != 1 || custom[0] != "payload" {
+		t.Errorf("Expected custom handler to receive the payload once, got %v", custom)
+	}
+	if len(unknown) != 1 || unknown[0] != "mystery" {
+		t.Errorf("Expected fallback handler to receive the unknown command once, got %v", unknown)
+	}
+
+	for _, name := range []string{"", "averyverylongcommand"} {
+		if err := RegisterCommand(name, func([]byte, *blockchain.Blockchain) error { return nil }); err == nil {
+			t.Errorf("Expected command name %q to be rejected", name)
+		}
+	}
+}
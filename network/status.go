@@ -0,0 +1,108 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/connection"
+	"mini-coin-go/network/message"
+	"mini-coin-go/network/peer"
+	netsync "mini-coin-go/network/sync"
+)
+
+// startTime 节点启动时间，用于计算运行时长
+var startTime = time.Now()
+
+// StatusSources 节点状态报告的数据来源，未启用的子系统可以为 nil
+type StatusSources struct {
+	Blockchain  *blockchain.Blockchain
+	PeerManager *peer.Manager
+	ConnManager *connection.Manager
+	MsgHandler  *message.Handler
+	TxSyncer    *netsync.TransactionSyncer
+	BlockSyncer *netsync.BlockSyncer
+}
+
+// BuildStatusReport 汇总区块链、内存池、节点、连接池和消息处理器的状态
+// 未提供交易同步器或节点管理器时，使用旧版网络的内存池和已知节点列表
+func BuildStatusReport(sources *StatusSources) map[string]interface{} {
+	if sources == nil {
+		sources = &StatusSources{}
+	}
+
+	uptime := time.Since(startTime)
+	report := map[string]interface{}{
+		"uptime":         uptime.Round(time.Second).String(),
+		"uptime_seconds": int64(uptime.Seconds()),
+		"height":         -1,
+		"mempool_size":   len(mempool),
+		"peer_count":     len(KnownNodes),
+	}
+
+	if sources.Blockchain != nil {
+		report["height"] = sources.Blockchain.GetBestHeight()
+	}
+	if sources.PeerManager != nil {
+		peerStats := sources.PeerManager.GetStats()
+		report["peer_count"] = peerStats["total_peers"]
+		report["peers"] = peerStats
+	}
+	if sources.ConnManager != nil {
+		report["connections"] = sources.ConnManager.GetManagerStats()
+	}
+	if sources.MsgHandler != nil {
+		report["message_handler"] = sources.MsgHandler.GetTotalStats()
+	}
+	if sources.TxSyncer != nil {
+		txInfo := sources.TxSyncer.GetSyncInfo()
+		report["mempool_size"] = txInfo["mempool_size"]
+		report["tx_sync"] = txInfo
+	}
+	if sources.BlockSyncer != nil {
+		report["block_sync"] = sources.BlockSyncer.GetSyncProgress()
+	}
+
+	return report
+}
+
+// handleGetStatus 在请求连接上回复运行中节点的状态报告，数据来自节点运行中的节点管理器和连接管理器
+func handleGetStatus(conn net.Conn, request []byte, bc *blockchain.Blockchain) error {
+	// 查询本身的连接不计入连接统计
+	connManager := getConnManager()
+	if connManager != nil {
+		connManager.Untrack(conn)
+	}
+
+	sources := &StatusSources{
+		Blockchain:  bc,
+		PeerManager: getPeerManager(),
+		ConnManager: connManager,
+	}
+	data, err := json.Marshal(BuildStatusReport(sources))
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(append(CommandToBytes("status"), data...))
+	return err
+}
+
+// QueryStatus 向本机运行中的节点查询状态报告
+func QueryStatus(address string, timeout time.Duration) (map[string]interface{}, error) {
+	reply, err := requestReply(address, CommandToBytes("getstatus"), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if command := BytesToCommand(reply[:commandLength]); command != "status" {
+		return nil, fmt.Errorf("unexpected reply %s to getstatus", command)
+	}
+
+	var report map[string]interface{}
+	if err := json.Unmarshal(reply[commandLength:], &report); err != nil {
+		return nil, fmt.Errorf("failed to decode status report: %v", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,107 @@
+package network
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mini-coin-go/network/connection"
+	"mini-coin-go/network/message"
+	"mini-coin-go/network/peer"
+	netsync "mini-coin-go/network/sync"
+)
+
+// TestBuildStatusReport 测试状态报告汇总各子系统的信息
+func TestBuildStatusReport(t *testing.T) {
+	bc := newTestBlockchain(t)
+
+	connManager := connection.NewManager(nil)
+	msgHandler := message.NewHandler(1)
+	sources := &StatusSources{
+		Blockchain:  bc,
+		PeerManager: peer.NewManager(""),
+		ConnManager: connManager,
+		MsgHandler:  msgHandler,
+		TxSyncer:    netsync.NewTransactionSyncer(bc, connManager, msgHandler, 0),
+	}
+
+	report := BuildStatusReport(sources)
+	for _, key := range []string{"height", "peer_count", "mempool_size", "uptime", "connections", "message_handler", "tx_sync"} {
+		if _, ok := report[key]; !ok {
+			t.Errorf("Expected report to include %s", key)
+		}
+	}
+	if report["height"] != bc.GetBestHeight() {
+		t.Errorf("Expected height %d, got %v", bc.GetBestHeight(), report["height"])
+	}
+	if report["mempool_size"] != 0 {
+		t.Errorf("Expected empty mempool, got %v", report["mempool_size"])
+	}
+	if peers := sources.PeerManager.GetStats()["total_peers"]; report["peer_count"] != peers {
+		t.Errorf("Expected peer count %v, got %v", peers, report["peer_count"])
+	}
+
+	if _, err := json.Marshal(report); err != nil {
+		t.Errorf("Expected report to be JSON encodable: %v", err)
+	}
+
+	// 未提供子系统时使用旧版网络的状态
+	legacy := BuildStatusReport(nil)
+	if legacy["height"] != -1 || legacy["peer_count"] != len(KnownNodes) {
+		t.Errorf("Unexpected legacy report: %v", legacy)
+	}
+}
+
+// TestQueryStatus 测试 CLI 通过本机连接查询运行中节点的状态，非本机请求被拒绝
+func TestQueryStatus(t *testing.T) {
+	bc := newTestBlockchain(t)
+	address := startTestNode(t, bc)
+
+	report, err := QueryStatus(address, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to query status: %v", err)
+	}
+	if report["height"] != float64(bc.GetBestHeight()) {
+		t.Errorf("Expected height %d, got %v", bc.GetBestHeight(), report["height"])
+	}
+	for _, key := range []string{"peer_count", "mempool_size", "uptime"} {
+		if _, ok := report[key]; !ok {
+			t.Errorf("Expected report to include %s", key)
+		}
+	}
+
+	// 运行中的节点管理器和连接管理器提供节点数和连接统计
+	manager := peer.NewManager("non_existent_config.json")
+	defer manager.Stop()
+	manager.AddPeer(peer.NewPeerFromAddress("127.0.0.1:4001"))
+	manager.AddPeer(peer.NewPeerFromAddress("127.0.0.1:4002"))
+	SetPeerManager(manager)
+	defer SetPeerManager(nil)
+	defer SetConnManager(getConnManager())
+	SetConnManager(connection.NewManager(nil))
+
+	report, err = QueryStatus(address, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to query status: %v", err)
+	}
+	if peers := manager.GetStats()["total_peers"]; report["peer_count"] != float64(peers.(int)) {
+		t.Errorf("Expected peer count %v from the peer manager, got %v", peers, report["peer_count"])
+	}
+	connections, ok := report["connections"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected report to include connection statistics, got %v", report["connections"])
+	}
+	if connections["total_connections"] != float64(0) {
+		t.Errorf("Expected the status query itself not to count as a connection, got %v", connections["total_connections"])
+	}
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	err = dispatchCommand(server, "getstatus", CommandToBytes("getstatus"), bc)
+	if err == nil || !strings.Contains(err.Error(), "non-local") {
+		t.Errorf("Expected a non-local status request to be rejected, got: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"time"
+
+	"mini-coin-go/blockchain"
+)
+
+// AdmissionRecord 一次交易进入内存池尝试的审计记录
+type AdmissionRecord struct {
+	Time   time.Time    // 尝试时间
+	TxID   []byte       // 交易ID
+	Result AcceptResult // 处理结果
+	Reason string       // 被拒绝的原因，接受时为空
+}
+
+// SetAdmissionLogSize 设置内存池准入审计日志保留的最大记录数，小于等于 0 时关闭审计日志
+func (ts *TransactionSyncer) SetAdmissionLogSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+
+	ts.admissionMutex.Lock()
+	defer ts.admissionMutex.Unlock()
+
+	ts.maxAdmissions = size
+	if len(ts.admissions) > size {
+		ts.admissions = append([]AdmissionRecord(nil), ts.admissions[len(ts.admissions)-size:]...)
+	}
+}
+
+// recordAdmission 记录一次准入尝试，超出上限时丢弃最旧的记录
+func (ts *TransactionSyncer) recordAdmission(tx *blockchain.Transaction, result AcceptResult, err error) {
+	ts.admissionMutex.Lock()
+	defer ts.admissionMutex.Unlock()
+
+	if ts.maxAdmissions == 0 {
+		return
+	}
+
+	record := AdmissionRecord{Time: time.Now(), TxID: tx.ID, Result: result}
+	if err != nil {
+		record.Reason = err.Error()
+	}
+
+	ts.admissions = append(ts.admissions, record)
+	if len(ts.admissions) > ts.maxAdmissions {
+		ts.admissions = ts.admissions[len(ts.admissions)-ts.maxAdmissions:]
+	}
+}
+
+// GetRecentAdmissions 返回最近的 n 条准入记录，最新的在前；n 小于等于 0 时返回全部记录
+func (ts *TransactionSyncer) GetRecentAdmissions(n int) []AdmissionRecord {
+	ts.admissionMutex.RLock()
+	defer ts.admissionMutex.RUnlock()
+
+	if n <= 0 || n > len(ts.admissions) {
+		n = len(ts.admissions)
+	}
+
+	records := make([]AdmissionRecord, 0, n)
+	for i := len(ts.admissions) - 1; i >= len(ts.admissions)-n; i-- {
+		records = append(records, ts.admissions[i])
+	}
+
+	return records
+}
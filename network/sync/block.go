@@ -1,7 +1,9 @@
 package sync
 
 import (
+	"bytes"
 	"context"
+	"encoding/gob"
 	"fmt"
 	"log"
 	"sync"
@@ -18,11 +20,17 @@ type BlockSyncer struct {
 	connManager   *connection.Manager
 	msgHandler    *message.Handler
 	maxWorkers    int
+	maxRetries    int
 	isRunning     bool
 	stopCh        chan bool
 	mutex         sync.RWMutex
 	downloadQueue chan *BlockDownloadTask
 	stats         *SyncStats
+	onConnected   []func(*blockchain.Block)     // 区块接入本地链时的回调
+	failedBlocks  map[string]*BlockDownloadTask // 超过重试次数的下载任务
+	orphanBlocks  map[string]*blockchain.Block  // 父区块未知的孤儿区块
+	orphanOrder   []string                      // 孤儿区块按加入顺序排列，用于淘汰最旧的条目
+	maxOrphans    int                           // 孤儿区块池容量
 }
 
 // BlockDownloadTask 区块下载任务
@@ -34,6 +42,16 @@ type BlockDownloadTask struct {
 	CreatedAt time.Time
 }
 
+// GetBlocksRequest getblocks 消息负载，对方据此只返回分叉点之后的区块
+type GetBlocksRequest struct {
+	Locator [][]byte // 请求方主链的区块定位器
+}
+
+// BlockInventory inv 消息负载
+type BlockInventory struct {
+	Hashes [][]byte // 区块哈希，旧区块在前
+}
+
 // SyncStats 同步统计信息
 type SyncStats struct {
 	TotalBlocks      int64         // 总区块数
@@ -48,20 +66,27 @@ type SyncStats struct {
 
 // NewBlockSyncer 创建区块同步器
 func NewBlockSyncer(bc *blockchain.Blockchain, connManager *connection.Manager,
-	msgHandler *message.Handler, maxWorkers int) *BlockSyncer {
+	msgHandler *message.Handler, maxWorkers, maxRetries int) *BlockSyncer {
 
 	if maxWorkers <= 0 {
 		maxWorkers = 10
 	}
+	if maxRetries <= 0 {
+		maxRetries = 3
+	}
 
 	syncer := &BlockSyncer{
 		blockchain:    bc,
 		connManager:   connManager,
 		msgHandler:    msgHandler,
 		maxWorkers:    maxWorkers,
+		maxRetries:    maxRetries,
 		stopCh:        make(chan bool),
 		downloadQueue: make(chan *BlockDownloadTask, 1000),
 		stats:         &SyncStats{StartTime: time.Now()},
+		failedBlocks:  make(map[string]*BlockDownloadTask),
+		orphanBlocks:  make(map[string]*blockchain.Block),
+		maxOrphans:    100,
 	}
 
 	// 注册消息处理器
@@ -110,6 +135,7 @@ func (bs *BlockSyncer) Stop() error {
 func (bs *BlockSyncer) registerHandlers() {
 	bs.msgHandler.RegisterHandler("block", bs.handleBlockMessage)
 	bs.msgHandler.RegisterHandler("inv", bs.handleInvMessage)
+	bs.msgHandler.RegisterHandler("getblocks", bs.handleGetBlocksMessage)
 	bs.msgHandler.RegisterHandler("getdata", bs.handleGetDataMessage)
 }
 
@@ -121,11 +147,8 @@ func (bs *BlockSyncer) SyncFromPeer(peerAddr string) error {
 
 	log.Printf("开始从节点同步区块: %s", peerAddr)
 
-	// 获取本地最佳高度
-	localHeight := bs.blockchain.GetBestHeight()
-
-	// 请求节点的区块列表
-	return bs.requestBlocksFromPeer(peerAddr, localHeight)
+	// 携带本地区块定位器请求节点的区块列表
+	return bs.requestBlocksFromPeer(peerAddr)
 }
 
 // SyncFromMultiplePeers 从多个节点并行同步
@@ -168,28 +191,75 @@ func (bs *BlockSyncer) SyncFromMultiplePeers(peerAddrs []string) error {
 	return nil
 }
 
-// requestBlocksFromPeer 从节点请求区块
-func (bs *BlockSyncer) requestBlocksFromPeer(peerAddr string, fromHeight int) error {
-	// 创建获取区块请求消息
-	payload := fmt.Sprintf(`{"from_height": %d}`, fromHeight)
-	msg := message.NewMessage("getblocks", []byte(payload), peerAddr)
-	msg.Priority = message.PriorityHigh
+// requestBlocksFromPeer 向节点发送带本地区块定位器的 getblocks 请求
+func (bs *BlockSyncer) requestBlocksFromPeer(peerAddr string) error {
+	msg, err := bs.newGetBlocksMessage(peerAddr)
+	if err != nil {
+		return err
+	}
 
 	return bs.msgHandler.Submit(msg)
 }
 
-// handleInvMessage 处理库存消息
+// newGetBlocksMessage 创建携带本地区块定位器的 getblocks 消息
+func (bs *BlockSyncer) newGetBlocksMessage(peerAddr string) (*message.Message, error) {
+	payload, err := encodePayload(GetBlocksRequest{Locator: bs.blockchain.GetBlockLocator()})
+	if err != nil {
+		return nil, fmt.Errorf("编码 getblocks 请求失败: %v", err)
+	}
+
+	msg := message.NewMessage("getblocks", payload, peerAddr)
+	msg.Priority = message.PriorityHigh
+
+	return msg, nil
+}
+
+// handleGetBlocksMessage 处理 getblocks 消息，只返回请求方定位器分叉点之后的区块哈希
+func (bs *BlockSyncer) handleGetBlocksMessage(msg *message.Message) error {
+	var request GetBlocksRequest
+	if err := decodePayload(msg.Payload, &request); err != nil {
+		return fmt.Errorf("解析 getblocks 请求失败: %v", err)
+	}
+
+	// 定位器为空或没有共同区块时返回整条链
+	hashes := bs.blockchain.GetBlockHashesAfterLocator(request.Locator)
+	if len(hashes) == 0 {
+		log.Printf("节点 %s 已同步到本地链尖", msg.TargetAddr)
+		return nil
+	}
+
+	// 调整为旧区块在前，方便对方按顺序接入
+	for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+
+	payload, err := encodePayload(BlockInventory{Hashes: hashes})
+	if err != nil {
+		return fmt.Errorf("编码库存消息失败: %v", err)
+	}
+
+	inv := message.NewMessage("inv", payload, msg.TargetAddr)
+	inv.Priority = message.PriorityHigh
+
+	return bs.msgHandler.Submit(inv)
+}
+
+// handleInvMessage 处理库存消息，只为本地缺少的区块创建下载任务
 func (bs *BlockSyncer) handleInvMessage(msg *message.Message) error {
 	log.Printf("收到库存消息从 %s", msg.TargetAddr)
 
 	// 解析库存消息，获取区块哈希列表
 	blockHashes := bs.parseInvMessage(msg.Payload)
+	missing := bs.missingBlocks(blockHashes)
+
+	bs.stats.mutex.Lock()
+	bs.stats.TotalBlocks += int64(len(missing))
+	bs.stats.mutex.Unlock()
 
-	// 为每个区块创建下载任务
-	for i, hash := range blockHashes {
+	// 为每个缺少的区块创建下载任务
+	for _, hash := range missing {
 		task := &BlockDownloadTask{
 			Hash:      hash,
-			Height:    i + 1, // 这里应该从消息中获取实际高度
 			PeerAddr:  msg.TargetAddr,
 			Retries:   0,
 			CreatedAt: time.Now(),
@@ -206,6 +276,27 @@ func (bs *BlockSyncer) handleInvMessage(msg *message.Message) error {
 	return nil
 }
 
+// missingBlocks 返回本地既不在链上也不在孤儿池中的区块哈希，保持原有顺序
+func (bs *BlockSyncer) missingBlocks(hashes [][]byte) [][]byte {
+	var missing [][]byte
+	for _, hash := range hashes {
+		if _, err := bs.blockchain.GetBlock(hash); err == nil {
+			continue
+		}
+
+		bs.mutex.RLock()
+		_, orphan := bs.orphanBlocks[fmt.Sprintf("%x", hash)]
+		bs.mutex.RUnlock()
+		if orphan {
+			continue
+		}
+
+		missing = append(missing, hash)
+	}
+
+	return missing
+}
+
 // handleBlockMessage 处理区块消息
 func (bs *BlockSyncer) handleBlockMessage(msg *message.Message) error {
 	start := time.Now()
@@ -215,23 +306,165 @@ func (bs *BlockSyncer) handleBlockMessage(msg *message.Message) error {
 	// 反序列化区块
 	block := blockchain.DeserializeBlock(msg.Payload)
 
-	// 验证区块
-	if !bs.validateBlock(block) {
-		return fmt.Errorf("区块验证失败: %x", block.Hash)
+	// 父区块未知时放入孤儿池，等待父区块到达
+	if bs.isOrphanBlock(block) {
+		bs.addOrphanBlock(block)
+		return nil
 	}
 
-	// 添加到区块链
-	if err := bs.blockchain.AddBlock(block); err != nil {
-		return fmt.Errorf("添加区块失败: %v", err)
+	if err := bs.connectBlock(block); err == blockchain.ErrBlockExists {
+		log.Printf("区块已存在，跳过: %x", block.Hash)
+		return nil
+	} else if err != nil {
+		return err
 	}
 
 	// 更新统计信息
 	bs.updateStats(time.Since(start))
 
 	log.Printf("成功添加区块: %x", block.Hash)
+
+	// 接入以该区块为父区块的孤儿区块
+	bs.promoteOrphanBlocks(block.Hash)
 	return nil
 }
 
+// connectBlock 验证区块并接入本地链
+func (bs *BlockSyncer) connectBlock(block *blockchain.Block) error {
+	// 验证区块
+	if err := bs.validateBlock(block); err != nil {
+		return err
+	}
+
+	// 添加到区块链
+	if err := bs.blockchain.AddBlock(block); err == blockchain.ErrBlockExists {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("添加区块失败: %v", err)
+	}
+	bs.notifyBlockConnected(block)
+
+	return nil
+}
+
+// isOrphanBlock 检查区块的父区块是否未知
+func (bs *BlockSyncer) isOrphanBlock(block *blockchain.Block) bool {
+	if len(block.PrevBlockHash) == 0 {
+		return false
+	}
+
+	_, err := bs.blockchain.GetBlock(block.PrevBlockHash)
+	return err != nil
+}
+
+// addOrphanBlock 将区块放入孤儿池，池满时淘汰最旧的孤儿区块
+func (bs *BlockSyncer) addOrphanBlock(block *blockchain.Block) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	key := fmt.Sprintf("%x", block.Hash)
+	if _, exists := bs.orphanBlocks[key]; exists {
+		return
+	}
+
+	for len(bs.orphanBlocks) >= bs.maxOrphans && len(bs.orphanOrder) > 0 {
+		oldest := bs.orphanOrder[0]
+		bs.removeOrphanBlock(oldest)
+		log.Printf("孤儿区块池已满，淘汰最旧的区块: %s", oldest)
+	}
+
+	bs.orphanBlocks[key] = block
+	bs.orphanOrder = append(bs.orphanOrder, key)
+
+	log.Printf("父区块未知，加入孤儿区块池: %x", block.Hash)
+}
+
+// removeOrphanBlock 从孤儿池移除区块，调用方需持有锁
+func (bs *BlockSyncer) removeOrphanBlock(key string) {
+	delete(bs.orphanBlocks, key)
+
+	for i, k := range bs.orphanOrder {
+		if k == key {
+			bs.orphanOrder = append(bs.orphanOrder[:i], bs.orphanOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// promoteOrphanBlocks 父区块接入后依次接入等待它的孤儿区块
+func (bs *BlockSyncer) promoteOrphanBlocks(parentHash []byte) {
+	queue := [][]byte{parentHash}
+
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		bs.mutex.Lock()
+		var children []*blockchain.Block
+		for _, key := range append([]string(nil), bs.orphanOrder...) {
+			orphan := bs.orphanBlocks[key]
+			if bytes.Equal(orphan.PrevBlockHash, parent) {
+				children = append(children, orphan)
+				bs.removeOrphanBlock(key)
+			}
+		}
+		bs.mutex.Unlock()
+
+		for _, child := range children {
+			if err := bs.connectBlock(child); err != nil && err != blockchain.ErrBlockExists {
+				log.Printf("孤儿区块接入失败 %x: %v", child.Hash, err)
+				continue
+			}
+
+			log.Printf("孤儿区块已接入: %x", child.Hash)
+			queue = append(queue, child.Hash)
+		}
+	}
+}
+
+// SetMaxOrphanBlocks 设置孤儿区块池容量
+func (bs *BlockSyncer) SetMaxOrphanBlocks(maxOrphans int) {
+	if maxOrphans <= 0 {
+		maxOrphans = 100
+	}
+
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	bs.maxOrphans = maxOrphans
+	for len(bs.orphanBlocks) > bs.maxOrphans && len(bs.orphanOrder) > 0 {
+		bs.removeOrphanBlock(bs.orphanOrder[0])
+	}
+}
+
+// GetOrphanBlockCount 获取孤儿区块数量
+func (bs *BlockSyncer) GetOrphanBlockCount() int {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	return len(bs.orphanBlocks)
+}
+
+// OnBlockConnected 注册区块接入本地链时的回调
+func (bs *BlockSyncer) OnBlockConnected(callback func(*blockchain.Block)) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	bs.onConnected = append(bs.onConnected, callback)
+}
+
+// notifyBlockConnected 通知所有已注册的回调
+func (bs *BlockSyncer) notifyBlockConnected(block *blockchain.Block) {
+	bs.mutex.RLock()
+	callbacks := make([]func(*blockchain.Block), len(bs.onConnected))
+	copy(callbacks, bs.onConnected)
+	bs.mutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(block)
+	}
+}
+
 // handleGetDataMessage 处理获取数据消息
 func (bs *BlockSyncer) handleGetDataMessage(msg *message.Message) error {
 	log.Printf("收到获取数据消息从 %s", msg.TargetAddr)
@@ -278,15 +511,17 @@ func (bs *BlockSyncer) processDownloadTask(task *BlockDownloadTask, workerID int
 		log.Printf("工作协程 %d 下载区块失败 %x: %v", workerID, task.Hash, err)
 
 		// 重试逻辑
-		if task.Retries < 3 {
+		if task.Retries < bs.maxRetries {
 			task.Retries++
 			select {
 			case bs.downloadQueue <- task:
 				// 重新加入队列
+				return
 			default:
 				log.Printf("下载队列已满，放弃重试: %x", task.Hash)
 			}
 		}
+		bs.markBlockFailed(task)
 		return
 	}
 
@@ -299,24 +534,101 @@ func (bs *BlockSyncer) processDownloadTask(task *BlockDownloadTask, workerID int
 	}
 }
 
-// validateBlock 验证区块
-func (bs *BlockSyncer) validateBlock(block *blockchain.Block) bool {
-	// 这里应该实现完整的区块验证逻辑
-	// 包括：工作量证明验证、交易验证、前一个区块哈希验证等
+// markBlockFailed 将下载任务移入失败集合
+func (bs *BlockSyncer) markBlockFailed(task *BlockDownloadTask) {
+	bs.mutex.Lock()
+	bs.failedBlocks[fmt.Sprintf("%x", task.Hash)] = task
+	bs.mutex.Unlock()
+
+	bs.stats.mutex.Lock()
+	bs.stats.FailedBlocks++
+	bs.stats.mutex.Unlock()
 
-	// 简单验证：检查区块是否为空
-	return block != nil && len(block.Hash) > 0
+	log.Printf("区块下载失败次数超过上限，已移入失败集合: %x", task.Hash)
 }
 
-// parseInvMessage 解析库存消息
-func (bs *BlockSyncer) parseInvMessage(payload []byte) [][]byte {
-	// 这里应该解析实际的库存消息格式
-	// 暂时返回模拟数据
-	var hashes [][]byte
-	// 模拟解析逻辑...
+// GetFailedBlocks 获取下载失败的区块哈希列表
+func (bs *BlockSyncer) GetFailedBlocks() [][]byte {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	hashes := make([][]byte, 0, len(bs.failedBlocks))
+	for _, task := range bs.failedBlocks {
+		hashes = append(hashes, task.Hash)
+	}
+
 	return hashes
 }
 
+// RetryFailedBlock 从指定节点重新请求失败的区块
+func (bs *BlockSyncer) RetryFailedBlock(hash []byte, peerAddr string) error {
+	key := fmt.Sprintf("%x", hash)
+
+	bs.mutex.Lock()
+	task, exists := bs.failedBlocks[key]
+	if exists {
+		delete(bs.failedBlocks, key)
+	}
+	bs.mutex.Unlock()
+
+	if !exists {
+		return fmt.Errorf("区块不在失败集合中: %s", key)
+	}
+
+	task.PeerAddr = peerAddr
+	task.Retries = 0
+	task.CreatedAt = time.Now()
+
+	select {
+	case bs.downloadQueue <- task:
+		return nil
+	default:
+		bs.mutex.Lock()
+		bs.failedBlocks[key] = task
+		bs.mutex.Unlock()
+		return fmt.Errorf("下载队列已满: %s", key)
+	}
+}
+
+// validateBlock 验证区块，包括工作量证明、高度连续性和交易验证
+func (bs *BlockSyncer) validateBlock(block *blockchain.Block) error {
+	if block == nil {
+		return fmt.Errorf("区块验证失败: 区块为空")
+	}
+
+	if err := bs.blockchain.ValidateBlock(block); err != nil {
+		return fmt.Errorf("区块验证失败 %x: %v", block.Hash, err)
+	}
+
+	return nil
+}
+
+// parseInvMessage 解析库存消息，格式错误时返回空列表
+func (bs *BlockSyncer) parseInvMessage(payload []byte) [][]byte {
+	var inv BlockInventory
+	if err := decodePayload(payload, &inv); err != nil {
+		log.Printf("解析库存消息失败: %v", err)
+		return nil
+	}
+
+	return inv.Hashes
+}
+
+// encodePayload 使用 gob 编码消息负载
+func encodePayload(data interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(data); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+// decodePayload 使用 gob 解码消息负载
+func decodePayload(payload []byte, data interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(payload)).Decode(data)
+}
+
 // parseGetDataMessage 解析获取数据消息
 func (bs *BlockSyncer) parseGetDataMessage(payload []byte) (string, []byte) {
 	// 这里应该解析实际的获取数据消息格式
@@ -398,6 +710,8 @@ func (bs *BlockSyncer) GetSyncProgress() map[string]interface{} {
 		"download_speed":     stats.DownloadSpeed,
 		"average_block_time": stats.AverageBlockTime.String(),
 		"queue_size":         len(bs.downloadQueue),
+		"failed_set_size":    len(bs.GetFailedBlocks()),
+		"orphan_blocks":      bs.GetOrphanBlockCount(),
 	}
 }
 
@@ -0,0 +1,408 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/message"
+
+	"go.etcd.io/bbolt"
+)
+
+const testNodeID = "test_sync"
+
+// testKey 测试链挖矿奖励地址的私钥，用于对测试交易签名
+var testKey, testAddress = newTestKey()
+
+// newTestKey 生成测试用私钥及其地址
+func newTestKey() (*ecdsa.PrivateKey, string) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	pubKey := append(privKey.PublicKey.X.Bytes(), privKey.PublicKey.Y.Bytes()...)
+
+	return privKey, blockchain.PubKeyHashToAddress(blockchain.HashPubKey(pubKey))
+}
+
+// signTestTx 用测试私钥对花费 prevs 输出的交易签名
+func signTestTx(tx *blockchain.Transaction, prevs ...*blockchain.Transaction) {
+	prevTXs := make(map[string]blockchain.Transaction)
+	for _, prev := range prevs {
+		prevTXs[hex.EncodeToString(prev.ID)] = *prev
+	}
+	tx.Sign(*testKey, prevTXs)
+}
+
+// setupTestBlockchain 创建测试用区块链，测试结束后自动清理
+func setupTestBlockchain(t *testing.T) *blockchain.Blockchain {
+	dbFile := "blockchain_" + testNodeID + ".db"
+	os.Remove(dbFile)
+
+	bc := blockchain.NewBlockchain(testAddress, testNodeID)
+	blockchain.UTXOSet{Blockchain: bc}.Reindex()
+
+	t.Cleanup(func() {
+		bc.DB.Close()
+		os.Remove(dbFile)
+	})
+
+	return bc
+}
+
+// newTestBlockSyncer 创建测试用区块同步器
+func newTestBlockSyncer(t *testing.T) (*BlockSyncer, *blockchain.Blockchain) {
+	bc := setupTestBlockchain(t)
+	return NewBlockSyncer(bc, nil, message.NewHandler(1), 1, 0), bc
+}
+
+// mineTestBlock 在当前链尖之上挖出一个包含给定交易的区块
+func mineTestBlock(bc *blockchain.Blockchain, data string, txs ...*blockchain.Transaction) *blockchain.Block {
+	tip, _ := bc.GetBlock(bc.GetBlockHashes()[0])
+	cbTx := blockchain.NewCoinbaseTX(testAddress, data)
+	txs = append([]*blockchain.Transaction{cbTx}, txs...)
+	return blockchain.NewBlock(txs, tip.Hash, tip.Height+1)
+}
+
+// TestBlockSyncer_ValidateBlock 测试区块同步器的区块验证
+func TestBlockSyncer_ValidateBlock(t *testing.T) {
+	syncer, bc := newTestBlockSyncer(t)
+
+	t.Run("ValidBlock", func(t *testing.T) {
+		block := mineTestBlock(bc, "valid")
+		if err := syncer.validateBlock(block); err != nil {
+			t.Errorf("Expected valid block to pass, got: %v", err)
+		}
+	})
+
+	t.Run("InvalidNonce", func(t *testing.T) {
+		block := mineTestBlock(bc, "bad nonce")
+
+		// 寻找一个不满足目标值的 nonce
+		for nonce := 0; ; nonce++ {
+			block.Nonce = nonce
+			if !blockchain.NewProofOfWork(block).Validate() {
+				break
+			}
+		}
+
+		err := syncer.validateBlock(block)
+		if err == nil || !strings.Contains(err.Error(), "proof of work") {
+			t.Errorf("Expected proof of work error, got: %v", err)
+		}
+	})
+
+	t.Run("InvalidTransaction", func(t *testing.T) {
+		// 引用一个不存在的交易输出
+		tx := &blockchain.Transaction{
+			Vin:  []blockchain.TXInput{{Txid: []byte("missing-transaction"), Vout: 0}},
+			Vout: []blockchain.TXOutput{newTestOutput(10)},
+		}
+		tx.ID = tx.Hash()
+
+		block := mineTestBlock(bc, "bad tx", tx)
+		err := syncer.validateBlock(block)
+		if err == nil || !strings.Contains(err.Error(), "invalid transaction") {
+			t.Errorf("Expected invalid transaction error, got: %v", err)
+		}
+	})
+
+	t.Run("TwoCoinbases", func(t *testing.T) {
+		block := mineTestBlock(bc, "first coinbase", blockchain.NewCoinbaseTX(testAddress, "second coinbase"))
+
+		err := syncer.validateBlock(block)
+		if err == nil || !strings.Contains(err.Error(), "coinbase transaction") {
+			t.Errorf("Expected duplicate coinbase error, got: %v", err)
+		}
+	})
+
+	t.Run("CoinbaseNotFirst", func(t *testing.T) {
+		tip, _ := bc.GetBlock(bc.GetBlockHashes()[0])
+		spend := &blockchain.Transaction{
+			Vin:  []blockchain.TXInput{{Txid: tip.Transactions[0].ID, Vout: 0, ScriptSig: testAddress}},
+			Vout: []blockchain.TXOutput{newTestOutput(tip.Transactions[0].Vout[0].Value)},
+		}
+		spend.ID = spend.Hash()
+		cbTx := blockchain.NewCoinbaseTX(testAddress, "late coinbase")
+		block := blockchain.NewBlock([]*blockchain.Transaction{spend, cbTx}, tip.Hash, tip.Height+1)
+
+		err := syncer.validateBlock(block)
+		if err == nil || !strings.Contains(err.Error(), "not a coinbase") {
+			t.Errorf("Expected misplaced coinbase error, got: %v", err)
+		}
+	})
+
+	t.Run("HeightGap", func(t *testing.T) {
+		tip, _ := bc.GetBlock(bc.GetBlockHashes()[0])
+		cbTx := blockchain.NewCoinbaseTX(testAddress, "gap")
+		block := blockchain.NewBlock([]*blockchain.Transaction{cbTx}, tip.Hash, tip.Height+5)
+
+		err := syncer.validateBlock(block)
+		if err == nil || !strings.Contains(err.Error(), "height") {
+			t.Errorf("Expected height continuity error, got: %v", err)
+		}
+	})
+
+	t.Run("FutureTimestamp", func(t *testing.T) {
+		block := mineTestBlock(bc, "future")
+		block.Timestamp = time.Now().Add(3 * time.Hour).Unix()
+		block.Nonce, block.Hash = blockchain.NewProofOfWork(block).Run()
+
+		err := syncer.validateBlock(block)
+		if err == nil || !strings.Contains(err.Error(), "future") {
+			t.Errorf("Expected future timestamp error, got: %v", err)
+		}
+	})
+
+	t.Run("TimestampBeforeMedian", func(t *testing.T) {
+		tip, _ := bc.GetBlock(bc.GetBlockHashes()[0])
+		block := mineTestBlock(bc, "past")
+		block.Timestamp = tip.Timestamp - 3600
+		block.Nonce, block.Hash = blockchain.NewProofOfWork(block).Run()
+
+		err := syncer.validateBlock(block)
+		if err == nil || !strings.Contains(err.Error(), "median time past") {
+			t.Errorf("Expected median time past error, got: %v", err)
+		}
+	})
+}
+
+// TestBlockSyncer_OnBlockConnected 测试区块接入回调
+func TestBlockSyncer_OnBlockConnected(t *testing.T) {
+	syncer, bc := newTestBlockSyncer(t)
+
+	var connected []*blockchain.Block
+	syncer.OnBlockConnected(func(block *blockchain.Block) {
+		connected = append(connected, block)
+	})
+
+	block := mineTestBlock(bc, "connected")
+	msg := message.NewMessage("block", block.Serialize(), "peer:3000")
+	if err := syncer.handleBlockMessage(msg); err != nil {
+		t.Fatalf("Failed to handle block message: %v", err)
+	}
+
+	if len(connected) != 1 {
+		t.Fatalf("Expected callback to fire once, got %d", len(connected))
+	}
+	if !bytes.Equal(connected[0].Hash, block.Hash) {
+		t.Errorf("Expected connected block %x, got %x", block.Hash, connected[0].Hash)
+	}
+
+	// 验证失败的区块不应触发回调
+	bad := mineTestBlock(bc, "rejected")
+	bad.Height += 3
+	msg = message.NewMessage("block", bad.Serialize(), "peer:3000")
+	if err := syncer.handleBlockMessage(msg); err == nil {
+		t.Error("Expected invalid block to be rejected")
+	}
+	if len(connected) != 1 {
+		t.Errorf("Expected no callback for rejected block, got %d calls", len(connected))
+	}
+}
+
+// TestBlockSyncer_RetryLimit 测试重试次数耗尽后区块进入失败集合
+func TestBlockSyncer_RetryLimit(t *testing.T) {
+	bc := setupTestBlockchain(t)
+	// 消息处理器未启动，提交 getdata 必然失败
+	syncer := NewBlockSyncer(bc, nil, message.NewHandler(1), 1, 1)
+
+	hash := []byte("missing_block_hash")
+	task := &BlockDownloadTask{Hash: hash, PeerAddr: "peer:3000", CreatedAt: time.Now()}
+
+	syncer.processDownloadTask(task, 0)
+	if len(syncer.GetFailedBlocks()) != 0 {
+		t.Fatal("Block should not be failed before retries are exhausted")
+	}
+
+	select {
+	case retried := <-syncer.downloadQueue:
+		if retried.Retries != 1 {
+			t.Errorf("Expected retry count 1, got %d", retried.Retries)
+		}
+		syncer.processDownloadTask(retried, 0)
+	default:
+		t.Fatal("Expected task to be requeued for retry")
+	}
+
+	if len(syncer.downloadQueue) != 0 {
+		t.Errorf("Expected no further retries, queue size %d", len(syncer.downloadQueue))
+	}
+
+	failed := syncer.GetFailedBlocks()
+	if len(failed) != 1 || !bytes.Equal(failed[0], hash) {
+		t.Fatalf("Expected %x in failed set, got %x", hash, failed)
+	}
+	if syncer.GetStats().FailedBlocks != 1 {
+		t.Errorf("Expected failed block count 1, got %d", syncer.GetStats().FailedBlocks)
+	}
+
+	// 从其他节点重新请求
+	if err := syncer.RetryFailedBlock(hash, "peer:3001"); err != nil {
+		t.Fatalf("Failed to retry block: %v", err)
+	}
+	if len(syncer.GetFailedBlocks()) != 0 {
+		t.Error("Retried block should leave the failed set")
+	}
+	requeued := <-syncer.downloadQueue
+	if requeued.PeerAddr != "peer:3001" || requeued.Retries != 0 {
+		t.Errorf("Unexpected requeued task: peer %s, retries %d", requeued.PeerAddr, requeued.Retries)
+	}
+}
+
+// TestBlockSyncer_OrphanBlocks 测试孤儿区块的接入与淘汰
+func TestBlockSyncer_OrphanBlocks(t *testing.T) {
+	t.Run("PromoteOnParent", func(t *testing.T) {
+		syncer, bc := newTestBlockSyncer(t)
+		height := bc.GetBestHeight()
+
+		parent := mineTestBlock(bc, "parent")
+		child := blockchain.NewBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(testAddress, "child")}, parent.Hash, parent.Height+1)
+
+		if err := syncer.handleBlockMessage(message.NewMessage("block", child.Serialize(), "peer:3000")); err != nil {
+			t.Fatalf("Orphan block should be held, got: %v", err)
+		}
+		if syncer.GetOrphanBlockCount() != 1 {
+			t.Fatalf("Expected 1 orphan block, got %d", syncer.GetOrphanBlockCount())
+		}
+
+		if err := syncer.handleBlockMessage(message.NewMessage("block", parent.Serialize(), "peer:3000")); err != nil {
+			t.Fatalf("Failed to handle parent block: %v", err)
+		}
+
+		if syncer.GetOrphanBlockCount() != 0 {
+			t.Errorf("Expected orphan pool to be empty, got %d", syncer.GetOrphanBlockCount())
+		}
+		if bc.GetBestHeight() != height+2 {
+			t.Errorf("Expected best height %d, got %d", height+2, bc.GetBestHeight())
+		}
+	})
+
+	t.Run("EvictOldest", func(t *testing.T) {
+		syncer, _ := newTestBlockSyncer(t)
+		syncer.SetMaxOrphanBlocks(2)
+
+		var orphans []*blockchain.Block
+		for i := 0; i < 3; i++ {
+			prevHash := []byte(fmt.Sprintf("unknown_parent_%d", i))
+			block := blockchain.NewBlock([]*blockchain.Transaction{blockchain.NewCoinbaseTX(testAddress, fmt.Sprintf("orphan %d", i))}, prevHash, 5)
+			orphans = append(orphans, block)
+
+			if err := syncer.handleBlockMessage(message.NewMessage("block", block.Serialize(), "peer:3000")); err != nil {
+				t.Fatalf("Orphan block should be held, got: %v", err)
+			}
+		}
+
+		if syncer.GetOrphanBlockCount() != 2 {
+			t.Fatalf("Expected orphan pool capped at 2, got %d", syncer.GetOrphanBlockCount())
+		}
+		if _, exists := syncer.orphanBlocks[fmt.Sprintf("%x", orphans[0].Hash)]; exists {
+			t.Error("Oldest orphan block should be evicted")
+		}
+		for _, block := range orphans[1:] {
+			if _, exists := syncer.orphanBlocks[fmt.Sprintf("%x", block.Hash)]; !exists {
+				t.Errorf("Orphan block %x should be kept", block.Hash)
+			}
+		}
+	})
+}
+
+// TestBlockSyncer_LocatorSync 测试只有部分链的节点只下载缺少的后缀区块
+func TestBlockSyncer_LocatorSync(t *testing.T) {
+	remoteBC := setupTestBlockchain(t)
+	for i := 0; i < 2; i++ {
+		if err := remoteBC.AddBlock(mineTestBlock(remoteBC, fmt.Sprintf("shared %d", i))); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+
+	// 本地节点复制远端当前的链，之后远端继续出块
+	localFile := "blockchain_" + testNodeID + "_local.db"
+	os.Remove(localFile)
+	if err := remoteBC.DB.View(func(tx *bbolt.Tx) error { return tx.CopyFile(localFile, 0600) }); err != nil {
+		t.Fatalf("Failed to copy blockchain: %v", err)
+	}
+	localBC := blockchain.NewBlockchain(testAddress, testNodeID+"_local")
+	t.Cleanup(func() {
+		localBC.DB.Close()
+		os.Remove(localFile)
+	})
+
+	for i := 0; i < 3; i++ {
+		if err := remoteBC.AddBlock(mineTestBlock(remoteBC, fmt.Sprintf("suffix %d", i))); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+	suffix := remoteBC.GetRecentBlockHashes(3)
+
+	// 远端回复的 inv 消息交给本地同步器处理
+	remoteHandler := message.NewHandler(1)
+	remote := NewBlockSyncer(remoteBC, nil, remoteHandler, 1, 0)
+	invs := make(chan *message.Message, 1)
+	remoteHandler.RegisterHandler("inv", func(msg *message.Message) error {
+		invs <- msg
+		return nil
+	})
+	if err := remoteHandler.Start(); err != nil {
+		t.Fatalf("Failed to start message handler: %v", err)
+	}
+	defer remoteHandler.Stop()
+
+	local := NewBlockSyncer(localBC, nil, message.NewHandler(1), 1, 0)
+	request, err := local.newGetBlocksMessage("remote:3000")
+	if err != nil {
+		t.Fatalf("Failed to build getblocks request: %v", err)
+	}
+	if err := remote.handleGetBlocksMessage(request); err != nil {
+		t.Fatalf("Failed to handle getblocks: %v", err)
+	}
+
+	var inv *message.Message
+	select {
+	case inv = <-invs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for inv response")
+	}
+	if err := local.handleInvMessage(inv); err != nil {
+		t.Fatalf("Failed to handle inv: %v", err)
+	}
+
+	if len(local.downloadQueue) != len(suffix) {
+		t.Fatalf("Expected %d download tasks, got %d", len(suffix), len(local.downloadQueue))
+	}
+	for i := len(suffix) - 1; i >= 0; i-- {
+		task := <-local.downloadQueue
+		if !bytes.Equal(task.Hash, suffix[i]) {
+			t.Fatalf("Expected download of %x, got %x", suffix[i], task.Hash)
+		}
+
+		block, err := remoteBC.GetBlock(task.Hash)
+		if err != nil {
+			t.Fatalf("Failed to get block: %v", err)
+		}
+		if err := local.handleBlockMessage(message.NewMessage("block", block.Serialize(), task.PeerAddr)); err != nil {
+			t.Fatalf("Failed to connect block: %v", err)
+		}
+	}
+
+	if localBC.GetBestHeight() != remoteBC.GetBestHeight() {
+		t.Errorf("Expected local height %d, got %d", remoteBC.GetBestHeight(), localBC.GetBestHeight())
+	}
+
+	// 再次收到同一库存时不会重复下载
+	if err := local.handleInvMessage(inv); err != nil {
+		t.Fatalf("Failed to handle inv: %v", err)
+	}
+	if len(local.downloadQueue) != 0 {
+		t.Errorf("Expected no download tasks for known blocks, got %d", len(local.downloadQueue))
+	}
+}
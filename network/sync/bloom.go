@@ -0,0 +1,206 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"math"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/message"
+)
+
+const (
+	// maxBloomFilterBytes 布隆过滤器位数组的最大字节数
+	maxBloomFilterBytes = 36000
+	// maxBloomHashFuncs 布隆过滤器哈希函数的最大个数
+	maxBloomHashFuncs = 50
+)
+
+// BloomFilter 轻客户端用来登记感兴趣的地址和输出的布隆过滤器
+type BloomFilter struct {
+	Bits      []byte // 位数组
+	HashFuncs uint32 // 哈希函数个数
+}
+
+// NewBloomFilter 按预计元素个数和误判率创建布隆过滤器
+func NewBloomFilter(elements int, falsePositiveRate float64) *BloomFilter {
+	if elements <= 0 {
+		elements = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.0001
+	}
+
+	// 位数 m = -n*ln(p)/ln(2)^2，哈希函数个数 k = m/n*ln(2)
+	bits := -float64(elements) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)
+	size := int(math.Min(math.Ceil(bits/8), maxBloomFilterBytes))
+	hashFuncs := uint32(math.Max(1, math.Min(float64(size*8)/float64(elements)*math.Ln2, maxBloomHashFuncs)))
+
+	return &BloomFilter{
+		Bits:      make([]byte, size),
+		HashFuncs: hashFuncs,
+	}
+}
+
+// DeserializeBloomFilter 反序列化并检查布隆过滤器
+func DeserializeBloomFilter(data []byte) (*BloomFilter, error) {
+	var filter BloomFilter
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&filter); err != nil {
+		return nil, fmt.Errorf("解码布隆过滤器失败: %v", err)
+	}
+
+	if len(filter.Bits) == 0 || len(filter.Bits) > maxBloomFilterBytes {
+		return nil, fmt.Errorf("布隆过滤器大小无效: %d 字节", len(filter.Bits))
+	}
+	if filter.HashFuncs == 0 || filter.HashFuncs > maxBloomHashFuncs {
+		return nil, fmt.Errorf("布隆过滤器哈希函数个数无效: %d", filter.HashFuncs)
+	}
+
+	return &filter, nil
+}
+
+// Serialize 序列化布隆过滤器
+func (f *BloomFilter) Serialize() []byte {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(f); err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// Add 将数据加入过滤器
+func (f *BloomFilter) Add(data []byte) {
+	for i := uint32(0); i < f.HashFuncs; i++ {
+		bit := f.bitIndex(data, i)
+		f.Bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Contains 判断数据是否可能在过滤器中
+func (f *BloomFilter) Contains(data []byte) bool {
+	for i := uint32(0); i < f.HashFuncs; i++ {
+		bit := f.bitIndex(data, i)
+		if f.Bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddAddress 登记地址，支付给该地址或由该地址花费的交易都会匹配
+func (f *BloomFilter) AddAddress(address string) {
+	f.Add(blockchain.AddressToPubKeyHash(address))
+}
+
+// AddOutpoint 登记输出，花费该输出的交易会匹配
+func (f *BloomFilter) AddOutpoint(txid []byte, vout int) {
+	f.Add(outpointBytes(txid, vout))
+}
+
+// MatchesTransaction 判断交易是否与过滤器匹配：交易ID、输出的公钥哈希、
+// 输入引用的输出或输入公钥的哈希任一命中即匹配
+func (f *BloomFilter) MatchesTransaction(tx *blockchain.Transaction) bool {
+	if f.Contains(tx.ID) {
+		return true
+	}
+
+	for _, out := range tx.Vout {
+		if f.Contains(out.ScriptPubKey) {
+			return true
+		}
+	}
+
+	if tx.IsCoinbase() {
+		return false
+	}
+	for _, vin := range tx.Vin {
+		if f.Contains(outpointBytes(vin.Txid, vin.Vout)) {
+			return true
+		}
+		if len(vin.PubKey) > 0 && f.Contains(blockchain.HashPubKey(vin.PubKey)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bitIndex 计算第 i 个哈希函数对应的位，每个哈希函数以序号为种子独立哈希
+// 小过滤器的位数与双重哈希的步长常有公因数，各哈希函数会落在少数几位上，误判率远高于设计值
+func (f *BloomFilter) bitIndex(data []byte, i uint32) uint32 {
+	seeded := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(seeded, i)
+	copy(seeded[4:], data)
+	sum := sha256.Sum256(seeded)
+
+	return binary.BigEndian.Uint32(sum[0:4]) % uint32(len(f.Bits)*8)
+}
+
+// outpointBytes 返回输出引用的字节表示
+func outpointBytes(txid []byte, vout int) []byte {
+	data := make([]byte, len(txid)+4)
+	copy(data, txid)
+	binary.BigEndian.PutUint32(data[len(txid):], uint32(vout))
+
+	return data
+}
+
+// FilterTransactions 返回内存池中与过滤器匹配的交易，过滤器为 nil 时返回全部交易
+func (ts *TransactionSyncer) FilterTransactions(filter *BloomFilter) []*blockchain.Transaction {
+	ts.mempoolMutex.RLock()
+	defer ts.mempoolMutex.RUnlock()
+
+	transactions := make([]*blockchain.Transaction, 0, len(ts.mempool))
+	for _, tx := range ts.mempool {
+		if filter == nil || filter.MatchesTransaction(tx) {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	return transactions
+}
+
+// handleFilterLoadMessage 处理 filterload 消息，之后只向该节点转发匹配的交易
+func (ts *TransactionSyncer) handleFilterLoadMessage(msg *message.Message) error {
+	filter, err := DeserializeBloomFilter(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("节点 %s 的过滤器无效: %v", msg.TargetAddr, err)
+	}
+
+	ts.filterMutex.Lock()
+	ts.peerFilters[msg.TargetAddr] = filter
+	ts.filterMutex.Unlock()
+
+	log.Printf("节点 %s 加载了布隆过滤器（%d 字节，%d 个哈希函数）", msg.TargetAddr, len(filter.Bits), filter.HashFuncs)
+	return nil
+}
+
+// handleFilterClearMessage 处理 filterclear 消息，恢复向该节点转发全部交易
+func (ts *TransactionSyncer) handleFilterClearMessage(msg *message.Message) error {
+	ts.filterMutex.Lock()
+	delete(ts.peerFilters, msg.TargetAddr)
+	ts.filterMutex.Unlock()
+
+	log.Printf("节点 %s 清除了布隆过滤器", msg.TargetAddr)
+	return nil
+}
+
+// getPeerFilter 返回节点加载的布隆过滤器，未加载时返回 nil
+func (ts *TransactionSyncer) getPeerFilter(peerAddr string) *BloomFilter {
+	ts.filterMutex.RLock()
+	defer ts.filterMutex.RUnlock()
+
+	return ts.peerFilters[peerAddr]
+}
+
+// relaysTo 判断交易是否应转发给节点：未加载过滤器或过滤器匹配
+func (ts *TransactionSyncer) relaysTo(peerAddr string, tx *blockchain.Transaction) bool {
+	filter := ts.getPeerFilter(peerAddr)
+	return filter == nil || filter.MatchesTransaction(tx)
+}
@@ -0,0 +1,154 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+
+	"mini-coin-go/blockchain"
+)
+
+// outpointKey 返回交易输入引用的输出的索引键
+func outpointKey(vin blockchain.TXInput) string {
+	return fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+}
+
+// indexTransactionLocked 将交易花费的输出加入冲突索引，调用方需持有 mempoolMutex
+func (ts *TransactionSyncer) indexTransactionLocked(tx *blockchain.Transaction) {
+	if tx.IsCoinbase() {
+		return
+	}
+
+	for _, vin := range tx.Vin {
+		key := outpointKey(vin)
+		ts.spentOutpoints[key] = append(ts.spentOutpoints[key], string(tx.ID))
+	}
+}
+
+// unindexTransactionLocked 从冲突索引中移除交易，调用方需持有 mempoolMutex
+func (ts *TransactionSyncer) unindexTransactionLocked(tx *blockchain.Transaction) {
+	if tx.IsCoinbase() {
+		return
+	}
+
+	for _, vin := range tx.Vin {
+		key := outpointKey(vin)
+		spenders := ts.spentOutpoints[key][:0]
+		for _, txID := range ts.spentOutpoints[key] {
+			if txID != string(tx.ID) {
+				spenders = append(spenders, txID)
+			}
+		}
+
+		if len(spenders) == 0 {
+			delete(ts.spentOutpoints, key)
+		} else {
+			ts.spentOutpoints[key] = spenders
+		}
+	}
+}
+
+// RebuildIndex 根据当前内存池重建输出冲突索引
+func (ts *TransactionSyncer) RebuildIndex() {
+	ts.mempoolMutex.Lock()
+	defer ts.mempoolMutex.Unlock()
+
+	ts.rebuildIndexLocked()
+}
+
+// rebuildIndexLocked 重建输出冲突索引，调用方需持有 mempoolMutex
+func (ts *TransactionSyncer) rebuildIndexLocked() {
+	ts.spentOutpoints = make(map[string][]string)
+	for _, tx := range ts.mempool {
+		ts.indexTransactionLocked(tx)
+	}
+}
+
+// GetConflicts 返回内存池中与交易花费相同输出的其他交易ID
+func (ts *TransactionSyncer) GetConflicts(tx *blockchain.Transaction) [][]byte {
+	ts.mempoolMutex.RLock()
+	defer ts.mempoolMutex.RUnlock()
+
+	var conflicts [][]byte
+	seen := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		for _, txID := range ts.spentOutpoints[outpointKey(vin)] {
+			if txID == string(tx.ID) || seen[txID] {
+				continue
+			}
+			seen[txID] = true
+			conflicts = append(conflicts, []byte(txID))
+		}
+	}
+
+	return conflicts
+}
+
+// SaveMempool 将内存池交易保存到文件
+func (ts *TransactionSyncer) SaveMempool(path string) error {
+	var serialized [][]byte
+	for _, tx := range ts.GetMempoolTransactions() {
+		serialized = append(serialized, tx.Serialize())
+	}
+
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(serialized); err != nil {
+		return fmt.Errorf("编码内存池失败: %v", err)
+	}
+
+	return os.WriteFile(path, buff.Bytes(), 0644)
+}
+
+// LoadMempool 从文件批量导入内存池交易并重建冲突索引，返回导入的交易数
+func (ts *TransactionSyncer) LoadMempool(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("读取内存池文件失败: %v", err)
+	}
+
+	var serialized [][]byte
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&serialized); err != nil {
+		return 0, fmt.Errorf("解码内存池失败: %v", err)
+	}
+
+	transactions := make([]*blockchain.Transaction, 0, len(serialized))
+	entries := make([]mempoolEntry, 0, len(serialized))
+	for _, txData := range serialized {
+		tx := blockchain.DeserializeTransaction(txData)
+		entry := mempoolEntry{size: len(txData)}
+		prevTXs, _ := ts.resolveInputs(tx)
+		if fee, err := tx.Fee(prevTXs); err == nil {
+			entry.fee = fee
+		}
+		transactions = append(transactions, tx)
+		entries = append(entries, entry)
+	}
+
+	ts.mempoolMutex.Lock()
+	defer ts.mempoolMutex.Unlock()
+
+	loaded := 0
+	for i, tx := range transactions {
+		txID := string(tx.ID)
+		if _, exists := ts.mempool[txID]; exists {
+			continue
+		}
+		if len(ts.mempool) >= ts.maxPoolSize || ts.mempoolBytes+entries[i].size > ts.maxPoolBytes {
+			log.Printf("内存池已满，跳过导入剩余的 %d 个交易", len(transactions)-i)
+			break
+		}
+
+		ts.mempool[txID] = tx
+		ts.mempoolEntries[txID] = entries[i]
+		ts.mempoolBytes += entries[i].size
+		loaded++
+	}
+
+	// 批量导入未逐笔维护索引，导入后统一重建
+	ts.rebuildIndexLocked()
+	log.Printf("从 %s 导入 %d 个内存池交易", path, loaded)
+
+	return loaded, nil
+}
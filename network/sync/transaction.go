@@ -1,8 +1,12 @@
 package sync
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,18 +17,98 @@ import (
 
 // TransactionSyncer 交易同步器
 type TransactionSyncer struct {
-	blockchain   *blockchain.Blockchain
-	connManager  *connection.Manager
-	msgHandler   *message.Handler
-	mempool      map[string]*blockchain.Transaction
-	mempoolMutex sync.RWMutex
-	maxPoolSize  int
-	isRunning    bool
-	stopCh       chan bool
-	mutex        sync.RWMutex
-	stats        *TxSyncStats
+	blockchain      *blockchain.Blockchain
+	connManager     *connection.Manager
+	msgHandler      *message.Handler
+	mempool         map[string]*blockchain.Transaction
+	mempoolEntries  map[string]mempoolEntry // 内存池交易的大小与手续费
+	mempoolBytes    int                     // 内存池交易序列化后的总字节数
+	spentOutpoints  map[string][]string     // 被花费的输出 -> 花费它的内存池交易ID，用于发现冲突
+	mempoolMutex    sync.RWMutex
+	maxPoolSize     int
+	maxPoolBytes    int // 内存池总字节数上限
+	isRunning       bool
+	stopCh          chan bool
+	mutex           sync.RWMutex
+	stats           *TxSyncStats
+	onAccepted      []func(*blockchain.Transaction)      // 交易进入内存池时的回调
+	minRelayFeeRate float64                              // 最低转发手续费率（手续费/字节）
+	orphans         map[string]*blockchain.Transaction   // 输入未知的孤儿交易
+	orphansByParent map[string][]*blockchain.Transaction // 缺失父交易ID -> 等待的孤儿交易
+	orphanOrder     []string                             // 孤儿交易按加入顺序排列，用于淘汰最旧的条目
+	orphanMutex     sync.RWMutex
+	maxOrphans      int
+	peerFilters     map[string]*BloomFilter // 轻客户端节点加载的布隆过滤器
+	filterMutex     sync.RWMutex
+	broadcastSlots  chan struct{}                // 广播协程槽位，容量即最大并发数
+	submitMessage   func(*message.Message) error // 提交广播消息的函数
+	admissions      []AdmissionRecord            // 内存池准入审计日志，旧记录在前
+	maxAdmissions   int                          // 审计日志保留的最大记录数，0 表示关闭
+	admissionMutex  sync.RWMutex
 }
 
+const (
+	// defaultMaxPoolBytes 内存池默认字节数上限
+	defaultMaxPoolBytes = 32 << 20
+	// defaultBroadcastConcurrency 默认同时进行的交易广播数
+	defaultBroadcastConcurrency = 8
+)
+
+// mempoolEntry 内存池交易的附加信息，用于按手续费率淘汰
+type mempoolEntry struct {
+	size int // 序列化后的字节数
+	fee  int // 手续费
+}
+
+// lowerFeeRate 判断 a 的手续费率（手续费/字节）是否低于 b
+func (a mempoolEntry) lowerFeeRate(b mempoolEntry) bool {
+	return a.fee*b.size < b.fee*a.size
+}
+
+// AcceptResult 交易进入内存池的处理结果
+type AcceptResult int
+
+const (
+	TxAccepted        AcceptResult = iota // 已加入内存池
+	TxOrphan                              // 输入未知，已放入孤儿池
+	TxDuplicate                           // 交易已在内存池中
+	TxInvalid                             // 交易未通过验证
+	TxInsufficientFee                     // 手续费或手续费率不满足要求
+	TxConflict                            // 与内存池中的交易花费相同输出
+	TxMempoolFull                         // 内存池交易数已达上限
+)
+
+// String 返回处理结果的名称
+func (r AcceptResult) String() string {
+	switch r {
+	case TxAccepted:
+		return "accepted"
+	case TxOrphan:
+		return "orphan"
+	case TxDuplicate:
+		return "duplicate"
+	case TxInvalid:
+		return "invalid"
+	case TxInsufficientFee:
+		return "insufficient-fee"
+	case TxConflict:
+		return "conflict"
+	case TxMempoolFull:
+		return "mempool-full"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
+}
+
+var (
+	// errTxExists 交易已在内存池中
+	errTxExists = errors.New("交易已存在")
+	// errMempoolFull 内存池交易数已达上限
+	errMempoolFull = errors.New("内存池已满")
+	// errFeeRateTooLow 内存池字节数已满且交易手续费率不足以淘汰现有交易
+	errFeeRateTooLow = errors.New("内存池已满，交易手续费率过低")
+)
+
 // TxSyncStats 交易同步统计信息
 type TxSyncStats struct {
 	TotalTxReceived  int64         // 总接收交易数
@@ -45,18 +129,32 @@ func NewTransactionSyncer(bc *blockchain.Blockchain, connManager *connection.Man
 	}
 
 	syncer := &TransactionSyncer{
-		blockchain:  bc,
-		connManager: connManager,
-		msgHandler:  msgHandler,
-		mempool:     make(map[string]*blockchain.Transaction),
-		maxPoolSize: maxPoolSize,
-		stopCh:      make(chan bool),
-		stats:       &TxSyncStats{},
+		blockchain:      bc,
+		connManager:     connManager,
+		msgHandler:      msgHandler,
+		mempool:         make(map[string]*blockchain.Transaction),
+		mempoolEntries:  make(map[string]mempoolEntry),
+		spentOutpoints:  make(map[string][]string),
+		maxPoolSize:     maxPoolSize,
+		maxPoolBytes:    defaultMaxPoolBytes,
+		stopCh:          make(chan bool),
+		stats:           &TxSyncStats{},
+		orphans:         make(map[string]*blockchain.Transaction),
+		orphansByParent: make(map[string][]*blockchain.Transaction),
+		maxOrphans:      100,
+		peerFilters:     make(map[string]*BloomFilter),
+		broadcastSlots:  make(chan struct{}, defaultBroadcastConcurrency),
 	}
+	syncer.submitMessage = msgHandler.Submit
 
 	// 注册消息处理器
 	syncer.registerHandlers()
 
+	// 使区块链能够识别内存池中的未确认交易
+	if bc != nil {
+		bc.SetMempool(syncer)
+	}
+
 	return syncer
 }
 
@@ -98,6 +196,8 @@ func (ts *TransactionSyncer) Stop() error {
 func (ts *TransactionSyncer) registerHandlers() {
 	ts.msgHandler.RegisterHandler("tx", ts.handleTxMessage)
 	ts.msgHandler.RegisterHandler("mempool", ts.handleMempoolMessage)
+	ts.msgHandler.RegisterHandler("filterload", ts.handleFilterLoadMessage)
+	ts.msgHandler.RegisterHandler("filterclear", ts.handleFilterClearMessage)
 }
 
 // handleTxMessage 处理交易消息
@@ -109,16 +209,14 @@ func (ts *TransactionSyncer) handleTxMessage(msg *message.Message) error {
 	// 反序列化交易
 	tx := blockchain.DeserializeTransaction(msg.Payload)
 
-	// 验证交易
-	if !ts.validateTransaction(tx) {
+	// 验证并添加到内存池
+	result, err := ts.acceptTransaction(tx)
+	if err != nil {
 		ts.updateFailedStats()
-		return fmt.Errorf("交易验证失败: %x", tx.ID)
+		return err
 	}
-
-	// 添加到内存池
-	if err := ts.addToMempool(tx); err != nil {
-		ts.updateFailedStats()
-		return fmt.Errorf("添加到内存池失败: %v", err)
+	if result == TxOrphan {
+		return nil
 	}
 
 	// 广播给其他节点
@@ -127,10 +225,261 @@ func (ts *TransactionSyncer) handleTxMessage(msg *message.Message) error {
 	// 更新统计信息
 	ts.updateProcessedStats(time.Since(start))
 
+	// 提升等待该交易的孤儿交易
+	ts.promoteOrphans(tx.ID)
+
 	log.Printf("成功处理交易: %x", tx.ID)
 	return nil
 }
 
+// acceptTransaction 验证交易并加入内存池，返回处理结果；被拒绝时同时返回原因
+// 启用审计日志时记录每一次尝试
+func (ts *TransactionSyncer) acceptTransaction(tx *blockchain.Transaction) (AcceptResult, error) {
+	result, err := ts.admitTransaction(tx)
+	ts.recordAdmission(tx, result, err)
+
+	return result, err
+}
+
+// admitTransaction 执行 acceptTransaction 的各项检查
+func (ts *TransactionSyncer) admitTransaction(tx *blockchain.Transaction) (AcceptResult, error) {
+	// 检查交易是否已存在
+	if ts.HasTransaction(tx.ID) {
+		return TxDuplicate, fmt.Errorf("交易已在内存池中: %x", tx.ID)
+	}
+
+	prevTXs, missing := ts.resolveInputs(tx)
+	if len(missing) > 0 {
+		ts.addOrphan(tx, missing)
+		return TxOrphan, nil
+	}
+
+	// 验证交易，内存池中的父交易作为可花费的前序交易
+	preceding := make([]*blockchain.Transaction, 0, len(prevTXs))
+	for _, prevTX := range prevTXs {
+		preceding = append(preceding, &prevTX)
+	}
+	if err := ts.blockchain.ValidateTransactionInBlock(tx, preceding); err != nil {
+		return TxInvalid, fmt.Errorf("交易验证失败 %x: %v", tx.ID, err)
+	}
+
+	// 拒绝与内存池中已有交易双花的交易
+	if conflicts := ts.GetConflicts(tx); len(conflicts) > 0 {
+		return TxConflict, fmt.Errorf("交易 %x 与内存池交易 %x 花费相同输出", tx.ID, conflicts[0])
+	}
+
+	// 检查手续费策略
+	if err := ts.checkRelayFee(tx, prevTXs); err != nil {
+		return TxInsufficientFee, err
+	}
+
+	// 添加到内存池
+	if err := ts.addToMempool(tx); err != nil {
+		result := TxInvalid
+		switch {
+		case errors.Is(err, errTxExists):
+			result = TxDuplicate
+		case errors.Is(err, errMempoolFull):
+			result = TxMempoolFull
+		case errors.Is(err, errFeeRateTooLow):
+			result = TxInsufficientFee
+		}
+		return result, fmt.Errorf("添加到内存池失败: %w", err)
+	}
+	ts.notifyTransactionAccepted(tx)
+
+	return TxAccepted, nil
+}
+
+// resolveInputs 查找交易引用的前序交易，优先查找内存池，其次查找区块链
+func (ts *TransactionSyncer) resolveInputs(tx *blockchain.Transaction) (map[string]blockchain.Transaction, [][]byte) {
+	prevTXs := make(map[string]blockchain.Transaction)
+	if tx.IsCoinbase() {
+		return prevTXs, nil
+	}
+
+	var missing [][]byte
+	for _, vin := range tx.Vin {
+		key := hex.EncodeToString(vin.Txid)
+		if _, found := prevTXs[key]; found {
+			continue
+		}
+
+		ts.mempoolMutex.RLock()
+		prevTX, inMempool := ts.mempool[string(vin.Txid)]
+		ts.mempoolMutex.RUnlock()
+		if inMempool {
+			prevTXs[key] = *prevTX
+			continue
+		}
+
+		chainTX, err := ts.blockchain.FindTransaction(vin.Txid)
+		if err != nil {
+			if !containsHash(missing, vin.Txid) {
+				missing = append(missing, vin.Txid)
+			}
+			continue
+		}
+		prevTXs[key] = chainTX
+	}
+
+	return prevTXs, missing
+}
+
+// addOrphan 将输入未知的交易放入孤儿池
+func (ts *TransactionSyncer) addOrphan(tx *blockchain.Transaction, missing [][]byte) {
+	ts.orphanMutex.Lock()
+	defer ts.orphanMutex.Unlock()
+
+	if _, exists := ts.orphans[string(tx.ID)]; exists {
+		return
+	}
+
+	// 孤儿池已满时淘汰最旧的孤儿交易
+	for len(ts.orphans) >= ts.maxOrphans && len(ts.orphanOrder) > 0 {
+		oldest := ts.orphans[ts.orphanOrder[0]]
+		ts.removeOrphan(oldest)
+		log.Printf("孤儿交易池已满，淘汰最旧的交易: %x", oldest.ID)
+	}
+
+	ts.orphans[string(tx.ID)] = tx
+	ts.orphanOrder = append(ts.orphanOrder, string(tx.ID))
+	for _, parentID := range missing {
+		key := hex.EncodeToString(parentID)
+		ts.orphansByParent[key] = append(ts.orphansByParent[key], tx)
+	}
+
+	log.Printf("交易输入未知，加入孤儿交易池: %x", tx.ID)
+}
+
+// removeOrphan 从孤儿池移除交易
+func (ts *TransactionSyncer) removeOrphan(tx *blockchain.Transaction) {
+	delete(ts.orphans, string(tx.ID))
+
+	for i, id := range ts.orphanOrder {
+		if id == string(tx.ID) {
+			ts.orphanOrder = append(ts.orphanOrder[:i], ts.orphanOrder[i+1:]...)
+			break
+		}
+	}
+
+	for _, vin := range tx.Vin {
+		key := hex.EncodeToString(vin.Txid)
+		children := ts.orphansByParent[key]
+		for i, child := range children {
+			if bytes.Equal(child.ID, tx.ID) {
+				children = append(children[:i], children[i+1:]...)
+				break
+			}
+		}
+
+		if len(children) == 0 {
+			delete(ts.orphansByParent, key)
+		} else {
+			ts.orphansByParent[key] = children
+		}
+	}
+}
+
+// promoteOrphans 父交易到达后尝试将等待它的孤儿交易加入内存池
+func (ts *TransactionSyncer) promoteOrphans(parentID []byte) {
+	queue := [][]byte{parentID}
+
+	for len(queue) > 0 {
+		key := hex.EncodeToString(queue[0])
+		queue = queue[1:]
+
+		ts.orphanMutex.Lock()
+		children := ts.orphansByParent[key]
+		for _, child := range children {
+			ts.removeOrphan(child)
+		}
+		ts.orphanMutex.Unlock()
+
+		for _, child := range children {
+			result, err := ts.acceptTransaction(child)
+			if err != nil {
+				log.Printf("孤儿交易提升失败 %x (%s): %v", child.ID, result, err)
+				continue
+			}
+			if result == TxOrphan {
+				continue
+			}
+
+			log.Printf("孤儿交易已提升到内存池: %x", child.ID)
+			ts.broadcastTransaction(child, "")
+			queue = append(queue, child.ID)
+		}
+	}
+}
+
+// HandleBlockConnected 处理新接入的区块，移除已确认交易并提升等待它们的孤儿交易
+func (ts *TransactionSyncer) HandleBlockConnected(block *blockchain.Block) {
+	var txIDs [][]byte
+	for _, tx := range block.Transactions {
+		txIDs = append(txIDs, tx.ID)
+	}
+
+	ts.RemoveTransactionsFromMempool(txIDs)
+
+	for _, txID := range txIDs {
+		ts.promoteOrphans(txID)
+	}
+}
+
+// SetMaxOrphans 设置孤儿交易池容量
+func (ts *TransactionSyncer) SetMaxOrphans(maxOrphans int) {
+	if maxOrphans <= 0 {
+		maxOrphans = 100
+	}
+
+	ts.orphanMutex.Lock()
+	defer ts.orphanMutex.Unlock()
+
+	ts.maxOrphans = maxOrphans
+	for len(ts.orphans) > ts.maxOrphans && len(ts.orphanOrder) > 0 {
+		ts.removeOrphan(ts.orphans[ts.orphanOrder[0]])
+	}
+}
+
+// GetOrphanTxCount 获取孤儿交易数量
+func (ts *TransactionSyncer) GetOrphanTxCount() int {
+	ts.orphanMutex.RLock()
+	defer ts.orphanMutex.RUnlock()
+
+	return len(ts.orphans)
+}
+
+// containsHash 检查哈希列表中是否包含指定哈希
+func containsHash(hashes [][]byte, hash []byte) bool {
+	for _, h := range hashes {
+		if bytes.Equal(h, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnTransactionAccepted 注册交易被内存池接受时的回调
+func (ts *TransactionSyncer) OnTransactionAccepted(callback func(*blockchain.Transaction)) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.onAccepted = append(ts.onAccepted, callback)
+}
+
+// notifyTransactionAccepted 通知所有已注册的回调
+func (ts *TransactionSyncer) notifyTransactionAccepted(tx *blockchain.Transaction) {
+	ts.mutex.RLock()
+	callbacks := make([]func(*blockchain.Transaction), len(ts.onAccepted))
+	copy(callbacks, ts.onAccepted)
+	ts.mutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(tx)
+	}
+}
+
 // handleMempoolMessage 处理内存池消息
 func (ts *TransactionSyncer) handleMempoolMessage(msg *message.Message) error {
 	log.Printf("收到内存池请求从 %s", msg.TargetAddr)
@@ -139,73 +488,203 @@ func (ts *TransactionSyncer) handleMempoolMessage(msg *message.Message) error {
 	return ts.sendMempoolToPeer(msg.TargetAddr)
 }
 
-// validateTransaction 验证交易
-func (ts *TransactionSyncer) validateTransaction(tx *blockchain.Transaction) bool {
-	// 检查交易是否已存在
-	ts.mempoolMutex.RLock()
-	_, exists := ts.mempool[string(tx.ID)]
-	ts.mempoolMutex.RUnlock()
+// SetMinRelayFeeRate 设置最低转发手续费率（手续费/字节），低于该值的交易不会进入内存池
+func (ts *TransactionSyncer) SetMinRelayFeeRate(rate float64) {
+	if rate < 0 {
+		rate = 0
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.minRelayFeeRate = rate
+}
+
+// GetMinRelayFeeRate 获取最低转发手续费率（手续费/字节）
+func (ts *TransactionSyncer) GetMinRelayFeeRate() float64 {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+
+	return ts.minRelayFeeRate
+}
+
+// checkRelayFee 检查交易手续费率是否满足最低转发要求
+func (ts *TransactionSyncer) checkRelayFee(tx *blockchain.Transaction, prevTXs map[string]blockchain.Transaction) error {
+	minRate := ts.GetMinRelayFeeRate()
+	if minRate == 0 {
+		return nil
+	}
+
+	rate, err := tx.FeeRate(prevTXs)
+	if err != nil {
+		return fmt.Errorf("计算交易手续费率失败 %x: %v", tx.ID, err)
+	}
 
-	if exists {
-		return false // 交易已存在
+	if rate < minRate {
+		return fmt.Errorf("交易手续费率过低 %x: %.4f < %.4f（%d 字节）", tx.ID, rate, minRate, tx.SerializedSize())
 	}
 
-	// 使用区块链验证交易
-	return ts.blockchain.VerifyTransaction(tx)
+	return nil
 }
 
 // addToMempool 添加交易到内存池
 func (ts *TransactionSyncer) addToMempool(tx *blockchain.Transaction) error {
+	entry := mempoolEntry{size: tx.SerializedSize()}
+	prevTXs, _ := ts.resolveInputs(tx)
+	if fee, err := tx.Fee(prevTXs); err == nil {
+		entry.fee = fee
+	}
+
 	ts.mempoolMutex.Lock()
 	defer ts.mempoolMutex.Unlock()
 
 	// 检查内存池大小限制
 	if len(ts.mempool) >= ts.maxPoolSize {
-		return fmt.Errorf("内存池已满")
+		return errMempoolFull
 	}
 
 	// 检查交易是否已存在
 	if _, exists := ts.mempool[string(tx.ID)]; exists {
-		return fmt.Errorf("交易已存在")
+		return errTxExists
+	}
+
+	// 超出字节数上限时淘汰手续费率最低的交易
+	if ts.mempoolBytes+entry.size > ts.maxPoolBytes {
+		victims, err := ts.selectEvictionsLocked(entry)
+		if err != nil {
+			return err
+		}
+		for _, txID := range victims {
+			ts.removeFromMempoolLocked(txID)
+			log.Printf("内存池超出字节上限，淘汰交易: %x", txID)
+		}
 	}
 
 	ts.mempool[string(tx.ID)] = tx
+	ts.mempoolEntries[string(tx.ID)] = entry
+	ts.indexTransactionLocked(tx)
+	ts.mempoolBytes += entry.size
 	log.Printf("交易已添加到内存池: %x", tx.ID)
 
 	return nil
 }
 
+// selectEvictionsLocked 按手续费率从低到高选出为新交易腾出空间需要淘汰的交易
+// 被淘汰交易的手续费率必须低于新交易，否则拒绝新交易。调用方需持有 mempoolMutex
+func (ts *TransactionSyncer) selectEvictionsLocked(entry mempoolEntry) ([]string, error) {
+	if entry.size > ts.maxPoolBytes {
+		return nil, fmt.Errorf("交易大小 %d 超过内存池上限 %d", entry.size, ts.maxPoolBytes)
+	}
+
+	candidates := make([]string, 0, len(ts.mempoolEntries))
+	for txID := range ts.mempoolEntries {
+		candidates = append(candidates, txID)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := ts.mempoolEntries[candidates[i]], ts.mempoolEntries[candidates[j]]
+		if a.lowerFeeRate(b) != b.lowerFeeRate(a) {
+			return a.lowerFeeRate(b)
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	var victims []string
+	freed := 0
+	for _, txID := range candidates {
+		if ts.mempoolBytes-freed+entry.size <= ts.maxPoolBytes {
+			break
+		}
+		if !ts.mempoolEntries[txID].lowerFeeRate(entry) {
+			return nil, errFeeRateTooLow
+		}
+		victims = append(victims, txID)
+		freed += ts.mempoolEntries[txID].size
+	}
+
+	return victims, nil
+}
+
+// removeFromMempoolLocked 从内存池移除交易并更新字节数，调用方需持有 mempoolMutex
+func (ts *TransactionSyncer) removeFromMempoolLocked(txID string) {
+	if tx, ok := ts.mempool[txID]; ok {
+		ts.unindexTransactionLocked(tx)
+	}
+	if entry, ok := ts.mempoolEntries[txID]; ok {
+		ts.mempoolBytes -= entry.size
+		delete(ts.mempoolEntries, txID)
+	}
+	delete(ts.mempool, txID)
+}
+
+// SetMaxPoolBytes 设置内存池总字节数上限，小于等于 0 时使用默认值
+func (ts *TransactionSyncer) SetMaxPoolBytes(maxBytes int) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxPoolBytes
+	}
+
+	ts.mempoolMutex.Lock()
+	defer ts.mempoolMutex.Unlock()
+
+	ts.maxPoolBytes = maxBytes
+}
+
+// GetMempoolBytes 返回内存池交易序列化后的总字节数
+func (ts *TransactionSyncer) GetMempoolBytes() int {
+	ts.mempoolMutex.RLock()
+	defer ts.mempoolMutex.RUnlock()
+
+	return ts.mempoolBytes
+}
+
 // broadcastTransaction 广播交易给其他节点
 func (ts *TransactionSyncer) broadcastTransaction(tx *blockchain.Transaction, excludeAddr string) {
 	// 获取活跃的连接地址
 	activeAddrs := ts.connManager.GetActiveAddresses()
+	payload := tx.Serialize()
+
+	ts.mutex.RLock()
+	slots := ts.broadcastSlots
+	ts.mutex.RUnlock()
 
 	for _, addr := range activeAddrs {
 		if addr == excludeAddr {
 			continue // 跳过发送方
 		}
+		if !ts.relaysTo(addr, tx) {
+			continue // 跳过过滤器不匹配的轻客户端
+		}
 
 		// 创建交易消息
-		msg := message.NewMessage("tx", tx.Serialize(), addr)
+		msg := message.NewMessage("tx", payload, addr)
 		msg.Priority = message.PriorityNormal
 
-		// 异步发送
+		// 异步发送，槽位用尽时等待已有的广播完成
+		slots <- struct{}{}
 		go func(targetAddr string, txMsg *message.Message) {
-			if err := ts.msgHandler.Submit(txMsg); err != nil {
+			defer func() { <-slots }()
+			if err := ts.submitMessage(txMsg); err != nil {
 				log.Printf("广播交易失败到 %s: %v", targetAddr, err)
 			}
 		}(addr, msg)
 	}
 }
 
+// SetBroadcastConcurrency 设置同时进行的交易广播数上限，小于等于 0 时使用默认值
+func (ts *TransactionSyncer) SetBroadcastConcurrency(limit int) {
+	if limit <= 0 {
+		limit = defaultBroadcastConcurrency
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.broadcastSlots = make(chan struct{}, limit)
+}
+
 // sendMempoolToPeer 向节点发送内存池交易
 func (ts *TransactionSyncer) sendMempoolToPeer(peerAddr string) error {
-	ts.mempoolMutex.RLock()
-	transactions := make([]*blockchain.Transaction, 0, len(ts.mempool))
-	for _, tx := range ts.mempool {
-		transactions = append(transactions, tx)
-	}
-	ts.mempoolMutex.RUnlock()
+	// 加载了过滤器的节点只接收匹配的交易
+	transactions := ts.FilterTransactions(ts.getPeerFilter(peerAddr))
 
 	// 分批发送交易（每批50个）
 	batchSize := 50
@@ -224,6 +703,34 @@ func (ts *TransactionSyncer) sendMempoolToPeer(peerAddr string) error {
 	return nil
 }
 
+// RebroadcastMempool 向当前所有活跃节点重新发送内存池中的全部交易，返回发送的消息数
+func (ts *TransactionSyncer) RebroadcastMempool() (int, error) {
+	if !ts.IsRunning() {
+		return 0, fmt.Errorf("交易同步器未运行")
+	}
+
+	transactions := ts.GetMempoolTransactions()
+	if len(transactions) == 0 {
+		return 0, nil
+	}
+
+	sent := 0
+	for _, addr := range ts.connManager.GetActiveAddresses() {
+		batch := transactions
+		if filter := ts.getPeerFilter(addr); filter != nil {
+			batch = ts.FilterTransactions(filter)
+		}
+		if err := ts.sendTransactionBatch(batch, addr); err != nil {
+			log.Printf("重新广播交易失败到 %s: %v", addr, err)
+			continue
+		}
+		sent += len(batch)
+	}
+
+	log.Printf("重新广播 %d 个内存池交易", len(transactions))
+	return sent, nil
+}
+
 // sendTransactionBatch 发送交易批次
 func (ts *TransactionSyncer) sendTransactionBatch(transactions []*blockchain.Transaction, peerAddr string) error {
 	for _, tx := range transactions {
@@ -238,6 +745,15 @@ func (ts *TransactionSyncer) sendTransactionBatch(transactions []*blockchain.Tra
 	return nil
 }
 
+// HasTransaction 检查交易是否在内存池中
+func (ts *TransactionSyncer) HasTransaction(txID []byte) bool {
+	ts.mempoolMutex.RLock()
+	defer ts.mempoolMutex.RUnlock()
+
+	_, exists := ts.mempool[string(txID)]
+	return exists
+}
+
 // GetMempool 获取内存池交易
 func (ts *TransactionSyncer) GetMempool() map[string]*blockchain.Transaction {
 	ts.mempoolMutex.RLock()
@@ -264,12 +780,45 @@ func (ts *TransactionSyncer) GetMempoolTransactions() []*blockchain.Transaction
 	return transactions
 }
 
+// GetPrioritizedTransactions 按优先级返回内存池交易：手续费率（手续费/字节）高者优先，手续费率相同时币龄高者优先
+func (ts *TransactionSyncer) GetPrioritizedTransactions() []*blockchain.Transaction {
+	transactions := ts.GetMempoolTransactions()
+
+	utxoSet := &blockchain.UTXOSet{Blockchain: ts.blockchain}
+	currentHeight := ts.blockchain.GetBestHeight()
+
+	entries := make(map[*blockchain.Transaction]mempoolEntry, len(transactions))
+	coinAges := make(map[*blockchain.Transaction]int, len(transactions))
+	for _, tx := range transactions {
+		entry := mempoolEntry{size: tx.SerializedSize()}
+		prevTXs, _ := ts.resolveInputs(tx)
+		if fee, err := tx.Fee(prevTXs); err == nil {
+			entry.fee = fee
+		}
+		entries[tx] = entry
+		coinAges[tx] = tx.CoinAge(utxoSet, currentHeight)
+	}
+
+	sort.SliceStable(transactions, func(i, j int) bool {
+		a, b := transactions[i], transactions[j]
+		if entries[a].lowerFeeRate(entries[b]) != entries[b].lowerFeeRate(entries[a]) {
+			return entries[b].lowerFeeRate(entries[a])
+		}
+		if coinAges[a] != coinAges[b] {
+			return coinAges[a] > coinAges[b]
+		}
+		return bytes.Compare(a.ID, b.ID) < 0
+	})
+
+	return transactions
+}
+
 // RemoveTransactionFromMempool 从内存池移除交易
 func (ts *TransactionSyncer) RemoveTransactionFromMempool(txID []byte) {
 	ts.mempoolMutex.Lock()
 	defer ts.mempoolMutex.Unlock()
 
-	delete(ts.mempool, string(txID))
+	ts.removeFromMempoolLocked(string(txID))
 }
 
 // RemoveTransactionsFromMempool 从内存池移除多个交易
@@ -278,7 +827,7 @@ func (ts *TransactionSyncer) RemoveTransactionsFromMempool(txIDs [][]byte) {
 	defer ts.mempoolMutex.Unlock()
 
 	for _, txID := range txIDs {
-		delete(ts.mempool, string(txID))
+		ts.removeFromMempoolLocked(string(txID))
 	}
 }
 
@@ -317,7 +866,7 @@ func (ts *TransactionSyncer) performCleanup() {
 	}
 
 	for _, txID := range toRemove {
-		delete(ts.mempool, txID)
+		ts.removeFromMempoolLocked(txID)
 	}
 
 	if len(toRemove) > 0 {
@@ -385,10 +934,19 @@ func (ts *TransactionSyncer) GetSyncInfo() map[string]interface{} {
 		successRate = float64(stats.TotalTxProcessed) / float64(stats.TotalTxReceived) * 100
 	}
 
+	ts.filterMutex.RLock()
+	filteredPeers := len(ts.peerFilters)
+	ts.filterMutex.RUnlock()
+
 	return map[string]interface{}{
 		"is_running":            ts.isRunning,
 		"mempool_size":          stats.MempoolSize,
 		"max_pool_size":         ts.maxPoolSize,
+		"mempool_bytes":         ts.GetMempoolBytes(),
+		"max_pool_bytes":        ts.maxPoolBytes,
+		"min_relay_fee_rate":    ts.GetMinRelayFeeRate(),
+		"orphan_count":          ts.GetOrphanTxCount(),
+		"filtered_peers":        filteredPeers,
 		"total_received":        stats.TotalTxReceived,
 		"total_processed":       stats.TotalTxProcessed,
 		"total_failed":          stats.TotalTxFailed,
@@ -406,26 +964,23 @@ func (ts *TransactionSyncer) IsRunning() bool {
 	return ts.isRunning
 }
 
-// BroadcastTransaction 向网络广播交易
-func (ts *TransactionSyncer) BroadcastTransaction(tx *blockchain.Transaction) error {
+// BroadcastTransaction 将交易加入内存池并向网络广播，返回交易的处理结果
+func (ts *TransactionSyncer) BroadcastTransaction(tx *blockchain.Transaction) (AcceptResult, error) {
 	if !ts.isRunning {
-		return fmt.Errorf("交易同步器未运行")
-	}
-
-	// 验证交易
-	if !ts.validateTransaction(tx) {
-		return fmt.Errorf("交易验证失败")
+		return TxInvalid, fmt.Errorf("交易同步器未运行")
 	}
 
-	// 添加到内存池
-	if err := ts.addToMempool(tx); err != nil {
-		return fmt.Errorf("添加到内存池失败: %v", err)
+	// 验证并添加到内存池
+	result, err := ts.acceptTransaction(tx)
+	if err != nil || result == TxOrphan {
+		return result, err
 	}
 
 	// 广播给所有节点
 	ts.broadcastTransaction(tx, "")
+	ts.promoteOrphans(tx.ID)
 
-	return nil
+	return TxAccepted, nil
 }
 
 // RequestMempool 请求节点的内存池
@@ -0,0 +1,709 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"mini-coin-go/blockchain"
+	"mini-coin-go/network/connection"
+	"mini-coin-go/network/message"
+)
+
+// newTestTransactionSyncer 创建测试用交易同步器
+func newTestTransactionSyncer(t *testing.T) (*TransactionSyncer, *blockchain.Blockchain) {
+	bc := setupTestBlockchain(t)
+	return NewTransactionSyncer(bc, connection.NewManager(nil), message.NewHandler(1), 0), bc
+}
+
+// TestTransactionSyncer_OnTransactionAccepted 测试交易接受回调
+func TestTransactionSyncer_OnTransactionAccepted(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+
+	var accepted []*blockchain.Transaction
+	syncer.OnTransactionAccepted(func(tx *blockchain.Transaction) {
+		accepted = append(accepted, tx)
+	})
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+	tx, err := blockchain.NewUTXOTransaction(testAddress, testAddress, 10, &UTXOSet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	bc.SignTransaction(tx, *testKey)
+
+	msg := message.NewMessage("tx", tx.Serialize(), "peer:3000")
+	if err := syncer.handleTxMessage(msg); err != nil {
+		t.Fatalf("Failed to handle tx message: %v", err)
+	}
+
+	if len(accepted) != 1 {
+		t.Fatalf("Expected callback to fire once, got %d", len(accepted))
+	}
+	if !bytes.Equal(accepted[0].ID, tx.ID) {
+		t.Errorf("Expected accepted transaction %x, got %x", tx.ID, accepted[0].ID)
+	}
+
+	// 重复交易不会再次进入内存池，也不应触发回调
+	if err := syncer.handleTxMessage(msg); err == nil {
+		t.Error("Expected duplicate transaction to be rejected")
+	}
+	if len(accepted) != 1 {
+		t.Errorf("Expected no callback for duplicate transaction, got %d calls", len(accepted))
+	}
+}
+
+// newTestSpend 创建花费链尖 coinbase 输出并支付指定手续费的交易
+func newTestSpend(t *testing.T, bc *blockchain.Blockchain, fee int) *blockchain.Transaction {
+	tip, err := bc.GetBlock(bc.GetBlockHashes()[0])
+	if err != nil {
+		t.Fatalf("Failed to get tip block: %v", err)
+	}
+	coinbase := tip.Transactions[0]
+
+	tx := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: coinbase.ID, Vout: 0, ScriptSig: testAddress}},
+		Vout: []blockchain.TXOutput{newTestOutput(coinbase.Vout[0].Value - fee)},
+	}
+	tx.ID = tx.Hash()
+	signTestTx(tx, coinbase)
+
+	return tx
+}
+
+// TestTransactionSyncer_MinRelayFeeRate 测试按字节计算的最低转发手续费率策略
+func TestTransactionSyncer_MinRelayFeeRate(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+	exact := newTestSpend(t, bc, 5)
+	minRate := 5 / float64(exact.SerializedSize())
+	syncer.SetMinRelayFeeRate(minRate)
+
+	if syncer.GetMinRelayFeeRate() != minRate {
+		t.Fatalf("Expected min relay fee rate %f, got %f", minRate, syncer.GetMinRelayFeeRate())
+	}
+
+	low := newTestSpend(t, bc, 4)
+	msg := message.NewMessage("tx", low.Serialize(), "peer:3000")
+	if err := syncer.handleTxMessage(msg); err == nil {
+		t.Error("Expected below-minimum-fee transaction to be rejected")
+	}
+	if syncer.HasTransaction(low.ID) {
+		t.Error("Rejected transaction should not enter the mempool")
+	}
+
+	// 手续费相同但体积更大的交易手续费率不足
+	bulky := newTestBulkySpend(t, bc, 5, 4)
+	msg = message.NewMessage("tx", bulky.Serialize(), "peer:3000")
+	if err := syncer.handleTxMessage(msg); err == nil {
+		t.Error("Expected larger transaction with the same fee to be rejected")
+	}
+
+	msg = message.NewMessage("tx", exact.Serialize(), "peer:3000")
+	if err := syncer.handleTxMessage(msg); err != nil {
+		t.Fatalf("Expected at-minimum-fee-rate transaction to be accepted, got: %v", err)
+	}
+	if !syncer.HasTransaction(exact.ID) {
+		t.Error("Accepted transaction should be in the mempool")
+	}
+}
+
+// newTestBulkySpend 创建花费链尖 coinbase 输出、拆分为多个输出并支付指定手续费的交易
+func newTestBulkySpend(t *testing.T, bc *blockchain.Blockchain, fee, outputs int) *blockchain.Transaction {
+	tip, err := bc.GetBlock(bc.GetBlockHashes()[0])
+	if err != nil {
+		t.Fatalf("Failed to get tip block: %v", err)
+	}
+	coinbase := tip.Transactions[0]
+
+	value := coinbase.Vout[0].Value - fee
+	tx := &blockchain.Transaction{
+		Vin: []blockchain.TXInput{{Txid: coinbase.ID, Vout: 0, ScriptSig: testAddress}},
+	}
+	for i := 0; i < outputs-1; i++ {
+		tx.Vout = append(tx.Vout, newTestOutput(value/outputs))
+	}
+	tx.Vout = append(tx.Vout, newTestOutput(value-value/outputs*(outputs-1)))
+	tx.ID = tx.Hash()
+	signTestTx(tx, coinbase)
+
+	return tx
+}
+
+// newTestOutput 创建锁定到测试地址的输出
+func newTestOutput(value int) blockchain.TXOutput {
+	out, err := blockchain.NewTXOutput(value, testAddress)
+	if err != nil {
+		panic(err)
+	}
+
+	return *out
+}
+
+// newTestChild 创建花费指定交易第一个输出的交易
+func newTestChild(parent *blockchain.Transaction) *blockchain.Transaction {
+	tx := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: parent.ID, Vout: 0, ScriptSig: testAddress}},
+		Vout: []blockchain.TXOutput{newTestOutput(parent.Vout[0].Value)},
+	}
+	tx.ID = tx.Hash()
+	signTestTx(tx, parent)
+
+	return tx
+}
+
+// TestTransactionSyncer_OrphanPool 测试孤儿交易在父交易到达后被提升
+func TestTransactionSyncer_OrphanPool(t *testing.T) {
+	t.Run("ParentInMempool", func(t *testing.T) {
+		syncer, bc := newTestTransactionSyncer(t)
+
+		parent := newTestSpend(t, bc, 0)
+		child := newTestChild(parent)
+
+		msg := message.NewMessage("tx", child.Serialize(), "peer:3000")
+		if err := syncer.handleTxMessage(msg); err != nil {
+			t.Fatalf("Orphan transaction should be held, got: %v", err)
+		}
+		if syncer.GetOrphanTxCount() != 1 {
+			t.Fatalf("Expected 1 orphan, got %d", syncer.GetOrphanTxCount())
+		}
+		if syncer.HasTransaction(child.ID) {
+			t.Fatal("Orphan should not be in the mempool")
+		}
+
+		msg = message.NewMessage("tx", parent.Serialize(), "peer:3000")
+		if err := syncer.handleTxMessage(msg); err != nil {
+			t.Fatalf("Failed to handle parent transaction: %v", err)
+		}
+
+		if syncer.GetOrphanTxCount() != 0 {
+			t.Errorf("Expected orphan pool to be empty, got %d", syncer.GetOrphanTxCount())
+		}
+		if !syncer.HasTransaction(parent.ID) || !syncer.HasTransaction(child.ID) {
+			t.Error("Parent and promoted child should both be in the mempool")
+		}
+	})
+
+	t.Run("ParentInBlock", func(t *testing.T) {
+		syncer, bc := newTestTransactionSyncer(t)
+
+		parent := newTestSpend(t, bc, 0)
+		child := newTestChild(parent)
+
+		msg := message.NewMessage("tx", child.Serialize(), "peer:3000")
+		if err := syncer.handleTxMessage(msg); err != nil {
+			t.Fatalf("Orphan transaction should be held, got: %v", err)
+		}
+
+		block, err := bc.MineBlock([]*blockchain.Transaction{parent}, "")
+		if err != nil {
+			t.Fatalf("Failed to mine block: %v", err)
+		}
+		syncer.HandleBlockConnected(block)
+
+		if syncer.GetOrphanTxCount() != 0 {
+			t.Errorf("Expected orphan pool to be empty, got %d", syncer.GetOrphanTxCount())
+		}
+		if !syncer.HasTransaction(child.ID) {
+			t.Error("Child should be promoted once its parent is mined")
+		}
+	})
+}
+
+// TestTransactionSyncer_OrphanPoolEviction 测试孤儿交易池满时淘汰最旧的交易
+func TestTransactionSyncer_OrphanPoolEviction(t *testing.T) {
+	syncer, _ := newTestTransactionSyncer(t)
+	syncer.SetMaxOrphans(2)
+
+	var orphans []*blockchain.Transaction
+	for i := 0; i < 3; i++ {
+		parent := &blockchain.Transaction{
+			ID:   []byte(fmt.Sprintf("unknown_parent_%d", i)),
+			Vout: []blockchain.TXOutput{newTestOutput(10)},
+		}
+		orphan := newTestChild(parent)
+		orphans = append(orphans, orphan)
+
+		msg := message.NewMessage("tx", orphan.Serialize(), "peer:3000")
+		if err := syncer.handleTxMessage(msg); err != nil {
+			t.Fatalf("Orphan transaction should be held, got: %v", err)
+		}
+	}
+
+	if syncer.GetOrphanTxCount() != 2 {
+		t.Fatalf("Expected orphan pool capped at 2, got %d", syncer.GetOrphanTxCount())
+	}
+	if _, exists := syncer.orphans[string(orphans[0].ID)]; exists {
+		t.Error("Oldest orphan transaction should be evicted")
+	}
+	if len(syncer.orphansByParent) != 2 {
+		t.Errorf("Expected parent index to shrink with eviction, got %d entries", len(syncer.orphansByParent))
+	}
+}
+
+// TestTransactionSyncer_PrioritizedTransactions 测试按手续费率排序，手续费率相同时按币龄排序
+func TestTransactionSyncer_PrioritizedTransactions(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+
+	genesis, err := bc.GetBlock(bc.GetBlockHashes()[0])
+	if err != nil {
+		t.Fatalf("Failed to get genesis block: %v", err)
+	}
+	if err := bc.AddBlock(mineTestBlock(bc, "newer coins")); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+
+	// 两笔交易手续费相同，young 花费刚确认的输出，old 花费创世区块的输出
+	young := newTestSpend(t, bc, 1)
+	old := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: genesis.Transactions[0].ID, Vout: 0, ScriptSig: testAddress}},
+		Vout: []blockchain.TXOutput{newTestOutput(genesis.Transactions[0].Vout[0].Value - 1)},
+	}
+	old.ID = old.Hash()
+	signTestTx(old, genesis.Transactions[0])
+
+	for _, tx := range []*blockchain.Transaction{young, old} {
+		if err := syncer.addToMempool(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	ordered := syncer.GetPrioritizedTransactions()
+	if len(ordered) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(ordered))
+	}
+	if !bytes.Equal(ordered[0].ID, old.ID) {
+		t.Errorf("Expected older-input transaction first, got %x", ordered[0].ID)
+	}
+
+	// 手续费率仍然优先于币龄，手续费更高但体积更大的交易排在后面
+	rich := newTestSpend(t, bc, 5)
+	bulky := newTestBulkySpend(t, bc, 6, 10)
+	for _, tx := range []*blockchain.Transaction{rich, bulky} {
+		if err := syncer.addToMempool(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+	ordered = syncer.GetPrioritizedTransactions()
+	if !bytes.Equal(ordered[0].ID, rich.ID) {
+		t.Errorf("Expected highest-fee-rate transaction first, got %x", ordered[0].ID)
+	}
+	if !bytes.Equal(ordered[1].ID, bulky.ID) {
+		t.Errorf("Expected larger higher-fee transaction second, got %x", ordered[1].ID)
+	}
+}
+
+// TestTransactionSyncer_MaxPoolBytes 测试超出字节上限时淘汰手续费率最低的交易
+func TestTransactionSyncer_MaxPoolBytes(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+
+	mid := newTestSpend(t, bc, 3)
+	low := newTestSpend(t, bc, 1)
+	high := newTestSpend(t, bc, 5)
+	size := len(mid.Serialize())
+	syncer.SetMaxPoolBytes(2*size + size/2)
+
+	for _, tx := range []*blockchain.Transaction{mid, low} {
+		if err := syncer.addToMempool(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+	if syncer.GetMempoolBytes() != len(mid.Serialize())+len(low.Serialize()) {
+		t.Errorf("Expected mempool bytes %d, got %d", len(mid.Serialize())+len(low.Serialize()), syncer.GetMempoolBytes())
+	}
+
+	// 第三笔交易超出上限，手续费率最低的交易被淘汰
+	if err := syncer.addToMempool(high); err != nil {
+		t.Fatalf("Expected higher-fee transaction to be accepted, got: %v", err)
+	}
+	if syncer.HasTransaction(low.ID) {
+		t.Error("Expected lowest-fee-rate transaction to be evicted")
+	}
+	if !syncer.HasTransaction(mid.ID) || !syncer.HasTransaction(high.ID) {
+		t.Error("Expected higher-fee-rate transactions to remain")
+	}
+	if syncer.GetMempoolBytes() > 2*size+size/2 {
+		t.Errorf("Mempool bytes %d exceed the limit", syncer.GetMempoolBytes())
+	}
+
+	// 手续费率不高于现有交易的新交易被拒绝
+	if err := syncer.addToMempool(newTestSpend(t, bc, 0)); err == nil {
+		t.Error("Expected zero-fee transaction to be rejected when the mempool is full")
+	}
+
+	syncer.RemoveTransactionFromMempool(mid.ID)
+	if syncer.GetMempoolBytes() != len(high.Serialize()) {
+		t.Errorf("Expected mempool bytes %d after removal, got %d", len(high.Serialize()), syncer.GetMempoolBytes())
+	}
+}
+
+// startTestListener 启动一个接受并保持连接的测试节点
+func startTestListener(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start listener: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// TestTransactionSyncer_RebroadcastMempool 测试节点重新加入后能收到内存池中的每笔交易
+func TestTransactionSyncer_RebroadcastMempool(t *testing.T) {
+	bc := setupTestBlockchain(t)
+	connManager := connection.NewManager(nil)
+	msgHandler := message.NewHandler(1)
+	syncer := NewTransactionSyncer(bc, connManager, msgHandler, 0)
+
+	// 替换 tx 处理函数，记录发往各节点的交易
+	sent := make(chan *message.Message, 16)
+	msgHandler.RegisterHandler("tx", func(msg *message.Message) error {
+		sent <- msg
+		return nil
+	})
+
+	for _, component := range []interface{ Start() error }{connManager, msgHandler, syncer} {
+		if err := component.Start(); err != nil {
+			t.Fatalf("Failed to start component: %v", err)
+		}
+	}
+	defer connManager.Stop()
+	defer msgHandler.Stop()
+	defer syncer.Stop()
+
+	txs := []*blockchain.Transaction{newTestSpend(t, bc, 1), newTestSpend(t, bc, 2)}
+	for _, tx := range txs {
+		if err := syncer.addToMempool(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	addPeer := func(addr string) {
+		conn, err := connManager.GetConnection(context.Background(), addr)
+		if err != nil {
+			t.Fatalf("Failed to connect to %s: %v", addr, err)
+		}
+		connManager.ReturnConnection(conn)
+	}
+
+	// 清空原有节点后重新加入一个节点
+	oldPeer := startTestListener(t)
+	addPeer(oldPeer)
+	if err := connManager.RemovePool(oldPeer); err != nil {
+		t.Fatalf("Failed to remove pool: %v", err)
+	}
+	newPeer := startTestListener(t)
+	addPeer(newPeer)
+
+	count, err := syncer.RebroadcastMempool()
+	if err != nil {
+		t.Fatalf("RebroadcastMempool failed: %v", err)
+	}
+	if count != len(txs) {
+		t.Fatalf("Expected %d messages, got %d", len(txs), count)
+	}
+
+	received := make(map[string]bool)
+	for range txs {
+		select {
+		case msg := <-sent:
+			if msg.TargetAddr != newPeer {
+				t.Errorf("Expected transaction sent to %s, got %s", newPeer, msg.TargetAddr)
+			}
+			received[string(blockchain.DeserializeTransaction(msg.Payload).ID)] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for rebroadcast")
+		}
+	}
+	for _, tx := range txs {
+		if !received[string(tx.ID)] {
+			t.Errorf("Transaction %x was not rebroadcast", tx.ID)
+		}
+	}
+}
+
+// TestTransactionSyncer_LoadMempoolRebuildsIndex 测试从文件导入内存池后冲突索引能正确标记冲突交易
+func TestTransactionSyncer_LoadMempoolRebuildsIndex(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+
+	first := newTestSpend(t, bc, 1)
+	second := newTestSpend(t, bc, 2) // 与 first 花费同一输出
+	child := newTestChild(first)
+	for _, tx := range []*blockchain.Transaction{first, second, child} {
+		if err := syncer.addToMempool(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "mempool.dat")
+	if err := syncer.SaveMempool(path); err != nil {
+		t.Fatalf("Failed to save mempool: %v", err)
+	}
+
+	loaded := NewTransactionSyncer(bc, connection.NewManager(nil), message.NewHandler(1), 0)
+	count, err := loaded.LoadMempool(path)
+	if err != nil {
+		t.Fatalf("Failed to load mempool: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected 3 loaded transactions, got %d", count)
+	}
+
+	conflicts := loaded.GetConflicts(first)
+	if len(conflicts) != 1 || !bytes.Equal(conflicts[0], second.ID) {
+		t.Errorf("Expected %x to conflict with %x, got %x", first.ID, second.ID, conflicts)
+	}
+	if conflicts := loaded.GetConflicts(child); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts for child transaction, got %x", conflicts)
+	}
+
+	// 移除冲突交易后索引随之更新
+	loaded.RemoveTransactionFromMempool(second.ID)
+	if conflicts := loaded.GetConflicts(first); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts after removal, got %x", conflicts)
+	}
+}
+
+// TestTransactionSyncer_BloomFilter 测试加载过滤器的轻客户端只收到匹配的交易
+func TestTransactionSyncer_BloomFilter(t *testing.T) {
+	bc := setupTestBlockchain(t)
+	msgHandler := message.NewHandler(1)
+	syncer := NewTransactionSyncer(bc, connection.NewManager(nil), msgHandler, 0)
+
+	sent := make(chan *message.Message, 16)
+	msgHandler.RegisterHandler("tx", func(msg *message.Message) error {
+		sent <- msg
+		return nil
+	})
+	if err := msgHandler.Start(); err != nil {
+		t.Fatalf("Failed to start message handler: %v", err)
+	}
+	defer msgHandler.Stop()
+
+	other := blockchain.PubKeyHashToAddress(blockchain.HashPubKey([]byte("bloom_other")))
+	otherOutput, err := blockchain.NewTXOutput(10, other)
+	if err != nil {
+		t.Fatalf("Failed to create output: %v", err)
+	}
+
+	matching := newTestSpend(t, bc, 1)
+	unrelated := &blockchain.Transaction{
+		Vin:  []blockchain.TXInput{{Txid: []byte("unrelated"), Vout: 0}},
+		Vout: []blockchain.TXOutput{*otherOutput},
+	}
+	unrelated.ID = unrelated.Hash()
+	for _, tx := range []*blockchain.Transaction{matching, unrelated} {
+		if err := syncer.addToMempool(tx); err != nil {
+			t.Fatalf("Failed to add transaction: %v", err)
+		}
+	}
+
+	filter := NewBloomFilter(1, 0.0001)
+	filter.AddAddress(testAddress)
+	if filtered := syncer.FilterTransactions(filter); len(filtered) != 1 || !bytes.Equal(filtered[0].ID, matching.ID) {
+		t.Fatalf("Expected only the matching transaction, got %d", len(filtered))
+	}
+
+	// 无效的过滤器被拒绝
+	if err := syncer.handleFilterLoadMessage(message.NewMessage("filterload", []byte("garbage"), "light:3000")); err == nil {
+		t.Error("Expected invalid filter to be rejected")
+	}
+
+	light := "light:3000"
+	if err := syncer.handleFilterLoadMessage(message.NewMessage("filterload", filter.Serialize(), light)); err != nil {
+		t.Fatalf("Failed to load filter: %v", err)
+	}
+	if err := syncer.handleMempoolMessage(message.NewMessage("mempool", nil, light)); err != nil {
+		t.Fatalf("Failed to handle mempool request: %v", err)
+	}
+
+	select {
+	case msg := <-sent:
+		if tx := blockchain.DeserializeTransaction(msg.Payload); !bytes.Equal(tx.ID, matching.ID) {
+			t.Errorf("Expected matching transaction %x, got %x", matching.ID, tx.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for filtered transaction")
+	}
+	select {
+	case msg := <-sent:
+		t.Errorf("Unexpected transaction relayed to light client: %x", blockchain.DeserializeTransaction(msg.Payload).ID)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// 清除过滤器后恢复转发全部交易
+	if err := syncer.handleFilterClearMessage(message.NewMessage("filterclear", nil, light)); err != nil {
+		t.Fatalf("Failed to clear filter: %v", err)
+	}
+	if !syncer.relaysTo(light, unrelated) {
+		t.Error("Expected all transactions to be relayed after filterclear")
+	}
+}
+
+// TestTransactionSyncer_AcceptResult 测试交易被接受或拒绝时返回对应的处理结果
+func TestTransactionSyncer_AcceptResult(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+
+	accepted := newTestSpend(t, bc, 1)
+	orphanParent := &blockchain.Transaction{
+		ID:   []byte("unknown_parent"),
+		Vout: []blockchain.TXOutput{newTestOutput(10)},
+	}
+
+	tests := []struct {
+		name   string
+		tx     *blockchain.Transaction
+		result AcceptResult
+	}{
+		{"Accepted", accepted, TxAccepted},
+		{"Duplicate", accepted, TxDuplicate},
+		{"Conflict", newTestSpend(t, bc, 2), TxConflict},
+		{"Invalid", newTestSpend(t, bc, -1), TxInvalid},
+		{"Orphan", newTestChild(orphanParent), TxOrphan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := syncer.acceptTransaction(tt.tx)
+			if result != tt.result {
+				t.Errorf("Expected result %s, got %s (err: %v)", tt.result, result, err)
+			}
+			if (err == nil) != (tt.result == TxAccepted || tt.result == TxOrphan) {
+				t.Errorf("Unexpected error for result %s: %v", tt.result, err)
+			}
+		})
+	}
+
+	t.Run("InsufficientFee", func(t *testing.T) {
+		syncer, bc := newTestTransactionSyncer(t)
+		tx := newTestSpend(t, bc, 4)
+		syncer.SetMinRelayFeeRate(5 / float64(tx.SerializedSize()))
+
+		result, err := syncer.acceptTransaction(tx)
+		if result != TxInsufficientFee || err == nil {
+			t.Errorf("Expected %s with an error, got %s (err: %v)", TxInsufficientFee, result, err)
+		}
+	})
+}
+
+// TestTransactionSyncer_BroadcastConcurrency 测试向大量节点广播时并发数不超过上限
+func TestTransactionSyncer_BroadcastConcurrency(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+	connManager := syncer.connManager
+	if err := connManager.Start(); err != nil {
+		t.Fatalf("Failed to start connection manager: %v", err)
+	}
+	defer connManager.Stop()
+
+	const peers, limit = 12, 3
+	for i := 0; i < peers; i++ {
+		conn, err := connManager.GetConnection(context.Background(), startTestListener(t))
+		if err != nil {
+			t.Fatalf("Failed to connect to peer: %v", err)
+		}
+		connManager.ReturnConnection(conn)
+	}
+	syncer.SetBroadcastConcurrency(limit)
+
+	var mu sync.Mutex
+	var active, maxActive, sent int
+	done := make(chan struct{}, peers)
+	syncer.submitMessage = func(msg *message.Message) error {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		sent++
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	}
+
+	syncer.broadcastTransaction(newTestSpend(t, bc, 1), "")
+	for i := 0; i < peers; i++ {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for broadcasts, got %d", i)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sent != peers {
+		t.Errorf("Expected %d broadcasts, got %d", peers, sent)
+	}
+	if maxActive > limit {
+		t.Errorf("Expected at most %d concurrent broadcasts, got %d", limit, maxActive)
+	}
+}
+
+// TestTransactionSyncer_AdmissionLog 测试内存池准入审计日志记录每次尝试并限制大小
+func TestTransactionSyncer_AdmissionLog(t *testing.T) {
+	syncer, bc := newTestTransactionSyncer(t)
+
+	// 默认关闭，不记录
+	valid := newTestSpend(t, bc, 1)
+	if _, err := syncer.acceptTransaction(valid); err != nil {
+		t.Fatalf("Failed to accept transaction: %v", err)
+	}
+	if records := syncer.GetRecentAdmissions(0); len(records) != 0 {
+		t.Fatalf("Expected no records while the log is disabled, got %d", len(records))
+	}
+
+	syncer.SetAdmissionLogSize(2)
+	invalid := newTestSpend(t, bc, -1)
+	syncer.acceptTransaction(valid)
+	syncer.acceptTransaction(invalid)
+
+	records := syncer.GetRecentAdmissions(5)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if !bytes.Equal(records[0].TxID, invalid.ID) || records[0].Result != TxInvalid || records[0].Reason == "" {
+		t.Errorf("Unexpected latest record: %+v", records[0])
+	}
+	if !bytes.Equal(records[1].TxID, valid.ID) || records[1].Result != TxDuplicate {
+		t.Errorf("Unexpected earlier record: %+v", records[1])
+	}
+	if records[0].Time.IsZero() {
+		t.Error("Expected record timestamp to be set")
+	}
+
+	// 超出上限时丢弃最旧的记录
+	accepted := NewTransactionSyncer(bc, connection.NewManager(nil), message.NewHandler(1), 0)
+	accepted.SetAdmissionLogSize(2)
+	accepted.acceptTransaction(invalid)
+	accepted.acceptTransaction(valid)
+	accepted.acceptTransaction(valid)
+	records = accepted.GetRecentAdmissions(0)
+	if len(records) != 2 || records[0].Result != TxDuplicate || records[1].Result != TxAccepted {
+		t.Fatalf("Expected oldest record to be dropped, got %+v", records)
+	}
+	if records[1].Reason != "" {
+		t.Errorf("Expected no reason for an accepted transaction, got %q", records[1].Reason)
+	}
+	if latest := accepted.GetRecentAdmissions(1); len(latest) != 1 || latest[0].Result != TxDuplicate {
+		t.Errorf("Expected only the latest record, got %+v", latest)
+	}
+}
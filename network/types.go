@@ -1,15 +1,21 @@
 package network
 
+import "mini-coin-go/network/security"
+
 // Version 消息，用于节点间同步区块链高度
 type Version struct {
 	Version    int
 	BestHeight int
 	AddrFrom   string
+	Services   uint64                // 节点支持的服务标志，见 peer.ServiceNodeNetwork 等
+	Challenge  []byte                // 要求对方证明身份的随机挑战，为空时不要求认证
+	Auth       *security.AuthMessage // 对对方挑战的签名应答
 }
 
 // GetBlocks 消息，用于向其他节点请求区块哈希列表
 type GetBlocks struct {
 	AddrFrom string
+	Locator  [][]byte // 区块定位器，为空时请求全部区块哈希
 }
 
 // Inv 消息，用于告诉其他节点自己拥有的区块或交易信息
@@ -40,5 +46,25 @@ type Tx struct {
 
 // Addr 消息，用于在节点间共享和广播其他节点的地址
 type Addr struct {
-	AddrList []string
+	AddrList  []string
+	AddrFrom  string // 发送方地址，用于验证签名
+	Signature []byte // 启用 addr 认证时发送方对地址列表的签名
+}
+
+// GetAddr 消息，用于向节点请求其已知节点列表，对方在同一连接上以 addr 消息回复
+type GetAddr struct {
+	AddrFrom string // 请求方地址，只用于从回复中排除请求方
+	MaxCount int    // 希望返回的最多地址数，0 表示使用对方配置的上限
+}
+
+// Resend 消息，用于请求节点向已知节点重新广播内存池中的交易
+type Resend struct {
+	AddrFrom string
+}
+
+// NotFound 消息，用于告知请求方本节点没有其请求的区块或交易
+type NotFound struct {
+	AddrFrom string
+	Type     string
+	ID       []byte
 }
@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"mini-coin-go/blockchain"
+)
+
+// signatureScalarLen 签名中 r、s 的固定长度
+const signatureScalarLen = 32
+
+// PrivateKey 根据钱包私钥还原 ECDSA 私钥
+func (w Wallet) PrivateKey() *ecdsa.PrivateKey {
+	curve := elliptic.P256()
+	x, y := curve.ScalarBaseMult(w.PrivKey)
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+		D:         new(big.Int).SetBytes(w.PrivKey),
+	}
+}
+
+// SignMessage 使用钱包私钥对消息签名
+// 签名格式: len(X) | len(Y) | X | Y | r | s，验证方可据此还原公钥并核对地址
+func (w Wallet) SignMessage(message string) ([]byte, error) {
+	privKey := w.PrivateKey()
+	hash := sha256.Sum256([]byte(message))
+
+	r, s, err := ecdsa.Sign(rand.Reader, privKey, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %v", err)
+	}
+
+	x := privKey.PublicKey.X.Bytes()
+	y := privKey.PublicKey.Y.Bytes()
+
+	signature := []byte{byte(len(x)), byte(len(y))}
+	signature = append(signature, x...)
+	signature = append(signature, y...)
+	signature = append(signature, r.FillBytes(make([]byte, signatureScalarLen))...)
+	signature = append(signature, s.FillBytes(make([]byte, signatureScalarLen))...)
+
+	return signature, nil
+}
+
+// VerifyMessage 验证消息签名是否由指定地址的私钥产生
+func VerifyMessage(address, message string, signature []byte) bool {
+	if !blockchain.ValidateAddress(address) || len(signature) < 2 {
+		return false
+	}
+
+	xLen, yLen := int(signature[0]), int(signature[1])
+	if len(signature) != 2+xLen+yLen+2*signatureScalarLen {
+		return false
+	}
+
+	pubKey := signature[2 : 2+xLen+yLen]
+	sig := signature[2+xLen+yLen:]
+
+	// 公钥必须与地址对应
+	pubKeyHash := blockchain.AddressToPubKeyHash(address)
+	if !bytes.Equal(HashPubKey(pubKey), pubKeyHash) {
+		return false
+	}
+
+	curve := elliptic.P256()
+	x := new(big.Int).SetBytes(pubKey[:xLen])
+	y := new(big.Int).SetBytes(pubKey[xLen:])
+	if !curve.IsOnCurve(x, y) {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:signatureScalarLen])
+	s := new(big.Int).SetBytes(sig[signatureScalarLen:])
+	hash := sha256.Sum256([]byte(message))
+
+	return ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, hash[:], r, s)
+}
@@ -95,3 +95,32 @@ func TestHashPubKey(t *testing.T) {
 		t.Error("Different public keys should produce different hashes")
 	}
 }
+
+// TestWallet_SignMessage 测试消息签名与验证
+func TestWallet_SignMessage(t *testing.T) {
+	wallet := NewWallet()
+	address := string(wallet.GetAddress())
+	message := "I control this address"
+
+	signature, err := wallet.SignMessage(message)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+
+	if !VerifyMessage(address, message, signature) {
+		t.Error("Signature should verify for the signing address")
+	}
+
+	if VerifyMessage(address, message+"!", signature) {
+		t.Error("Signature should not verify for a tampered message")
+	}
+
+	other := string(NewWallet().GetAddress())
+	if VerifyMessage(other, message, signature) {
+		t.Error("Signature should not verify for a different address")
+	}
+
+	if VerifyMessage(address, message, signature[:len(signature)-1]) {
+		t.Error("Truncated signature should not verify")
+	}
+}
@@ -8,8 +8,14 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
+
+	"mini-coin-go/blockchain"
 )
 
+// DefaultNodeID is the node ID used when an empty one is given
+const DefaultNodeID = "3000"
+
 // Wallets stores a collection of wallets
 type Wallets struct {
 	Wallets map[string]*Wallet
@@ -36,6 +42,12 @@ func (ws *Wallets) CreateWallet() string {
 	return address
 }
 
+// NewChangeAddress creates a fresh wallet to receive transaction change; the
+// caller must save the wallets so the change stays spendable
+func (ws *Wallets) NewChangeAddress() string {
+	return ws.CreateWallet()
+}
+
 // GetAddresses returns an array of addresses stored in the wallet file
 func (ws *Wallets) GetAddresses() []string {
 	var addresses []string
@@ -52,9 +64,50 @@ func (ws Wallets) GetWallet(address string) Wallet {
 	return *ws.Wallets[address]
 }
 
+// IsAddressUsed reports whether address has received funds on the main chain of bc
+func (ws *Wallets) IsAddressUsed(address string, bc *blockchain.Blockchain) bool {
+	history, err := bc.GetAddressHistory(address)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range history {
+		if entry.Direction == blockchain.HistoryReceived {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetUnusedAddress returns a wallet address that has never received funds,
+// creating a new wallet when every existing address is used. created reports
+// whether a wallet was added, in which case the caller should save the wallets
+func (ws *Wallets) GetUnusedAddress(bc *blockchain.Blockchain) (address string, created bool) {
+	addresses := ws.GetAddresses()
+	sort.Strings(addresses)
+
+	for _, address := range addresses {
+		if !ws.IsAddressUsed(address, bc) {
+			return address, false
+		}
+	}
+
+	return ws.CreateWallet(), true
+}
+
+// WalletFile returns the wallet file name of the node, falling back to DefaultNodeID
+func WalletFile(nodeID string) string {
+	if nodeID == "" {
+		nodeID = DefaultNodeID
+	}
+
+	return fmt.Sprintf("wallet_%s.dat", nodeID)
+}
+
 // LoadFromFile loads wallets from the file
 func (ws *Wallets) LoadFromFile(nodeID string) error {
-	walletFile := fmt.Sprintf("wallet_%s.dat", nodeID)
+	walletFile := WalletFile(nodeID)
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		return err
 	}
@@ -79,7 +132,7 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 // SaveToFile saves wallets to a file
 func (ws Wallets) SaveToFile(nodeID string) {
 	var content bytes.Buffer
-	walletFile := fmt.Sprintf("wallet_%s.dat", nodeID)
+	walletFile := WalletFile(nodeID)
 
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(ws)
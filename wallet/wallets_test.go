@@ -3,6 +3,8 @@ package wallet
 import (
 	"os"
 	"testing"
+
+	"mini-coin-go/blockchain"
 )
 
 const (
@@ -186,3 +188,130 @@ func TestWallets_SaveToFile_LoadFromFile(t *testing.T) {
 		t.Error("All saved addresses should be loaded")
 	}
 }
+
+// TestWallets_PerNodeFiles 测试不同节点的钱包文件互相隔离
+func TestWallets_PerNodeFiles(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	const otherNodeID = "test_node_other"
+	defer os.Remove(WalletFile(otherNodeID))
+
+	if file := WalletFile(testNodeID); file != "wallet_test_node.dat" {
+		t.Errorf("Expected wallet file derived from node ID, got %s", file)
+	}
+	if file := WalletFile(""); file != WalletFile(DefaultNodeID) {
+		t.Errorf("Expected empty node ID to use the default, got %s", file)
+	}
+
+	wallets1, _ := NewWallets(testNodeID)
+	addr1 := wallets1.CreateWallet()
+	wallets1.SaveToFile(testNodeID)
+
+	wallets2, _ := NewWallets(otherNodeID)
+	addr2 := wallets2.CreateWallet()
+	wallets2.SaveToFile(otherNodeID)
+
+	loaded1, err := NewWallets(testNodeID)
+	if err != nil {
+		t.Fatalf("Failed to load wallets: %v", err)
+	}
+	loaded2, err := NewWallets(otherNodeID)
+	if err != nil {
+		t.Fatalf("Failed to load wallets: %v", err)
+	}
+
+	if len(loaded1.Wallets) != 1 || loaded1.Wallets[addr1] == nil {
+		t.Errorf("Expected only %s in %s, got %v", addr1, testNodeID, loaded1.GetAddresses())
+	}
+	if len(loaded2.Wallets) != 1 || loaded2.Wallets[addr2] == nil {
+		t.Errorf("Expected only %s in %s, got %v", addr2, otherNodeID, loaded2.GetAddresses())
+	}
+}
+
+// TestWallets_IsAddressUsed 测试收到过币的地址被标记为已使用，新地址未被标记
+func TestWallets_IsAddressUsed(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	wallets, _ := NewWallets(testNodeID)
+	funded := wallets.CreateWallet()
+
+	bc := blockchain.NewBlockchain(funded, testNodeID)
+	defer func() {
+		bc.DB.Close()
+		os.Remove("blockchain_" + testNodeID + ".db")
+	}()
+
+	if !wallets.IsAddressUsed(funded, bc) {
+		t.Errorf("Expected funded address %s to be used", funded)
+	}
+
+	fresh := wallets.CreateWallet()
+	if wallets.IsAddressUsed(fresh, bc) {
+		t.Errorf("Expected new address %s to be unused", fresh)
+	}
+
+	address, created := wallets.GetUnusedAddress(bc)
+	if address != fresh || created {
+		t.Errorf("Expected existing unused address %s, got %s (created: %v)", fresh, address, created)
+	}
+
+	delete(wallets.Wallets, fresh)
+	address, created = wallets.GetUnusedAddress(bc)
+	if !created || address == funded || wallets.IsAddressUsed(address, bc) {
+		t.Errorf("Expected a newly created unused address, got %s (created: %v)", address, created)
+	}
+}
+
+// TestWallets_NewChangeAddress 测试启用新找零地址时找零发送到另一个钱包地址
+func TestWallets_NewChangeAddress(t *testing.T) {
+	setupTestEnvironment()
+	defer teardownTestEnvironment()
+
+	wallets, _ := NewWallets(testNodeID)
+	from := wallets.CreateWallet()
+	to := wallets.CreateWallet()
+
+	bc := blockchain.NewBlockchain(from, testNodeID)
+	defer func() {
+		bc.DB.Close()
+		os.Remove("blockchain_" + testNodeID + ".db")
+	}()
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+	UTXOSet.Reindex()
+
+	tx, err := blockchain.NewUTXOTransactionWithChange(from, to, 10, &UTXOSet, wallets)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if len(tx.Vout) != 2 {
+		t.Fatalf("Expected payment and change outputs, got %d", len(tx.Vout))
+	}
+
+	change := tx.Vout[1]
+	if change.IsLockedWithKey(blockchain.AddressToPubKeyHash(from)) {
+		t.Error("Expected change to go to a new address, not the sender")
+	}
+	if len(wallets.Wallets) != 3 {
+		t.Fatalf("Expected a change wallet to be created, got %d wallets", len(wallets.Wallets))
+	}
+	owned := false
+	for address := range wallets.Wallets {
+		if address != from && address != to && change.IsLockedWithKey(blockchain.AddressToPubKeyHash(address)) {
+			owned = true
+		}
+	}
+	if !owned {
+		t.Error("Expected change output locked to an address owned by the wallet")
+	}
+
+	// 默认行为不变，找零退回发送方
+	tx, err = blockchain.NewUTXOTransaction(from, to, 10, &UTXOSet)
+	if err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if !tx.Vout[1].IsLockedWithKey(blockchain.AddressToPubKeyHash(from)) {
+		t.Error("Expected default change to return to the sender")
+	}
+}